@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+)
+
+// runPeriodically calls job every interval until ctx is cancelled. An interval of zero or
+// less turns the job off.
+func runPeriodically(ctx context.Context, name string, interval time.Duration, job func() (int64, error)) {
+	if interval <= 0 {
+		slog.Warn("Background job is disabled, its interval is not positive", "job", name, "interval", interval)
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			affected, err := job()
+			if err != nil {
+				slog.Error("Background job failed", "job", name, "error", err)
+				continue
+			}
+			if affected > 0 {
+				slog.Info("Background job completed", "job", name, "affected", affected)
+			}
+		}
+	}
+}
+
+func envString(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Warn("Invalid duration in environment, using default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return d
+}
+
+func envInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Warn("Invalid number in environment, using default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return n
+}
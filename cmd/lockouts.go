@@ -0,0 +1,104 @@
+package main
+
+import (
+	"2/internal/app/service"
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+const lockoutsUsage = "usage: lockouts list [limit] | unlock <email, ip or link:slug>"
+
+// newLoginGuard configures brute-force protection of the login from LOGIN_* variables.
+func newLoginGuard(repos *repositories) *service.LoginGuard {
+	return service.NewLoginGuard(repos.LoginGuard, models.LoginPolicy{
+		Account: models.LoginLimit{
+			FreeAttempts: envInt("LOGIN_ACCOUNT_FREE_ATTEMPTS", 3),
+			LockoutAfter: envInt("LOGIN_ACCOUNT_LOCKOUT_AFTER", 10),
+		},
+		IP: models.LoginLimit{
+			FreeAttempts: envInt("LOGIN_IP_FREE_ATTEMPTS", 20),
+			LockoutAfter: envInt("LOGIN_IP_LOCKOUT_AFTER", 100),
+		},
+		BaseDelay:       envDuration("LOGIN_BACKOFF_BASE", time.Second),
+		MaxDelay:        envDuration("LOGIN_BACKOFF_MAX", 5*time.Minute),
+		LockoutDuration: envDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		ResetAfter:      envDuration("LOGIN_FAILURES_RESET_AFTER", 24*time.Hour),
+	})
+}
+
+// runLockouts implements the `lockouts` subcommand: admins list recent lockouts
+// and lift the lockout of an account, an address or a public link.
+func runLockouts(args []string) {
+	loadEnv()
+
+	if len(args) == 0 {
+		log.Fatal(lockoutsUsage)
+	}
+
+	backend := os.Getenv("STORAGE_BACKEND")
+	if backend == memoryBackend {
+		log.Fatal("the memory backend keeps lockouts inside the server process, restart it instead")
+	}
+
+	repos, err := newRepositories(backend)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer repos.Close()
+	guard := newLoginGuard(repos)
+
+	switch args[0] {
+	case "list":
+		limit := 50
+		if len(args) == 2 {
+			if limit, err = strconv.Atoi(args[1]); err != nil || limit <= 0 {
+				log.Fatalf("invalid limit %q", args[1])
+			}
+		}
+		printLockouts(guard, limit)
+	case "unlock":
+		if len(args) != 2 {
+			log.Fatal(lockoutsUsage)
+		}
+		err = guard.Unlock(args[1])
+		if errors.Is(err, repository.ErrNotFound) {
+			fmt.Printf("%s has no failed logins\n", args[1])
+			return
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("unlocked %s\n", args[1])
+	default:
+		log.Fatal(lockoutsUsage)
+	}
+}
+
+func printLockouts(guard *service.LoginGuard, limit int) {
+	events, err := guard.Lockouts(limit)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	now := time.Now()
+	for _, e := range events {
+		state := "expired"
+		switch {
+		case e.UnlockedAt != nil:
+			state = "unlocked " + e.UnlockedAt.Format(time.RFC3339)
+		case e.LockedUntil.After(now):
+			state = "locked until " + e.LockedUntil.Format(time.RFC3339)
+		}
+		fmt.Printf("%s  %-7s %-32s from %-15s %3d failures  %s\n",
+			e.LockedAt.Format(time.RFC3339), e.Kind, e.Subject, e.IP, e.Failures, state)
+	}
+	if len(events) == 0 {
+		fmt.Println("no lockouts")
+	}
+}
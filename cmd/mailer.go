@@ -0,0 +1,27 @@
+package main
+
+import (
+	"2/internal/infrastructure/mail"
+	"fmt"
+	"os"
+)
+
+// newMailer builds the mailer chosen by MAILER: smtp (SMTP_ADDR, SMTP_USERNAME, SMTP_PASSWORD,
+// MAIL_FROM), file (MAIL_FILE) or log, which only prints messages and is meant for development.
+func newMailer(kind string) (mail.Mailer, error) {
+	switch kind {
+	case "smtp":
+		addr := os.Getenv("SMTP_ADDR")
+		if addr == "" {
+			return nil, fmt.Errorf("SMTP_ADDR is required for the smtp mailer")
+		}
+		return mail.NewSMTPMailer(addr, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"),
+			envString("MAIL_FROM", "no-reply@localhost")), nil
+	case "file":
+		return mail.NewFileMailer(envString("MAIL_FILE", "mail.log")), nil
+	case "log":
+		return mail.LogMailer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown MAILER %q", kind)
+	}
+}
@@ -2,22 +2,23 @@ package main
 
 import (
 	_ "2/docs"
+	"2/internal/app/events"
 	"2/internal/app/service"
-	"2/internal/infrastructure/storage"
+	"2/internal/app/signing"
+	"2/internal/domain/models"
 	"2/internal/interface/http/handlers/httpHandlers"
 	"2/internal/interface/http/middleware"
 	"context"
-	"database/sql"
 	"github.com/swaggo/http-swagger"
 	"log"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
-	//nolint
-	_ "github.com/jackc/pgx/v5/stdlib" // pgx driver for database/sql
+
 	"github.com/joho/godotenv"
 
 	//nolint
@@ -41,53 +42,212 @@ import (
 // @name Authorization
 // @schemes http
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "lockouts" {
+		runLockouts(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "users" {
+		runUsers(os.Args[2:])
+		return
+	}
+
 	Run()
 }
 
-func Run() {
-
+func loadEnv() {
 	if err := godotenv.Load(".env"); err != nil {
 		log.Fatalf("Error loading .env file")
 	}
+}
 
-	dbConnStr := os.Getenv("DB_CONNECTION")
-	if dbConnStr == "" {
-		log.Fatal("Failed to load environment variables. Check BOT_TOKEN and DB_CONNECTION.")
-	}
+func Run() {
 
-	db, err := sql.Open("pgx", dbConnStr)
+	loadEnv()
+
+	repos, err := newRepositories(os.Getenv("STORAGE_BACKEND"))
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer repos.Close()
+
+	autoMigrate(repos)
+
+	if err := repos.configureSearch(os.Getenv("SEARCH_LANGUAGE")); err != nil {
+		log.Fatalf("Failed to configure search language: %s", err)
+	}
 
 	secret := os.Getenv("SECRET")
 	if secret == "" {
 		log.Fatal("Failed to load environment variables. Check BOT_TOKEN and DB_CONNECTION.")
 	}
 
-	UserRepo := storage.NewUserRepository(db)
-	NotesRepo := storage.NewNotesRepository(db)
-	NotesService := service.NewNoteService(*NotesRepo)
-	AuthService := service.NewAuthService(UserRepo, secret)
+	mailer, err := newMailer(envString("MAILER", "log"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// SECRET только шифрует закрытые ключи в базе, токены подписываются ими
+	keys, err := signing.NewKeyring(repos.Keys, secret, signing.Policy{
+		Algorithm: envString("JWT_ALGORITHM", signing.RS256),
+		Rotation:  envDuration("JWT_KEY_ROTATION", 30*24*time.Hour),
+		Grace:     envDuration("JWT_KEY_GRACE", 7*24*time.Hour),
+	})
+	if err != nil {
+		log.Fatalf("Failed to load signing keys: %s", err)
+	}
+	tokens := service.NewTokenSigner(keys)
+
+	passwords, err := newPasswords()
+	if err != nil {
+		log.Fatalf("Failed to configure passwords: %s", err)
+	}
+
+	// события живут в памяти процесса: клиент, пропустивший больше EVENT_LOG_SIZE, загружает заметки заново
+	EventBus := events.NewBus(envInt("EVENT_LOG_SIZE", 1000))
+	NotesService := service.NewNoteService(repos.Notes, repos.Notebooks, repos.Shares, EventBus)
+	LoginGuard := newLoginGuard(repos)
+	AuthService := service.NewAuthService(repos.Users, repos.Sessions, repos.TwoFactor, tokens, passwords, LoginGuard, models.TokenPolicy{
+		AccessTTL:  envDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTTL: envDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+	})
+	AuthService.RequireVerifiedEmail = os.Getenv("REQUIRE_EMAIL_VERIFICATION") == "true"
+	AccountService := service.NewAccountService(repos.Users, repos.Tokens, repos.Sessions, tokens, passwords, mailer, models.AccountPolicy{
+		AppURL:           envString("APP_URL", "http://localhost:8080"),
+		PasswordResetTTL: envDuration("PASSWORD_RESET_TTL", time.Hour),
+		VerificationTTL:  envDuration("EMAIL_VERIFICATION_TTL", 48*time.Hour),
+	})
+	providers, err := newOIDCProviders()
+	if err != nil {
+		log.Fatal(err)
+	}
+	OIDCService := service.NewOIDCService(repos.Identities, repos.Users, passwords, AuthService, providers...)
+	UserService := service.NewUserService(repos.Users, repos.Sessions, passwords, AccountService, LoginGuard)
+	APITokenService := service.NewAPITokenService(repos.APITokens)
+	ShareService := service.NewShareService(repos.Shares, repos.Notes, repos.Users)
+	PublicLinkService := service.NewPublicLinkService(repos.Links, repos.Notes, repos.Shares, repos.Users, passwords, LoginGuard,
+		strings.TrimRight(envString("API_URL", "http://localhost:8080"), "/"))
+	TagService := service.NewTagService(repos.Tags)
+	NotebookService := service.NewNotebookService(repos.Notebooks, repos.Notes, repos.Shares, EventBus)
+	SearchService := service.NewSearchService(repos.Search)
+	TwoFactorService := service.NewTwoFactorService(repos.TwoFactor, repos.Users, passwords, envString("TOTP_ISSUER", "Notes"))
+	RevisionService := service.NewRevisionService(repos.Revisions, repos.Notes, repos.Shares, EventBus, models.RevisionPolicy{
+		KeepLast: envInt("REVISION_KEEP_LAST", 100),
+		MaxAge:   envDuration("REVISION_MAX_AGE", 0),
+	})
+	AdminService := service.NewAdminService(repos.Users, repos.Notes, repos.Sessions, LoginGuard)
+	CollabService := service.NewCollabService(repos.Notes, repos.Shares, repos.Users, EventBus)
+	TrashService := service.NewTrashService(repos.Notes, repos.Shares, EventBus, envDuration("TRASH_RETENTION", 30*24*time.Hour))
+	SyncService := service.NewSyncService(NotesService, repos.Notes, envDuration("SYNC_TOMBSTONE_RETENTION", 90*24*time.Hour))
 
-	AuthHandler := httpHandlers.NewAuthHandler(AuthService)
-	NotesHandler := httpHandlers.NewNoteHandler(NotesService)
+	AuthHandler := httpHandlers.NewAuthHandler(AuthService, AccountService)
+	AccountHandler := httpHandlers.NewAccountHandler(AccountService)
+	OIDCHandler := httpHandlers.NewOIDCHandler(OIDCService)
+	UserHandler := httpHandlers.NewUserHandler(UserService)
+	APITokenHandler := httpHandlers.NewAPITokenHandler(APITokenService)
+	JWKSHandler := httpHandlers.NewJWKSHandler(keys)
+	NotesHandler := httpHandlers.NewNoteHandler(NotesService, os.Getenv("REQUIRE_IF_MATCH") == "true")
+	ShareHandler := httpHandlers.NewShareHandler(ShareService)
+	PublicLinkHandler := httpHandlers.NewPublicLinkHandler(PublicLinkService)
+	TagHandler := httpHandlers.NewTagHandler(TagService)
+	NotebookHandler := httpHandlers.NewNotebookHandler(NotebookService)
+	SearchHandler := httpHandlers.NewSearchHandler(SearchService)
+	TwoFactorHandler := httpHandlers.NewTwoFactorHandler(TwoFactorService)
+	RevisionHandler := httpHandlers.NewRevisionHandler(RevisionService)
+	TrashHandler := httpHandlers.NewTrashHandler(TrashService)
+	AdminHandler := httpHandlers.NewAdminHandler(AdminService)
+	EventHandler := httpHandlers.NewEventHandler(EventBus, AuthService, envDuration("EVENT_HEARTBEAT", 30*time.Second))
+	CollabHandler := httpHandlers.NewCollabHandler(CollabService, AuthService, envDuration("COLLAB_HEARTBEAT", 30*time.Second))
+	SyncHandler := httpHandlers.NewSyncHandler(SyncService)
 
-	mux := http.NewServeMux()
+	// маршрут закрыт, пока не объявлен через Public: тогда AuthMiddleware пропускает его без токена
+	mux := middleware.NewRoutes()
 
-	mux.Handle("/swagger/", httpSwagger.Handler(
+	mux.PublicHandler("/swagger/", httpSwagger.Handler(
 		httpSwagger.URL("/swagger/doc.json"), // URL к JSON документации
 	))
 
-	mux.HandleFunc("POST  /user/login", AuthHandler.Login)
-	mux.HandleFunc("POST  /user/register", AuthHandler.Register)
-	mux.HandleFunc("GET /notes", NotesHandler.GetNotes)
-	mux.HandleFunc("GET /notes/{id}", NotesHandler.GetNoteHandler)
-	mux.HandleFunc("POST /notes", NotesHandler.CreateNote)
-	mux.HandleFunc("PUT /notes/{id}", NotesHandler.UpdateNote)
-	mux.HandleFunc("DELETE /notes/{id}", NotesHandler.DeleteNote)
+	mux.Public("GET /.well-known/jwks.json", JWKSHandler.GetJWKS)
+	mux.Public("POST  /user/login", AuthHandler.Login)
+	mux.Public("POST  /user/register", AuthHandler.Register)
+	mux.Public("POST /user/login/2fa", AuthHandler.LoginTwoFactor)
+	mux.Public("POST /user/refresh", AuthHandler.Refresh)
+	mux.Public("GET /user/oidc", OIDCHandler.GetProviders)
+	mux.Public("GET /user/oidc/{provider}/login", OIDCHandler.Login)
+	mux.Public("GET /user/oidc/{provider}/callback", OIDCHandler.Callback)
+	mux.Public("POST /user/password/forgot", AccountHandler.ForgotPassword)
+	mux.Public("POST /user/password/reset", AccountHandler.ResetPassword)
+	mux.Public("POST /user/verify-email", AccountHandler.VerifyEmail)
+	mux.Public("POST /user/verify-email/resend", AccountHandler.ResendVerification)
+	mux.Public("GET /p/{slug}", PublicLinkHandler.ViewNote)
+	mux.Public("POST /p/{slug}", PublicLinkHandler.UnlockNote)
+	// маршруты без RequireScope доступны только сессии из логина, персональный токен туда не пустят
+	mux.HandleFunc("GET /user/me", middleware.RequireScope(models.ScopeUserRead, UserHandler.GetMe))
+	mux.HandleFunc("PATCH /user/me", UserHandler.UpdateMe)
+	mux.HandleFunc("DELETE /user/me", UserHandler.DeleteMe)
+	mux.HandleFunc("POST /user/password", UserHandler.ChangePassword)
+	mux.HandleFunc("POST /user/logout", AuthHandler.Logout)
+	mux.HandleFunc("POST /user/logout-all", AuthHandler.LogoutAll)
+	mux.HandleFunc("GET /user/sessions", AuthHandler.GetSessions)
+	mux.HandleFunc("DELETE /user/sessions/{id}", AuthHandler.RevokeSession)
+	mux.HandleFunc("GET /user/tokens", APITokenHandler.GetTokens)
+	mux.HandleFunc("POST /user/tokens", APITokenHandler.CreateToken)
+	mux.HandleFunc("DELETE /user/tokens/{id}", APITokenHandler.RevokeToken)
+	mux.HandleFunc("POST /user/2fa/enroll", TwoFactorHandler.Enroll)
+	mux.HandleFunc("POST /user/2fa/confirm", TwoFactorHandler.Confirm)
+	mux.HandleFunc("POST /user/2fa/disable", TwoFactorHandler.Disable)
+	mux.HandleFunc("GET /events", middleware.RequireScope(models.ScopeNotesRead, EventHandler.Stream))
+	mux.HandleFunc("GET /notes", middleware.RequireScope(models.ScopeNotesRead, NotesHandler.GetNotes))
+	mux.HandleFunc("GET /notes/search", middleware.RequireScope(models.ScopeNotesRead, SearchHandler.SearchNotes))
+	mux.HandleFunc("GET /notes/shared-with-me", middleware.RequireScope(models.ScopeNotesRead, ShareHandler.GetSharedWithMe))
+	mux.HandleFunc("GET /notes/{id}", middleware.RequireScope(models.ScopeNotesRead, NotesHandler.GetNoteHandler))
+	mux.HandleFunc("POST /notes", middleware.RequireScope(models.ScopeNotesWrite, NotesHandler.CreateNote))
+	mux.HandleFunc("PUT /notes/{id}", middleware.RequireScope(models.ScopeNotesWrite, NotesHandler.UpdateNote))
+	mux.HandleFunc("DELETE /notes/{id}", middleware.RequireScope(models.ScopeNotesWrite, NotesHandler.DeleteNote))
+	mux.HandleFunc("POST /notes/{id}/move", middleware.RequireScope(models.ScopeNotesWrite, NotesHandler.MoveNote))
+	mux.HandleFunc("GET /notes/{id}/shares", middleware.RequireScope(models.ScopeNotesRead, ShareHandler.GetShares))
+	mux.HandleFunc("POST /notes/{id}/shares", middleware.RequireScope(models.ScopeNotesWrite, ShareHandler.ShareNote))
+	mux.HandleFunc("DELETE /notes/{id}/shares/{userId}", middleware.RequireScope(models.ScopeNotesWrite, ShareHandler.RevokeShare))
+	mux.HandleFunc("GET /notes/{id}/links", middleware.RequireScope(models.ScopeNotesRead, PublicLinkHandler.GetLinks))
+	mux.HandleFunc("POST /notes/{id}/links", middleware.RequireScope(models.ScopeNotesWrite, PublicLinkHandler.CreateLink))
+	mux.HandleFunc("DELETE /notes/{id}/links/{linkId}", middleware.RequireScope(models.ScopeNotesWrite, PublicLinkHandler.RevokeLink))
+	mux.HandleFunc("GET /notes/{id}/collab", middleware.RequireScope(models.ScopeNotesRead, CollabHandler.Collaborate))
+	mux.HandleFunc("GET /notes/{id}/revisions", middleware.RequireScope(models.ScopeNotesRead, RevisionHandler.GetRevisions))
+	mux.HandleFunc("GET /notes/{id}/revisions/diff", middleware.RequireScope(models.ScopeNotesRead, RevisionHandler.DiffRevisions))
+	mux.HandleFunc("GET /notes/{id}/revisions/{rev}", middleware.RequireScope(models.ScopeNotesRead, RevisionHandler.GetRevision))
+	mux.HandleFunc("POST /notes/{id}/revisions/{rev}/restore", middleware.RequireScope(models.ScopeNotesWrite, RevisionHandler.RestoreRevision))
+	mux.HandleFunc("GET /trash", middleware.RequireScope(models.ScopeNotesRead, TrashHandler.GetTrash))
+	mux.HandleFunc("DELETE /trash", middleware.RequireScope(models.ScopeNotesWrite, TrashHandler.EmptyTrash))
+	mux.HandleFunc("POST /trash/{id}/restore", middleware.RequireScope(models.ScopeNotesWrite, TrashHandler.RestoreNote))
+	mux.HandleFunc("GET /tags", middleware.RequireScope(models.ScopeNotesRead, TagHandler.GetTags))
+	mux.HandleFunc("PATCH /tags/{name}", middleware.RequireScope(models.ScopeNotesWrite, TagHandler.RenameTag))
+	mux.HandleFunc("POST /tags/merge", middleware.RequireScope(models.ScopeNotesWrite, TagHandler.MergeTags))
+	mux.HandleFunc("GET /sync", middleware.RequireScope(models.ScopeNotesRead, SyncHandler.Pull))
+	mux.HandleFunc("POST /sync", middleware.RequireScope(models.ScopeNotesWrite, SyncHandler.Push))
+	mux.HandleFunc("GET /notebooks", middleware.RequireScope(models.ScopeNotebooksRead, NotebookHandler.GetNotebooks))
+	mux.HandleFunc("POST /notebooks", middleware.RequireScope(models.ScopeNotebooksWrite, NotebookHandler.CreateNotebook))
+	mux.HandleFunc("GET /notebooks/{id}", middleware.RequireScope(models.ScopeNotebooksRead, NotebookHandler.GetNotebook))
+	mux.HandleFunc("PATCH /notebooks/{id}", middleware.RequireScope(models.ScopeNotebooksWrite, NotebookHandler.RenameNotebook))
+	mux.HandleFunc("DELETE /notebooks/{id}", middleware.RequireScope(models.ScopeNotebooksWrite, NotebookHandler.DeleteNotebook))
+	mux.HandleFunc("GET /notebooks/{id}/notes", middleware.RequireScope(models.ScopeNotebooksRead, NotebookHandler.GetNotebookNotes))
+	mux.HandleFunc("POST /notebooks/{id}/move", middleware.RequireScope(models.ScopeNotebooksWrite, NotebookHandler.MoveNotebook))
+
+	// вся группа /admin/ открыта модераторам, роли раздают только админы
+	adminMux := http.NewServeMux()
+	adminMux.HandleFunc("GET /admin/users", AdminHandler.GetUsers)
+	adminMux.HandleFunc("GET /admin/users/{id}", AdminHandler.GetUser)
+	adminMux.HandleFunc("POST /admin/users/{id}/disable", AdminHandler.DisableUser)
+	adminMux.HandleFunc("POST /admin/users/{id}/enable", AdminHandler.EnableUser)
+	adminMux.HandleFunc("POST /admin/users/{id}/logout", AdminHandler.ForceLogout)
+	adminMux.Handle("PUT /admin/users/{id}/role", middleware.RequireRole(models.RoleAdmin, http.HandlerFunc(AdminHandler.SetRole)))
+	adminMux.HandleFunc("GET /admin/lockouts", AdminHandler.GetLockouts)
+	adminMux.HandleFunc("POST /admin/lockouts/unlock", AdminHandler.Unlock)
+	mux.Handle("/admin/", middleware.RequireRole(models.RoleModerator, adminMux))
 
-	AuthMiddleware := middleware.NewAuthMiddleware(secret)
+	AuthMiddleware := middleware.NewAuthMiddleware(keys, AuthService, APITokenService, AuthService, mux)
 
 	authMux := AuthMiddleware.AuthMiddleware(mux)
 	loggMux := middleware.Logger(authMux)
@@ -96,6 +256,23 @@ func Run() {
 		Addr:    ":8080",
 		Handler: loggMux,
 	}
+	// Shutdown не ждёт открытые потоки /events: закрытая шина завершает их сама
+	server.RegisterOnShutdown(EventBus.Close)
+
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	go runPeriodically(jobsCtx, "rotate signing keys", envDuration("JWT_KEY_CHECK_INTERVAL", time.Hour), keys.Rotate)
+	go runPeriodically(jobsCtx, "prune revisions", envDuration("REVISION_PRUNE_INTERVAL", time.Hour), RevisionService.PruneRevisions)
+	go runPeriodically(jobsCtx, "purge trash", envDuration("TRASH_PURGE_INTERVAL", time.Hour), TrashService.PurgeTrash)
+	go runPeriodically(jobsCtx, "purge sync tombstones", envDuration("SYNC_TOMBSTONE_PURGE_INTERVAL", time.Hour), SyncService.PurgeTombstones)
+	go runPeriodically(jobsCtx, "purge sessions", envDuration("SESSION_PURGE_INTERVAL", time.Hour), AuthService.PurgeSessions)
+	go runPeriodically(jobsCtx, "purge email tokens", envDuration("EMAIL_TOKEN_PURGE_INTERVAL", time.Hour), AccountService.PurgeTokens)
+	go runPeriodically(jobsCtx, "purge login throttles", envDuration("LOGIN_THROTTLE_PURGE_INTERVAL", time.Hour), LoginGuard.PurgeThrottles)
+	go runPeriodically(jobsCtx, "purge public links", envDuration("PUBLIC_LINK_PURGE_INTERVAL", time.Hour), PublicLinkService.PurgeLinks)
+	go runPeriodically(jobsCtx, "purge oidc states", envDuration("OIDC_STATE_PURGE_INTERVAL", time.Hour), OIDCService.PurgeStates)
+	go runPeriodically(jobsCtx, "save collaborative notes", envDuration("COLLAB_COMPACT_INTERVAL", 30*time.Second), CollabService.Compact)
+	go runPeriodically(jobsCtx, "flush session activity", envDuration("SESSION_TOUCH_INTERVAL", time.Minute), AuthService.FlushLastSeen)
 
 	go func() {
 		log.Print("Server is runnig...")
@@ -117,5 +294,14 @@ func Run() {
 		log.Fatalf("Server shutdown error: %s", err)
 	}
 
+	// сокеты совместного редактирования Shutdown не видит, их правки сохраняем и закрываем сами
+	if err := CollabService.Close(); err != nil {
+		slog.Error("Failed to save collaborative notes", "error", err)
+	}
+
+	if _, err := AuthService.FlushLastSeen(); err != nil {
+		slog.Error("Failed to save session activity", "error", err)
+	}
+
 	slog.AnyValue("Server gracefully stopped")
 }
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"2/internal/infrastructure/migrations"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+const migrateUsage = "usage: migrate up | down | status | to <version>"
+
+// runMigrate implements the `migrate` subcommand against the database chosen by STORAGE_BACKEND.
+func runMigrate(args []string) {
+	loadEnv()
+
+	if len(args) == 0 {
+		log.Fatal(migrateUsage)
+	}
+
+	backend := os.Getenv("STORAGE_BACKEND")
+	if backend == memoryBackend {
+		log.Fatal(migrations.ErrNoDatabase)
+	}
+
+	dialect, db, err := openDatabase(backend)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	migrator, err := migrations.NewMigrator(db, dialect)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var done []migrations.Migration
+	switch args[0] {
+	case "up":
+		done, err = migrator.Up()
+	case "down":
+		done, err = migrator.Down()
+	case "to":
+		if len(args) != 2 {
+			log.Fatal(migrateUsage)
+		}
+		version, perr := strconv.ParseInt(args[1], 10, 64)
+		if perr != nil {
+			log.Fatalf("invalid version %q", args[1])
+		}
+		done, err = migrator.To(version)
+	case "status":
+		printMigrationStatus(migrator)
+		return
+	default:
+		log.Fatal(migrateUsage)
+	}
+
+	for _, m := range done {
+		fmt.Printf("migrated %04d_%s\n", m.Version, m.Name)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(done) == 0 {
+		fmt.Println("nothing to migrate")
+	}
+}
+
+func printMigrationStatus(migrator *migrations.Migrator) {
+	statuses, err := migrator.Status()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, s := range statuses {
+		state := "pending"
+		if s.Applied {
+			state = "applied " + s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, state)
+	}
+}
+
+// autoMigrate brings the schema up to date on startup when AUTO_MIGRATE=true.
+func autoMigrate(repos *repositories) {
+	if os.Getenv("AUTO_MIGRATE") != "true" || repos.db == nil {
+		return
+	}
+
+	migrator, err := migrations.NewMigrator(repos.db, repos.dialect)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	done, err := migrator.Up()
+	if err != nil {
+		log.Fatalf("Auto migration failed: %s", err)
+	}
+	for _, m := range done {
+		log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"2/internal/app/oidc"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// newOIDCProviders reads the identity providers listed in OIDC_PROVIDERS, e.g. "university,google".
+// Each is configured with OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET and optionally
+// _DISPLAY_NAME and _SCOPES. The redirect address is built from API_URL.
+func newOIDCProviders() ([]*oidc.Provider, error) {
+	apiURL := strings.TrimRight(envString("API_URL", "http://localhost:8080"), "/")
+
+	var providers []*oidc.Provider
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+
+		config := oidc.Config{
+			Name:         name,
+			DisplayName:  os.Getenv(prefix + "DISPLAY_NAME"),
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  apiURL + "/user/oidc/" + name + "/callback",
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+		}
+		if config.Issuer == "" || config.ClientID == "" {
+			return nil, fmt.Errorf("%sISSUER and %sCLIENT_ID are required for provider %q", prefix, prefix, name)
+		}
+		providers = append(providers, oidc.NewProvider(config, nil))
+	}
+	return providers, nil
+}
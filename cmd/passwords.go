@@ -0,0 +1,54 @@
+package main
+
+import (
+	"2/internal/app/password"
+	"2/internal/app/service"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newPasswords configures password hashing and the policy for new passwords.
+// PASSWORD_HASHER is argon2id (default) or bcrypt, hashes of the other one are still
+// accepted and replaced on the next login. bcrypt takes at most 72 bytes, so with it
+// longer passwords are refused whatever PASSWORD_MAX_LENGTH says.
+func newPasswords() (*service.Passwords, error) {
+	argon, err := password.NewArgon2id(password.Argon2idParams{
+		Memory:      uint32(envInt("ARGON2_MEMORY_KIB", int(password.DefaultArgon2idParams.Memory))),
+		Iterations:  uint32(envInt("ARGON2_ITERATIONS", int(password.DefaultArgon2idParams.Iterations))),
+		Parallelism: uint8(envInt("ARGON2_PARALLELISM", int(password.DefaultArgon2idParams.Parallelism))),
+		SaltLength:  password.DefaultArgon2idParams.SaltLength,
+		KeyLength:   password.DefaultArgon2idParams.KeyLength,
+	})
+	if err != nil {
+		return nil, err
+	}
+	bcryptHasher, err := password.NewBcrypt(envInt("BCRYPT_COST", bcrypt.DefaultCost))
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := password.NewPolicy(
+		envInt("PASSWORD_MIN_LENGTH", 8),
+		envInt("PASSWORD_MAX_LENGTH", 128),
+		envInt("PASSWORD_MIN_CLASSES", 0),
+		envString("PASSWORD_BREACHED_LIST", ""))
+	if err != nil {
+		return nil, err
+	}
+
+	var hasher *password.Hasher
+	switch kind := envString("PASSWORD_HASHER", "argon2id"); kind {
+	case "argon2id":
+		hasher = password.NewHasher(argon, bcryptHasher)
+	case "bcrypt":
+		if policy.MinLength > password.BcryptMaxBytes {
+			return nil, fmt.Errorf("PASSWORD_MIN_LENGTH %d is more than the %d bytes bcrypt hashes", policy.MinLength, password.BcryptMaxBytes)
+		}
+		policy.MaxBytes = password.BcryptMaxBytes
+		hasher = password.NewHasher(bcryptHasher, argon)
+	default:
+		return nil, fmt.Errorf("unknown PASSWORD_HASHER %q, use argon2id or bcrypt", kind)
+	}
+
+	return service.NewPasswords(hasher, policy), nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"2/internal/domain/repository"
+	"2/internal/infrastructure/storage"
+	"2/internal/infrastructure/storage/memory"
+	"database/sql"
+	"fmt"
+	"os"
+)
+
+const memoryBackend = "memory"
+
+// repositories bundles every repository the services need, backed by a single storage backend.
+type repositories struct {
+	Users      repository.UserRepository
+	Notes      repository.NotesRepository
+	Tags       repository.TagsRepository
+	Notebooks  repository.NotebooksRepository
+	Search     repository.SearchRepository
+	Revisions  repository.RevisionsRepository
+	Sessions   repository.SessionsRepository
+	TwoFactor  repository.TwoFactorRepository
+	Tokens     repository.UserTokensRepository
+	APITokens  repository.APITokensRepository
+	Keys       repository.SigningKeysRepository
+	Identities repository.IdentitiesRepository
+	LoginGuard repository.LoginGuardRepository
+	Shares     repository.SharesRepository
+	Links      repository.PublicLinksRepository
+
+	db      *sql.DB
+	dialect storage.Dialect
+	search  *storage.SearchRepository
+}
+
+// openDatabase opens the SQL database chosen by STORAGE_BACKEND:
+// postgres (default, DB_CONNECTION) or sqlite (SQLITE_PATH).
+func openDatabase(backend string) (storage.Dialect, *sql.DB, error) {
+	switch backend {
+	case "", storage.Postgres.Name:
+		dbConnStr := os.Getenv("DB_CONNECTION")
+		if dbConnStr == "" {
+			return storage.Dialect{}, nil, fmt.Errorf("DB_CONNECTION is required for the postgres backend")
+		}
+		db, err := storage.Open(storage.Postgres, dbConnStr)
+		return storage.Postgres, db, err
+	case storage.SQLite.Name:
+		path := os.Getenv("SQLITE_PATH")
+		if path == "" {
+			path = "notes.db"
+		}
+		db, err := storage.Open(storage.SQLite, path)
+		return storage.SQLite, db, err
+	default:
+		return storage.Dialect{}, nil, fmt.Errorf("unknown STORAGE_BACKEND %q", backend)
+	}
+}
+
+// newRepositories builds the repositories for the backend chosen by STORAGE_BACKEND,
+// memory keeps everything in process and needs no database.
+func newRepositories(backend string) (*repositories, error) {
+	if backend == memoryBackend {
+		notes := memory.NewNotesRepository()
+		notebooks := memory.NewNotebooksRepository(notes)
+		sessions := memory.NewSessionsRepository()
+		twoFactor := memory.NewTwoFactorRepository()
+		tokens := memory.NewUserTokensRepository()
+		apiTokens := memory.NewAPITokensRepository()
+		identities := memory.NewIdentitiesRepository()
+		users := memory.NewUserRepository(notes, notebooks, sessions, twoFactor, tokens, apiTokens, identities)
+		return &repositories{
+			Users:      users,
+			Notes:      notes,
+			Tags:       memory.NewTagsRepository(notes),
+			Notebooks:  notebooks,
+			Search:     memory.NewSearchRepository(notes),
+			Revisions:  memory.NewRevisionsRepository(notes),
+			Sessions:   sessions,
+			TwoFactor:  twoFactor,
+			Tokens:     tokens,
+			APITokens:  apiTokens,
+			Keys:       memory.NewSigningKeysRepository(),
+			Identities: identities,
+			LoginGuard: memory.NewLoginGuardRepository(),
+			Shares:     memory.NewSharesRepository(notes, users),
+			Links:      memory.NewPublicLinksRepository(notes),
+		}, nil
+	}
+
+	dialect, db, err := openDatabase(backend)
+	if err != nil {
+		return nil, err
+	}
+
+	search := storage.NewSearchRepository(db, dialect)
+	return &repositories{
+		Users:      storage.NewUserRepository(db, dialect),
+		Notes:      storage.NewNotesRepository(db, dialect),
+		Tags:       storage.NewTagsRepository(db, dialect),
+		Notebooks:  storage.NewNotebooksRepository(db, dialect),
+		Search:     search,
+		Revisions:  storage.NewRevisionsRepository(db, dialect),
+		Sessions:   storage.NewSessionsRepository(db, dialect),
+		TwoFactor:  storage.NewTwoFactorRepository(db, dialect),
+		Tokens:     storage.NewUserTokensRepository(db, dialect),
+		APITokens:  storage.NewAPITokensRepository(db, dialect),
+		Keys:       storage.NewSigningKeysRepository(db, dialect),
+		Identities: storage.NewIdentitiesRepository(db, dialect),
+		LoginGuard: storage.NewLoginGuardRepository(db, dialect),
+		Shares:     storage.NewSharesRepository(db, dialect),
+		Links:      storage.NewPublicLinksRepository(db, dialect),
+		search:     search,
+		db:         db,
+		dialect:    dialect,
+	}, nil
+}
+
+// configureSearch applies SEARCH_LANGUAGE to the database full-text search,
+// it must run after the migrations.
+func (r *repositories) configureSearch(language string) error {
+	if r.search == nil {
+		return nil
+	}
+	return r.search.SetLanguage(language)
+}
+
+func (r *repositories) Close() error {
+	if r.db == nil {
+		return nil
+	}
+	return r.db.Close()
+}
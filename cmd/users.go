@@ -0,0 +1,47 @@
+package main
+
+import (
+	"2/internal/app/service"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+const usersUsage = "usage: users role <email> <user|moderator|admin>"
+
+// runUsers implements the `users` subcommand. It appoints the first admin, the admin
+// API can do the rest once someone holds the role.
+func runUsers(args []string) {
+	loadEnv()
+
+	if len(args) != 3 || args[0] != "role" {
+		log.Fatal(usersUsage)
+	}
+
+	backend := os.Getenv("STORAGE_BACKEND")
+	if backend == memoryBackend {
+		log.Fatal("the memory backend keeps users inside the server process, there is nobody to change")
+	}
+
+	repos, err := newRepositories(backend)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer repos.Close()
+
+	email, role := strings.TrimSpace(args[1]), args[2]
+	user, exists, err := repos.Users.GetUserByEmail(email)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !exists {
+		log.Fatalf("there is no user with email %s", email)
+	}
+
+	admin := service.NewAdminService(repos.Users, repos.Notes, repos.Sessions, newLoginGuard(repos))
+	if err = admin.AssignRole(user.UserId, role); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%s is now %s, their sessions were revoked\n", email, role)
+}
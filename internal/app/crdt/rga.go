@@ -0,0 +1,272 @@
+// Package crdt is a replicated text for collaborative editing, a Replicated Growable Array.
+// Every character gets an id that never changes, operations name characters by id instead of
+// by position, so replicas that apply the same operations in any causal order end up with
+// the same text.
+package crdt
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+var (
+	// ErrUnknownID means the operation refers to a character the replica has not seen yet,
+	// it has to wait for the operation that inserted it.
+	ErrUnknownID = errors.New("operation refers to an unknown character")
+	ErrInvalidOp = errors.New("operation is invalid")
+)
+
+const (
+	OpInsert = "insert"
+	OpDelete = "delete"
+)
+
+// ID names one character of a document. Counter is a Lamport clock: a replica gives a new
+// character a counter above every counter it has seen, Site tells replicas apart on ties.
+// The zero ID stands for the beginning of the document.
+type ID struct {
+	Counter uint64 `json:"c"`
+	Site    uint32 `json:"s"`
+}
+
+func (id ID) IsZero() bool {
+	return id == ID{}
+}
+
+// newer orders ids: among characters inserted after the same one, the newer comes first.
+func (id ID) newer(other ID) bool {
+	if id.Counter != other.Counter {
+		return id.Counter > other.Counter
+	}
+	return id.Site > other.Site
+}
+
+// Op is one change of a document. An insert puts Text after the character After, its first
+// character gets ID and the next ones the following counters of the same site. A delete
+// removes the character ID, the character stays in the document as a tombstone so that
+// operations made concurrently can still refer to it.
+type Op struct {
+	Type  string `json:"type" enums:"insert,delete"`
+	ID    ID     `json:"id"`
+	After ID     `json:"after"`
+	Text  string `json:"text,omitempty"`
+}
+
+// Element is a character of a document as it is sent to a new replica.
+type Element struct {
+	ID      ID     `json:"id"`
+	Value   string `json:"value"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+type element struct {
+	id      ID
+	value   rune
+	deleted bool
+}
+
+// Doc is one replica of a text. It is not safe for concurrent use.
+type Doc struct {
+	elems []element
+	// ids holds every character with the index it had when last looked up. Characters are
+	// never removed, inserts only move them right, so the real index is never below it.
+	ids map[ID]int
+	// clock is the highest counter seen, the next local insert starts above it
+	clock uint64
+}
+
+func New() *Doc {
+	return &Doc{ids: make(map[ID]int)}
+}
+
+// FromText makes a document holding text. Its characters belong to site 0, so every
+// replica that starts from the same text gets the same ids.
+func FromText(text string) *Doc {
+	d := New()
+	for i, r := range []rune(text) {
+		id := ID{Counter: uint64(i + 1)}
+		d.ids[id] = len(d.elems)
+		d.elems = append(d.elems, element{id: id, value: r})
+		d.clock = id.Counter
+	}
+	return d
+}
+
+// Load makes a replica from the elements of another one.
+func Load(elements []Element) (*Doc, error) {
+	d := New()
+	for _, e := range elements {
+		runes := []rune(e.Value)
+		if len(runes) != 1 || e.ID.IsZero() {
+			return nil, fmt.Errorf("%w: element must hold one character and a non-zero id", ErrInvalidOp)
+		}
+		if _, ok := d.ids[e.ID]; ok {
+			return nil, fmt.Errorf("%w: duplicate id %v", ErrInvalidOp, e.ID)
+		}
+		d.ids[e.ID] = len(d.elems)
+		d.elems = append(d.elems, element{id: e.ID, value: runes[0], deleted: e.Deleted})
+		d.clock = max(d.clock, e.ID.Counter)
+	}
+	return d, nil
+}
+
+// Apply integrates a local or remote operation. Applying an operation twice changes nothing.
+func (d *Doc) Apply(op Op) error {
+	switch op.Type {
+	case OpInsert:
+		return d.insert(op)
+	case OpDelete:
+		return d.delete(op)
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidOp, op.Type)
+	}
+}
+
+func (d *Doc) insert(op Op) error {
+	runes := []rune(op.Text)
+	if len(runes) == 0 {
+		return fmt.Errorf("%w: nothing to insert", ErrInvalidOp)
+	}
+	// порядок RGA держится на том, что потомок всегда новее того, после кого вставлен
+	if op.ID.Counter <= op.After.Counter || op.ID.Counter > math.MaxUint64-uint64(len(runes)) {
+		return fmt.Errorf("%w: counter %d must be above the counter of after", ErrInvalidOp, op.ID.Counter)
+	}
+
+	if _, ok := d.ids[op.ID]; ok {
+		return nil
+	}
+	for i := range runes {
+		if _, ok := d.ids[ID{Counter: op.ID.Counter + uint64(i), Site: op.ID.Site}]; ok {
+			return fmt.Errorf("%w: id %d/%d is taken", ErrInvalidOp, op.ID.Counter+uint64(i), op.ID.Site)
+		}
+	}
+
+	pos := -1
+	if !op.After.IsZero() {
+		if pos = d.index(op.After); pos < 0 {
+			return ErrUnknownID
+		}
+	}
+
+	// более новые вставки после того же символа (и всё, что вставлено после них) стоят раньше
+	at := pos + 1
+	for at < len(d.elems) && d.elems[at].id.newer(op.ID) {
+		at++
+	}
+
+	// следующие символы вставки новее предыдущего, а всё, что стоит за at, его не новее,
+	// поэтому вся вставка ложится одним куском
+	d.elems = append(d.elems, make([]element, len(runes))...)
+	copy(d.elems[at+len(runes):], d.elems[at:])
+	for i, r := range runes {
+		id := ID{Counter: op.ID.Counter + uint64(i), Site: op.ID.Site}
+		d.elems[at+i] = element{id: id, value: r}
+		d.ids[id] = at + i
+	}
+
+	d.clock = max(d.clock, op.ID.Counter+uint64(len(runes)-1))
+	return nil
+}
+
+func (d *Doc) delete(op Op) error {
+	pos := d.index(op.ID)
+	if pos < 0 {
+		return ErrUnknownID
+	}
+	d.elems[pos].deleted = true
+	return nil
+}
+
+// index finds the character from the index it had last time, it has moved right by as
+// many characters as were inserted before it since.
+func (d *Doc) index(id ID) int {
+	i, ok := d.ids[id]
+	if !ok {
+		return -1
+	}
+	for d.elems[i].id != id {
+		i++
+	}
+	d.ids[id] = i
+	return i
+}
+
+// Insert makes the operation that puts text at the visible position pos and applies it.
+func (d *Doc) Insert(site uint32, pos int, text string) (Op, error) {
+	after := ID{}
+	if pos > 0 {
+		i := d.visible(pos - 1)
+		if i < 0 {
+			return Op{}, fmt.Errorf("%w: position %d is out of the text", ErrInvalidOp, pos)
+		}
+		after = d.elems[i].id
+	}
+
+	op := Op{Type: OpInsert, ID: ID{Counter: d.clock + 1, Site: site}, After: after, Text: text}
+	return op, d.Apply(op)
+}
+
+// Delete makes the operations that remove n visible characters from pos and applies them.
+func (d *Doc) Delete(pos int, n int) ([]Op, error) {
+	ops := make([]Op, 0, n)
+	for range n {
+		i := d.visible(pos)
+		if i < 0 {
+			return ops, fmt.Errorf("%w: position %d is out of the text", ErrInvalidOp, pos)
+		}
+		op := Op{Type: OpDelete, ID: d.elems[i].id}
+		d.elems[i].deleted = true
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// visible returns the index of the visible character at pos, or -1.
+func (d *Doc) visible(pos int) int {
+	if pos < 0 {
+		return -1
+	}
+	for i := range d.elems {
+		if d.elems[i].deleted {
+			continue
+		}
+		if pos == 0 {
+			return i
+		}
+		pos--
+	}
+	return -1
+}
+
+// Text is the document without deleted characters.
+func (d *Doc) Text() string {
+	var b strings.Builder
+	for _, e := range d.elems {
+		if !e.deleted {
+			b.WriteRune(e.value)
+		}
+	}
+	return b.String()
+}
+
+// Elements returns every character with tombstones, for a new replica to Load.
+func (d *Doc) Elements() []Element {
+	elements := make([]Element, len(d.elems))
+	for i, e := range d.elems {
+		elements[i] = Element{ID: e.id, Value: string(e.value), Deleted: e.deleted}
+	}
+	return elements
+}
+
+// Clock is the highest counter in the document, new characters of a replica that joins
+// now must get counters above it.
+func (d *Doc) Clock() uint64 {
+	return d.clock
+}
+
+// Len counts the characters with tombstones, which is what the document costs in memory.
+func (d *Doc) Len() int {
+	return len(d.elems)
+}
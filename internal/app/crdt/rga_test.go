@@ -0,0 +1,251 @@
+package crdt_test
+
+import (
+	"2/internal/app/crdt"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// replica is one editor in a simulated session: it edits its own copy and receives the
+// operations of the others late and in a shuffled order.
+type replica struct {
+	site    uint32
+	doc     *crdt.Doc
+	log     []crdt.Op
+	pending []crdt.Op
+}
+
+// deliver applies the operation, or keeps it until the character it refers to arrives.
+func (r *replica) deliver(t *testing.T, op crdt.Op) {
+	t.Helper()
+	r.pending = append(r.pending, op)
+	for progress := true; progress; {
+		progress = false
+		rest := r.pending[:0]
+		for _, op := range r.pending {
+			err := r.doc.Apply(op)
+			switch {
+			case errors.Is(err, crdt.ErrUnknownID):
+				rest = append(rest, op)
+			case err != nil:
+				t.Fatalf("site %d: apply %+v: %v", r.site, op, err)
+			default:
+				progress = true
+			}
+		}
+		r.pending = rest
+	}
+}
+
+func (r *replica) edit(t *testing.T, rng *rand.Rand) {
+	t.Helper()
+	length := len([]rune(r.doc.Text()))
+	if length > 0 && rng.Intn(3) == 0 {
+		ops, err := r.doc.Delete(rng.Intn(length), 1+rng.Intn(min(3, length)))
+		if err != nil && !errors.Is(err, crdt.ErrInvalidOp) {
+			t.Fatalf("site %d: delete: %v", r.site, err)
+		}
+		r.log = append(r.log, ops...)
+		return
+	}
+
+	words := []string{"a", "b", "xyz", "Привет", " ", "\n", "42"}
+	op, err := r.doc.Insert(r.site, rng.Intn(length+1), words[rng.Intn(len(words))])
+	if err != nil {
+		t.Fatalf("site %d: insert: %v", r.site, err)
+	}
+	r.log = append(r.log, op)
+}
+
+func TestConcurrentEditsConverge(t *testing.T) {
+	for seed := int64(1); seed <= 200; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+
+		replicas := make([]*replica, 2+rng.Intn(4))
+		for i := range replicas {
+			replicas[i] = &replica{site: uint32(i + 1), doc: crdt.FromText("shared note")}
+		}
+		// sent[i][j] is how much of the log of j has been sent to i
+		sent := make([][]int, len(replicas))
+		for i := range sent {
+			sent[i] = make([]int, len(replicas))
+		}
+
+		for step := 0; step < 150; step++ {
+			r := replicas[rng.Intn(len(replicas))]
+			if rng.Intn(2) == 0 {
+				r.edit(t, rng)
+				continue
+			}
+
+			// отдаём случайный кусок чужого журнала, сами операции в нём перемешаны
+			to, from := rng.Intn(len(replicas)), rng.Intn(len(replicas))
+			if to == from {
+				continue
+			}
+			log := replicas[from].log
+			n := rng.Intn(len(log) - sent[to][from] + 1)
+			batch := append([]crdt.Op(nil), log[sent[to][from]:sent[to][from]+n]...)
+			rng.Shuffle(len(batch), func(i, j int) { batch[i], batch[j] = batch[j], batch[i] })
+			for _, op := range batch {
+				replicas[to].deliver(t, op)
+			}
+			sent[to][from] += n
+		}
+
+		// в конце каждый получает всё, что ещё не получил, а сервер — все журналы подряд
+		server := crdt.FromText("shared note")
+		serverPending := &replica{doc: server}
+		for from, r := range replicas {
+			for to := range replicas {
+				if to != from {
+					for _, op := range r.log[sent[to][from]:] {
+						replicas[to].deliver(t, op)
+					}
+				}
+			}
+			for _, op := range r.log {
+				serverPending.deliver(t, op)
+			}
+		}
+
+		want := server.Text()
+		for _, r := range replicas {
+			if len(r.pending) != 0 || len(serverPending.pending) != 0 {
+				t.Fatalf("seed %d: site %d has %d undelivered operations", seed, r.site, len(r.pending))
+			}
+			if got := r.doc.Text(); got != want {
+				t.Fatalf("seed %d: site %d diverged:\n got %q\nwant %q", seed, r.site, got, want)
+			}
+		}
+	}
+}
+
+func TestConcurrentInsertsAtSamePlace(t *testing.T) {
+	a, b := crdt.FromText("ac"), crdt.FromText("ac")
+	opA, _ := a.Insert(1, 1, "B")
+	opB, _ := b.Insert(2, 1, "b")
+
+	if err := a.Apply(opB); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Apply(opA); err != nil {
+		t.Fatal(err)
+	}
+	if a.Text() != b.Text() {
+		t.Fatalf("replicas diverged: %q and %q", a.Text(), b.Text())
+	}
+	// при равных счётчиках раньше стоит вставка с большим site
+	if a.Text() != "abBc" {
+		t.Fatalf("got %q, want %q", a.Text(), "abBc")
+	}
+}
+
+func TestApplyTwiceAndDeletedAnchor(t *testing.T) {
+	a, b := crdt.FromText("abc"), crdt.FromText("abc")
+
+	// b дописывает после «b», а a тем временем «b» удаляет
+	ins, _ := b.Insert(2, 2, "X")
+	del, _ := a.Delete(1, 1)
+
+	for _, op := range append(del, del...) {
+		if err := b.Apply(op); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for range 2 {
+		if err := a.Apply(ins); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if a.Text() != "aXc" || b.Text() != "aXc" {
+		t.Fatalf("got %q and %q, want %q", a.Text(), b.Text(), "aXc")
+	}
+}
+
+func TestLoadedReplicaCatchesUp(t *testing.T) {
+	a := crdt.FromText("draft")
+	a.Delete(0, 1)
+	a.Insert(1, 4, "!")
+
+	b, err := crdt.Load(a.Elements())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Clock() != a.Clock() {
+		t.Fatalf("clock %d, want %d", b.Clock(), a.Clock())
+	}
+
+	op, _ := b.Insert(2, 0, "D")
+	if err = a.Apply(op); err != nil {
+		t.Fatal(err)
+	}
+	if a.Text() != "Draft!" || b.Text() != "Draft!" {
+		t.Fatalf("got %q and %q, want %q", a.Text(), b.Text(), "Draft!")
+	}
+}
+
+// Characters move right when text is inserted before them, operations still find them.
+func TestOpsAfterLargeInsert(t *testing.T) {
+	text := strings.Repeat("abcdefghij", 10000)
+	d := crdt.FromText(text)
+	last := crdt.ID{Counter: uint64(len(text))}
+
+	// вставка одним куском перед всем текстом сдвигает каждый символ
+	ins := crdt.Op{Type: crdt.OpInsert, ID: crdt.ID{Counter: last.Counter + 1, Site: 1}, Text: strings.Repeat("X", 5000)}
+	for _, op := range []crdt.Op{
+		ins,
+		{Type: crdt.OpDelete, ID: last},
+		{Type: crdt.OpInsert, ID: crdt.ID{Counter: last.Counter + 5001, Site: 2}, After: last, Text: "!"},
+		{Type: crdt.OpDelete, ID: crdt.ID{Counter: last.Counter + 5000, Site: 1}},
+	} {
+		if err := d.Apply(op); err != nil {
+			t.Fatalf("apply %+v: %v", op, err)
+		}
+	}
+
+	want := strings.Repeat("X", 4999) + text[:len(text)-1] + "!"
+	if d.Text() != want {
+		t.Fatalf("got %d characters %q...%q, want %q...%q", len(d.Text()), d.Text()[:10], d.Text()[len(d.Text())-10:], want[:10], want[len(want)-10:])
+	}
+	if d.Len() != len(text)+5001 {
+		t.Fatalf("len %d, want %d", d.Len(), len(text)+5001)
+	}
+}
+
+func TestRejectsInvalidOperations(t *testing.T) {
+	d := crdt.FromText("abc")
+
+	cases := map[string]struct {
+		op   crdt.Op
+		want error
+	}{
+		"unknown after":    {crdt.Op{Type: crdt.OpInsert, ID: crdt.ID{Counter: 10, Site: 1}, After: crdt.ID{Counter: 9, Site: 7}, Text: "x"}, crdt.ErrUnknownID},
+		"counter too low":  {crdt.Op{Type: crdt.OpInsert, ID: crdt.ID{Counter: 2, Site: 1}, After: crdt.ID{Counter: 3}, Text: "x"}, crdt.ErrInvalidOp},
+		"empty insert":     {crdt.Op{Type: crdt.OpInsert, ID: crdt.ID{Counter: 10, Site: 1}, Text: ""}, crdt.ErrInvalidOp},
+		"already applied":  {crdt.Op{Type: crdt.OpInsert, ID: crdt.ID{Counter: 2}, After: crdt.ID{Counter: 1}, Text: "b"}, nil},
+		"unknown delete":   {crdt.Op{Type: crdt.OpDelete, ID: crdt.ID{Counter: 99, Site: 1}}, crdt.ErrUnknownID},
+		"unknown type":     {crdt.Op{Type: "move", ID: crdt.ID{Counter: 1}}, crdt.ErrInvalidOp},
+		"overflow counter": {crdt.Op{Type: crdt.OpInsert, ID: crdt.ID{Counter: ^uint64(0), Site: 1}, Text: "xy"}, crdt.ErrInvalidOp},
+	}
+	for name, c := range cases {
+		err := d.Apply(c.op)
+		if !errors.Is(err, c.want) {
+			t.Errorf("%s: got %v, want %v", name, err, c.want)
+		}
+	}
+	if d.Text() != "abc" {
+		t.Fatalf("rejected operations changed the text to %q", d.Text())
+	}
+
+	// вставка, чей хвост занимает чужие id, отклоняется целиком
+	if err := d.Apply(crdt.Op{Type: crdt.OpInsert, ID: crdt.ID{Counter: 10, Site: 1}, Text: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	err := d.Apply(crdt.Op{Type: crdt.OpInsert, ID: crdt.ID{Counter: 9, Site: 1}, Text: "yz"})
+	if !errors.Is(err, crdt.ErrInvalidOp) || d.Text() != "xabc" {
+		t.Fatalf("got %v and %q, want %v and %q", err, d.Text(), crdt.ErrInvalidOp, "xabc")
+	}
+}
@@ -0,0 +1,228 @@
+// Package diff compares two texts line by line or word by word using the Myers algorithm.
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type Op string
+
+const (
+	Equal  Op = "equal"
+	Insert Op = "insert"
+	Delete Op = "delete"
+)
+
+// Edit is a run of text that is kept, inserted or deleted on the way from the old text to the new one.
+type Edit struct {
+	Op   Op     `json:"op" example:"insert"`
+	Text string `json:"text" example:"new words"`
+}
+
+// Words diffs two texts word by word, whitespace is kept so that joining
+// the Equal and Insert edits gives back the new text.
+func Words(a, b string) []Edit {
+	return merge(compare(splitWords(a), splitWords(b)))
+}
+
+// Lines diffs two texts line by line, every edit holds a single line without its newline.
+func Lines(a, b string) []Edit {
+	return compare(splitLines(a), splitLines(b))
+}
+
+// Unified renders a line diff in the unified format with the given number of context lines.
+func Unified(a, b string, fromName, toName string, context int) string {
+	edits := Lines(a, b)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+
+	// позиции строк в старом и новом тексте перед каждой правкой
+	aLine := make([]int, len(edits)+1)
+	bLine := make([]int, len(edits)+1)
+	for i, e := range edits {
+		aLine[i+1], bLine[i+1] = aLine[i], bLine[i]
+		if e.Op != Insert {
+			aLine[i+1]++
+		}
+		if e.Op != Delete {
+			bLine[i+1]++
+		}
+	}
+
+	for i := 0; i < len(edits); {
+		if edits[i].Op == Equal {
+			i++
+			continue
+		}
+
+		start := max(i-context, 0)
+		end := i
+		for j := i; j < len(edits); j++ {
+			if edits[j].Op != Equal {
+				end = j
+			} else if j-end > 2*context {
+				break
+			}
+		}
+		end = min(end+context+1, len(edits))
+
+		aStart, aCount := aLine[start], aLine[end]-aLine[start]
+		bStart, bCount := bLine[start], bLine[end]-bLine[start]
+		if aCount > 0 {
+			aStart++
+		}
+		if bCount > 0 {
+			bStart++
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount)
+
+		for _, e := range edits[start:end] {
+			switch e.Op {
+			case Equal:
+				out.WriteString(" ")
+			case Insert:
+				out.WriteString("+")
+			case Delete:
+				out.WriteString("-")
+			}
+			out.WriteString(e.Text)
+			out.WriteString("\n")
+		}
+		i = end
+	}
+
+	return out.String()
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// splitWords cuts text into alternating runs of whitespace and non-whitespace.
+func splitWords(text string) []string {
+	var tokens []string
+	start := 0
+	space := false
+	for i, r := range text {
+		if i > start && unicode.IsSpace(r) != space {
+			tokens = append(tokens, text[start:i])
+			start = i
+		}
+		space = unicode.IsSpace(r)
+	}
+	if start < len(text) {
+		tokens = append(tokens, text[start:])
+	}
+	return tokens
+}
+
+func merge(edits []Edit) []Edit {
+	var merged []Edit
+	for _, e := range edits {
+		if n := len(merged); n > 0 && merged[n-1].Op == e.Op {
+			merged[n-1].Text += e.Text
+			continue
+		}
+		merged = append(merged, e)
+	}
+	return merged
+}
+
+// compare returns the shortest edit script between a and b.
+func compare(a, b []string) []Edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	edits := make([]Edit, 0, len(a)+len(b))
+	for _, t := range a[:prefix] {
+		edits = append(edits, Edit{Op: Equal, Text: t})
+	}
+	edits = append(edits, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, t := range a[len(a)-suffix:] {
+		edits = append(edits, Edit{Op: Equal, Text: t})
+	}
+	return edits
+}
+
+func myers(a, b []string) []Edit {
+	n, m := len(a), len(b)
+	if n == 0 && m == 0 {
+		return nil
+	}
+
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	// trace[d] keeps v[-d-1..d+1] as it was before step d, enough to walk the path back
+	var trace [][]int
+
+	var d int
+search:
+	for d = 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	var edits []Edit
+	x, y := n, m
+	for ; d >= 0; d-- {
+		prev := trace[d]
+		at := func(k int) int { return prev[k+d+1] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			edits = append(edits, Edit{Op: Equal, Text: a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, Edit{Op: Insert, Text: b[y-1]})
+			} else {
+				edits = append(edits, Edit{Op: Delete, Text: a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
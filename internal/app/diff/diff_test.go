@@ -0,0 +1,161 @@
+package diff_test
+
+import (
+	"2/internal/app/diff"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// sides joins the edits back into the old and the new text.
+func sides(edits []diff.Edit) (a, b []string) {
+	for _, e := range edits {
+		if e.Op != diff.Insert {
+			a = append(a, e.Text)
+		}
+		if e.Op != diff.Delete {
+			b = append(b, e.Text)
+		}
+	}
+	return a, b
+}
+
+func changed(edits []diff.Edit) int {
+	n := 0
+	for _, e := range edits {
+		if e.Op != diff.Equal {
+			n++
+		}
+	}
+	return n
+}
+
+// lcs is the length of the longest common subsequence, the shortest script changes
+// len(a)+len(b)-2*lcs lines.
+func lcs(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for i := range a {
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(prev[j+1], cur[j])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func TestLinesShortest(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, rnd.Intn(12))
+		for i := range lines {
+			lines[i] = string(rune('a' + rnd.Intn(4)))
+		}
+		return lines
+	}
+
+	for i := 0; i < 2000; i++ {
+		a, b := randomLines(), randomLines()
+		edits := diff.Lines(strings.Join(a, "\n"), strings.Join(b, "\n"))
+
+		gotA, gotB := sides(edits)
+		if !slices.Equal(gotA, a) || !slices.Equal(gotB, b) {
+			t.Fatalf("%q -> %q: edits %v give back %q -> %q", a, b, edits, gotA, gotB)
+		}
+		if want := len(a) + len(b) - 2*lcs(a, b); changed(edits) != want {
+			t.Fatalf("%q -> %q: %d changed lines, the shortest script has %d", a, b, changed(edits), want)
+		}
+	}
+}
+
+func TestLines(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want []diff.Edit
+	}{
+		{"", "", nil},
+		{"same\n", "same", []diff.Edit{{diff.Equal, "same"}}},
+		{"", "new", []diff.Edit{{diff.Insert, "new"}}},
+		{"old\n", "", []diff.Edit{{diff.Delete, "old"}}},
+		{"a\nb\nc", "a\nx\nc", []diff.Edit{{diff.Equal, "a"}, {diff.Delete, "b"}, {diff.Insert, "x"}, {diff.Equal, "c"}}},
+		{"a\nb", "b\na", []diff.Edit{{diff.Delete, "a"}, {diff.Equal, "b"}, {diff.Insert, "a"}}},
+	} {
+		if got := diff.Lines(tc.a, tc.b); !slices.Equal(got, tc.want) {
+			t.Errorf("Lines(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestWords(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want []diff.Edit
+	}{
+		{"the quick fox", "the quick fox", []diff.Edit{{diff.Equal, "the quick fox"}}},
+		{"the quick fox", "the slow fox", []diff.Edit{{diff.Equal, "the "}, {diff.Delete, "quick"}, {diff.Insert, "slow"}, {diff.Equal, " fox"}}},
+		{"one two", "one  two\tthree", []diff.Edit{{diff.Equal, "one"}, {diff.Delete, " "}, {diff.Insert, "  "}, {diff.Equal, "two"}, {diff.Insert, "\tthree"}}},
+		{"привет мир", "привет всем", []diff.Edit{{diff.Equal, "привет "}, {diff.Delete, "мир"}, {diff.Insert, "всем"}}},
+	} {
+		got := diff.Words(tc.a, tc.b)
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("Words(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+
+		// соседние правки одного вида склеены, из правок собираются оба текста
+		for i := 1; i < len(got); i++ {
+			if got[i].Op == got[i-1].Op {
+				t.Errorf("Words(%q, %q): edits %d and %d are both %s", tc.a, tc.b, i-1, i, got[i].Op)
+			}
+		}
+		a, b := sides(got)
+		if strings.Join(a, "") != tc.a || strings.Join(b, "") != tc.b {
+			t.Errorf("Words(%q, %q) gives back %q -> %q", tc.a, tc.b, strings.Join(a, ""), strings.Join(b, ""))
+		}
+	}
+}
+
+func TestUnified(t *testing.T) {
+	numbered := func(from, to int, replace map[int]string) string {
+		var lines []string
+		for i := from; i <= to; i++ {
+			line, ok := replace[i]
+			if !ok {
+				line = string(rune('a'+i-1)) + "\n"
+			}
+			lines = append(lines, line)
+		}
+		return strings.Join(lines, "")
+	}
+
+	for _, tc := range []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"no changes", "a\nb\n", "a\nb\n", "--- old\n+++ new\n"},
+		{"one line changed", "a\nb\nc\n", "a\nx\nc\n",
+			"--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n"},
+		{"created", "", "a\nb\n",
+			"--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{"emptied", "a\nb\n", "",
+			"--- old\n+++ new\n@@ -1,2 +0,0 @@\n-a\n-b\n"},
+		{"insert after the context", numbered(1, 8, nil), numbered(1, 8, map[int]string{8: "h\ni\n"}),
+			"--- old\n+++ new\n@@ -8,1 +8,2 @@\n h\n+i\n"},
+		// правки дальше 2*context друг от друга дают два блока, ближе — один
+		{"two hunks", numbered(1, 12, nil), numbered(1, 12, map[int]string{2: "B\n", 11: "K\n"}),
+			"--- old\n+++ new\n" +
+				"@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n" +
+				"@@ -10,3 +10,3 @@\n j\n-k\n+K\n l\n"},
+		{"one hunk", numbered(1, 8, nil), numbered(1, 8, map[int]string{2: "B\n", 5: "E\n"}),
+			"--- old\n+++ new\n@@ -1,6 +1,6 @@\n a\n-b\n+B\n c\n d\n-e\n+E\n f\n"},
+	} {
+		if got := diff.Unified(tc.a, tc.b, "old", "new", 1); got != tc.want {
+			t.Errorf("%s:\n got %q\nwant %q", tc.name, got, tc.want)
+		}
+	}
+}
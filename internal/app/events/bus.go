@@ -0,0 +1,141 @@
+// Package events delivers note changes to the clients listening in this process.
+package events
+
+import (
+	"2/internal/domain/models"
+	"github.com/google/uuid"
+	"slices"
+	"sync"
+	"time"
+)
+
+// сколько событий может ждать медленный подписчик, прежде чем его отключат
+const subscriberBuffer = 64
+
+// Bus publishes note events to subscribers and keeps the latest of them, so that a client
+// that lost its connection can resume from the last event it saw.
+type Bus struct {
+	mu   sync.Mutex
+	size int
+	log  []models.NoteEvent
+	// lastId is the id of the latest event, dropped the id of the latest event
+	// that fell out of the log. Events after dropped can be replayed.
+	lastId  int64
+	dropped int64
+	subs    map[*Subscription]struct{}
+	closed  bool
+}
+
+// NewBus keeps the last size events. Ids start from the current time in microseconds,
+// so ids a client got before a restart are older than anything the new log holds.
+func NewBus(size int) *Bus {
+	start := time.Now().UnixMicro()
+	return &Bus{
+		size:    max(size, 1),
+		lastId:  start,
+		dropped: start,
+		subs:    make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscription receives the events of one user until it is closed.
+type Subscription struct {
+	bus    *Bus
+	userId uuid.UUID
+	events chan models.NoteEvent
+	start  int64
+}
+
+// Start is the id of the latest event when the subscription began, every event on
+// the channel comes after it.
+func (s *Subscription) Start() int64 {
+	return s.start
+}
+
+// Events is closed when the subscription ends: the bus was closed or the subscriber
+// fell too far behind. The client should reconnect and resume.
+func (s *Subscription) Events() <-chan models.NoteEvent {
+	return s.events
+}
+
+// Close stops the subscription, it is safe to call more than once.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.remove(s)
+}
+
+// Publish gives the event the next id, logs it and sends it to the subscribed recipients.
+func (b *Bus) Publish(event models.NoteEvent) models.NoteEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastId++
+	event.ID = b.lastId
+	b.log = append(b.log, event)
+	if len(b.log) > b.size {
+		b.dropped = b.log[0].ID
+		b.log = b.log[1:]
+	}
+
+	for sub := range b.subs {
+		if !slices.Contains(event.Recipients, sub.userId) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			// не ждём медленного клиента: он переподключится и дочитает из журнала
+			b.remove(sub)
+		}
+	}
+	return event
+}
+
+// Subscribe starts delivering the events of the user. With after set it also returns the
+// logged events of the user that came after that id; complete is false when some of them
+// are no longer in the log, the client then has to reload its notes.
+func (b *Bus) Subscribe(userId uuid.UUID, after int64) (sub *Subscription, missed []models.NoteEvent, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub = &Subscription{bus: b, userId: userId, events: make(chan models.NoteEvent, subscriberBuffer), start: b.lastId}
+	if b.closed {
+		close(sub.events)
+		return sub, nil, true
+	}
+	b.subs[sub] = struct{}{}
+
+	if after == 0 {
+		return sub, nil, true
+	}
+	if after < b.dropped || after > b.lastId {
+		return sub, nil, false
+	}
+	for _, event := range b.log {
+		if event.ID > after && slices.Contains(event.Recipients, userId) {
+			missed = append(missed, event)
+		}
+	}
+	return sub, missed, true
+}
+
+// Close ends every subscription, for the server shutdown.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subs {
+		b.remove(sub)
+	}
+}
+
+// remove drops the subscriber and closes its channel, the caller holds the lock.
+func (b *Bus) remove(sub *Subscription) {
+	if _, ok := b.subs[sub]; !ok {
+		return
+	}
+	delete(b.subs, sub)
+	close(sub.events)
+}
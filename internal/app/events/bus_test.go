@@ -0,0 +1,184 @@
+package events_test
+
+import (
+	"2/internal/app/events"
+	"2/internal/domain/models"
+	"github.com/google/uuid"
+	"testing"
+)
+
+func publish(bus *events.Bus, recipients ...uuid.UUID) models.NoteEvent {
+	return bus.Publish(models.NoteEvent{Type: models.EventNoteUpdated, NoteId: uuid.New(), Recipients: recipients})
+}
+
+func ids(list []models.NoteEvent) []int64 {
+	var out []int64
+	for _, event := range list {
+		out = append(out, event.ID)
+	}
+	return out
+}
+
+func sameIds(a, b []models.NoteEvent) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].ID != b[i].ID {
+			return false
+		}
+	}
+	return true
+}
+
+// received takes what is already waiting on the channel without blocking.
+func received(sub *events.Subscription) (list []models.NoteEvent, open bool) {
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				return list, false
+			}
+			list = append(list, event)
+		default:
+			return list, true
+		}
+	}
+}
+
+func TestPublishRecipients(t *testing.T) {
+	bus := events.NewBus(16)
+	alice, bob := uuid.New(), uuid.New()
+
+	aliceSub, _, _ := bus.Subscribe(alice, 0)
+	bobSub, _, _ := bus.Subscribe(bob, 0)
+	defer aliceSub.Close()
+	defer bobSub.Close()
+
+	own := publish(bus, alice)
+	shared := publish(bus, alice, bob)
+	publish(bus)
+
+	if own.ID <= aliceSub.Start() || shared.ID != own.ID+1 {
+		t.Errorf("ids %d, %d after start %d, want growing ids after it", own.ID, shared.ID, aliceSub.Start())
+	}
+	if got, _ := received(aliceSub); !sameIds(got, []models.NoteEvent{own, shared}) {
+		t.Errorf("alice got %v, want %v", ids(got), []int64{own.ID, shared.ID})
+	}
+	if got, _ := received(bobSub); !sameIds(got, []models.NoteEvent{shared}) {
+		t.Errorf("bob got %v, want only the shared %d", ids(got), shared.ID)
+	}
+}
+
+func TestSubscribeResume(t *testing.T) {
+	bus := events.NewBus(16)
+	alice, bob := uuid.New(), uuid.New()
+
+	first := publish(bus, alice)
+	second := publish(bus, alice)
+	publish(bus, bob)
+	third := publish(bus, alice, bob)
+
+	// Last-Event-ID: клиент видел first и дочитывает остальное из журнала
+	sub, missed, complete := bus.Subscribe(alice, first.ID)
+	defer sub.Close()
+	if !complete || !sameIds(missed, []models.NoteEvent{second, third}) {
+		t.Errorf("resume after %d: missed %v, complete %v, want %v and true", first.ID, ids(missed), complete, []int64{second.ID, third.ID})
+	}
+	if sub.Start() != third.ID {
+		t.Errorf("start %d, want the latest id %d", sub.Start(), third.ID)
+	}
+
+	// пропущенные события не приходят повторно в канал, новые приходят
+	next := publish(bus, alice)
+	if got, _ := received(sub); !sameIds(got, []models.NoteEvent{next}) {
+		t.Errorf("channel got %v, want only %d", ids(got), next.ID)
+	}
+
+	if _, missed, complete = bus.Subscribe(alice, next.ID); !complete || len(missed) != 0 {
+		t.Errorf("resume at the latest id: missed %v, complete %v, want nothing and true", ids(missed), complete)
+	}
+	if _, missed, complete = bus.Subscribe(alice, 0); !complete || len(missed) != 0 {
+		t.Errorf("subscribe without Last-Event-ID: missed %v, complete %v, want nothing and true", ids(missed), complete)
+	}
+}
+
+func TestSubscribeDroppedFromLog(t *testing.T) {
+	bus := events.NewBus(2)
+	alice := uuid.New()
+
+	first := publish(bus, alice)
+	second := publish(bus, alice)
+	third := publish(bus, alice)
+	fourth := publish(bus, alice)
+
+	// в журнале остались third и fourth: после second ничего не потеряно, после first — потеряно
+	sub, missed, complete := bus.Subscribe(alice, second.ID)
+	sub.Close()
+	if !complete || !sameIds(missed, []models.NoteEvent{third, fourth}) {
+		t.Errorf("resume after %d: missed %v, complete %v, want %v and true", second.ID, ids(missed), complete, []int64{third.ID, fourth.ID})
+	}
+
+	for name, after := range map[string]int64{
+		"id dropped from the log": first.ID,
+		"id older than the bus":   first.ID - 100,
+		"id from the future":      fourth.ID + 1,
+	} {
+		sub, missed, complete = bus.Subscribe(alice, after)
+		sub.Close()
+		if complete || len(missed) != 0 {
+			t.Errorf("%s: missed %v, complete %v, want nothing and false", name, ids(missed), complete)
+		}
+	}
+}
+
+func TestSlowSubscriberDropped(t *testing.T) {
+	bus := events.NewBus(256)
+	slow, fast := uuid.New(), uuid.New()
+
+	slowSub, _, _ := bus.Subscribe(slow, 0)
+	fastSub, _, _ := bus.Subscribe(fast, 0)
+	defer slowSub.Close()
+	defer fastSub.Close()
+
+	// медленный клиент не читает, его буфер переполняется
+	for i := 0; i < 100; i++ {
+		publish(bus, slow)
+		publish(bus, fast)
+		if got, open := received(fastSub); !open || len(got) != 1 {
+			t.Fatalf("fast subscriber: got %d events, open %v, want 1 and open", len(got), open)
+		}
+	}
+
+	got, open := received(slowSub)
+	if open {
+		t.Fatalf("slow subscriber is still open after %d events", len(got))
+	}
+	if len(got) == 0 || len(got) >= 100 {
+		t.Fatalf("slow subscriber got %d events before it was dropped, want the buffered ones", len(got))
+	}
+
+	// переподключившись, клиент дочитывает остальное из журнала
+	sub, missed, complete := bus.Subscribe(slow, got[len(got)-1].ID)
+	defer sub.Close()
+	if !complete || len(got)+len(missed) != 100 {
+		t.Errorf("resume: %d missed after %d received, complete %v, want 100 in all and true", len(missed), len(got), complete)
+	}
+}
+
+func TestCloseBus(t *testing.T) {
+	bus := events.NewBus(16)
+	alice := uuid.New()
+
+	sub, _, _ := bus.Subscribe(alice, 0)
+	bus.Close()
+	if _, open := received(sub); open {
+		t.Errorf("subscription is open after the bus was closed")
+	}
+	sub.Close()
+
+	late, _, _ := bus.Subscribe(alice, 0)
+	if _, open := received(late); open {
+		t.Errorf("subscription to a closed bus is open")
+	}
+}
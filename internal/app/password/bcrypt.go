@@ -0,0 +1,46 @@
+package password
+
+import (
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BcryptMaxBytes is the longest password bcrypt hashes, it refuses longer ones.
+const BcryptMaxBytes = 72
+
+// Bcrypt hashes passwords with bcrypt. Accounts created before Argon2id have such hashes.
+type Bcrypt struct {
+	cost int
+}
+
+func NewBcrypt(cost int) (*Bcrypt, error) {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return nil, errors.New("bcrypt cost is out of range")
+	}
+	return &Bcrypt{cost: cost}, nil
+}
+
+func (b *Bcrypt) Accepts(id string) bool {
+	return id == "2a" || id == "2b" || id == "2y"
+}
+
+func (b *Bcrypt) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.cost)
+	return string(hash), err
+}
+
+func (b *Bcrypt) Verify(password string, encoded string) (bool, bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+
+	cost, err := bcrypt.Cost([]byte(encoded))
+	if err != nil {
+		return false, false, err
+	}
+	return true, cost == b.cost, nil
+}
@@ -0,0 +1,64 @@
+// Package password hashes and checks user passwords. Hashes name their algorithm and
+// parameters, so hashes made with older settings keep working and can be upgraded.
+package password
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrUnknownHash = errors.New("password hash has an unknown format")
+
+// Algorithm is one way of hashing passwords.
+type Algorithm interface {
+	// Accepts reports whether the hash with the PHC identifier id, e.g. "argon2id" or "2b", is of this algorithm
+	Accepts(id string) bool
+	Hash(password string) (string, error)
+	// Verify checks the password against a hash of this algorithm. current is false when the hash
+	// was made with other parameters than the algorithm is configured with now.
+	Verify(password string, encoded string) (ok bool, current bool, err error)
+}
+
+// Hasher hashes new passwords with the current algorithm and verifies hashes of any known one.
+type Hasher struct {
+	current Algorithm
+	known   []Algorithm
+}
+
+// NewHasher returns a hasher that hashes with current and also verifies hashes of legacy algorithms.
+func NewHasher(current Algorithm, legacy ...Algorithm) *Hasher {
+	return &Hasher{
+		current: current,
+		known:   append([]Algorithm{current}, legacy...),
+	}
+}
+
+func (h *Hasher) Hash(password string) (string, error) {
+	return h.current.Hash(password)
+}
+
+// Verify checks the password. rehash is true when the password is right but its hash was
+// made with another algorithm or other parameters, the caller should then store a new hash.
+func (h *Hasher) Verify(password string, encoded string) (ok bool, rehash bool, err error) {
+	id := hashID(encoded)
+	for _, algorithm := range h.known {
+		if !algorithm.Accepts(id) {
+			continue
+		}
+		ok, current, err := algorithm.Verify(password, encoded)
+		if err != nil || !ok {
+			return false, false, err
+		}
+		return true, algorithm != h.current || !current, nil
+	}
+	return false, false, ErrUnknownHash
+}
+
+// hashID returns the identifier between the first two $ of a PHC or crypt(3) style hash.
+func hashID(encoded string) string {
+	if !strings.HasPrefix(encoded, "$") {
+		return ""
+	}
+	id, _, _ := strings.Cut(encoded[1:], "$")
+	return id
+}
@@ -0,0 +1,166 @@
+package password_test
+
+import (
+	"2/internal/app/password"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var cheapArgon2id = password.Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func newArgon2id(t *testing.T, params password.Argon2idParams) *password.Argon2id {
+	t.Helper()
+
+	argon, err := password.NewArgon2id(params)
+	if err != nil {
+		t.Fatalf("argon2id: %v", err)
+	}
+	return argon
+}
+
+func newBcrypt(t *testing.T, cost int) *password.Bcrypt {
+	t.Helper()
+
+	b, err := password.NewBcrypt(cost)
+	if err != nil {
+		t.Fatalf("bcrypt: %v", err)
+	}
+	return b
+}
+
+func hash(t *testing.T, algorithm interface{ Hash(string) (string, error) }, plain string) string {
+	t.Helper()
+
+	encoded, err := algorithm.Hash(plain)
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	return encoded
+}
+
+func TestHasherRehash(t *testing.T) {
+	argon := newArgon2id(t, cheapArgon2id)
+	stronger := cheapArgon2id
+	stronger.Iterations = 2
+	hasher := password.NewHasher(argon, newBcrypt(t, 5))
+
+	for _, tc := range []struct {
+		name   string
+		hash   string
+		rehash bool
+	}{
+		{"current argon2id", hash(t, argon, "secret"), false},
+		{"argon2id with other parameters", hash(t, newArgon2id(t, stronger), "secret"), true},
+		{"legacy bcrypt", hash(t, newBcrypt(t, 5), "secret"), true},
+		{"legacy bcrypt with another cost", hash(t, newBcrypt(t, 4), "secret"), true},
+	} {
+		ok, rehash, err := hasher.Verify("secret", tc.hash)
+		if err != nil || !ok || rehash != tc.rehash {
+			t.Errorf("%s: ok=%v rehash=%v err=%v, want ok and rehash=%v", tc.name, ok, rehash, err, tc.rehash)
+		}
+		// неверный пароль никогда не просит перехэширования
+		if ok, rehash, err = hasher.Verify("wrong", tc.hash); err != nil || ok || rehash {
+			t.Errorf("%s with a wrong password: ok=%v rehash=%v err=%v", tc.name, ok, rehash, err)
+		}
+	}
+
+	if !strings.HasPrefix(hash(t, hasher, "secret"), "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("hasher does not hash with the current algorithm")
+	}
+	if _, _, err := hasher.Verify("secret", "plain text"); !errors.Is(err, password.ErrUnknownHash) {
+		t.Errorf("hash of unknown format: got %v, want ErrUnknownHash", err)
+	}
+	// без bcrypt в списке старые хэши не принимаются
+	if _, _, err := password.NewHasher(argon).Verify("secret", hash(t, newBcrypt(t, 4), "secret")); !errors.Is(err, password.ErrUnknownHash) {
+		t.Errorf("bcrypt hash without the legacy algorithm: got %v, want ErrUnknownHash", err)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	breached := "hunter2-but-longer"
+	sum := sha1.Sum([]byte("correct horse battery"))
+	list := filepath.Join(t.TempDir(), "pwned.txt")
+	content := "# downloaded list\r\n" +
+		strings.ToUpper(hex.EncodeToString(sum[:])) + ":3303003\r\n" +
+		breached + "\n"
+	if err := os.WriteFile(list, []byte(content), 0o600); err != nil {
+		t.Fatalf("write list: %v", err)
+	}
+
+	policy, err := password.NewPolicy(8, 20, 2, list)
+	if err != nil {
+		t.Fatalf("policy: %v", err)
+	}
+
+	for _, tc := range []struct {
+		password string
+		personal []string
+		ok       bool
+	}{
+		{"Tr0ub4dor", nil, true},
+		{"пароль из слов 7", nil, true},
+		{"Sh0rt", nil, false},
+		{"Much-too-long-password-1", nil, false},
+		{"onlylowercase", nil, false},
+		{"Ann-Smith-2024", []string{"ann.smith@notes.test"}, true},
+		{"ANN.SMITH-2024", []string{"Ann.Smith@notes.test"}, false},
+		{"Bobby-tables-9", []string{"x@notes.test", "bobby"}, false},
+		// слишком короткие личные данные не проверяются
+		{"Al-is-here-77", []string{"al@notes.test", "al"}, true},
+		{"Password1", nil, false},
+		{"correct horse battery", nil, false},
+		{breached, nil, false},
+	} {
+		err := policy.Validate(tc.password, tc.personal...)
+		if tc.ok && err != nil {
+			t.Errorf("%q %v: %v", tc.password, tc.personal, err)
+		}
+		if !tc.ok && !errors.Is(err, password.ErrWeakPassword) {
+			t.Errorf("%q %v: got %v, want ErrWeakPassword", tc.password, tc.personal, err)
+		}
+	}
+
+	if _, err = password.NewPolicy(8, 0, 0, filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Errorf("policy with a missing breached list: got no error")
+	}
+}
+
+// bcrypt refuses passwords over 72 bytes, a policy for it counts bytes and not letters.
+func TestPolicyMaxBytes(t *testing.T) {
+	policy, err := password.NewPolicy(8, 128, 0, "")
+	if err != nil {
+		t.Fatalf("policy: %v", err)
+	}
+	policy.MaxBytes = password.BcryptMaxBytes
+	bcryptHasher := newBcrypt(t, 4)
+
+	for _, tc := range []struct {
+		password string
+		ok       bool
+	}{
+		{strings.Repeat("a1", 36), true},
+		{strings.Repeat("a1", 36) + "b", false},
+		// 36 кириллических букв — ровно 72 байта, 37 уже не помещаются
+		{strings.Repeat("пя", 18), true},
+		{strings.Repeat("пя", 18) + "ь", false},
+	} {
+		err := policy.Validate(tc.password)
+		if !tc.ok {
+			if !errors.Is(err, password.ErrWeakPassword) {
+				t.Errorf("%d bytes: got %v, want ErrWeakPassword", len(tc.password), err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d bytes: %v", len(tc.password), err)
+		}
+		if _, err = bcryptHasher.Hash(tc.password); err != nil {
+			t.Errorf("bcrypt of an accepted password of %d bytes: %v", len(tc.password), err)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrWeakPassword = errors.New("password is too weak")
+
+//go:embed common-passwords.txt
+var commonPasswords string
+
+// Policy decides which new passwords are acceptable.
+type Policy struct {
+	MinLength int
+	// MaxLength bounds the work of hashing, zero means no limit
+	MaxLength int
+	// MaxBytes is the input limit of the hashing algorithm in UTF-8 bytes, zero means none
+	MaxBytes int
+	// MinClasses is how many of lowercase, uppercase, digits and symbols a password must mix
+	MinClasses int
+
+	breached map[[sha1.Size]byte]struct{}
+}
+
+// NewPolicy returns a policy that also refuses the passwords of a breached list at
+// breachedList, if set. Its lines are either passwords or SHA-1 hashes in hex, optionally
+// followed by ":count" as in the Have I Been Pwned downloads. Lines starting with # are skipped.
+func NewPolicy(minLength int, maxLength int, minClasses int, breachedList string) (*Policy, error) {
+	p := &Policy{
+		MinLength:  minLength,
+		MaxLength:  maxLength,
+		MinClasses: minClasses,
+		breached:   make(map[[sha1.Size]byte]struct{}),
+	}
+
+	if err := p.loadBreached(strings.NewReader(commonPasswords)); err != nil {
+		return nil, err
+	}
+	if breachedList != "" {
+		f, err := os.Open(breachedList)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if err = p.loadBreached(f); err != nil {
+			return nil, fmt.Errorf("breached password list %s: %w", breachedList, err)
+		}
+	}
+	return p, nil
+}
+
+func (p *Policy) loadBreached(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if sum, ok := parseSHA1(line); ok {
+			p.breached[sum] = struct{}{}
+			continue
+		}
+		p.breached[sha1.Sum([]byte(line))] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+// parseSHA1 reads a line of a Have I Been Pwned list: "<40 hex digits>[:count]".
+func parseSHA1(line string) ([sha1.Size]byte, bool) {
+	var sum [sha1.Size]byte
+	digits, _, _ := strings.Cut(line, ":")
+	if len(digits) != 2*sha1.Size {
+		return sum, false
+	}
+	if _, err := hex.Decode(sum[:], []byte(digits)); err != nil {
+		return sum, false
+	}
+	return sum, true
+}
+
+// Validate checks a new password. personal are the email, username and the like,
+// a password containing them is easy to guess.
+func (p *Policy) Validate(password string, personal ...string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return fmt.Errorf("%w: it must be at least %d characters long", ErrWeakPassword, p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return fmt.Errorf("%w: it must be at most %d characters long", ErrWeakPassword, p.MaxLength)
+	}
+	if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		return fmt.Errorf("%w: it must be at most %d bytes long, letters outside ASCII take several", ErrWeakPassword, p.MaxBytes)
+	}
+
+	if classes := characterClasses(password); classes < p.MinClasses {
+		return fmt.Errorf("%w: it must mix at least %d of lowercase letters, uppercase letters, digits and symbols", ErrWeakPassword, p.MinClasses)
+	}
+
+	lower := strings.ToLower(password)
+	for _, value := range personal {
+		// у email сравниваем только часть до @
+		value, _, _ = strings.Cut(strings.ToLower(strings.TrimSpace(value)), "@")
+		if utf8.RuneCountInString(value) >= 3 && strings.Contains(lower, value) {
+			return fmt.Errorf("%w: it must not contain your email or username", ErrWeakPassword)
+		}
+	}
+
+	if _, found := p.breached[sha1.Sum([]byte(password))]; found {
+		return fmt.Errorf("%w: it appears in a list of leaked passwords, choose another one", ErrWeakPassword)
+	}
+	return nil
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+
+	classes := 0
+	for _, has := range []bool{lower, upper, digit, other} {
+		if has {
+			classes++
+		}
+	}
+	return classes
+}
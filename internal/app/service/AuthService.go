@@ -2,22 +2,64 @@ package service
 
 import (
 	"2/internal/domain/models"
-	"2/internal/infrastructure/storage"
+	"2/internal/domain/repository"
 	"2/internal/interface/http/dto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
+	"log/slog"
+	"sort"
+	"sync"
 	"time"
 )
 
+var (
+	ErrInvalidRefreshToken = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used, the session is revoked")
+	ErrSessionRevoked      = errors.New("session is revoked or expired, log in again")
+	ErrInvalidMFAToken     = errors.New("mfa token is invalid or expired, log in again")
+	ErrAccountDisabled     = errors.New("account is disabled")
+)
+
+// Значения claim typ. Токен без typ или с другим typ не принимается там, где ждут access.
+const (
+	accessToken     = "access"
+	mfaPendingToken = "mfa_pending"
+)
+
+const mfaTokenTTL = 5 * time.Minute
+
 type AuthService struct {
-	UserRepo *storage.UserRepository
-	Secret   string
+	UserRepo      repository.UserRepository
+	SessionRepo   repository.SessionsRepository
+	TwoFactorRepo repository.TwoFactorRepository
+	// RequireVerifiedEmail refuses to log in users who have not confirmed their email yet
+	RequireVerifiedEmail bool
+	tokens               *TokenSigner
+	passwords            *Passwords
+	guard                *LoginGuard
+	policy               models.TokenPolicy
+
+	// last_seen копится здесь и пишется в базу пачкой из FlushLastSeen, а не на каждый запрос
+	seenMu sync.Mutex
+	seen   map[uuid.UUID]time.Time
 }
 
-func NewAuthService(userRepo *storage.UserRepository, secret string) *AuthService {
-	return &AuthService{UserRepo: userRepo, Secret: secret}
+func NewAuthService(userRepo repository.UserRepository, sessionRepo repository.SessionsRepository, twoFactorRepo repository.TwoFactorRepository, tokens *TokenSigner, passwords *Passwords, guard *LoginGuard, policy models.TokenPolicy) *AuthService {
+	return &AuthService{
+		UserRepo:      userRepo,
+		SessionRepo:   sessionRepo,
+		TwoFactorRepo: twoFactorRepo,
+		tokens:        tokens,
+		passwords:     passwords,
+		guard:         guard,
+		policy:        policy,
+		seen:          make(map[uuid.UUID]time.Time),
+	}
 }
 
 func (s *AuthService) RegisterUser(req dto.RegistrationRequest) error {
@@ -34,42 +76,433 @@ func (s *AuthService) RegisterUser(req dto.RegistrationRequest) error {
 		UserId:   uuid.New(),
 		Username: req.Username,
 		Email:    req.Email,
-		Password: req.Password,
+		Role:     models.RoleUser,
 		Created:  time.Now(),
 	}
-	user.HashPassword()
+	if err := s.passwords.Set(&user, req.Password); err != nil {
+		return err
+	}
 
 	return s.UserRepo.Create(user)
 
 }
 
-// Исправляем функцию LoginUser для сохранения UUID как строки в токене
-func (s *AuthService) LoginUser(req dto.LoginRequest) (string, error) {
-	user, exsists, err := s.UserRepo.GetUserByEmail(req.Email)
+// LoginUser checks the password. Unknown emails and wrong passwords get the same
+// ErrInvalidCredentials, repeated failures are delayed and locked out by the guard.
+func (s *AuthService) LoginUser(req dto.LoginRequest, client models.ClientInfo) (dto.AuthResponse, error) {
+	if err := s.guard.Check(req.Email, client.IP); err != nil {
+		return dto.AuthResponse{}, err
+	}
+
+	user, exists, err := s.UserRepo.GetUserByEmail(req.Email)
+	if err != nil {
+		return dto.AuthResponse{}, err
+	}
+
+	var ok, rehash bool
+	if exists {
+		ok, rehash = s.passwords.Check(user, req.Password)
+	} else {
+		// неизвестный email проверяется так же долго, иначе его видно по времени ответа
+		s.passwords.CheckNobody(req.Password)
+	}
+	if !ok {
+		if err = s.guard.Fail(req.Email, client.IP); err != nil {
+			return dto.AuthResponse{}, err
+		}
+		return dto.AuthResponse{}, ErrInvalidCredentials
+	}
+
+	if err = s.guard.Succeed(req.Email); err != nil {
+		return dto.AuthResponse{}, err
+	}
+	if rehash {
+		s.upgradePassword(user.UserId, req.Password)
+	}
+
+	if s.RequireVerifiedEmail && !user.EmailVerified {
+		return dto.AuthResponse{}, ErrEmailNotVerified
+	}
+
+	return s.completeLogin(user, client)
+}
+
+// upgradePassword replaces a hash made with an older algorithm or parameters. The login
+// goes on if it fails, the old hash still works.
+func (s *AuthService) upgradePassword(userId uuid.UUID, plain string) {
+	hash, err := s.passwords.Rehash(plain)
+	if err == nil {
+		err = s.UserRepo.UpdatePassword(userId, hash)
+	}
+	if err != nil {
+		slog.Error("Failed to upgrade password hash", "user", userId, "error", err)
+	}
+}
+
+// completeLogin starts a session for a user who proved who they are, with a password
+// or at an identity provider. With two-factor authentication that only earns the right
+// to enter the code.
+func (s *AuthService) completeLogin(user models.User, client models.ClientInfo) (dto.AuthResponse, error) {
+	if user.Disabled() {
+		return dto.AuthResponse{}, ErrAccountDisabled
+	}
+
+	current, err := s.TwoFactorRepo.GetTOTP(user.UserId)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return dto.AuthResponse{}, err
+	}
+	if err == nil && current.Enabled {
+		return s.issueMFAToken(user.UserId)
+	}
+
+	return s.startSession(user, client)
+}
+
+// LoginSecondFactor finishes a login of a user with two-factor authentication,
+// code is either a TOTP code or a recovery code. The mfa token works for one login.
+func (s *AuthService) LoginSecondFactor(req dto.LoginTwoFactorRequest, client models.ClientInfo) (dto.AuthResponse, error) {
+	claims, err := s.tokens.Parse(req.MFAToken, mfaPendingToken)
+	if err != nil {
+		return dto.AuthResponse{}, ErrInvalidMFAToken
+	}
+	userIdStr, _ := claims["user_id"].(string)
+	userId, err := uuid.Parse(userIdStr)
+	if err != nil {
+		return dto.AuthResponse{}, ErrInvalidMFAToken
+	}
+	jti, _ := claims["jti"].(string)
+	tokenId, err := uuid.Parse(jti)
+	if err != nil {
+		return dto.AuthResponse{}, ErrInvalidMFAToken
+	}
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil || expiresAt == nil {
+		return dto.AuthResponse{}, ErrInvalidMFAToken
+	}
+
+	current, err := s.TwoFactorRepo.GetTOTP(userId)
+	if errors.Is(err, repository.ErrNotFound) || err == nil && !current.Enabled {
+		return dto.AuthResponse{}, ErrInvalidMFAToken
+	}
+	if err != nil {
+		return dto.AuthResponse{}, err
+	}
+
+	// код из шести цифр подбирается быстрее пароля, неудачи считаются вместе с паролем
+	user, err := s.UserRepo.GetUserById(userId)
+	if err != nil {
+		return dto.AuthResponse{}, err
+	}
+	if err = s.guard.Check(user.Email, client.IP); err != nil {
+		return dto.AuthResponse{}, err
+	}
+
+	if err = verifySecondFactor(s.TwoFactorRepo, current, req.Code); err != nil {
+		if errors.Is(err, ErrInvalidCode) {
+			if ferr := s.guard.Fail(user.Email, client.IP); ferr != nil {
+				return dto.AuthResponse{}, ferr
+			}
+		}
+		return dto.AuthResponse{}, err
+	}
+	if err = s.guard.Succeed(user.Email); err != nil {
+		return dto.AuthResponse{}, err
+	}
+	// аккаунт могли отключить, пока вводили код
+	if user.Disabled() {
+		return dto.AuthResponse{}, ErrAccountDisabled
+	}
+	// токен годится на один вход, как refresh-токен: повтор до истечения новой сессии не даёт
+	err = s.TwoFactorRepo.UseMFAToken(tokenId, expiresAt.Time)
+	if errors.Is(err, repository.ErrNotFound) {
+		return dto.AuthResponse{}, ErrInvalidMFAToken
+	}
+	if err != nil {
+		return dto.AuthResponse{}, err
+	}
+
+	return s.startSession(user, client)
+}
+
+func (s *AuthService) startSession(user models.User, client models.ClientInfo) (dto.AuthResponse, error) {
+	now := time.Now()
+	session := models.Session{
+		ID:         uuid.New(),
+		UserId:     user.UserId,
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(s.policy.RefreshTTL),
+	}
+
+	refreshToken, token, err := s.newRefreshToken(session.ID, now)
+	if err != nil {
+		return dto.AuthResponse{}, err
+	}
 
-	if !exsists {
-		return "", errors.New("There is no user with this email")
+	if err = s.SessionRepo.Create(session, token); err != nil {
+		return dto.AuthResponse{}, err
 	}
 
-	//переписать под валидацию данных
+	return s.issueTokens(user, session.ID, refreshToken, now)
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token pair. Every refresh
+// token works once: presenting a used one means it leaked, so the whole session is revoked.
+func (s *AuthService) Refresh(req dto.RefreshRequest) (dto.AuthResponse, error) {
+	now := time.Now()
+
+	old, err := s.SessionRepo.GetRefreshToken(hashToken(req.RefreshToken))
+	if errors.Is(err, repository.ErrNotFound) {
+		return dto.AuthResponse{}, ErrInvalidRefreshToken
+	}
 	if err != nil {
-		return "", errors.New("Invalid email")
+		return dto.AuthResponse{}, err
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password))
+	if old.UsedAt != nil {
+		return dto.AuthResponse{}, s.revokeReused(old.SessionId, now)
+	}
+	if now.After(old.ExpiresAt) {
+		return dto.AuthResponse{}, ErrInvalidRefreshToken
+	}
+
+	session, err := s.SessionRepo.Get(old.SessionId)
 	if err != nil {
-		return "", errors.New("Invalid password")
+		return dto.AuthResponse{}, err
+	}
+	if !session.Active(now) {
+		return dto.AuthResponse{}, ErrInvalidRefreshToken
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	// роль берётся заново на каждый refresh, в новом access-токене она уже актуальная
+	user, err := s.UserRepo.GetUserById(session.UserId)
+	if err != nil {
+		return dto.AuthResponse{}, err
+	}
+	if user.Disabled() {
+		return dto.AuthResponse{}, ErrAccountDisabled
+	}
+
+	refreshToken, token, err := s.newRefreshToken(session.ID, now)
+	if err != nil {
+		return dto.AuthResponse{}, err
+	}
+
+	err = s.SessionRepo.Rotate(old.ID, token)
+	// параллельный refresh тем же токеном успел раньше
+	if errors.Is(err, repository.ErrNotFound) {
+		return dto.AuthResponse{}, s.revokeReused(session.ID, now)
+	}
+	if err != nil {
+		return dto.AuthResponse{}, err
+	}
+	s.markSeen(session.ID, now)
+
+	return s.issueTokens(user, session.ID, refreshToken, now)
+}
+
+func (s *AuthService) revokeReused(sessionId uuid.UUID, now time.Time) error {
+	if err := s.SessionRepo.Revoke(sessionId, now); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// Logout revokes the session the request was made with.
+func (s *AuthService) Logout(sessionId uuid.UUID) error {
+	return s.SessionRepo.Revoke(sessionId, time.Now())
+}
+
+// LogoutAll revokes every session of the user, including the current one.
+func (s *AuthService) LogoutAll(userId uuid.UUID) error {
+	return s.SessionRepo.RevokeAllByUserId(userId, time.Now())
+}
+
+// CheckSession is called for every authenticated request with the claims of its access token.
+func (s *AuthService) CheckSession(userId uuid.UUID, sessionId uuid.UUID) error {
+	session, err := s.SessionRepo.Get(sessionId)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrSessionRevoked
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if session.UserId != userId || !session.Active(now) {
+		return ErrSessionRevoked
+	}
+
+	s.markSeen(sessionId, now)
+	return nil
+}
+
+// CheckAccount is called for every authenticated request, with an access or a personal
+// access token, and turns away users an admin has disabled.
+func (s *AuthService) CheckAccount(userId uuid.UUID) error {
+	user, err := s.UserRepo.GetUserById(userId)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrSessionRevoked
+	}
+	if err != nil {
+		return err
+	}
+	if user.Disabled() {
+		return ErrAccountDisabled
+	}
+	return nil
+}
+
+func (s *AuthService) markSeen(sessionId uuid.UUID, at time.Time) {
+	s.seenMu.Lock()
+	s.seen[sessionId] = at
+	s.seenMu.Unlock()
+}
+
+// FlushLastSeen writes the activity collected since the previous flush.
+func (s *AuthService) FlushLastSeen() (int64, error) {
+	s.seenMu.Lock()
+	seen := s.seen
+	s.seen = make(map[uuid.UUID]time.Time)
+	s.seenMu.Unlock()
+
+	if err := s.SessionRepo.Touch(seen); err != nil {
+		// вернём обратно, чтобы не потерять до следующей попытки
+		s.seenMu.Lock()
+		for id, at := range seen {
+			if at.After(s.seen[id]) {
+				s.seen[id] = at
+			}
+		}
+		s.seenMu.Unlock()
+		return 0, err
+	}
+	return int64(len(seen)), nil
+}
+
+// GetSessions lists active sessions of the user, current is the session of the request.
+func (s *AuthService) GetSessions(userId uuid.UUID, current uuid.UUID) ([]dto.SessionResponse, error) {
+	sessions, err := s.SessionRepo.GetAllByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	s.seenMu.Lock()
+	defer s.seenMu.Unlock()
+
+	now := time.Now()
+	resp := []dto.SessionResponse{}
+	for _, session := range sessions {
+		if !session.Active(now) {
+			continue
+		}
+		lastSeen := session.LastSeenAt
+		if at, ok := s.seen[session.ID]; ok && at.After(lastSeen) {
+			lastSeen = at
+		}
+		resp = append(resp, dto.SessionResponse{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: lastSeen,
+			Current:    session.ID == current,
+		})
+	}
+	sort.Slice(resp, func(i, j int) bool {
+		return resp[i].LastSeenAt.After(resp[j].LastSeenAt)
+	})
+
+	return resp, nil
+}
+
+// RevokeSession logs the user out on one device.
+func (s *AuthService) RevokeSession(userId uuid.UUID, sessionId uuid.UUID) error {
+	session, err := s.SessionRepo.Get(sessionId)
+	if err != nil {
+		return err
+	}
+	if session.UserId != userId || session.RevokedAt != nil {
+		return repository.ErrNotFound
+	}
+
+	return s.SessionRepo.Revoke(sessionId, time.Now())
+}
+
+// PurgeSessions removes sessions that can no longer be used, together with the ids of
+// used mfa tokens that have expired.
+func (s *AuthService) PurgeSessions() (int64, error) {
+	now := time.Now()
+	// отозванные держим ещё один refresh TTL, чтобы повторное использование токена было видно как reuse
+	sessions, err := s.SessionRepo.DeleteExpired(now.Add(-s.policy.RefreshTTL))
+	if err != nil {
+		return sessions, err
+	}
+	tokens, err := s.TwoFactorRepo.DeleteUsedMFATokens(now)
+	return sessions + tokens, err
+}
+
+func (s *AuthService) issueTokens(user models.User, sessionId uuid.UUID, refreshToken string, now time.Time) (dto.AuthResponse, error) {
+	tokenString, err := s.tokens.Sign(jwt.MapClaims{
+		"typ":     accessToken,
 		"user_id": user.UserId.String(),
-		"exp":     time.Now().Add(time.Hour * 72).Unix(),
+		"role":    user.Role,
+		"sid":     sessionId.String(),
+		"jti":     uuid.NewString(),
+		"iat":     now.Unix(),
+		"exp":     now.Add(s.policy.AccessTTL).Unix(),
 	})
+	if err != nil {
+		return dto.AuthResponse{}, err
+	}
 
-	tokenString, err := token.SignedString([]byte(s.Secret))
+	return dto.AuthResponse{
+		Token:        tokenString,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.policy.AccessTTL.Seconds()),
+	}, nil
+}
+
+// issueMFAToken returns the short-lived token that only LoginSecondFactor accepts.
+func (s *AuthService) issueMFAToken(userId uuid.UUID) (dto.AuthResponse, error) {
+	now := time.Now()
+	tokenString, err := s.tokens.Sign(jwt.MapClaims{
+		"typ":     mfaPendingToken,
+		"user_id": userId.String(),
+		"jti":     uuid.NewString(),
+		"iat":     now.Unix(),
+		"exp":     now.Add(mfaTokenTTL).Unix(),
+	})
 	if err != nil {
-		return "", err
+		return dto.AuthResponse{}, err
 	}
 
-	return tokenString, nil
+	return dto.AuthResponse{
+		MFARequired: true,
+		MFAToken:    tokenString,
+	}, nil
+}
+
+// newRefreshToken returns a random token for the client and the record that keeps only its hash.
+func (s *AuthService) newRefreshToken(sessionId uuid.UUID, now time.Time) (string, models.RefreshToken, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", models.RefreshToken{}, err
+	}
+	value := base64.RawURLEncoding.EncodeToString(raw)
+
+	return value, models.RefreshToken{
+		ID:        uuid.New(),
+		SessionId: sessionId,
+		TokenHash: hashToken(value),
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.policy.RefreshTTL),
+	}, nil
+}
+
+func hashToken(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
 }
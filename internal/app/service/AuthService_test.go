@@ -0,0 +1,421 @@
+package service_test
+
+import (
+	"2/internal/app/password"
+	"2/internal/app/service"
+	"2/internal/app/signing"
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"2/internal/infrastructure/storage/memory"
+	"2/internal/interface/http/dto"
+	"errors"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// authEnv is the login stack on memory repositories with cheap Argon2id parameters and
+// bcrypt as the legacy algorithm.
+type authEnv struct {
+	users     repository.UserRepository
+	sessions  repository.SessionsRepository
+	twoFactor repository.TwoFactorRepository
+	tokens    *service.TokenSigner
+	passwords *service.Passwords
+	guard     *service.LoginGuard
+	auth      *service.AuthService
+}
+
+var defaultLoginPolicy = models.LoginPolicy{
+	Account:         models.LoginLimit{FreeAttempts: 5, LockoutAfter: 10},
+	BaseDelay:       time.Second,
+	MaxDelay:        time.Minute,
+	LockoutDuration: time.Minute,
+	ResetAfter:      time.Hour,
+}
+
+func newAuthEnv(t *testing.T, loginPolicy models.LoginPolicy) *authEnv {
+	t.Helper()
+
+	keys, err := signing.NewKeyring(memory.NewSigningKeysRepository(), "test-secret", signing.Policy{
+		Algorithm: signing.EdDSA,
+		Rotation:  time.Hour,
+		Grace:     time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("keyring: %v", err)
+	}
+
+	// сессии и второй фактор удаляются вместе с пользователем, как в базе
+	sessions := memory.NewSessionsRepository()
+	twoFactor := memory.NewTwoFactorRepository()
+
+	argon, err := password.NewArgon2id(password.Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+	if err != nil {
+		t.Fatalf("argon2id: %v", err)
+	}
+	legacy, err := password.NewBcrypt(bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt: %v", err)
+	}
+	passwordPolicy, err := password.NewPolicy(8, 128, 0, "")
+	if err != nil {
+		t.Fatalf("password policy: %v", err)
+	}
+
+	env := &authEnv{
+		users:     memory.NewUserRepository(sessions, twoFactor),
+		sessions:  sessions,
+		twoFactor: twoFactor,
+		tokens:    service.NewTokenSigner(keys),
+		passwords: service.NewPasswords(password.NewHasher(argon, legacy), passwordPolicy),
+		guard:     service.NewLoginGuard(memory.NewLoginGuardRepository(), loginPolicy),
+	}
+	env.auth = service.NewAuthService(env.users, env.sessions, env.twoFactor, env.tokens, env.passwords, env.guard, models.TokenPolicy{
+		AccessTTL:  time.Minute,
+		RefreshTTL: time.Hour,
+	})
+	return env
+}
+
+func (e *authEnv) register(t *testing.T, email string, plain string) uuid.UUID {
+	t.Helper()
+
+	if err := e.auth.RegisterUser(dto.RegistrationRequest{Email: email, Username: "user", Password: plain}); err != nil {
+		t.Fatalf("register %s: %v", email, err)
+	}
+	user, _, err := e.users.GetUserByEmail(email)
+	if err != nil {
+		t.Fatalf("get %s: %v", email, err)
+	}
+	return user.UserId
+}
+
+func (e *authEnv) login(t *testing.T, email string, plain string) dto.AuthResponse {
+	t.Helper()
+
+	resp, err := e.auth.LoginUser(dto.LoginRequest{Email: email, Password: plain}, models.ClientInfo{IP: "192.0.2.1"})
+	if err != nil {
+		t.Fatalf("login %s: %v", email, err)
+	}
+	return resp
+}
+
+// session returns the session id the access token was issued for.
+func (e *authEnv) session(t *testing.T, resp dto.AuthResponse) uuid.UUID {
+	t.Helper()
+
+	claims, err := e.tokens.Parse(resp.Token, "access")
+	if err != nil {
+		t.Fatalf("parse access token: %v", err)
+	}
+	sid, _ := claims["sid"].(string)
+	id, err := uuid.Parse(sid)
+	if err != nil {
+		t.Fatalf("sid %q: %v", sid, err)
+	}
+	return id
+}
+
+func (e *authEnv) refresh(token string) (dto.AuthResponse, error) {
+	return e.auth.Refresh(dto.RefreshRequest{RefreshToken: token})
+}
+
+func TestRefreshRotation(t *testing.T) {
+	env := newAuthEnv(t, defaultLoginPolicy)
+	userId := env.register(t, "ann@notes.test", "long password")
+	first := env.login(t, "ann@notes.test", "long password")
+	sid := env.session(t, first)
+
+	second, err := env.refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken || second.Token == first.Token {
+		t.Fatalf("refresh returned the same tokens")
+	}
+	if got := env.session(t, second); got != sid {
+		t.Errorf("refreshed token is of session %s, want %s", got, sid)
+	}
+	third, err := env.refresh(second.RefreshToken)
+	if err != nil {
+		t.Fatalf("second refresh: %v", err)
+	}
+
+	if _, err = env.refresh("made-up"); !errors.Is(err, service.ErrInvalidRefreshToken) {
+		t.Errorf("unknown token: got %v, want ErrInvalidRefreshToken", err)
+	}
+	if err = env.auth.CheckSession(userId, sid); err != nil {
+		t.Errorf("session after rotation: %v", err)
+	}
+	if _, err = env.refresh(third.RefreshToken); err != nil {
+		t.Errorf("refresh with the latest token: %v", err)
+	}
+}
+
+// A refresh token that is used twice has leaked: the whole session is revoked, the tokens
+// the thief or the owner got from it stop working, other sessions stay.
+func TestRefreshReuseRevokesSession(t *testing.T) {
+	env := newAuthEnv(t, defaultLoginPolicy)
+	userId := env.register(t, "ann@notes.test", "long password")
+	stolen := env.login(t, "ann@notes.test", "long password")
+	other := env.login(t, "ann@notes.test", "long password")
+	sid := env.session(t, stolen)
+
+	rotated, err := env.refresh(stolen.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+
+	if _, err = env.refresh(stolen.RefreshToken); !errors.Is(err, service.ErrRefreshTokenReused) {
+		t.Fatalf("reuse: got %v, want ErrRefreshTokenReused", err)
+	}
+	if _, err = env.refresh(rotated.RefreshToken); !errors.Is(err, service.ErrInvalidRefreshToken) {
+		t.Errorf("refresh of the revoked session: got %v, want ErrInvalidRefreshToken", err)
+	}
+	if err = env.auth.CheckSession(userId, sid); !errors.Is(err, service.ErrSessionRevoked) {
+		t.Errorf("access token of the revoked session: got %v, want ErrSessionRevoked", err)
+	}
+	// повторное использование видно и после отзыва
+	if _, err = env.refresh(stolen.RefreshToken); !errors.Is(err, service.ErrRefreshTokenReused) {
+		t.Errorf("reuse after the revoke: got %v, want ErrRefreshTokenReused", err)
+	}
+
+	if err = env.auth.CheckSession(userId, env.session(t, other)); err != nil {
+		t.Errorf("other session: %v", err)
+	}
+	if _, err = env.refresh(other.RefreshToken); err != nil {
+		t.Errorf("refresh of the other session: %v", err)
+	}
+}
+
+// Two clients that refresh with the same token at once cannot both win, the loser
+// counts as reuse.
+func TestConcurrentRefresh(t *testing.T) {
+	env := newAuthEnv(t, defaultLoginPolicy)
+	userId := env.register(t, "ann@notes.test", "long password")
+	resp := env.login(t, "ann@notes.test", "long password")
+
+	const clients = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, clients)
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := env.refresh(resp.RefreshToken)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, service.ErrRefreshTokenReused):
+			t.Errorf("concurrent refresh: got %v, want ErrRefreshTokenReused", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d concurrent refreshes succeeded, want 1", succeeded)
+	}
+	if err := env.auth.CheckSession(userId, env.session(t, resp)); !errors.Is(err, service.ErrSessionRevoked) {
+		t.Errorf("session after a reused token: got %v, want ErrSessionRevoked", err)
+	}
+}
+
+func TestRefreshAfterLogout(t *testing.T) {
+	env := newAuthEnv(t, defaultLoginPolicy)
+	userId := env.register(t, "ann@notes.test", "long password")
+	resp := env.login(t, "ann@notes.test", "long password")
+
+	if err := env.auth.Logout(env.session(t, resp)); err != nil {
+		t.Fatalf("logout: %v", err)
+	}
+	if _, err := env.refresh(resp.RefreshToken); !errors.Is(err, service.ErrInvalidRefreshToken) {
+		t.Errorf("refresh after logout: got %v, want ErrInvalidRefreshToken", err)
+	}
+
+	resp = env.login(t, "ann@notes.test", "long password")
+	if err := env.auth.LogoutAll(userId); err != nil {
+		t.Fatalf("logout all: %v", err)
+	}
+	if _, err := env.refresh(resp.RefreshToken); !errors.Is(err, service.ErrInvalidRefreshToken) {
+		t.Errorf("refresh after logout everywhere: got %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+// A bcrypt hash of an account from before Argon2id is replaced on the first login with
+// the right password.
+func TestLoginUpgradesPasswordHash(t *testing.T) {
+	env := newAuthEnv(t, defaultLoginPolicy)
+	userId := env.register(t, "ann@notes.test", "long password")
+	legacy, err := bcrypt.GenerateFromPassword([]byte("long password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt: %v", err)
+	}
+	if err = env.users.UpdatePassword(userId, string(legacy)); err != nil {
+		t.Fatalf("store bcrypt hash: %v", err)
+	}
+	storedHash := func() string {
+		user, _, err := env.users.GetUserByEmail("ann@notes.test")
+		if err != nil {
+			t.Fatalf("get user: %v", err)
+		}
+		return user.Password
+	}
+
+	if _, err = env.auth.LoginUser(dto.LoginRequest{Email: "ann@notes.test", Password: "wrong password"}, models.ClientInfo{IP: "198.51.100.7"}); !errors.Is(err, service.ErrInvalidCredentials) {
+		t.Fatalf("wrong password: got %v, want ErrInvalidCredentials", err)
+	}
+	if got := storedHash(); got != string(legacy) {
+		t.Errorf("hash changed after a wrong password: %s", got)
+	}
+
+	env.login(t, "ann@notes.test", "long password")
+	upgraded := storedHash()
+	if !strings.HasPrefix(upgraded, "$argon2id$") {
+		t.Fatalf("hash after login: %s, want argon2id", upgraded)
+	}
+
+	// новый хэш работает и больше не переписывается
+	env.login(t, "ann@notes.test", "long password")
+	if got := storedHash(); got != upgraded {
+		t.Errorf("current hash was rehashed again")
+	}
+}
+
+func TestRegisterWeakPassword(t *testing.T) {
+	env := newAuthEnv(t, defaultLoginPolicy)
+
+	for _, plain := range []string{"short", "password1", "ann-is-my-name"} {
+		err := env.auth.RegisterUser(dto.RegistrationRequest{Email: "ann@notes.test", Username: "ann", Password: plain})
+		if !errors.Is(err, password.ErrWeakPassword) {
+			t.Errorf("register with %q: got %v, want ErrWeakPassword", plain, err)
+		}
+	}
+	if _, exists, _ := env.users.GetUserByEmail("ann@notes.test"); exists {
+		t.Errorf("user with a weak password was created")
+	}
+}
+
+// Requests only note the time a session was seen, the sessions are written in one batch
+// by FlushLastSeen. The list of sessions shows the noted time before that.
+func TestSessionLastSeenBatched(t *testing.T) {
+	env := newAuthEnv(t, defaultLoginPolicy)
+	userId := env.register(t, "ann@notes.test", "long password")
+	resp, err := env.auth.LoginUser(dto.LoginRequest{Email: "ann@notes.test", Password: "long password"}, models.ClientInfo{UserAgent: "phone", IP: "192.0.2.1"})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	sid := env.session(t, resp)
+	idle := env.login(t, "ann@notes.test", "long password")
+
+	stored := func() time.Time {
+		t.Helper()
+		session, err := env.sessions.Get(sid)
+		if err != nil {
+			t.Fatalf("get session: %v", err)
+		}
+		return session.LastSeenAt
+	}
+	created := stored()
+
+	if flushed, err := env.auth.FlushLastSeen(); err != nil || flushed != 0 {
+		t.Fatalf("flush before any request: %d, %v, want 0", flushed, err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	for i := 0; i < 5; i++ {
+		if err = env.auth.CheckSession(userId, sid); err != nil {
+			t.Fatalf("check session: %v", err)
+		}
+	}
+	if got := stored(); !got.Equal(created) {
+		t.Errorf("last_seen written by a request: %s, want %s until the flush", got, created)
+	}
+
+	sessions, err := env.auth.GetSessions(userId, sid)
+	if err != nil || len(sessions) != 2 {
+		t.Fatalf("sessions: %v, %v, want 2", sessions, err)
+	}
+	// свежая сессия первой
+	if first := sessions[0]; first.ID != sid || !first.Current || first.UserAgent != "phone" || first.IP != "192.0.2.1" || !first.LastSeenAt.After(created) {
+		t.Errorf("active session: %+v, want the current one seen after %s", first, created)
+	}
+	if second := sessions[1]; second.ID != env.session(t, idle) || second.Current {
+		t.Errorf("idle session: %+v", second)
+	}
+
+	// пять запросов — одна запись
+	if flushed, err := env.auth.FlushLastSeen(); err != nil || flushed != 1 {
+		t.Fatalf("flush: %d, %v, want 1 session", flushed, err)
+	}
+	if got := stored(); !got.Equal(sessions[0].LastSeenAt) {
+		t.Errorf("last_seen after the flush: %s, want %s", got, sessions[0].LastSeenAt)
+	}
+	if flushed, err := env.auth.FlushLastSeen(); err != nil || flushed != 0 {
+		t.Errorf("second flush: %d, %v, want 0", flushed, err)
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	env := newAuthEnv(t, defaultLoginPolicy)
+	userId := env.register(t, "ann@notes.test", "long password")
+	otherId := env.register(t, "bob@notes.test", "long password")
+	current := env.session(t, env.login(t, "ann@notes.test", "long password"))
+	lost := env.login(t, "ann@notes.test", "long password")
+	kept := env.session(t, env.login(t, "ann@notes.test", "long password"))
+	foreign := env.session(t, env.login(t, "bob@notes.test", "long password"))
+
+	if err := env.auth.RevokeSession(userId, foreign); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("revoke a session of another user: got %v, want ErrNotFound", err)
+	}
+	if err := env.auth.CheckSession(otherId, foreign); err != nil {
+		t.Errorf("session of the other user after a foreign revoke: %v", err)
+	}
+
+	if err := env.auth.RevokeSession(userId, env.session(t, lost)); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if err := env.auth.CheckSession(userId, env.session(t, lost)); !errors.Is(err, service.ErrSessionRevoked) {
+		t.Errorf("revoked session: got %v, want ErrSessionRevoked", err)
+	}
+	if _, err := env.refresh(lost.RefreshToken); !errors.Is(err, service.ErrInvalidRefreshToken) {
+		t.Errorf("refresh of the revoked session: got %v, want ErrInvalidRefreshToken", err)
+	}
+	if err := env.auth.RevokeSession(userId, env.session(t, lost)); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("revoke twice: got %v, want ErrNotFound", err)
+	}
+
+	// остальные устройства не задеты
+	for name, sid := range map[string]uuid.UUID{"current": current, "kept": kept} {
+		if err := env.auth.CheckSession(userId, sid); err != nil {
+			t.Errorf("%s session after revoking another one: %v", name, err)
+		}
+	}
+	sessions, err := env.auth.GetSessions(userId, current)
+	if err != nil || len(sessions) != 2 {
+		t.Fatalf("sessions after the revoke: %v, %v, want 2", sessions, err)
+	}
+
+	if err = env.auth.LogoutAll(userId); err != nil {
+		t.Fatalf("logout all: %v", err)
+	}
+	for name, sid := range map[string]uuid.UUID{"current": current, "kept": kept} {
+		if err := env.auth.CheckSession(userId, sid); !errors.Is(err, service.ErrSessionRevoked) {
+			t.Errorf("%s session after logout everywhere: got %v, want ErrSessionRevoked", name, err)
+		}
+	}
+	if sessions, err = env.auth.GetSessions(userId, current); err != nil || len(sessions) != 0 {
+		t.Errorf("sessions after logout everywhere: %v, %v, want none", sessions, err)
+	}
+	if err = env.auth.CheckSession(otherId, foreign); err != nil {
+		t.Errorf("session of the other user after logout everywhere: %v", err)
+	}
+}
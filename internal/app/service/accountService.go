@@ -0,0 +1,197 @@
+package service
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"2/internal/infrastructure/mail"
+	"2/internal/interface/http/dto"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidEmailToken = errors.New("link is invalid, expired or was already used")
+	ErrEmailNotVerified  = errors.New("email is not verified, follow the link sent to it")
+)
+
+// AccountService sends password reset and email verification links and redeems them.
+// A link carries a signed token that expires and works once.
+type AccountService struct {
+	userRepo    repository.UserRepository
+	tokenRepo   repository.UserTokensRepository
+	sessionRepo repository.SessionsRepository
+	tokens      *TokenSigner
+	passwords   *Passwords
+	mailer      mail.Mailer
+	policy      models.AccountPolicy
+}
+
+func NewAccountService(userRepo repository.UserRepository, tokenRepo repository.UserTokensRepository, sessionRepo repository.SessionsRepository, tokens *TokenSigner, passwords *Passwords, mailer mail.Mailer, policy models.AccountPolicy) *AccountService {
+	return &AccountService{
+		userRepo:    userRepo,
+		tokenRepo:   tokenRepo,
+		sessionRepo: sessionRepo,
+		tokens:      tokens,
+		passwords:   passwords,
+		mailer:      mailer,
+		policy:      policy,
+	}
+}
+
+// SendVerification mails a verification link. Unknown and already verified addresses
+// are silently skipped, so the caller cannot tell which emails are registered.
+func (s *AccountService) SendVerification(email string) error {
+	user, exists, err := s.userRepo.GetUserByEmail(email)
+	if err != nil || !exists || user.EmailVerified {
+		return err
+	}
+
+	token, err := s.issueToken(user, models.EmailVerificationToken, s.policy.VerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Confirm your email",
+		Body: fmt.Sprintf("Hi %s,\n\nconfirm your email address by opening the link below:\n\n%s\n\nThe link is valid for %s.\n",
+			user.Username, s.link("verify-email", token), s.policy.VerificationTTL),
+	})
+}
+
+// VerifyEmail marks the email of the token owner verified.
+func (s *AccountService) VerifyEmail(req dto.VerifyEmailRequest) error {
+	user, err := s.redeemToken(req.Token, models.EmailVerificationToken)
+	if err != nil {
+		return err
+	}
+
+	return s.userRepo.SetEmailVerified(user.UserId)
+}
+
+// ForgotPassword mails a password reset link, unknown addresses are silently skipped.
+func (s *AccountService) ForgotPassword(email string) error {
+	user, exists, err := s.userRepo.GetUserByEmail(email)
+	if err != nil || !exists {
+		return err
+	}
+
+	token, err := s.issueToken(user, models.PasswordResetToken, s.policy.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nsomeone asked to reset the password of your account. If it was you, open the link below:\n\n%s\n\nThe link is valid for %s. If it was not you, ignore this email.\n",
+			user.Username, s.link("reset-password", token), s.policy.PasswordResetTTL),
+	})
+}
+
+// ResetPassword sets a new password and logs the user out everywhere.
+func (s *AccountService) ResetPassword(req dto.ResetPasswordRequest) error {
+	if req.Password == "" {
+		return errors.New("password is required")
+	}
+
+	user, err := s.redeemToken(req.Token, models.PasswordResetToken)
+	if err != nil {
+		return err
+	}
+
+	if err = s.passwords.Set(&user, req.Password); err != nil {
+		return err
+	}
+	if err = s.userRepo.UpdatePassword(user.UserId, user.Password); err != nil {
+		return err
+	}
+
+	// ссылка пришла на этот адрес, значит он принадлежит пользователю
+	if !user.EmailVerified {
+		if err = s.userRepo.SetEmailVerified(user.UserId); err != nil {
+			return err
+		}
+	}
+
+	return s.sessionRepo.RevokeAllByUserId(user.UserId, time.Now())
+}
+
+// PurgeTokens removes records of expired links.
+func (s *AccountService) PurgeTokens() (int64, error) {
+	return s.tokenRepo.DeleteExpired(time.Now())
+}
+
+func (s *AccountService) issueToken(user models.User, purpose string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	record := models.UserToken{
+		ID:        uuid.New(),
+		UserId:    user.UserId,
+		Purpose:   purpose,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	if err := s.tokenRepo.Create(record); err != nil {
+		return "", err
+	}
+
+	return s.tokens.Sign(jwt.MapClaims{
+		"typ":     purpose,
+		"user_id": user.UserId.String(),
+		// ссылка на старый адрес перестаёт работать, если email сменился
+		"email": user.Email,
+		"jti":   record.ID.String(),
+		"iat":   now.Unix(),
+		"exp":   record.ExpiresAt.Unix(),
+	})
+}
+
+// redeemToken checks the token and uses it up, it returns the user the token was issued to.
+func (s *AccountService) redeemToken(tokenString string, purpose string) (models.User, error) {
+	claims, err := s.tokens.Parse(tokenString, purpose)
+	if err != nil {
+		return models.User{}, ErrInvalidEmailToken
+	}
+
+	userIdStr, _ := claims["user_id"].(string)
+	jtiStr, _ := claims["jti"].(string)
+	email, _ := claims["email"].(string)
+	userId, err := uuid.Parse(userIdStr)
+	if err != nil {
+		return models.User{}, ErrInvalidEmailToken
+	}
+	jti, err := uuid.Parse(jtiStr)
+	if err != nil {
+		return models.User{}, ErrInvalidEmailToken
+	}
+
+	user, err := s.userRepo.GetUserById(userId)
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.User{}, ErrInvalidEmailToken
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+	if !strings.EqualFold(user.Email, email) {
+		return models.User{}, ErrInvalidEmailToken
+	}
+
+	err = s.tokenRepo.Use(jti, time.Now())
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.User{}, ErrInvalidEmailToken
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+func (s *AccountService) link(path string, token string) string {
+	return strings.TrimRight(s.policy.AppURL, "/") + "/" + path + "?token=" + url.QueryEscape(token)
+}
@@ -0,0 +1,269 @@
+package service_test
+
+import (
+	"2/internal/app/service"
+	"2/internal/domain/models"
+	"2/internal/infrastructure/mail"
+	"2/internal/infrastructure/storage/memory"
+	"2/internal/interface/http/dto"
+	"bufio"
+	"errors"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpServer is a minimal SMTP server that accepts every message and hands it to the test.
+type smtpServer struct {
+	listener net.Listener
+	messages chan string
+}
+
+func startSMTPServer(t *testing.T) *smtpServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := &smtpServer{listener: listener, messages: make(chan string, 16)}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serve(conn)
+		}
+	}()
+	return srv
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost test SMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250-localhost")
+			reply("250 8BITMIME")
+		case strings.HasPrefix(cmd, "MAIL FROM"), strings.HasPrefix(cmd, "RCPT TO"), cmd == "RSET", cmd == "NOOP":
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+			s.messages <- data.String()
+			reply("250 OK queued")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func (s *smtpServer) addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *smtpServer) next(t *testing.T) string {
+	t.Helper()
+	select {
+	case msg := <-s.messages:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message was delivered")
+		return ""
+	}
+}
+
+func (s *smtpServer) expectNone(t *testing.T) {
+	t.Helper()
+	select {
+	case msg := <-s.messages:
+		t.Fatalf("unexpected message:\n%s", msg)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+var tokenPattern = regexp.MustCompile(`\?token=(\S+)`)
+
+func tokenFrom(t *testing.T, message string) string {
+	t.Helper()
+	match := tokenPattern.FindStringSubmatch(message)
+	if match == nil {
+		t.Fatalf("no link in message:\n%s", message)
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatalf("unescape token: %v", err)
+	}
+	return token
+}
+
+type testEnv struct {
+	smtp     *smtpServer
+	auth     *service.AuthService
+	accounts *service.AccountService
+}
+
+func newTestEnv(t *testing.T, policy models.AccountPolicy) testEnv {
+	t.Helper()
+
+	smtp := startSMTPServer(t)
+	env := newAuthEnv(t, defaultLoginPolicy)
+	auth := env.auth
+	auth.RequireVerifiedEmail = true
+
+	mailer := mail.NewSMTPMailer(smtp.addr(), "", "", "no-reply@notes.test")
+	accounts := service.NewAccountService(env.users, memory.NewUserTokensRepository(), env.sessions, env.tokens, env.passwords, mailer, policy)
+
+	if err := auth.RegisterUser(dto.RegistrationRequest{Email: "ann@notes.test", Username: "ann", Password: "old-password"}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	return testEnv{smtp: smtp, auth: auth, accounts: accounts}
+}
+
+var defaultPolicy = models.AccountPolicy{
+	AppURL:           "https://notes.test/",
+	PasswordResetTTL: time.Hour,
+	VerificationTTL:  time.Hour,
+}
+
+func (e testEnv) login(password string) error {
+	_, err := e.auth.LoginUser(dto.LoginRequest{Email: "ann@notes.test", Password: password}, models.ClientInfo{})
+	return err
+}
+
+func TestEmailVerification(t *testing.T) {
+	env := newTestEnv(t, defaultPolicy)
+
+	if err := env.login("old-password"); !errors.Is(err, service.ErrEmailNotVerified) {
+		t.Fatalf("login before verification: got %v, want ErrEmailNotVerified", err)
+	}
+
+	if err := env.accounts.SendVerification("ann@notes.test"); err != nil {
+		t.Fatalf("send verification: %v", err)
+	}
+	message := env.smtp.next(t)
+	for _, header := range []string{"To: ann@notes.test", "From: no-reply@notes.test", "Subject: Confirm your email"} {
+		if !strings.Contains(message, header+"\r\n") {
+			t.Errorf("message has no %q header:\n%s", header, message)
+		}
+	}
+	if !strings.Contains(message, "https://notes.test/verify-email?token=") {
+		t.Errorf("message has no verification link:\n%s", message)
+	}
+
+	token := tokenFrom(t, message)
+	if err := env.accounts.VerifyEmail(dto.VerifyEmailRequest{Token: token}); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if err := env.accounts.VerifyEmail(dto.VerifyEmailRequest{Token: token}); !errors.Is(err, service.ErrInvalidEmailToken) {
+		t.Fatalf("second verify: got %v, want ErrInvalidEmailToken", err)
+	}
+	if err := env.login("old-password"); err != nil {
+		t.Fatalf("login after verification: %v", err)
+	}
+
+	// подтверждённому и незнакомому адресу ничего не отправляется
+	if err := env.accounts.SendVerification("ann@notes.test"); err != nil {
+		t.Fatalf("send verification again: %v", err)
+	}
+	if err := env.accounts.SendVerification("nobody@notes.test"); err != nil {
+		t.Fatalf("send verification to unknown email: %v", err)
+	}
+	env.smtp.expectNone(t)
+}
+
+func TestPasswordReset(t *testing.T) {
+	env := newTestEnv(t, defaultPolicy)
+
+	if err := env.accounts.ForgotPassword("nobody@notes.test"); err != nil {
+		t.Fatalf("forgot for unknown email: %v", err)
+	}
+	env.smtp.expectNone(t)
+
+	if err := env.accounts.ForgotPassword("ann@notes.test"); err != nil {
+		t.Fatalf("forgot: %v", err)
+	}
+	message := env.smtp.next(t)
+	if !strings.Contains(message, "https://notes.test/reset-password?token=") {
+		t.Fatalf("message has no reset link:\n%s", message)
+	}
+	token := tokenFrom(t, message)
+
+	if err := env.accounts.VerifyEmail(dto.VerifyEmailRequest{Token: token}); !errors.Is(err, service.ErrInvalidEmailToken) {
+		t.Fatalf("reset token accepted as verification token: %v", err)
+	}
+
+	if err := env.accounts.ResetPassword(dto.ResetPasswordRequest{Token: token, Password: "new-password"}); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if err := env.accounts.ResetPassword(dto.ResetPasswordRequest{Token: token, Password: "other-password"}); !errors.Is(err, service.ErrInvalidEmailToken) {
+		t.Fatalf("second reset: got %v, want ErrInvalidEmailToken", err)
+	}
+
+	if err := env.login("old-password"); err == nil {
+		t.Fatal("login with the old password succeeded")
+	}
+	// ссылка из письма подтверждает и сам адрес
+	if err := env.login("new-password"); err != nil {
+		t.Fatalf("login with the new password: %v", err)
+	}
+}
+
+func TestExpiredAndForgedTokens(t *testing.T) {
+	env := newTestEnv(t, models.AccountPolicy{
+		AppURL:           "https://notes.test",
+		PasswordResetTTL: -time.Minute,
+		VerificationTTL:  time.Hour,
+	})
+
+	if err := env.accounts.ForgotPassword("ann@notes.test"); err != nil {
+		t.Fatalf("forgot: %v", err)
+	}
+	expired := tokenFrom(t, env.smtp.next(t))
+	if err := env.accounts.ResetPassword(dto.ResetPasswordRequest{Token: expired, Password: "new-password"}); !errors.Is(err, service.ErrInvalidEmailToken) {
+		t.Fatalf("expired token: got %v, want ErrInvalidEmailToken", err)
+	}
+
+	if err := env.accounts.SendVerification("ann@notes.test"); err != nil {
+		t.Fatalf("send verification: %v", err)
+	}
+	token := tokenFrom(t, env.smtp.next(t))
+	// первый символ подписи значим целиком, в отличие от последнего
+	sig := strings.LastIndex(token, ".") + 1
+	replacement := "A"
+	if token[sig] == 'A' {
+		replacement = "B"
+	}
+	forged := token[:sig] + replacement + token[sig+1:]
+	if err := env.accounts.VerifyEmail(dto.VerifyEmailRequest{Token: forged}); !errors.Is(err, service.ErrInvalidEmailToken) {
+		t.Fatalf("forged token: got %v, want ErrInvalidEmailToken", err)
+	}
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"2/internal/interface/http/dto"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrInvalidRole = fmt.Errorf("role must be one of: %s", strings.Join(models.Roles, ", "))
+
+const (
+	defaultUsersLimit = 50
+	maxUsersLimit     = 200
+)
+
+// AdminService lets moderators and admins look after user accounts.
+type AdminService struct {
+	userRepo    repository.UserRepository
+	notesRepo   repository.NotesRepository
+	sessionRepo repository.SessionsRepository
+	guard       *LoginGuard
+}
+
+func NewAdminService(userRepo repository.UserRepository, notesRepo repository.NotesRepository, sessionRepo repository.SessionsRepository, guard *LoginGuard) *AdminService {
+	return &AdminService{
+		userRepo:    userRepo,
+		notesRepo:   notesRepo,
+		sessionRepo: sessionRepo,
+		guard:       guard,
+	}
+}
+
+// FindUsers returns a page of users with their note counts.
+func (s *AdminService) FindUsers(filter models.UserFilter) (dto.AdminUsersPageResponse, error) {
+	if filter.Role != "" && !slices.Contains(models.Roles, filter.Role) {
+		return dto.AdminUsersPageResponse{}, ErrInvalidRole
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultUsersLimit
+	}
+	filter.Limit = min(filter.Limit, maxUsersLimit)
+	filter.Offset = max(filter.Offset, 0)
+
+	// на одного больше, чтобы понять, есть ли следующая страница
+	limit := filter.Limit
+	filter.Limit++
+	users, err := s.userRepo.Find(filter)
+	if err != nil {
+		return dto.AdminUsersPageResponse{}, err
+	}
+
+	page := dto.AdminUsersPageResponse{Users: []dto.AdminUserResponse{}}
+	if len(users) > limit {
+		users = users[:limit]
+		next := filter.Offset + limit
+		page.NextOffset = &next
+	}
+
+	ids := make([]uuid.UUID, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.UserId)
+	}
+	counts, err := s.notesRepo.CountByUsers(ids)
+	if err != nil {
+		return dto.AdminUsersPageResponse{}, err
+	}
+
+	for _, user := range users {
+		page.Users = append(page.Users, toAdminUserResponse(user, counts[user.UserId]))
+	}
+	return page, nil
+}
+
+// GetUser returns one user with note counts and the number of active sessions.
+func (s *AdminService) GetUser(id uuid.UUID) (dto.AdminUserDetailsResponse, error) {
+	user, err := s.userRepo.GetUserById(id)
+	if err != nil {
+		return dto.AdminUserDetailsResponse{}, err
+	}
+
+	counts, err := s.notesRepo.CountByUsers([]uuid.UUID{id})
+	if err != nil {
+		return dto.AdminUserDetailsResponse{}, err
+	}
+
+	sessions, err := s.sessionRepo.GetAllByUserId(id)
+	if err != nil {
+		return dto.AdminUserDetailsResponse{}, err
+	}
+	now := time.Now()
+	active := 0
+	for _, session := range sessions {
+		if session.Active(now) {
+			active++
+		}
+	}
+
+	return dto.AdminUserDetailsResponse{
+		AdminUserResponse: toAdminUserResponse(user, counts[id]),
+		ActiveSessions:    active,
+	}, nil
+}
+
+// SetDisabled disables or enables an account. A disabled user is logged out everywhere
+// and cannot log in, personal access tokens stop working until the account is enabled.
+func (s *AdminService) SetDisabled(actorId uuid.UUID, id uuid.UUID, disabled bool) error {
+	_, target, err := s.authorize(actorId, id)
+	if err != nil {
+		return err
+	}
+	if target.Disabled() == disabled {
+		return nil
+	}
+
+	if !disabled {
+		return s.userRepo.SetDisabled(id, nil)
+	}
+	now := time.Now()
+	if err = s.userRepo.SetDisabled(id, &now); err != nil {
+		return err
+	}
+	return s.sessionRepo.RevokeAllByUserId(id, now)
+}
+
+// ForceLogout revokes every session of the user.
+func (s *AdminService) ForceLogout(actorId uuid.UUID, id uuid.UUID) error {
+	if _, _, err := s.authorize(actorId, id); err != nil {
+		return err
+	}
+	return s.sessionRepo.RevokeAllByUserId(id, time.Now())
+}
+
+// SetRole changes the role of another user, only admins may do it.
+func (s *AdminService) SetRole(actorId uuid.UUID, id uuid.UUID, req dto.SetRoleRequest) error {
+	if !slices.Contains(models.Roles, req.Role) {
+		return ErrInvalidRole
+	}
+	actor, _, err := s.authorize(actorId, id)
+	if err != nil {
+		return err
+	}
+	if actor.Role != models.RoleAdmin {
+		return fmt.Errorf("%w: only admins change roles", ErrAccessDenied)
+	}
+	return s.AssignRole(id, req.Role)
+}
+
+// AssignRole sets the role without checking who asks, for the command line. Sessions
+// are revoked so that no access token with the old role stays in use.
+func (s *AdminService) AssignRole(id uuid.UUID, role string) error {
+	if !slices.Contains(models.Roles, role) {
+		return ErrInvalidRole
+	}
+	if err := s.userRepo.SetRole(id, role); err != nil {
+		return err
+	}
+	return s.sessionRepo.RevokeAllByUserId(id, time.Now())
+}
+
+// Lockouts returns the latest login lockouts, newest first.
+func (s *AdminService) Lockouts(limit int) ([]dto.LockoutResponse, error) {
+	events, err := s.guard.Lockouts(limit)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := []dto.LockoutResponse{}
+	for _, e := range events {
+		resp = append(resp, dto.LockoutResponse{
+			ID:          e.ID,
+			Kind:        e.Kind,
+			Subject:     e.Subject,
+			IP:          e.IP,
+			Failures:    e.Failures,
+			LockedAt:    e.LockedAt,
+			LockedUntil: e.LockedUntil,
+			UnlockedAt:  e.UnlockedAt,
+		})
+	}
+	return resp, nil
+}
+
+// Unlock lifts the lockout of an account, an address or a public link.
+func (s *AdminService) Unlock(req dto.UnlockRequest) error {
+	subject := strings.TrimSpace(req.Subject)
+	if subject == "" {
+		return errors.New("subject is required")
+	}
+	return s.guard.Unlock(subject)
+}
+
+// authorize checks that the actor may manage the target account. Nobody manages their
+// own account here, moderators manage only plain users, admins manage everyone else.
+func (s *AdminService) authorize(actorId uuid.UUID, id uuid.UUID) (actor models.User, target models.User, err error) {
+	if actorId == id {
+		return actor, target, fmt.Errorf("%w: you cannot manage your own account", ErrAccessDenied)
+	}
+
+	// роль из базы, а не из токена: её могли понизить минуту назад
+	if actor, err = s.userRepo.GetUserById(actorId); err != nil {
+		return actor, target, err
+	}
+	if target, err = s.userRepo.GetUserById(id); err != nil {
+		return actor, target, err
+	}
+
+	if actor.Role != models.RoleAdmin && models.RoleRank(actor.Role) <= models.RoleRank(target.Role) {
+		return actor, target, fmt.Errorf("%w: %s cannot manage %s accounts", ErrAccessDenied, actor.Role, target.Role)
+	}
+	return actor, target, nil
+}
+
+func toAdminUserResponse(user models.User, counts models.NoteCounts) dto.AdminUserResponse {
+	return dto.AdminUserResponse{
+		UserId:        user.UserId,
+		Email:         user.Email,
+		Username:      user.Username,
+		Role:          user.Role,
+		EmailVerified: user.EmailVerified,
+		Disabled:      user.Disabled(),
+		DisabledAt:    user.DisabledAt,
+		Created:       user.Created,
+		NoteCount:     counts.Notes,
+		TrashedCount:  counts.Trashed,
+	}
+}
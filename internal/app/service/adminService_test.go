@@ -0,0 +1,122 @@
+package service_test
+
+import (
+	"2/internal/app/service"
+	"2/internal/domain/models"
+	"2/internal/infrastructure/storage/memory"
+	"2/internal/interface/http/dto"
+	"errors"
+	"github.com/google/uuid"
+	"slices"
+	"testing"
+)
+
+type adminEnv struct {
+	*authEnv
+	admin *service.AdminService
+	ids   map[string]uuid.UUID
+}
+
+// newAdminEnv registers one account of every role and a second one of each, so that
+// every actor has a peer.
+func newAdminEnv(t *testing.T) *adminEnv {
+	t.Helper()
+
+	env := &adminEnv{authEnv: newAuthEnv(t, defaultLoginPolicy), ids: map[string]uuid.UUID{}}
+	env.admin = service.NewAdminService(env.users, memory.NewNotesRepository(), env.sessions, env.guard)
+
+	for _, role := range models.Roles {
+		for _, name := range []string{role, role + "2"} {
+			id := env.register(t, name+"@notes.test", "long password")
+			if err := env.users.SetRole(id, role); err != nil {
+				t.Fatalf("set role of %s: %v", name, err)
+			}
+			env.ids[name] = id
+		}
+	}
+	return env
+}
+
+func (e *adminEnv) activeSessions(t *testing.T, name string) int {
+	t.Helper()
+
+	user, err := e.admin.GetUser(e.ids[name])
+	if err != nil {
+		t.Fatalf("get %s: %v", name, err)
+	}
+	return user.ActiveSessions
+}
+
+func TestAdminAuthorize(t *testing.T) {
+	// кто кем может управлять: модератор только обычными пользователями, админ всеми, кроме себя
+	allowed := map[string][]string{
+		models.RoleUser:      {},
+		models.RoleModerator: {models.RoleUser, models.RoleUser + "2"},
+		models.RoleAdmin:     {models.RoleUser, models.RoleUser + "2", models.RoleModerator, models.RoleModerator + "2", models.RoleAdmin + "2"},
+	}
+
+	for actor, targets := range allowed {
+		for _, role := range models.Roles {
+			for _, target := range []string{role, role + "2"} {
+				env := newAdminEnv(t)
+				env.login(t, target+"@notes.test", "long password")
+				want := slices.Contains(targets, target)
+
+				err := env.admin.ForceLogout(env.ids[actor], env.ids[target])
+				if want && err != nil {
+					t.Errorf("%s logs out %s: %v", actor, target, err)
+				}
+				if !want && !errors.Is(err, service.ErrAccessDenied) {
+					t.Errorf("%s logs out %s: got %v, want ErrAccessDenied", actor, target, err)
+				}
+				if got := env.activeSessions(t, target); want != (got == 0) {
+					t.Errorf("%s logs out %s: %d active sessions left", actor, target, got)
+				}
+
+				err = env.admin.SetDisabled(env.ids[actor], env.ids[target], true)
+				if want != (err == nil) || err != nil && !errors.Is(err, service.ErrAccessDenied) {
+					t.Errorf("%s disables %s: got %v, allowed %v", actor, target, err, want)
+				}
+				user, _ := env.admin.GetUser(env.ids[target])
+				if user.Disabled != want {
+					t.Errorf("%s disables %s: disabled=%v", actor, target, user.Disabled)
+				}
+			}
+		}
+	}
+}
+
+func TestAdminSetRole(t *testing.T) {
+	env := newAdminEnv(t)
+	env.login(t, "user@notes.test", "long password")
+
+	// модератор не раздаёт роли даже тем, кем управляет
+	if err := env.admin.SetRole(env.ids[models.RoleModerator], env.ids[models.RoleUser], dto.SetRoleRequest{Role: models.RoleModerator}); !errors.Is(err, service.ErrAccessDenied) {
+		t.Errorf("moderator sets a role: got %v, want ErrAccessDenied", err)
+	}
+	if err := env.admin.SetRole(env.ids[models.RoleAdmin], env.ids[models.RoleAdmin], dto.SetRoleRequest{Role: models.RoleUser}); !errors.Is(err, service.ErrAccessDenied) {
+		t.Errorf("admin demotes themselves: got %v, want ErrAccessDenied", err)
+	}
+	if err := env.admin.SetRole(env.ids[models.RoleAdmin], env.ids[models.RoleUser], dto.SetRoleRequest{Role: "owner"}); !errors.Is(err, service.ErrInvalidRole) {
+		t.Errorf("unknown role: got %v, want ErrInvalidRole", err)
+	}
+
+	if err := env.admin.SetRole(env.ids[models.RoleAdmin], env.ids[models.RoleUser], dto.SetRoleRequest{Role: models.RoleModerator}); err != nil {
+		t.Fatalf("admin promotes a user: %v", err)
+	}
+	user, err := env.admin.GetUser(env.ids[models.RoleUser])
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	if user.Role != models.RoleModerator || user.ActiveSessions != 0 {
+		t.Errorf("promoted user: role %s, %d active sessions, want moderator without sessions", user.Role, user.ActiveSessions)
+	}
+
+	// новый модератор теперь ровня старому
+	if err = env.admin.ForceLogout(env.ids[models.RoleModerator], env.ids[models.RoleUser]); !errors.Is(err, service.ErrAccessDenied) {
+		t.Errorf("moderator logs out a promoted peer: got %v, want ErrAccessDenied", err)
+	}
+	if err = env.admin.ForceLogout(env.ids[models.RoleAdmin], uuid.New()); err == nil {
+		t.Errorf("unknown user: got no error")
+	}
+}
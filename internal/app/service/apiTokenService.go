@@ -0,0 +1,148 @@
+package service
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"2/internal/interface/http/dto"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// сколько символов токена хранится открыто и показывается в списке
+	apiTokenVisible = 12
+	// last_used_at пишется не чаще раза в минуту на токен
+	apiTokenTouchInterval = time.Minute
+)
+
+var ErrInvalidAPIToken = errors.New("api token is invalid or expired")
+
+type APITokenService struct {
+	tokenRepo repository.APITokensRepository
+}
+
+func NewAPITokenService(tokenRepo repository.APITokensRepository) *APITokenService {
+	return &APITokenService{tokenRepo: tokenRepo}
+}
+
+// CreateToken creates a token with the given scopes. The token is in the response only,
+// the service keeps its hash.
+func (s *APITokenService) CreateToken(userId uuid.UUID, req dto.CreateAPITokenRequest) (dto.CreatedAPITokenResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return dto.CreatedAPITokenResponse{}, errors.New("name is required")
+	}
+
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		return dto.CreatedAPITokenResponse{}, err
+	}
+
+	now := time.Now()
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		return dto.CreatedAPITokenResponse{}, errors.New("expires_at must be in the future")
+	}
+
+	raw := make([]byte, 32)
+	if _, err = rand.Read(raw); err != nil {
+		return dto.CreatedAPITokenResponse{}, err
+	}
+	value := models.APITokenPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	token := models.APIToken{
+		ID:        uuid.New(),
+		UserId:    userId,
+		Name:      name,
+		Prefix:    value[:apiTokenVisible],
+		TokenHash: hashToken(value),
+		Scopes:    scopes,
+		CreatedAt: now,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err = s.tokenRepo.Create(token); err != nil {
+		return dto.CreatedAPITokenResponse{}, err
+	}
+
+	return dto.CreatedAPITokenResponse{
+		APITokenResponse: toAPITokenResponse(token),
+		Token:            value,
+	}, nil
+}
+
+func (s *APITokenService) GetTokens(userId uuid.UUID) ([]dto.APITokenResponse, error) {
+	tokens, err := s.tokenRepo.GetAllByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]dto.APITokenResponse, len(tokens))
+	for i, token := range tokens {
+		resp[i] = toAPITokenResponse(token)
+	}
+	return resp, nil
+}
+
+func (s *APITokenService) RevokeToken(userId uuid.UUID, id uuid.UUID) error {
+	return s.tokenRepo.Delete(userId, id)
+}
+
+// CheckAPIToken is called by the auth middleware for requests with a personal access token.
+func (s *APITokenService) CheckAPIToken(value string) (uuid.UUID, []string, error) {
+	token, err := s.tokenRepo.GetByHash(hashToken(value))
+	if errors.Is(err, repository.ErrNotFound) {
+		return uuid.UUID{}, nil, ErrInvalidAPIToken
+	}
+	if err != nil {
+		return uuid.UUID{}, nil, err
+	}
+
+	now := time.Now()
+	if !token.Active(now) {
+		return uuid.UUID{}, nil, ErrInvalidAPIToken
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > apiTokenTouchInterval {
+		if err = s.tokenRepo.Touch(token.ID, now); err != nil {
+			slog.Error("Failed to save api token usage", "error", err)
+		}
+	}
+
+	return token.UserId, token.Scopes, nil
+}
+
+// normalizeScopes checks the requested scopes and drops duplicates.
+func normalizeScopes(requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return nil, fmt.Errorf("at least one scope is required, available: %s", strings.Join(models.Scopes, ", "))
+	}
+
+	scopes := []string{}
+	for _, scope := range requested {
+		if !slices.Contains(models.Scopes, scope) {
+			return nil, fmt.Errorf("unknown scope %q, available: %s", scope, strings.Join(models.Scopes, ", "))
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+func toAPITokenResponse(token models.APIToken) dto.APITokenResponse {
+	return dto.APITokenResponse{
+		ID:         token.ID,
+		Name:       token.Name,
+		Prefix:     token.Prefix,
+		Scopes:     token.Scopes,
+		CreatedAt:  token.CreatedAt,
+		ExpiresAt:  token.ExpiresAt,
+		LastUsedAt: token.LastUsedAt,
+	}
+}
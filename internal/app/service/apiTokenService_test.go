@@ -0,0 +1,88 @@
+package service_test
+
+import (
+	"2/internal/app/service"
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"2/internal/infrastructure/storage/memory"
+	"2/internal/interface/http/dto"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/google/uuid"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The token is returned once, the repository keeps only its hash and visible prefix.
+func TestAPITokenStoredHashed(t *testing.T) {
+	repo := memory.NewAPITokensRepository()
+	tokens := service.NewAPITokenService(repo)
+	userId := uuid.New()
+
+	created, err := tokens.CreateToken(userId, dto.CreateAPITokenRequest{
+		Name:   " script ",
+		Scopes: []string{models.ScopeNotesRead, models.ScopeNotesRead, models.ScopeUserRead},
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if !strings.HasPrefix(created.Token, models.APITokenPrefix) || !strings.HasPrefix(created.Token, created.Prefix) {
+		t.Errorf("token %q with prefix %q", created.Token, created.Prefix)
+	}
+	if created.Name != "script" || !slices.Equal(created.Scopes, []string{models.ScopeNotesRead, models.ScopeUserRead}) {
+		t.Errorf("created token: name %q, scopes %v", created.Name, created.Scopes)
+	}
+
+	stored, err := repo.GetAllByUserId(userId)
+	if err != nil || len(stored) != 1 {
+		t.Fatalf("stored tokens: %v, %v", stored, err)
+	}
+	sum := sha256.Sum256([]byte(created.Token))
+	if stored[0].TokenHash != hex.EncodeToString(sum[:]) {
+		t.Errorf("stored hash %q is not the SHA-256 of the token", stored[0].TokenHash)
+	}
+	if len(stored[0].Prefix) >= len(created.Token) {
+		t.Errorf("stored prefix %q reveals the token", stored[0].Prefix)
+	}
+
+	owner, scopes, err := tokens.CheckAPIToken(created.Token)
+	if err != nil || owner != userId || !slices.Equal(scopes, created.Scopes) {
+		t.Errorf("check: %s %v %v, want the owner and the scopes", owner, scopes, err)
+	}
+	if _, _, err = tokens.CheckAPIToken(stored[0].TokenHash); !errors.Is(err, service.ErrInvalidAPIToken) {
+		t.Errorf("check with the stored hash: got %v, want ErrInvalidAPIToken", err)
+	}
+	if stored, _ = repo.GetAllByUserId(userId); stored[0].LastUsedAt == nil {
+		t.Errorf("last_used_at is not set after a check")
+	}
+
+	// чужой токен отозвать нельзя, свой - один раз
+	if err = tokens.RevokeToken(uuid.New(), created.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("revoke a token of another user: got %v, want ErrNotFound", err)
+	}
+	if err = tokens.RevokeToken(userId, created.ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if _, _, err = tokens.CheckAPIToken(created.Token); !errors.Is(err, service.ErrInvalidAPIToken) {
+		t.Errorf("check a revoked token: got %v, want ErrInvalidAPIToken", err)
+	}
+}
+
+func TestCreateAPITokenInvalid(t *testing.T) {
+	tokens := service.NewAPITokenService(memory.NewAPITokensRepository())
+	past := time.Now().Add(-time.Minute)
+
+	for name, req := range map[string]dto.CreateAPITokenRequest{
+		"no name":       {Name: " ", Scopes: []string{models.ScopeNotesRead}},
+		"no scopes":     {Name: "script"},
+		"unknown scope": {Name: "script", Scopes: []string{"notes:admin"}},
+		"past expiry":   {Name: "script", Scopes: []string{models.ScopeNotesRead}, ExpiresAt: &past},
+	} {
+		if _, err := tokens.CreateToken(uuid.New(), req); err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package service_test
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"2/internal/infrastructure/migrations"
+	"2/internal/infrastructure/storage"
+	"2/internal/infrastructure/storage/memory"
+	"github.com/google/uuid"
+	"path/filepath"
+	"testing"
+)
+
+// testBackend holds the note repositories of one storage backend.
+type testBackend struct {
+	users     repository.UserRepository
+	notes     repository.NotesRepository
+	notebooks repository.NotebooksRepository
+	shares    repository.SharesRepository
+	tags      repository.TagsRepository
+	revisions repository.RevisionsRepository
+	links     repository.PublicLinksRepository
+	search    repository.SearchRepository
+}
+
+// eachBackend runs the test against the memory backend and against sqlite in a fresh
+// migrated database file.
+func eachBackend(t *testing.T, test func(t *testing.T, b testBackend)) {
+	t.Run("memory", func(t *testing.T) {
+		notes := memory.NewNotesRepository()
+		notebooks := memory.NewNotebooksRepository(notes)
+		users := memory.NewUserRepository(notes, notebooks)
+		test(t, testBackend{
+			users:     users,
+			notes:     notes,
+			notebooks: notebooks,
+			shares:    memory.NewSharesRepository(notes, users),
+			tags:      memory.NewTagsRepository(notes),
+			revisions: memory.NewRevisionsRepository(notes),
+			links:     memory.NewPublicLinksRepository(notes),
+			search:    memory.NewSearchRepository(notes),
+		})
+	})
+
+	t.Run("sqlite", func(t *testing.T) {
+		db, err := storage.Open(storage.SQLite, filepath.Join(t.TempDir(), "notes.db"))
+		if err != nil {
+			t.Fatalf("open sqlite: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		migrator, err := migrations.NewMigrator(db, storage.SQLite)
+		if err != nil {
+			t.Fatalf("migrator: %v", err)
+		}
+		if _, err = migrator.Up(); err != nil {
+			t.Fatalf("migrate: %v", err)
+		}
+
+		test(t, testBackend{
+			users:     storage.NewUserRepository(db, storage.SQLite),
+			notes:     storage.NewNotesRepository(db, storage.SQLite),
+			notebooks: storage.NewNotebooksRepository(db, storage.SQLite),
+			shares:    storage.NewSharesRepository(db, storage.SQLite),
+			tags:      storage.NewTagsRepository(db, storage.SQLite),
+			revisions: storage.NewRevisionsRepository(db, storage.SQLite),
+			links:     storage.NewPublicLinksRepository(db, storage.SQLite),
+			search:    storage.NewSearchRepository(db, storage.SQLite),
+		})
+	})
+}
+
+// newUser stores a user, notes of unknown users break the foreign keys of sqlite.
+func (b testBackend) newUser(t *testing.T, name string) uuid.UUID {
+	t.Helper()
+
+	id := uuid.New()
+	err := b.users.Create(models.User{
+		UserId:        id,
+		Username:      name,
+		Email:         name + "@notes.test",
+		EmailVerified: true,
+		Role:          models.RoleUser,
+	})
+	if err != nil {
+		t.Fatalf("create user %s: %v", name, err)
+	}
+	return id
+}
@@ -0,0 +1,472 @@
+package service
+
+import (
+	"2/internal/app/crdt"
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"2/internal/interface/http/dto"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"log/slog"
+	"sync"
+)
+
+var (
+	ErrCollabClosed   = errors.New("the editing session has ended, connect again")
+	ErrCollabStale    = errors.New("the document was reloaded, apply the reset and send the changes again")
+	ErrCollabTooLarge = errors.New("the note is too large to edit together")
+)
+
+const (
+	// сколько сообщений ждут отправки одному участнику; кто не успевает, того отключаем
+	collabPeerBuffer = 256
+	// предел документа вместе с удалёнными символами
+	maxCollabLength = 1 << 20
+	// столько операций в одном сообщении, редактор шлёт их по мере набора
+	maxCollabOps = 1000
+)
+
+// CollabService runs live editing sessions, one per note. Participants exchange CRDT
+// operations, so concurrent edits merge instead of overwriting each other, and the merged
+// text is written back to the note from time to time by Compact.
+//
+// Sessions live in this process: everyone editing a note must reach the same instance.
+// Locks are taken in one order, the service before a session.
+type CollabService struct {
+	noteRepo repository.NotesRepository
+	userRepo repository.UserRepository
+	access   noteAccess
+	events   noteEvents
+
+	mu       sync.Mutex
+	sessions map[uuid.UUID]*collabSession
+}
+
+func NewCollabService(noteRepo repository.NotesRepository, shareRepo repository.SharesRepository, userRepo repository.UserRepository, publisher NotePublisher) *CollabService {
+	return &CollabService{
+		noteRepo: noteRepo,
+		userRepo: userRepo,
+		access:   noteAccess{noteRepo: noteRepo, shareRepo: shareRepo},
+		events:   noteEvents{shareRepo: shareRepo, publisher: publisher},
+		sessions: make(map[uuid.UUID]*collabSession),
+	}
+}
+
+type collabSession struct {
+	noteId uuid.UUID
+
+	mu    sync.Mutex
+	doc   *crdt.Doc
+	epoch int
+	peers map[uint32]*CollabPeer
+	// lastSite is the site given to the latest participant, site 0 is the loaded text
+	lastSite uint32
+	// base and baseText are the version and the content of the note when the session last
+	// loaded or saved it, to notice changes made past the session
+	base     int64
+	baseText string
+	dirty    bool
+	closed   bool
+}
+
+// CollabPeer is one connection to an editing session.
+type CollabPeer struct {
+	service    *CollabService
+	session    *collabSession
+	site       uint32
+	userId     uuid.UUID
+	username   string
+	permission string
+	cursor     *dto.CollabCursorRange
+	out        chan dto.CollabMessage
+}
+
+// Join connects the user to the editing session of the note, starting it when nobody edits
+// the note yet. Viewers, and tokens without notes:write when readOnly is set, only watch.
+// The first message of the peer is hello with the document.
+func (s *CollabService) Join(userId uuid.UUID, noteId uuid.UUID, readOnly bool) (*CollabPeer, error) {
+	note, err := s.access.check(userId, noteId, models.PermissionViewer)
+	if err != nil {
+		return nil, err
+	}
+	permission, err := s.access.permission(userId, note)
+	if err != nil {
+		return nil, err
+	}
+	if readOnly {
+		permission = models.PermissionViewer
+	}
+
+	user, err := s.userRepo.GetUserById(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session := s.sessions[noteId]
+	if session == nil || session.closed {
+		// заметку перечитываем под блокировкой: последний ушедший мог только что её сохранить
+		if note, err = s.noteRepo.Get(noteId); err != nil {
+			return nil, err
+		}
+		session = &collabSession{
+			noteId:   noteId,
+			doc:      crdt.FromText(note.Content),
+			peers:    make(map[uint32]*CollabPeer),
+			base:     note.Version,
+			baseText: note.Content,
+		}
+		s.sessions[noteId] = session
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	session.lastSite++
+	peer := &CollabPeer{
+		service:    s,
+		session:    session,
+		site:       session.lastSite,
+		userId:     userId,
+		username:   user.Username,
+		permission: permission,
+		out:        make(chan dto.CollabMessage, collabPeerBuffer),
+	}
+
+	hello := session.snapshot(dto.CollabHello)
+	hello.Site = peer.site
+	hello.UserId = &peer.userId
+	hello.Permission = permission
+	hello.Peers = []dto.CollabPeerResponse{}
+	for _, other := range session.peers {
+		hello.Peers = append(hello.Peers, dto.CollabPeerResponse{
+			Site:       other.site,
+			UserId:     other.userId,
+			Username:   other.username,
+			Permission: other.permission,
+			Cursor:     other.cursor,
+		})
+	}
+	peer.out <- hello
+
+	session.broadcast(peer.presence("join"), peer.site)
+	session.peers[peer.site] = peer
+	return peer, nil
+}
+
+// Messages delivers what the peer should send to its client. It is closed when the peer
+// leaves or is dropped for falling behind, the connection should then be closed.
+func (p *CollabPeer) Messages() <-chan dto.CollabMessage {
+	return p.out
+}
+
+// Apply integrates operations of the peer and passes them on to the others. The operations
+// before the first invalid one stay applied. A message of more than maxCollabOps operations
+// is refused whole, it would hold the session lock for too long.
+func (p *CollabPeer) Apply(epoch int, ops []crdt.Op) error {
+	if len(ops) > maxCollabOps {
+		return fmt.Errorf("%w: at most %d operations per message", crdt.ErrInvalidOp, maxCollabOps)
+	}
+
+	session := p.session
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	if session.peers[p.site] != p {
+		return ErrCollabClosed
+	}
+	if models.PermissionRank(p.permission) < models.PermissionRank(models.PermissionEditor) {
+		return fmt.Errorf("%w: you can only watch this note", ErrAccessDenied)
+	}
+	if epoch != session.epoch {
+		return ErrCollabStale
+	}
+
+	var err error
+	applied := make([]crdt.Op, 0, len(ops))
+	for _, op := range ops {
+		if op.Type == crdt.OpInsert {
+			// свой site выдаёт сервер, так id разных участников не совпадут
+			if op.ID.Site != p.site {
+				err = fmt.Errorf("%w: insert with site %d, yours is %d", crdt.ErrInvalidOp, op.ID.Site, p.site)
+				break
+			}
+			if session.doc.Len()+len(op.Text) > maxCollabLength {
+				err = ErrCollabTooLarge
+				break
+			}
+		}
+		if err = session.doc.Apply(op); err != nil {
+			break
+		}
+		applied = append(applied, op)
+	}
+
+	if len(applied) > 0 {
+		session.dirty = true
+		session.broadcast(dto.CollabMessage{
+			Type:  dto.CollabOps,
+			Epoch: session.epoch,
+			Site:  p.site,
+			Ops:   applied,
+		}, p.site)
+	}
+	return err
+}
+
+// SetCursor shows the selection of the peer to the others.
+func (p *CollabPeer) SetCursor(cursor *dto.CollabCursorRange) error {
+	session := p.session
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	if session.peers[p.site] != p {
+		return ErrCollabClosed
+	}
+
+	p.cursor = cursor
+	msg := p.presence("")
+	msg.Type = dto.CollabCursor
+	msg.Cursor = cursor
+	session.broadcast(msg, p.site)
+	return nil
+}
+
+// SendError tells the client of the peer that its last message was refused.
+func (p *CollabPeer) SendError(err error) {
+	session := p.session
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	if session.peers[p.site] == p {
+		session.send(p, dto.CollabMessage{Type: dto.CollabError, Error: err.Error()})
+	}
+}
+
+// Leave disconnects the peer. The last one to leave saves the note and ends the session.
+func (p *CollabPeer) Leave() {
+	s := p.service
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session := p.session
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	session.remove(p)
+	if len(session.peers) > 0 || session.closed {
+		return
+	}
+
+	session.closed = true
+	if s.sessions[session.noteId] == session {
+		delete(s.sessions, session.noteId)
+	}
+	if _, err := s.sync(session); err != nil {
+		slog.Error("Failed to save collaborative note", "note", session.noteId, "error", err)
+	}
+}
+
+// Compact writes the merged text of every session with changes back to its note, for the
+// background job. It also notices notes that were changed or deleted past the sessions.
+func (s *CollabService) Compact() (int64, error) {
+	var saved int64
+	var errs []error
+	for _, session := range s.activeSessions() {
+		session.mu.Lock()
+		ok, err := s.sync(session)
+		closed := session.closed
+		session.mu.Unlock()
+
+		if closed {
+			// заметку удалили: участников уже отключили, и Leave пустую сессию не уберёт
+			s.forget(session)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("note %s: %w", session.noteId, err))
+		}
+		if ok {
+			saved++
+		}
+	}
+	return saved, errors.Join(errs...)
+}
+
+// Close saves every session and disconnects everyone, for the server shutdown.
+func (s *CollabService) Close() error {
+	var errs []error
+	for _, session := range s.activeSessions() {
+		session.mu.Lock()
+		if _, err := s.sync(session); err != nil {
+			errs = append(errs, fmt.Errorf("note %s: %w", session.noteId, err))
+		}
+		session.end("the server is restarting, connect again")
+		session.mu.Unlock()
+		s.forget(session)
+	}
+	return errors.Join(errs...)
+}
+
+// forget drops the ended session from the service unless Join has already replaced it.
+func (s *CollabService) forget(session *collabSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sessions[session.noteId] == session {
+		delete(s.sessions, session.noteId)
+	}
+}
+
+func (s *CollabService) activeSessions() []*collabSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessions := make([]*collabSession, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
+
+// sync brings the session and its note together, the caller holds the session lock.
+// It reports whether the merged text was saved.
+func (s *CollabService) sync(session *collabSession) (bool, error) {
+	note, err := s.noteRepo.Get(session.noteId)
+	if errors.Is(err, repository.ErrNotFound) {
+		// заметку удалили или убрали в корзину, правки сохранять некуда
+		session.end("the note was deleted")
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	s.recheckPeers(session, note)
+
+	if note.Version != session.base && note.Content == session.baseText {
+		// поменяли заголовок, теги или блокнот, текст тот же: правки сессии остаются в силе
+		session.base = note.Version
+	}
+	if note.Version != session.base {
+		// текст изменили в обход сессии, например через PUT /notes/{id}: побеждает его версия
+		session.doc = crdt.FromText(note.Content)
+		session.epoch++
+		session.base = note.Version
+		session.baseText = note.Content
+		session.dirty = false
+		session.broadcast(session.snapshot(dto.CollabReset), 0)
+		return false, nil
+	}
+	if !session.dirty {
+		return false, nil
+	}
+	text := session.doc.Text()
+	if text == note.Content {
+		// правки свелись к прежнему тексту, сохранять нечего
+		session.dirty = false
+		return false, nil
+	}
+	note.Content = text
+
+	err = s.noteRepo.Update(note)
+	if errors.Is(err, repository.ErrVersionConflict) {
+		// заметку изменили между чтением и записью, следующий sync её перечитает и сбросит сессию
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	// время изменения ставит хранилище
+	if note, err = s.noteRepo.Get(session.noteId); err != nil {
+		return false, err
+	}
+	session.base = note.Version
+	session.baseText = note.Content
+	session.dirty = false
+
+	s.events.publish(models.EventNoteUpdated, note)
+	return true, nil
+}
+
+// recheckPeers applies shares changed during the session: a revoked user is disconnected,
+// a changed permission takes effect.
+func (s *CollabService) recheckPeers(session *collabSession, note models.Note) {
+	for _, peer := range session.peers {
+		permission, err := s.access.permission(peer.userId, note)
+		if errors.Is(err, ErrAccessDenied) {
+			session.send(peer, dto.CollabMessage{Type: dto.CollabError, Error: "the note is no longer shared with you"})
+			session.remove(peer)
+			continue
+		}
+		if err != nil {
+			slog.Error("Failed to recheck collaborative note access", "note", note.ID, "user", peer.userId, "error", err)
+			continue
+		}
+		// права токена только на чтение так и остаются правами на чтение
+		if peer.permission != models.PermissionViewer || permission == models.PermissionViewer {
+			peer.permission = permission
+		}
+	}
+}
+
+func (session *collabSession) snapshot(msgType string) dto.CollabMessage {
+	return dto.CollabMessage{
+		Type:     msgType,
+		Epoch:    session.epoch,
+		Clock:    session.doc.Clock(),
+		Elements: session.doc.Elements(),
+	}
+}
+
+func (p *CollabPeer) presence(event string) dto.CollabMessage {
+	return dto.CollabMessage{
+		Type:       dto.CollabPresence,
+		Site:       p.site,
+		UserId:     &p.userId,
+		Username:   p.username,
+		Permission: p.permission,
+		Event:      event,
+	}
+}
+
+// broadcast sends the message to every peer but except (0 sends to everyone),
+// the caller holds the session lock.
+func (session *collabSession) broadcast(msg dto.CollabMessage, except uint32) {
+	for site, peer := range session.peers {
+		if site != except {
+			session.send(peer, msg)
+		}
+	}
+}
+
+// send never blocks: a peer whose queue is full is dropped, its client reconnects
+// and starts again from hello.
+func (session *collabSession) send(peer *CollabPeer, msg dto.CollabMessage) {
+	select {
+	case peer.out <- msg:
+	default:
+		session.remove(peer)
+	}
+}
+
+// remove disconnects the peer and tells the others, the caller holds the session lock.
+func (session *collabSession) remove(peer *CollabPeer) {
+	if session.peers[peer.site] != peer {
+		return
+	}
+	delete(session.peers, peer.site)
+	close(peer.out)
+	session.broadcast(peer.presence("leave"), 0)
+}
+
+// end disconnects everyone with the reason and closes the session, Join starts a new one.
+func (session *collabSession) end(reason string) {
+	session.closed = true
+	for _, peer := range session.peers {
+		session.send(peer, dto.CollabMessage{Type: dto.CollabError, Error: reason})
+		session.remove(peer)
+	}
+}
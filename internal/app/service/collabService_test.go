@@ -0,0 +1,60 @@
+package service_test
+
+import (
+	"2/internal/app/events"
+	"2/internal/app/service"
+	"2/internal/interface/http/dto"
+	"testing"
+)
+
+// A note deleted during a session disconnects everyone on the next Compact, and the ended
+// session is not kept for the life of the process.
+func TestCollabSessionOfDeletedNote(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b testBackend) {
+		bus := events.NewBus(16)
+		notes := service.NewNoteService(b.notes, b.notebooks, b.shares, bus)
+		collab := service.NewCollabService(b.notes, b.shares, b.users, bus)
+		userId := b.newUser(t, "collab")
+
+		note, err := notes.CreateNote(userId, dto.CreateNoteRequest{Title: "together", Content: "text"})
+		if err != nil {
+			t.Fatalf("create note: %v", err)
+		}
+		var peers []*service.CollabPeer
+		for i := 0; i < 2; i++ {
+			peer, err := collab.Join(userId, note.ID, false)
+			if err != nil {
+				t.Fatalf("join %d: %v", i+1, err)
+			}
+			peers = append(peers, peer)
+		}
+		if got := collab.SessionCount(); got != 1 {
+			t.Fatalf("sessions after join: got %d, want 1", got)
+		}
+
+		if err = notes.DeleteNote(userId, note.ID, 0); err != nil {
+			t.Fatalf("delete note: %v", err)
+		}
+		if _, err = collab.Compact(); err != nil {
+			t.Fatalf("compact: %v", err)
+		}
+		if got := collab.SessionCount(); got != 0 {
+			t.Errorf("sessions after the note was deleted: got %d, want 0", got)
+		}
+
+		for i, peer := range peers {
+			var last dto.CollabMessage
+			for msg := range peer.Messages() {
+				last = msg
+			}
+			if last.Type != dto.CollabError {
+				t.Errorf("peer %d: last message %q, want an error", i+1, last.Type)
+			}
+			// обработчик соединения зовёт Leave, когда канал закрыт
+			peer.Leave()
+		}
+		if got := collab.SessionCount(); got != 0 {
+			t.Errorf("sessions after the peers left: got %d, want 0", got)
+		}
+	})
+}
@@ -0,0 +1,6 @@
+package service
+
+// SessionCount reports how many editing sessions the service keeps, for the tests.
+func (s *CollabService) SessionCount() int {
+	return len(s.activeSessions())
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrTooManyAttempts    = errors.New("too many failed login attempts, try again later")
+)
+
+// LoginThrottledError is returned instead of checking the password while the account
+// or the address has to wait. It matches ErrTooManyAttempts with errors.Is.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return fmt.Sprintf("%s (retry in %s)", ErrTooManyAttempts, e.RetryAfter)
+}
+
+func (e *LoginThrottledError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// LoginGuard counts failed logins per account and per address. After a few failures
+// every next attempt has to wait twice as long, after many the account or the address
+// is locked out for a while and the lockout is recorded.
+type LoginGuard struct {
+	repo   repository.LoginGuardRepository
+	policy models.LoginPolicy
+}
+
+func NewLoginGuard(repo repository.LoginGuardRepository, policy models.LoginPolicy) *LoginGuard {
+	return &LoginGuard{
+		repo:   repo,
+		policy: policy,
+	}
+}
+
+// Check returns a LoginThrottledError when the account or the address may not try yet.
+// Unknown emails are throttled the same way, so the answer does not tell they are unknown.
+func (g *LoginGuard) Check(email string, ip string) error {
+	return g.check(g.keys(models.LockoutAccount, normalizeEmail(email), ip))
+}
+
+// CheckLink is Check for the password of a public link. Guesses are counted per link the
+// way they are per account, and per address together with logins.
+func (g *LoginGuard) CheckLink(slug string, ip string) error {
+	return g.check(g.keys(models.LockoutLink, slug, ip))
+}
+
+func (g *LoginGuard) check(keys []string) error {
+	throttles, err := g.repo.GetThrottles(keys...)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var until time.Time
+	for _, throttle := range throttles {
+		if throttle.BlockedUntil != nil && throttle.BlockedUntil.After(until) {
+			until = *throttle.BlockedUntil
+		}
+	}
+	if !until.After(now) {
+		return nil
+	}
+	return &LoginThrottledError{RetryAfter: until.Sub(now).Truncate(time.Second) + time.Second}
+}
+
+// Fail records a failed attempt and delays or locks out the account and the address.
+func (g *LoginGuard) Fail(email string, ip string) error {
+	return g.failBoth(models.LockoutAccount, normalizeEmail(email), ip)
+}
+
+// FailLink records a wrong password of a public link, it has the limits of an account.
+func (g *LoginGuard) FailLink(slug string, ip string) error {
+	return g.failBoth(models.LockoutLink, slug, ip)
+}
+
+func (g *LoginGuard) failBoth(kind string, subject string, ip string) error {
+	now := time.Now()
+	if err := g.fail(kind, subject, ip, g.policy.Account, now); err != nil {
+		return err
+	}
+	if ip == "" {
+		return nil
+	}
+	return g.fail(models.LockoutIP, ip, ip, g.policy.IP, now)
+}
+
+func (g *LoginGuard) fail(kind string, subject string, ip string, limit models.LoginLimit, now time.Time) error {
+	key := kind + ":" + subject
+	failures, err := g.repo.RecordFailure(key, now, now.Add(-g.policy.ResetAfter))
+	if err != nil {
+		return err
+	}
+
+	var until time.Time
+	switch {
+	case limit.LockoutAfter > 0 && failures >= limit.LockoutAfter:
+		until = now.Add(g.policy.LockoutDuration)
+		err = g.repo.CreateLockout(models.LockoutEvent{
+			ID:          uuid.New(),
+			Kind:        kind,
+			Subject:     subject,
+			IP:          ip,
+			Failures:    failures,
+			LockedAt:    now,
+			LockedUntil: until,
+		})
+		if err != nil {
+			return err
+		}
+		slog.Warn("Login locked out", "kind", kind, "subject", subject, "ip", ip, "failures", failures)
+	case failures > limit.FreeAttempts:
+		until = now.Add(g.delay(failures - limit.FreeAttempts))
+	default:
+		return nil
+	}
+
+	return g.repo.Block(key, until)
+}
+
+// delay is BaseDelay for the first failure over the free ones, doubled for every next one.
+func (g *LoginGuard) delay(over int) time.Duration {
+	delay := g.policy.BaseDelay
+	for i := 1; i < over && delay < g.policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, g.policy.MaxDelay)
+}
+
+// Succeed forgets the failures of the account. Failures of the address stay,
+// otherwise logging into an own account would hide guessing at others.
+func (g *LoginGuard) Succeed(email string) error {
+	return g.reset(models.LockoutAccount + ":" + normalizeEmail(email))
+}
+
+// SucceedLink forgets the failures of a public link once its password was right.
+func (g *LoginGuard) SucceedLink(slug string) error {
+	return g.reset(models.LockoutLink + ":" + slug)
+}
+
+func (g *LoginGuard) reset(key string) error {
+	err := g.repo.Reset(key)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Unlock lifts the delay or lockout of an account (by email), of an address or of a
+// public link ("link:<slug>"). It returns ErrNotFound when there is nothing to lift.
+func (g *LoginGuard) Unlock(subject string) error {
+	kind := models.LockoutAccount
+	if slug, ok := strings.CutPrefix(subject, models.LockoutLink+":"); ok {
+		// slug чувствителен к регистру, его не приводим
+		kind, subject = models.LockoutLink, slug
+	} else if subject = normalizeEmail(subject); net.ParseIP(subject) != nil {
+		kind = models.LockoutIP
+	}
+
+	if err := g.repo.Reset(kind + ":" + subject); err != nil {
+		return err
+	}
+	return g.repo.MarkUnlocked(kind, subject, time.Now())
+}
+
+// Lockouts returns the latest lockouts, newest first.
+func (g *LoginGuard) Lockouts(limit int) ([]models.LockoutEvent, error) {
+	return g.repo.GetLockouts(limit)
+}
+
+// PurgeThrottles removes counters of accounts and addresses that stopped failing.
+func (g *LoginGuard) PurgeThrottles() (int64, error) {
+	return g.repo.DeleteIdle(time.Now().Add(-g.policy.ResetAfter))
+}
+
+func (g *LoginGuard) keys(kind string, subject string, ip string) []string {
+	keys := []string{kind + ":" + subject}
+	if ip != "" {
+		keys = append(keys, models.LockoutIP+":"+ip)
+	}
+	return keys
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
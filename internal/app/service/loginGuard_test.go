@@ -0,0 +1,181 @@
+package service_test
+
+import (
+	"2/internal/app/service"
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"2/internal/infrastructure/storage/memory"
+	"2/internal/interface/http/dto"
+	"errors"
+	"testing"
+	"time"
+)
+
+var guardPolicy = models.LoginPolicy{
+	Account:         models.LoginLimit{FreeAttempts: 2, LockoutAfter: 7},
+	IP:              models.LoginLimit{FreeAttempts: 20, LockoutAfter: 50},
+	BaseDelay:       time.Second,
+	MaxDelay:        4 * time.Second,
+	LockoutDuration: time.Hour,
+	ResetAfter:      time.Hour,
+}
+
+// retryAfter returns how long Check makes the login wait, zero when it may try now.
+func retryAfter(t *testing.T, guard *service.LoginGuard, email string, ip string) time.Duration {
+	t.Helper()
+
+	err := guard.Check(email, ip)
+	if err == nil {
+		return 0
+	}
+	var throttled *service.LoginThrottledError
+	if !errors.As(err, &throttled) || !errors.Is(err, service.ErrTooManyAttempts) {
+		t.Fatalf("check: got %v, want LoginThrottledError", err)
+	}
+	return throttled.RetryAfter
+}
+
+func TestLoginGuardDelays(t *testing.T) {
+	guard := service.NewLoginGuard(memory.NewLoginGuardRepository(), guardPolicy)
+
+	// первые FreeAttempts ошибок бесплатны, дальше задержка удваивается до MaxDelay
+	want := []time.Duration{0, 0, time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second}
+	for i, delay := range want {
+		if err := guard.Fail("ann@notes.test", "192.0.2.1"); err != nil {
+			t.Fatalf("fail %d: %v", i+1, err)
+		}
+		if got := retryAfter(t, guard, "ann@notes.test", "192.0.2.1"); got != delay {
+			t.Errorf("after %d failures: retry in %s, want %s", i+1, got, delay)
+		}
+	}
+
+	// ящик один и тот же независимо от регистра и пробелов
+	if got := retryAfter(t, guard, " ANN@notes.test", ""); got != 4*time.Second {
+		t.Errorf("same email in other case: retry in %s, want 4s", got)
+	}
+	if got := retryAfter(t, guard, "bob@notes.test", "192.0.2.1"); got != 0 {
+		t.Errorf("other account from the same address: retry in %s, want no wait", got)
+	}
+	if lockouts, _ := guard.Lockouts(10); len(lockouts) != 0 {
+		t.Errorf("%d lockouts before LockoutAfter, want none", len(lockouts))
+	}
+}
+
+func TestLoginGuardLockout(t *testing.T) {
+	guard := service.NewLoginGuard(memory.NewLoginGuardRepository(), guardPolicy)
+
+	for i := 0; i < guardPolicy.Account.LockoutAfter; i++ {
+		if err := guard.Fail("ann@notes.test", "192.0.2.1"); err != nil {
+			t.Fatalf("fail %d: %v", i+1, err)
+		}
+	}
+	if got := retryAfter(t, guard, "ann@notes.test", ""); got <= guardPolicy.MaxDelay || got > guardPolicy.LockoutDuration {
+		t.Errorf("locked out account: retry in %s, want the lockout duration %s", got, guardPolicy.LockoutDuration)
+	}
+
+	lockouts, err := guard.Lockouts(10)
+	if err != nil {
+		t.Fatalf("lockouts: %v", err)
+	}
+	if len(lockouts) != 1 {
+		t.Fatalf("%d lockouts, want 1", len(lockouts))
+	}
+	event := lockouts[0]
+	if event.Kind != models.LockoutAccount || event.Subject != "ann@notes.test" || event.IP != "192.0.2.1" ||
+		event.Failures != guardPolicy.Account.LockoutAfter || event.UnlockedAt != nil {
+		t.Errorf("lockout %+v", event)
+	}
+
+	if err = guard.Unlock("Ann@notes.test"); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if got := retryAfter(t, guard, "ann@notes.test", ""); got != 0 {
+		t.Errorf("unlocked account: retry in %s, want no wait", got)
+	}
+	if lockouts, _ = guard.Lockouts(10); lockouts[0].UnlockedAt == nil {
+		t.Errorf("lockout is not marked as unlocked")
+	}
+	if err = guard.Unlock("nobody@notes.test"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("unlock of an account without failures: got %v, want ErrNotFound", err)
+	}
+}
+
+// Guessing passwords of many accounts from one address locks out the address, a successful
+// login into an own account does not reset it.
+func TestLoginGuardAddress(t *testing.T) {
+	policy := guardPolicy
+	policy.IP = models.LoginLimit{FreeAttempts: 1, LockoutAfter: 3}
+	guard := service.NewLoginGuard(memory.NewLoginGuardRepository(), policy)
+
+	for _, email := range []string{"a@notes.test", "b@notes.test", "c@notes.test"} {
+		if err := guard.Fail(email, "192.0.2.1"); err != nil {
+			t.Fatalf("fail %s: %v", email, err)
+		}
+	}
+	if err := guard.Succeed("own@notes.test"); err != nil {
+		t.Fatalf("succeed: %v", err)
+	}
+	if got := retryAfter(t, guard, "own@notes.test", "192.0.2.1"); got <= policy.MaxDelay {
+		t.Errorf("locked out address: retry in %s, want the lockout", got)
+	}
+	if got := retryAfter(t, guard, "a@notes.test", "198.51.100.7"); got != 0 {
+		t.Errorf("account with one failure from another address: retry in %s, want no wait", got)
+	}
+
+	if err := guard.Unlock("192.0.2.1"); err != nil {
+		t.Fatalf("unlock address: %v", err)
+	}
+	if got := retryAfter(t, guard, "own@notes.test", "192.0.2.1"); got != 0 {
+		t.Errorf("unlocked address: retry in %s, want no wait", got)
+	}
+}
+
+func TestLoginGuardSucceedResetsAccount(t *testing.T) {
+	guard := service.NewLoginGuard(memory.NewLoginGuardRepository(), guardPolicy)
+
+	for i := 0; i < guardPolicy.Account.FreeAttempts+1; i++ {
+		if err := guard.Fail("ann@notes.test", "192.0.2.1"); err != nil {
+			t.Fatalf("fail: %v", err)
+		}
+	}
+	if err := guard.Succeed("ANN@notes.test"); err != nil {
+		t.Fatalf("succeed: %v", err)
+	}
+	// после успешного входа счёт начинается заново
+	for i := 0; i < guardPolicy.Account.FreeAttempts; i++ {
+		if err := guard.Fail("ann@notes.test", "192.0.2.1"); err != nil {
+			t.Fatalf("fail: %v", err)
+		}
+	}
+	if got := retryAfter(t, guard, "ann@notes.test", "192.0.2.1"); got != 0 {
+		t.Errorf("free attempts after a success: retry in %s, want no wait", got)
+	}
+	if err := guard.Succeed("never-failed@notes.test"); err != nil {
+		t.Errorf("succeed without failures: %v", err)
+	}
+}
+
+// The login checks the guard before the password: a throttled login fails even with the
+// right password and unknown emails are throttled like known ones.
+func TestLoginThrottled(t *testing.T) {
+	env := newAuthEnv(t, guardPolicy)
+	env.register(t, "ann@notes.test", "long password")
+
+	for _, email := range []string{"ann@notes.test", "ghost@notes.test"} {
+		var err error
+		for i := 0; i <= guardPolicy.Account.FreeAttempts; i++ {
+			_, err = env.auth.LoginUser(dto.LoginRequest{Email: email, Password: "wrong password"}, models.ClientInfo{IP: "192.0.2.1"})
+		}
+		if !errors.Is(err, service.ErrInvalidCredentials) {
+			t.Fatalf("%s: last free failure got %v, want ErrInvalidCredentials", email, err)
+		}
+		if _, err = env.auth.LoginUser(dto.LoginRequest{Email: email, Password: "long password"}, models.ClientInfo{IP: "192.0.2.1"}); !errors.Is(err, service.ErrTooManyAttempts) {
+			t.Errorf("%s: login while delayed got %v, want ErrTooManyAttempts", email, err)
+		}
+	}
+
+	if err := env.guard.Unlock("ann@notes.test"); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	env.login(t, "ann@notes.test", "long password")
+}
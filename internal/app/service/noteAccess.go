@@ -0,0 +1,55 @@
+package service
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+)
+
+// noteAccess decides what a user may do with a note. The owner may do everything, other
+// users only what the note was shared with them:
+//
+//	         read, revisions   edit, restore revision   trash, move, share
+//	viewer   yes               no                       no
+//	editor   yes               yes                      no
+//	owner    yes               yes                      yes
+type noteAccess struct {
+	noteRepo  repository.NotesRepository
+	shareRepo repository.SharesRepository
+}
+
+// check loads the note and makes sure the user holds at least the permission.
+func (a noteAccess) check(userId uuid.UUID, noteId uuid.UUID, need string) (models.Note, error) {
+	note, err := a.noteRepo.Get(noteId)
+	if err != nil {
+		return models.Note{}, err
+	}
+
+	permission, err := a.permission(userId, note)
+	if err != nil {
+		return models.Note{}, err
+	}
+
+	if models.PermissionRank(permission) < models.PermissionRank(need) {
+		return models.Note{}, fmt.Errorf("%w: the note is shared with you as %s", ErrAccessDenied, permission)
+	}
+	return note, nil
+}
+
+// permission tells what the user holds on the note, ErrAccessDenied when it is not shared with them.
+func (a noteAccess) permission(userId uuid.UUID, note models.Note) (string, error) {
+	if note.UserId == userId {
+		return models.PermissionOwner, nil
+	}
+
+	share, err := a.shareRepo.Get(note.ID, userId)
+	if errors.Is(err, repository.ErrNotFound) {
+		return "", ErrAccessDenied
+	}
+	if err != nil {
+		return "", err
+	}
+	return share.Permission, nil
+}
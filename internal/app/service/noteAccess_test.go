@@ -0,0 +1,193 @@
+package service_test
+
+import (
+	"2/internal/app/events"
+	"2/internal/app/service"
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"2/internal/interface/http/dto"
+	"errors"
+	"github.com/google/uuid"
+	"slices"
+	"testing"
+)
+
+type accessEnv struct {
+	notes     *service.NoteService
+	revisions *service.RevisionService
+	shares    *service.ShareService
+	links     *service.PublicLinkService
+	// пользователи по ролям: owner, editor, viewer и stranger, которому заметку не открывали
+	users map[string]uuid.UUID
+}
+
+func newAccessEnv(t *testing.T, b testBackend) *accessEnv {
+	t.Helper()
+
+	bus := events.NewBus(16)
+	env := &accessEnv{
+		notes:     service.NewNoteService(b.notes, b.notebooks, b.shares, bus),
+		revisions: service.NewRevisionService(b.revisions, b.notes, b.shares, bus, models.RevisionPolicy{}),
+		shares:    service.NewShareService(b.shares, b.notes, b.users),
+		links:     service.NewPublicLinkService(b.links, b.notes, b.shares, b.users, nil, nil, "http://notes.test"),
+		users:     make(map[string]uuid.UUID),
+	}
+	for _, role := range []string{"owner", "editor", "viewer", "stranger"} {
+		env.users[role] = b.newUser(t, role)
+	}
+	return env
+}
+
+// sharedNote creates a note of the owner with two revisions and shares it with the editor
+// and the viewer.
+func (e *accessEnv) sharedNote(t *testing.T) models.Note {
+	t.Helper()
+
+	owner := e.users["owner"]
+	note, err := e.notes.CreateNote(owner, dto.CreateNoteRequest{Title: "shared", Content: "first"})
+	if err != nil {
+		t.Fatalf("create note: %v", err)
+	}
+	if note, err = e.notes.UpdateNote(owner, note.ID, dto.UpdateNoteRequest{Title: "shared", Content: "second"}, 0); err != nil {
+		t.Fatalf("update note: %v", err)
+	}
+	for _, role := range []string{models.PermissionEditor, models.PermissionViewer} {
+		req := dto.ShareNoteRequest{Email: role + "@notes.test", Permission: role}
+		if _, err = e.shares.ShareNote(owner, note.ID, req); err != nil {
+			t.Fatalf("share with %s: %v", role, err)
+		}
+	}
+	return note
+}
+
+func TestNoteAccessMatrix(t *testing.T) {
+	actions := []struct {
+		name string
+		// allowed lists the roles that may do it, the others get ErrAccessDenied
+		allowed []string
+		do      func(e *accessEnv, userId uuid.UUID, note models.Note) error
+	}{
+		{"get", []string{"owner", "editor", "viewer"}, func(e *accessEnv, userId uuid.UUID, note models.Note) error {
+			_, err := e.notes.GetNote(userId, note.ID)
+			return err
+		}},
+		{"update", []string{"owner", "editor"}, func(e *accessEnv, userId uuid.UUID, note models.Note) error {
+			_, err := e.notes.UpdateNote(userId, note.ID, dto.UpdateNoteRequest{Title: "shared", Content: "changed"}, note.Version)
+			return err
+		}},
+		{"delete", []string{"owner"}, func(e *accessEnv, userId uuid.UUID, note models.Note) error {
+			return e.notes.DeleteNote(userId, note.ID, note.Version)
+		}},
+		{"move", []string{"owner"}, func(e *accessEnv, userId uuid.UUID, note models.Note) error {
+			_, err := e.notes.MoveNote(userId, note.ID, nil, note.Version)
+			return err
+		}},
+		{"list revisions", []string{"owner", "editor", "viewer"}, func(e *accessEnv, userId uuid.UUID, note models.Note) error {
+			_, err := e.revisions.GetRevisions(userId, note.ID)
+			return err
+		}},
+		{"get revision", []string{"owner", "editor", "viewer"}, func(e *accessEnv, userId uuid.UUID, note models.Note) error {
+			_, err := e.revisions.GetRevision(userId, note.ID, 1)
+			return err
+		}},
+		{"diff revisions", []string{"owner", "editor", "viewer"}, func(e *accessEnv, userId uuid.UUID, note models.Note) error {
+			_, err := e.revisions.DiffRevisions(userId, note.ID, 1, 2, false)
+			return err
+		}},
+		{"restore revision", []string{"owner", "editor"}, func(e *accessEnv, userId uuid.UUID, note models.Note) error {
+			_, err := e.revisions.RestoreRevision(userId, note.ID, 1)
+			return err
+		}},
+		{"share", []string{"owner"}, func(e *accessEnv, userId uuid.UUID, note models.Note) error {
+			_, err := e.shares.ShareNote(userId, note.ID, dto.ShareNoteRequest{Email: "stranger@notes.test", Permission: models.PermissionViewer})
+			return err
+		}},
+		{"list shares", []string{"owner"}, func(e *accessEnv, userId uuid.UUID, note models.Note) error {
+			_, err := e.shares.GetShares(userId, note.ID)
+			return err
+		}},
+		{"revoke another user's share", []string{"owner"}, func(e *accessEnv, userId uuid.UUID, note models.Note) error {
+			other := e.users["editor"]
+			if userId == other {
+				other = e.users["viewer"]
+			}
+			return e.shares.RevokeShare(userId, note.ID, other)
+		}},
+		{"create link", []string{"owner"}, func(e *accessEnv, userId uuid.UUID, note models.Note) error {
+			_, err := e.links.CreateLink(userId, note.ID, dto.CreatePublicLinkRequest{})
+			return err
+		}},
+		{"list links", []string{"owner"}, func(e *accessEnv, userId uuid.UUID, note models.Note) error {
+			_, err := e.links.GetLinks(userId, note.ID)
+			return err
+		}},
+	}
+
+	eachBackend(t, func(t *testing.T, b testBackend) {
+		env := newAccessEnv(t, b)
+
+		for _, action := range actions {
+			for _, role := range []string{"owner", "editor", "viewer", "stranger"} {
+				t.Run(action.name+"/"+role, func(t *testing.T) {
+					note := env.sharedNote(t)
+					err := action.do(env, env.users[role], note)
+
+					allowed := slices.Contains(action.allowed, role)
+					switch {
+					case allowed && err != nil:
+						t.Errorf("got %v, want it allowed", err)
+					case !allowed && !errors.Is(err, service.ErrAccessDenied):
+						t.Errorf("got %v, want ErrAccessDenied", err)
+					}
+
+					// отказ ничего не меняет в заметке
+					if !allowed {
+						current, err := env.notes.GetNote(env.users["owner"], note.ID)
+						if err != nil || current.Version != note.Version {
+							t.Errorf("note after a denied %s: %+v, %v, want it untouched", action.name, current, err)
+						}
+					}
+				})
+			}
+		}
+	})
+}
+
+func TestRevokeShare(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b testBackend) {
+		env := newAccessEnv(t, b)
+		note := env.sharedNote(t)
+		owner, editor, viewer, stranger := env.users["owner"], env.users["editor"], env.users["viewer"], env.users["stranger"]
+
+		// отказаться от чужой заметки может только тот, с кем ей поделились
+		if err := env.shares.RevokeShare(stranger, note.ID, viewer); !errors.Is(err, service.ErrAccessDenied) {
+			t.Errorf("stranger revokes the viewer: got %v, want ErrAccessDenied", err)
+		}
+		if err := env.shares.RevokeShare(viewer, note.ID, editor); !errors.Is(err, service.ErrAccessDenied) {
+			t.Errorf("viewer revokes the editor: got %v, want ErrAccessDenied", err)
+		}
+		if err := env.shares.RevokeShare(stranger, note.ID, stranger); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("stranger gives up a share they never had: got %v, want ErrNotFound", err)
+		}
+
+		if err := env.shares.RevokeShare(viewer, note.ID, viewer); err != nil {
+			t.Fatalf("viewer gives up the share: %v", err)
+		}
+		if _, err := env.notes.GetNote(viewer, note.ID); !errors.Is(err, service.ErrAccessDenied) {
+			t.Errorf("viewer reads after giving up the share: got %v, want ErrAccessDenied", err)
+		}
+		if shared, err := env.shares.GetSharedWithMe(viewer); err != nil || len(shared) != 0 {
+			t.Errorf("shared with the viewer: %v, %v, want nothing", shared, err)
+		}
+
+		if err := env.shares.RevokeShare(owner, note.ID, editor); err != nil {
+			t.Fatalf("owner revokes the editor: %v", err)
+		}
+		if _, err := env.notes.UpdateNote(editor, note.ID, dto.UpdateNoteRequest{Title: "shared", Content: "late"}, 0); !errors.Is(err, service.ErrAccessDenied) {
+			t.Errorf("editor writes after the revoke: got %v, want ErrAccessDenied", err)
+		}
+		if shares, err := env.shares.GetShares(owner, note.ID); err != nil || len(shares) != 0 {
+			t.Errorf("shares left: %v, %v, want none", shares, err)
+		}
+	})
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"github.com/google/uuid"
+	"log/slog"
+	"time"
+)
+
+// NotePublisher delivers note changes to whoever listens, such as the /events stream.
+type NotePublisher interface {
+	Publish(event models.NoteEvent) models.NoteEvent
+}
+
+// noteEvents publishes a change of a note to its owner and everyone it is shared with.
+type noteEvents struct {
+	shareRepo repository.SharesRepository
+	publisher NotePublisher
+}
+
+func (e noteEvents) publish(eventType string, note models.Note) {
+	recipients := []uuid.UUID{note.UserId}
+	shares, err := e.shareRepo.GetAllByNoteId(note.ID)
+	if err != nil {
+		// изменение уже сохранено, без событий клиенты просто узнают о нём позже
+		slog.Error("Failed to load note shares for an event", "note", note.ID, "error", err)
+	}
+	for _, share := range shares {
+		recipients = append(recipients, share.UserId)
+	}
+
+	event := models.NoteEvent{
+		Type:       eventType,
+		NoteId:     note.ID,
+		Recipients: recipients,
+		At:         time.Now(),
+	}
+	if eventType != models.EventNoteDeleted {
+		event.Note = &note
+	}
+	e.publisher.Publish(event)
+}
@@ -2,81 +2,298 @@ package service
 
 import (
 	"2/internal/domain/models"
-	"2/internal/infrastructure/storage"
+	"2/internal/domain/repository"
 	"2/internal/interface/http/dto"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	"time"
 )
 
+var ErrAccessDenied = errors.New("Accsess denied")
+
+var ErrInvalidCursor = errors.New("cursor is invalid")
+
+// VersionMismatchError is returned when the note is no longer at the version the client
+// read it at. It matches repository.ErrVersionConflict with errors.Is.
+type VersionMismatchError struct {
+	Current int64
+}
+
+func (e *VersionMismatchError) Error() string {
+	return fmt.Sprintf("the note was changed since you read it, its version is now %d", e.Current)
+}
+
+func (e *VersionMismatchError) Unwrap() error {
+	return repository.ErrVersionConflict
+}
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 200
+)
+
 type NoteService struct {
-	noteRepo storage.NotesRepository
+	noteRepo     repository.NotesRepository
+	notebookRepo repository.NotebooksRepository
+	access       noteAccess
+	events       noteEvents
 }
 
-func NewNoteService(noteRepo storage.NotesRepository) *NoteService {
-	return &NoteService{noteRepo: noteRepo}
+func NewNoteService(noteRepo repository.NotesRepository, notebookRepo repository.NotebooksRepository, shareRepo repository.SharesRepository, publisher NotePublisher) *NoteService {
+	return &NoteService{
+		noteRepo:     noteRepo,
+		notebookRepo: notebookRepo,
+		access:       noteAccess{noteRepo: noteRepo, shareRepo: shareRepo},
+		events:       noteEvents{shareRepo: shareRepo, publisher: publisher},
+	}
 }
 
 func (s *NoteService) CreateNote(userId uuid.UUID, req dto.CreateNoteRequest) (models.Note, error) {
+	return s.create(userId, uuid.New(), req)
+}
+
+// create makes a note with the given id, offline clients choose ids of their notes themselves.
+func (s *NoteService) create(userId uuid.UUID, id uuid.UUID, req dto.CreateNoteRequest) (models.Note, error) {
 	if req.Title == "" {
 		return models.Note{}, errors.New("title is required")
 	}
 
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		return models.Note{}, err
+	}
+
+	if err = s.checkNotebook(userId, req.NotebookId); err != nil {
+		return models.Note{}, err
+	}
+
 	note := models.Note{
-		ID:        uuid.New(),
-		UserId:    userId,
-		Title:     req.Title,
-		Content:   req.Content,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		ID:         id,
+		UserId:     userId,
+		NotebookId: req.NotebookId,
+		Title:      req.Title,
+		Content:    req.Content,
+		Tags:       tags,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+		Version:    1,
 	}
 
-	return s.noteRepo.Create(note)
+	if err := s.noteRepo.Create(note); err != nil {
+		return models.Note{}, err
+	}
+
+	s.events.publish(models.EventNoteCreated, note)
+	return note, nil
 }
 
+// GetNote returns a note of the user or a note shared with them.
 func (s *NoteService) GetNote(userId uuid.UUID, noteId uuid.UUID) (models.Note, error) {
+	return s.access.check(userId, noteId, models.PermissionViewer)
+}
 
-	note, err := s.noteRepo.Get(noteId)
+// UpdateNote saves a new title, content and tags of a note of the user or a note shared with
+// them as editor. A non-zero version is the one the client read, the note is only changed
+// while it is still at that version.
+func (s *NoteService) UpdateNote(userId uuid.UUID, noteId uuid.UUID, req dto.UpdateNoteRequest, version int64) (models.Note, error) {
+
+	note, err := s.access.check(userId, noteId, models.PermissionEditor)
 	if err != nil {
 		return models.Note{}, err
 	}
+	if version != 0 && note.Version != version {
+		return models.Note{}, &VersionMismatchError{Current: note.Version}
+	}
 
-	if note.UserId != userId {
-		return models.Note{}, errors.New("Accsess denied")
+	if req.Title == "" {
+		return models.Note{}, errors.New("title is required")
+	}
+
+	if req.Content == "" {
+		return models.Note{}, errors.New("content is required")
 	}
 
+	// без поля tags в запросе теги заметки не трогаем
+	if req.Tags != nil {
+		note.Tags, err = normalizeTags(req.Tags)
+		if err != nil {
+			return models.Note{}, err
+		}
+	}
+
+	note.Title = req.Title
+	note.Content = req.Content
+	note.UpdatedAt = time.Now()
+	// без версии от клиента сверяем с той, что прочитали сами: чужая запись между чтением
+	// и записью тоже конфликт, а не молча затёртые правки
+	err = s.noteRepo.Update(note)
+	if errors.Is(err, repository.ErrVersionConflict) {
+		return models.Note{}, s.versionMismatch(noteId)
+	}
+	if err != nil {
+		return models.Note{}, err
+	}
+	note.Version++
+
+	s.events.publish(models.EventNoteUpdated, note)
 	return note, nil
 }
 
-func (s *NoteService) UpdateNote(userId uuid.UUID, noteId uuid.UUID, req dto.UpdateNoteRequest) error {
+// MoveNote puts the note into one of the user's notebooks, nil moves it to the top level.
+// Notebooks belong to the owner, so only the owner moves the note. The move is a change of
+// the note: a non-zero version must match like in UpdateNote, and the version moves on.
+func (s *NoteService) MoveNote(userId uuid.UUID, noteId uuid.UUID, notebookId *uuid.UUID, version int64) (models.Note, error) {
+	note, err := s.access.check(userId, noteId, models.PermissionOwner)
+	if err != nil {
+		return models.Note{}, err
+	}
+	if version != 0 && note.Version != version {
+		return models.Note{}, &VersionMismatchError{Current: note.Version}
+	}
+
+	if err = s.checkNotebook(userId, notebookId); err != nil {
+		return models.Note{}, err
+	}
+
+	err = s.noteRepo.Move(note.ID, notebookId, note.Version)
+	if errors.Is(err, repository.ErrVersionConflict) {
+		return models.Note{}, s.versionMismatch(noteId)
+	}
+	if err != nil {
+		return models.Note{}, err
+	}
 
+	note, err = s.noteRepo.Get(note.ID)
+	if err != nil {
+		return models.Note{}, err
+	}
+
+	s.events.publish(models.EventNoteUpdated, note)
+	return note, nil
+}
+
+// versionMismatch reports the version the note has after a write lost to another one.
+func (s *NoteService) versionMismatch(noteId uuid.UUID) error {
 	note, err := s.noteRepo.Get(noteId)
 	if err != nil {
 		return err
 	}
+	return &VersionMismatchError{Current: note.Version}
+}
 
-	if note.UserId != userId {
-		return errors.New("Accsess denied")
+func (s *NoteService) checkNotebook(userId uuid.UUID, notebookId *uuid.UUID) error {
+	if notebookId == nil {
+		return nil
 	}
 
-	if req.Title == "" {
-		return errors.New("title is required")
+	notebook, err := s.notebookRepo.Get(*notebookId)
+	if err != nil {
+		return err
+	}
+	if notebook.UserId != userId {
+		return repository.ErrNotFound
 	}
 
-	if req.Content == "" {
-		return errors.New("content is required")
+	return nil
+}
+
+// GetUserNotes returns one page of the user's notes, cursor is the next_cursor of the previous page.
+func (s *NoteService) GetUserNotes(userID uuid.UUID, filter models.NoteFilter, page models.NotePage, cursor string) (dto.NotesPageResponse, error) {
+	tags, err := normalizeTags(filter.Tags)
+	if err != nil {
+		return dto.NotesPageResponse{}, err
 	}
+	filter.Tags = tags
 
-	note.Title = req.Title
-	note.Content = req.Content
-	note.UpdatedAt = time.Now()
-	return s.noteRepo.Update(note)
+	switch page.Sort {
+	case "":
+		page.Sort = models.NoteSortUpdatedAt
+	case models.NoteSortUpdatedAt, models.NoteSortCreatedAt, models.NoteSortTitle:
+	default:
+		return dto.NotesPageResponse{}, errors.New("sort must be updated_at, created_at or title")
+	}
+
+	switch {
+	case page.Limit == 0:
+		page.Limit = DefaultPageLimit
+	case page.Limit < 0 || page.Limit > MaxPageLimit:
+		return dto.NotesPageResponse{}, fmt.Errorf("limit must be between 1 and %d", MaxPageLimit)
+	}
+
+	if cursor != "" {
+		after, err := decodeCursor(cursor)
+		if err != nil {
+			return dto.NotesPageResponse{}, err
+		}
+		// курсор от другой сортировки указывает в чужой порядок
+		if after.Sort != page.Sort || after.Desc != page.Desc {
+			return dto.NotesPageResponse{}, ErrInvalidCursor
+		}
+		page.After = &after
+	}
+
+	// one extra note tells whether there is a next page
+	limit := page.Limit
+	page.Limit++
+	notes, err := s.noteRepo.Find(userID, filter, page)
+	if err != nil {
+		return dto.NotesPageResponse{}, err
+	}
+
+	resp := dto.NotesPageResponse{Notes: notes}
+	if len(notes) > limit {
+		resp.Notes = notes[:limit]
+		resp.NextCursor = encodeCursor(page.CursorOf(notes[limit-1]))
+	}
+	if resp.Notes == nil {
+		resp.Notes = []models.Note{}
+	}
+
+	return resp, nil
 }
 
-func (s *NoteService) GetUserNotes(userID uuid.UUID) ([]models.Note, error) {
-	return s.noteRepo.GetAllByUserId(userID)
+func encodeCursor(cursor models.NoteCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
 }
 
-func (s *NoteService) DeleteNote(userId uuid.UUID, noteId uuid.UUID) error {
-	return s.noteRepo.Delete(noteId)
+func decodeCursor(token string) (models.NoteCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return models.NoteCursor{}, ErrInvalidCursor
+	}
+
+	var cursor models.NoteCursor
+	if err = json.Unmarshal(data, &cursor); err != nil {
+		return models.NoteCursor{}, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+// DeleteNote moves the note to the trash, it is removed for good by TrashService.
+// The trash is the owner's, editors cannot delete a shared note. A non-zero version
+// must be the current one, as in UpdateNote.
+func (s *NoteService) DeleteNote(userId uuid.UUID, noteId uuid.UUID, version int64) error {
+	note, err := s.access.check(userId, noteId, models.PermissionOwner)
+	if err != nil {
+		return err
+	}
+	if version != 0 && note.Version != version {
+		return &VersionMismatchError{Current: note.Version}
+	}
+
+	// как и в UpdateNote, заметка не должна измениться между проверкой и записью
+	err = s.noteRepo.Trash(note.ID, time.Now(), note.Version)
+	if errors.Is(err, repository.ErrVersionConflict) {
+		return s.versionMismatch(noteId)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.events.publish(models.EventNoteDeleted, note)
+	return nil
 }
@@ -0,0 +1,253 @@
+package service_test
+
+import (
+	"2/internal/app/events"
+	"2/internal/app/service"
+	"2/internal/domain/models"
+	"2/internal/interface/http/dto"
+	"cmp"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tiedNotes stores notes whose dates and titles repeat, so that every sort has ties
+// broken only by id.
+func tiedNotes(t *testing.T, b testBackend, userId uuid.UUID) []models.Note {
+	t.Helper()
+
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	titles := []string{"Beta", "alpha", "Alpha"}
+
+	var notes []models.Note
+	for i := 0; i < 10; i++ {
+		note := models.Note{
+			ID:        uuid.New(),
+			UserId:    userId,
+			Title:     titles[i%len(titles)],
+			Content:   fmt.Sprintf("note %d", i),
+			Tags:      []string{},
+			CreatedAt: base.Add(time.Duration(i/4) * time.Hour),
+			UpdatedAt: base.Add(time.Duration(i%3) * time.Minute),
+			Version:   1,
+		}
+		if err := b.notes.Create(note); err != nil {
+			t.Fatalf("create note: %v", err)
+		}
+		notes = append(notes, note)
+	}
+	return notes
+}
+
+// keysetOrder sorts the notes the way the listing must return them.
+func keysetOrder(notes []models.Note, sort models.NoteSort, desc bool) []uuid.UUID {
+	sorted := slices.Clone(notes)
+	slices.SortFunc(sorted, func(a, b models.Note) int {
+		var c int
+		switch sort {
+		case models.NoteSortTitle:
+			c = strings.Compare(a.Title, b.Title)
+		case models.NoteSortCreatedAt:
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		default:
+			c = a.UpdatedAt.Compare(b.UpdatedAt)
+		}
+		c = cmp.Or(c, strings.Compare(a.ID.String(), b.ID.String()))
+		if desc {
+			return -c
+		}
+		return c
+	})
+
+	ids := make([]uuid.UUID, len(sorted))
+	for i, note := range sorted {
+		ids[i] = note.ID
+	}
+	return ids
+}
+
+func TestGetUserNotesKeyset(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b testBackend) {
+		svc := service.NewNoteService(b.notes, b.notebooks, b.shares, events.NewBus(16))
+		userId := b.newUser(t, "pager")
+		notes := tiedNotes(t, b, userId)
+		// чужие заметки в выдачу не попадают
+		tiedNotes(t, b, b.newUser(t, "other"))
+
+		for _, sort := range []models.NoteSort{models.NoteSortUpdatedAt, models.NoteSortCreatedAt, models.NoteSortTitle} {
+			for _, desc := range []bool{false, true} {
+				want := keysetOrder(notes, sort, desc)
+
+				for _, limit := range []int{1, 3, 4, len(notes), service.MaxPageLimit} {
+					name := fmt.Sprintf("%s desc=%v limit=%d", sort, desc, limit)
+					page := models.NotePage{Sort: sort, Desc: desc, Limit: limit}
+
+					var got []uuid.UUID
+					cursor := ""
+					for pages := 0; ; pages++ {
+						if pages > len(notes) {
+							t.Fatalf("%s: paging does not stop", name)
+						}
+						resp, err := svc.GetUserNotes(userId, models.NoteFilter{}, page, cursor)
+						if err != nil {
+							t.Fatalf("%s: %v", name, err)
+						}
+						if len(resp.Notes) > limit || resp.NextCursor != "" && len(resp.Notes) != limit {
+							t.Fatalf("%s: page of %d notes with next_cursor %q", name, len(resp.Notes), resp.NextCursor)
+						}
+						for _, note := range resp.Notes {
+							got = append(got, note.ID)
+						}
+						if cursor = resp.NextCursor; cursor == "" {
+							break
+						}
+					}
+
+					if !slices.Equal(got, want) {
+						t.Errorf("%s:\n got %v\nwant %v", name, got, want)
+					}
+				}
+			}
+		}
+	})
+}
+
+func TestGetUserNotesCursorErrors(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b testBackend) {
+		svc := service.NewNoteService(b.notes, b.notebooks, b.shares, events.NewBus(16))
+		userId := b.newUser(t, "pager")
+		tiedNotes(t, b, userId)
+
+		byTitle := models.NotePage{Sort: models.NoteSortTitle, Limit: 2}
+		resp, err := svc.GetUserNotes(userId, models.NoteFilter{}, byTitle, "")
+		if err != nil || resp.NextCursor == "" {
+			t.Fatalf("first page: %v, next_cursor %q", err, resp.NextCursor)
+		}
+
+		// курсор годится только для той сортировки, в которой его выдали
+		for _, page := range []models.NotePage{
+			{Sort: models.NoteSortCreatedAt, Limit: 2},
+			{Sort: models.NoteSortTitle, Desc: true, Limit: 2},
+		} {
+			if _, err = svc.GetUserNotes(userId, models.NoteFilter{}, page, resp.NextCursor); !errors.Is(err, service.ErrInvalidCursor) {
+				t.Errorf("cursor of title asc with %s desc=%v: got %v, want ErrInvalidCursor", page.Sort, page.Desc, err)
+			}
+		}
+		for _, cursor := range []string{"not base64!", base64.RawURLEncoding.EncodeToString([]byte("[1]"))} {
+			if _, err = svc.GetUserNotes(userId, models.NoteFilter{}, byTitle, cursor); !errors.Is(err, service.ErrInvalidCursor) {
+				t.Errorf("cursor %q: got %v, want ErrInvalidCursor", cursor, err)
+			}
+		}
+
+		for _, page := range []models.NotePage{
+			{Sort: "content"},
+			{Limit: -1},
+			{Limit: service.MaxPageLimit + 1},
+		} {
+			if _, err = svc.GetUserNotes(userId, models.NoteFilter{}, page, ""); err == nil {
+				t.Errorf("page %+v: got no error", page)
+			}
+		}
+
+		// без сортировки и лимита: updated_at по возрастанию, страница по умолчанию
+		resp, err = svc.GetUserNotes(userId, models.NoteFilter{}, models.NotePage{}, "")
+		if err != nil || len(resp.Notes) != 10 || resp.NextCursor != "" {
+			t.Fatalf("default page: %d notes, next_cursor %q, %v", len(resp.Notes), resp.NextCursor, err)
+		}
+		for i := 1; i < len(resp.Notes); i++ {
+			if resp.Notes[i].UpdatedAt.Before(resp.Notes[i-1].UpdatedAt) {
+				t.Errorf("default page is not sorted by updated_at at %d", i)
+			}
+		}
+	})
+}
+
+// A note created between two pages lands where the sort puts it: before the cursor it is
+// missed, after it it shows up, and no note is ever listed twice.
+func TestGetUserNotesStableAcrossWrites(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b testBackend) {
+		svc := service.NewNoteService(b.notes, b.notebooks, b.shares, events.NewBus(16))
+		userId := b.newUser(t, "pager")
+		for _, title := range []string{"b", "d", "f"} {
+			if _, err := svc.CreateNote(userId, dto.CreateNoteRequest{Title: title, Content: title}); err != nil {
+				t.Fatalf("create %s: %v", title, err)
+			}
+		}
+
+		page := models.NotePage{Sort: models.NoteSortTitle, Limit: 2}
+		first, err := svc.GetUserNotes(userId, models.NoteFilter{}, page, "")
+		if err != nil {
+			t.Fatalf("first page: %v", err)
+		}
+		for _, title := range []string{"a", "e"} {
+			if _, err = svc.CreateNote(userId, dto.CreateNoteRequest{Title: title, Content: title}); err != nil {
+				t.Fatalf("create %s: %v", title, err)
+			}
+		}
+		second, err := svc.GetUserNotes(userId, models.NoteFilter{}, page, first.NextCursor)
+		if err != nil {
+			t.Fatalf("second page: %v", err)
+		}
+
+		var titles []string
+		for _, note := range append(first.Notes, second.Notes...) {
+			titles = append(titles, note.Title)
+		}
+		if want := []string{"b", "d", "e", "f"}; !slices.Equal(titles, want) {
+			t.Errorf("titles %v, want %v", titles, want)
+		}
+	})
+}
+
+// A move changes the note like an edit: it checks and moves on the version. The trash does
+// not, a restored note comes back at the version it was deleted with.
+func TestNoteVersionOfMoveAndTrash(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b testBackend) {
+		userId := b.newUser(t, "ann")
+		notes := service.NewNoteService(b.notes, b.notebooks, b.shares, events.NewBus(16))
+		trash := service.NewTrashService(b.notes, b.shares, events.NewBus(16), 0)
+
+		notebook := models.Notebook{ID: uuid.New(), UserId: userId, Name: "work", CreatedAt: time.Now(), UpdatedAt: time.Now()}
+		if err := b.notebooks.Create(notebook); err != nil {
+			t.Fatalf("create notebook: %v", err)
+		}
+		note, err := notes.CreateNote(userId, dto.CreateNoteRequest{Title: "note", Content: "text"})
+		if err != nil {
+			t.Fatalf("create note: %v", err)
+		}
+
+		var mismatch *service.VersionMismatchError
+		if _, err = notes.MoveNote(userId, note.ID, &notebook.ID, note.Version+1); !errors.As(err, &mismatch) || mismatch.Current != note.Version {
+			t.Fatalf("move with a wrong version: got %v, want a mismatch at %d", err, note.Version)
+		}
+		moved, err := notes.MoveNote(userId, note.ID, &notebook.ID, note.Version)
+		if err != nil {
+			t.Fatalf("move: %v", err)
+		}
+		if moved.Version != note.Version+1 || moved.NotebookId == nil || *moved.NotebookId != notebook.ID {
+			t.Errorf("moved note: version %d, notebook %v, want %d and %s", moved.Version, moved.NotebookId, note.Version+1, notebook.ID)
+		}
+		if _, err = notes.MoveNote(userId, note.ID, nil, note.Version); !errors.As(err, &mismatch) || mismatch.Current != moved.Version {
+			t.Errorf("move with the version before the move: got %v, want a mismatch at %d", err, moved.Version)
+		}
+
+		if err = notes.DeleteNote(userId, note.ID, moved.Version); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		restored, err := trash.RestoreNote(userId, note.ID)
+		if err != nil {
+			t.Fatalf("restore: %v", err)
+		}
+		if restored.Version != moved.Version {
+			t.Errorf("restored note: version %d, want %d as before the delete", restored.Version, moved.Version)
+		}
+		if _, err = notes.MoveNote(userId, note.ID, nil, moved.Version); err != nil {
+			t.Errorf("move with the version from before the delete: %v", err)
+		}
+	})
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"2/internal/interface/http/dto"
+	"errors"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+var ErrNotebookCycle = errors.New("notebook cannot be moved into itself or its own sub-notebook")
+
+type NotebookService struct {
+	notebookRepo repository.NotebooksRepository
+	noteRepo     repository.NotesRepository
+	events       noteEvents
+}
+
+func NewNotebookService(notebookRepo repository.NotebooksRepository, noteRepo repository.NotesRepository, shareRepo repository.SharesRepository, publisher NotePublisher) *NotebookService {
+	return &NotebookService{
+		notebookRepo: notebookRepo,
+		noteRepo:     noteRepo,
+		events:       noteEvents{shareRepo: shareRepo, publisher: publisher},
+	}
+}
+
+func (s *NotebookService) CreateNotebook(userId uuid.UUID, req dto.CreateNotebookRequest) (models.Notebook, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return models.Notebook{}, errors.New("name is required")
+	}
+
+	if req.ParentId != nil {
+		if _, err := s.GetNotebook(userId, *req.ParentId); err != nil {
+			return models.Notebook{}, err
+		}
+	}
+
+	notebook := models.Notebook{
+		ID:        uuid.New(),
+		UserId:    userId,
+		ParentId:  req.ParentId,
+		Name:      name,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	if err := s.notebookRepo.Create(notebook); err != nil {
+		return models.Notebook{}, err
+	}
+
+	return notebook, nil
+}
+
+// GetNotebook returns the user's notebook, notebooks of other users look like missing ones.
+func (s *NotebookService) GetNotebook(userId uuid.UUID, notebookId uuid.UUID) (models.Notebook, error) {
+	notebook, err := s.notebookRepo.Get(notebookId)
+	if err != nil {
+		return models.Notebook{}, err
+	}
+
+	if notebook.UserId != userId {
+		return models.Notebook{}, repository.ErrNotFound
+	}
+
+	return notebook, nil
+}
+
+func (s *NotebookService) GetUserNotebooks(userId uuid.UUID) ([]models.Notebook, error) {
+	return s.notebookRepo.GetAllByUserId(userId)
+}
+
+func (s *NotebookService) GetNotebookNotes(userId uuid.UUID, notebookId uuid.UUID) ([]models.Note, error) {
+	if _, err := s.GetNotebook(userId, notebookId); err != nil {
+		return nil, err
+	}
+
+	return s.noteRepo.Find(userId, models.NoteFilter{NotebookId: &notebookId}, models.NotePage{})
+}
+
+func (s *NotebookService) RenameNotebook(userId uuid.UUID, notebookId uuid.UUID, name string) (models.Notebook, error) {
+	notebook, err := s.GetNotebook(userId, notebookId)
+	if err != nil {
+		return models.Notebook{}, err
+	}
+
+	notebook.Name = strings.TrimSpace(name)
+	if notebook.Name == "" {
+		return models.Notebook{}, errors.New("name is required")
+	}
+
+	if err = s.notebookRepo.Update(notebook); err != nil {
+		return models.Notebook{}, err
+	}
+
+	return s.notebookRepo.Get(notebookId)
+}
+
+// MoveNotebook re-parents a notebook, nil parentId moves it to the top level.
+func (s *NotebookService) MoveNotebook(userId uuid.UUID, notebookId uuid.UUID, parentId *uuid.UUID) (models.Notebook, error) {
+	notebook, err := s.GetNotebook(userId, notebookId)
+	if err != nil {
+		return models.Notebook{}, err
+	}
+
+	if parentId != nil {
+		if _, err = s.GetNotebook(userId, *parentId); err != nil {
+			return models.Notebook{}, err
+		}
+
+		notebooks, err := s.notebookRepo.GetAllByUserId(userId)
+		if err != nil {
+			return models.Notebook{}, err
+		}
+		parents := parentMap(notebooks)
+
+		// поднимаемся от нового родителя к корню, встретили себя - значит цикл
+		for cur, steps := parentId, 0; cur != nil && steps <= len(parents); cur, steps = parents[*cur], steps+1 {
+			if *cur == notebookId {
+				return models.Notebook{}, ErrNotebookCycle
+			}
+		}
+	}
+
+	notebook.ParentId = parentId
+	if err = s.notebookRepo.Update(notebook); err != nil {
+		return models.Notebook{}, err
+	}
+
+	return s.notebookRepo.Get(notebookId)
+}
+
+// DeleteNotebook removes a notebook. With cascade its sub-notebooks go too and all their notes
+// move to the trash, otherwise they are handed over to the parent of the deleted notebook.
+func (s *NotebookService) DeleteNotebook(userId uuid.UUID, notebookId uuid.UUID, cascade bool) error {
+	notebook, err := s.GetNotebook(userId, notebookId)
+	if err != nil {
+		return err
+	}
+
+	if !cascade {
+		return s.notebookRepo.DeleteReparent(notebook)
+	}
+
+	notebooks, err := s.notebookRepo.GetAllByUserId(userId)
+	if err != nil {
+		return err
+	}
+
+	children := make(map[uuid.UUID][]uuid.UUID)
+	for _, nb := range notebooks {
+		if nb.ParentId != nil {
+			children[*nb.ParentId] = append(children[*nb.ParentId], nb.ID)
+		}
+	}
+
+	ids := []uuid.UUID{notebookId}
+	seen := map[uuid.UUID]bool{notebookId: true}
+	for i := 0; i < len(ids); i++ {
+		for _, child := range children[ids[i]] {
+			if !seen[child] {
+				seen[child] = true
+				ids = append(ids, child)
+			}
+		}
+	}
+
+	trashed, err := s.notebookRepo.DeleteCascade(userId, ids, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, noteId := range trashed {
+		s.events.publish(models.EventNoteDeleted, models.Note{ID: noteId, UserId: userId})
+	}
+	return nil
+}
+
+func parentMap(notebooks []models.Notebook) map[uuid.UUID]*uuid.UUID {
+	parents := make(map[uuid.UUID]*uuid.UUID, len(notebooks))
+	for _, nb := range notebooks {
+		parents[nb.ID] = nb.ParentId
+	}
+	return parents
+}
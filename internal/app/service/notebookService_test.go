@@ -0,0 +1,230 @@
+package service_test
+
+import (
+	"2/internal/app/events"
+	"2/internal/app/service"
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"2/internal/interface/http/dto"
+	"errors"
+	"github.com/google/uuid"
+	"slices"
+	"testing"
+)
+
+type notebookEnv struct {
+	notes     *service.NoteService
+	notebooks *service.NotebookService
+	trash     *service.TrashService
+	sync      *service.SyncService
+	userId    uuid.UUID
+}
+
+func newNotebookEnv(t *testing.T, b testBackend) *notebookEnv {
+	t.Helper()
+
+	bus := events.NewBus(16)
+	notes := service.NewNoteService(b.notes, b.notebooks, b.shares, bus)
+	return &notebookEnv{
+		notes:     notes,
+		notebooks: service.NewNotebookService(b.notebooks, b.notes, b.shares, bus),
+		trash:     service.NewTrashService(b.notes, b.shares, bus, 0),
+		sync:      service.NewSyncService(notes, b.notes, 0),
+		userId:    b.newUser(t, "nb-"+uuid.NewString()[:8]),
+	}
+}
+
+func (e *notebookEnv) notebook(t *testing.T, name string, parentId *uuid.UUID) models.Notebook {
+	t.Helper()
+
+	notebook, err := e.notebooks.CreateNotebook(e.userId, dto.CreateNotebookRequest{Name: name, ParentId: parentId})
+	if err != nil {
+		t.Fatalf("create notebook %s: %v", name, err)
+	}
+	return notebook
+}
+
+func (e *notebookEnv) note(t *testing.T, title string, notebookId *uuid.UUID) models.Note {
+	t.Helper()
+
+	note, err := e.notes.CreateNote(e.userId, dto.CreateNoteRequest{Title: title, Content: "content", NotebookId: notebookId})
+	if err != nil {
+		t.Fatalf("create note %s: %v", title, err)
+	}
+	return note
+}
+
+// trashed creates a note in the notebook and moves it to the trash.
+func (e *notebookEnv) trashed(t *testing.T, title string, notebookId *uuid.UUID) models.Note {
+	t.Helper()
+
+	note := e.note(t, title, notebookId)
+	if err := e.notes.DeleteNote(e.userId, note.ID, 0); err != nil {
+		t.Fatalf("trash %s: %v", title, err)
+	}
+	return e.inTrash(t, note.ID)
+}
+
+func (e *notebookEnv) inTrash(t *testing.T, noteId uuid.UUID) models.Note {
+	t.Helper()
+
+	trash, err := e.trash.GetTrash(e.userId)
+	if err != nil {
+		t.Fatalf("trash: %v", err)
+	}
+	for _, note := range trash {
+		if note.ID == noteId {
+			return note
+		}
+	}
+	t.Fatalf("note %s is not in the trash", noteId)
+	return models.Note{}
+}
+
+// changedSince returns the feed of the user after the token, sorted.
+func (e *notebookEnv) changedSince(t *testing.T, since string) []string {
+	t.Helper()
+
+	resp, err := e.sync.Pull(e.userId, since, 0)
+	if err != nil {
+		t.Fatalf("pull: %v", err)
+	}
+	changes := feed(resp.Changes)
+	slices.Sort(changes)
+	return changes
+}
+
+func (e *notebookEnv) syncToken(t *testing.T) string {
+	t.Helper()
+
+	resp, err := e.sync.Pull(e.userId, "", 0)
+	if err != nil {
+		t.Fatalf("pull: %v", err)
+	}
+	return resp.NextToken
+}
+
+func TestMoveNotebookCycle(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b testBackend) {
+		env := newNotebookEnv(t, b)
+		top := env.notebook(t, "top", nil)
+		middle := env.notebook(t, "middle", &top.ID)
+		bottom := env.notebook(t, "bottom", &middle.ID)
+
+		for name, target := range map[string]uuid.UUID{"itself": top.ID, "its child": middle.ID, "its grandchild": bottom.ID} {
+			if _, err := env.notebooks.MoveNotebook(env.userId, top.ID, &target); !errors.Is(err, service.ErrNotebookCycle) {
+				t.Errorf("move into %s: got %v, want ErrNotebookCycle", name, err)
+			}
+		}
+
+		// нижний поднимаем наверх, и тогда верхний можно положить в него
+		if _, err := env.notebooks.MoveNotebook(env.userId, bottom.ID, nil); err != nil {
+			t.Fatalf("move to the top level: %v", err)
+		}
+		moved, err := env.notebooks.MoveNotebook(env.userId, top.ID, &bottom.ID)
+		if err != nil {
+			t.Fatalf("move under the former grandchild: %v", err)
+		}
+		if moved.ParentId == nil || *moved.ParentId != bottom.ID {
+			t.Errorf("moved notebook parent %v, want %s", moved.ParentId, bottom.ID)
+		}
+		if _, err = env.notebooks.MoveNotebook(env.userId, bottom.ID, &middle.ID); !errors.Is(err, service.ErrNotebookCycle) {
+			t.Errorf("move into the new grandchild: got %v, want ErrNotebookCycle", err)
+		}
+
+		foreign := newNotebookEnv(t, b).notebook(t, "foreign", nil)
+		if _, err = env.notebooks.MoveNotebook(env.userId, top.ID, &foreign.ID); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("move into a notebook of another user: got %v, want ErrNotFound", err)
+		}
+	})
+}
+
+// Deleting a notebook without cascade hands its notes and sub-notebooks to its parent. Notes
+// in the trash move too, but they are not a change for sync clients.
+func TestDeleteNotebookReparent(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b testBackend) {
+		env := newNotebookEnv(t, b)
+		parent := env.notebook(t, "parent", nil)
+		deleted := env.notebook(t, "deleted", &parent.ID)
+		child := env.notebook(t, "child", &deleted.ID)
+		live := env.note(t, "live", &deleted.ID)
+		old := env.trashed(t, "old", &deleted.ID)
+		since := env.syncToken(t)
+
+		if err := env.notebooks.DeleteNotebook(env.userId, deleted.ID, false); err != nil {
+			t.Fatalf("delete notebook: %v", err)
+		}
+
+		if _, err := env.notebooks.GetNotebook(env.userId, deleted.ID); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("deleted notebook: got %v, want ErrNotFound", err)
+		}
+		got, err := env.notebooks.GetNotebook(env.userId, child.ID)
+		if err != nil || got.ParentId == nil || *got.ParentId != parent.ID {
+			t.Errorf("child notebook: parent %v, %v, want %s", got.ParentId, err, parent.ID)
+		}
+
+		note, err := env.notes.GetNote(env.userId, live.ID)
+		if err != nil {
+			t.Fatalf("get note: %v", err)
+		}
+		if note.NotebookId == nil || *note.NotebookId != parent.ID || note.Version != live.Version+1 {
+			t.Errorf("note of the deleted notebook: notebook %v, version %d, want %s and %d", note.NotebookId, note.Version, parent.ID, live.Version+1)
+		}
+		if note = env.inTrash(t, old.ID); note.NotebookId == nil || *note.NotebookId != parent.ID || note.Version != old.Version {
+			t.Errorf("trashed note: notebook %v, version %d, want %s and %d", note.NotebookId, note.Version, parent.ID, old.Version)
+		}
+		if changes := env.changedSince(t, since); !slices.Equal(changes, []string{live.ID.String()}) {
+			t.Errorf("feed after the delete: %v, want only %s", changes, live.ID)
+		}
+	})
+}
+
+// Deleting a notebook with cascade removes its sub-notebooks and moves the notes of all of
+// them to the trash. Notes already in the trash only lose the notebook.
+func TestDeleteNotebookCascade(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b testBackend) {
+		env := newNotebookEnv(t, b)
+		parent := env.notebook(t, "parent", nil)
+		deleted := env.notebook(t, "deleted", &parent.ID)
+		child := env.notebook(t, "child", &deleted.ID)
+		kept := env.note(t, "kept", &parent.ID)
+		inDeleted := env.note(t, "in deleted", &deleted.ID)
+		inChild := env.note(t, "in child", &child.ID)
+		old := env.trashed(t, "old", &child.ID)
+		since := env.syncToken(t)
+
+		if err := env.notebooks.DeleteNotebook(env.userId, deleted.ID, true); err != nil {
+			t.Fatalf("delete notebook: %v", err)
+		}
+
+		for _, id := range []uuid.UUID{deleted.ID, child.ID} {
+			if _, err := env.notebooks.GetNotebook(env.userId, id); !errors.Is(err, repository.ErrNotFound) {
+				t.Errorf("notebook %s: got %v, want ErrNotFound", id, err)
+			}
+		}
+		if _, err := env.notebooks.GetNotebook(env.userId, parent.ID); err != nil {
+			t.Errorf("parent notebook: %v", err)
+		}
+		if note, err := env.notes.GetNote(env.userId, kept.ID); err != nil || note.Version != kept.Version {
+			t.Errorf("note of the parent: version %d, %v, want it untouched", note.Version, err)
+		}
+
+		for _, before := range []models.Note{inDeleted, inChild} {
+			if _, err := env.notes.GetNote(env.userId, before.ID); !errors.Is(err, repository.ErrNotFound) {
+				t.Errorf("note %s: got %v, want it in the trash", before.Title, err)
+			}
+			if note := env.inTrash(t, before.ID); note.NotebookId != nil || note.Version != before.Version+1 {
+				t.Errorf("note %s in the trash: notebook %v, version %d, want none and %d", before.Title, note.NotebookId, note.Version, before.Version+1)
+			}
+		}
+		if note := env.inTrash(t, old.ID); note.NotebookId != nil || note.Version != old.Version {
+			t.Errorf("note trashed before: notebook %v, version %d, want none and %d", note.NotebookId, note.Version, old.Version)
+		}
+
+		want := []string{"-" + inDeleted.ID.String(), "-" + inChild.ID.String()}
+		slices.Sort(want)
+		if changes := env.changedSince(t, since); !slices.Equal(changes, want) {
+			t.Errorf("feed after the delete: %v, want %v", changes, want)
+		}
+	})
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"2/internal/app/oidc"
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"2/internal/interface/http/dto"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnknownProvider       = errors.New("unknown identity provider")
+	ErrInvalidOIDCState      = errors.New("login with the identity provider is invalid or expired, start again")
+	ErrOIDCLoginFailed       = errors.New("login with the identity provider failed")
+	ErrOIDCEmailNotVerified  = errors.New("the identity provider did not confirm the email of the account")
+	ErrOIDCAccountUnverified = errors.New("an account with this email exists but its email is not confirmed, confirm it or log in with the password first")
+)
+
+// за это время пользователь должен войти у провайдера и вернуться
+const oidcStateTTL = 10 * time.Minute
+
+// OIDCService signs users in with external OpenID Connect providers. An external account
+// is linked to the user with the same verified email, or a new user is created for it.
+type OIDCService struct {
+	identities repository.IdentitiesRepository
+	userRepo   repository.UserRepository
+	passwords  *Passwords
+	auth       *AuthService
+	providers  map[string]*oidc.Provider
+	order      []string
+}
+
+func NewOIDCService(identities repository.IdentitiesRepository, userRepo repository.UserRepository, passwords *Passwords, auth *AuthService, providers ...*oidc.Provider) *OIDCService {
+	s := &OIDCService{
+		identities: identities,
+		userRepo:   userRepo,
+		passwords:  passwords,
+		auth:       auth,
+		providers:  make(map[string]*oidc.Provider, len(providers)),
+	}
+	for _, p := range providers {
+		s.providers[p.Name()] = p
+		s.order = append(s.order, p.Name())
+	}
+	return s
+}
+
+// Providers lists the configured providers in configuration order.
+func (s *OIDCService) Providers() []dto.OIDCProviderResponse {
+	resp := []dto.OIDCProviderResponse{}
+	for _, name := range s.order {
+		resp = append(resp, dto.OIDCProviderResponse{
+			Name:        name,
+			DisplayName: s.providers[name].DisplayName(),
+			LoginURL:    "/user/oidc/" + name + "/login",
+		})
+	}
+	return resp
+}
+
+// LoginURL starts a login: it remembers a fresh state, nonce and PKCE verifier and
+// returns the provider page to send the user to.
+func (s *OIDCService) LoginURL(providerName string) (string, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return "", ErrUnknownProvider
+	}
+
+	state, err := oidc.RandomString(32)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := oidc.RandomString(32)
+	if err != nil {
+		return "", err
+	}
+	verifier, err := oidc.RandomString(48)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = s.identities.SaveState(models.OIDCState{
+		State:        state,
+		Provider:     providerName,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(oidcStateTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return provider.AuthCodeURL(state, nonce, verifier)
+}
+
+// Callback finishes a login the provider redirected back from and issues the usual tokens,
+// or an mfa token when the user has two-factor authentication enabled.
+func (s *OIDCService) Callback(providerName string, req dto.OIDCCallbackRequest, client models.ClientInfo) (dto.AuthResponse, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return dto.AuthResponse{}, ErrUnknownProvider
+	}
+
+	// state используется один раз, даже если провайдер вернул ошибку
+	state, err := s.identities.TakeState(req.State, time.Now())
+	if errors.Is(err, repository.ErrNotFound) {
+		return dto.AuthResponse{}, ErrInvalidOIDCState
+	}
+	if err != nil {
+		return dto.AuthResponse{}, err
+	}
+	if state.Provider != providerName {
+		return dto.AuthResponse{}, ErrInvalidOIDCState
+	}
+
+	if req.Error != "" {
+		return dto.AuthResponse{}, fmt.Errorf("%w: %s %s", ErrOIDCLoginFailed, req.Error, req.ErrorDescription)
+	}
+	if req.Code == "" {
+		return dto.AuthResponse{}, errors.New("code is required")
+	}
+
+	idToken, err := provider.Exchange(req.Code, state.CodeVerifier)
+	if err != nil {
+		slog.Warn("OIDC code exchange failed", "provider", providerName, "error", err)
+		return dto.AuthResponse{}, ErrOIDCLoginFailed
+	}
+	claims, err := provider.VerifyIDToken(idToken, state.Nonce)
+	if err != nil {
+		slog.Warn("OIDC id token rejected", "provider", providerName, "error", err)
+		return dto.AuthResponse{}, ErrOIDCLoginFailed
+	}
+
+	userId, err := s.resolveUser(providerName, claims)
+	if err != nil {
+		return dto.AuthResponse{}, err
+	}
+
+	user, err := s.userRepo.GetUserById(userId)
+	if err != nil {
+		return dto.AuthResponse{}, err
+	}
+
+	return s.auth.completeLogin(user, client)
+}
+
+// resolveUser finds the user of an external account, linking or creating one on the first login.
+func (s *OIDCService) resolveUser(providerName string, claims oidc.Claims) (uuid.UUID, error) {
+	identity, err := s.identities.GetIdentity(providerName, claims.Subject)
+	if err == nil {
+		return identity.UserId, nil
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return uuid.UUID{}, err
+	}
+
+	// связываем только по адресу, который провайдер подтвердил сам
+	if claims.Email == "" || !claims.EmailVerified {
+		return uuid.UUID{}, ErrOIDCEmailNotVerified
+	}
+
+	user, exists, err := s.userRepo.GetUserByEmail(claims.Email)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	if exists {
+		// неподтверждённый аккаунт мог зарегистрировать кто угодно, заранее заняв чужой адрес
+		if !user.EmailVerified {
+			return uuid.UUID{}, ErrOIDCAccountUnverified
+		}
+	} else {
+		user, err = s.createUser(claims)
+		if err != nil {
+			return uuid.UUID{}, err
+		}
+	}
+
+	err = s.identities.CreateIdentity(models.ExternalIdentity{
+		Provider:  providerName,
+		Subject:   claims.Subject,
+		UserId:    user.UserId,
+		Email:     claims.Email,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	return user.UserId, nil
+}
+
+// createUser registers the owner of an external account. The password is random, the user
+// can set a real one with the password reset link.
+func (s *OIDCService) createUser(claims oidc.Claims) (models.User, error) {
+	username := claims.PreferredUsername
+	if username == "" {
+		username = claims.Name
+	}
+	if username == "" {
+		username, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	user := models.User{
+		UserId:        uuid.New(),
+		Username:      username,
+		Email:         claims.Email,
+		EmailVerified: true,
+		Role:          models.RoleUser,
+		Created:       time.Now(),
+	}
+	if err := s.passwords.SetRandom(&user); err != nil {
+		return models.User{}, err
+	}
+	if err := s.userRepo.Create(user); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// PurgeStates removes logins that were started but never finished.
+func (s *OIDCService) PurgeStates() (int64, error) {
+	return s.identities.DeleteExpiredStates(time.Now())
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"2/internal/app/password"
+	"2/internal/domain/models"
+	"crypto/rand"
+	"encoding/base64"
+	"log/slog"
+	"sync"
+)
+
+// Passwords hashes, checks and validates passwords for every service that handles them.
+type Passwords struct {
+	hasher *password.Hasher
+	policy *password.Policy
+
+	dummyOnce sync.Once
+	dummy     string
+}
+
+func NewPasswords(hasher *password.Hasher, policy *password.Policy) *Passwords {
+	return &Passwords{
+		hasher: hasher,
+		policy: policy,
+	}
+}
+
+// Set checks a new password of the user against the policy and stores its hash in user.Password.
+func (p *Passwords) Set(user *models.User, plain string) error {
+	if err := p.policy.Validate(plain, user.Email, user.Username); err != nil {
+		return err
+	}
+
+	hash, err := p.hasher.Hash(plain)
+	if err != nil {
+		return err
+	}
+	user.Password = hash
+	return nil
+}
+
+// SetRandom gives the user a password nobody knows, for accounts created by identity providers.
+func (p *Passwords) SetRandom(user *models.User) error {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+
+	hash, err := p.hasher.Hash(base64.RawURLEncoding.EncodeToString(raw))
+	if err != nil {
+		return err
+	}
+	user.Password = hash
+	return nil
+}
+
+// Check reports whether plain is the password of the user. rehash means its hash is of an
+// older algorithm or parameters and should be replaced with Rehash while the password is known.
+func (p *Passwords) Check(user models.User, plain string) (ok bool, rehash bool) {
+	ok, rehash, err := p.hasher.Verify(plain, user.Password)
+	if err != nil {
+		slog.Error("Failed to verify password hash", "user", user.UserId, "error", err)
+		return false, false
+	}
+	return ok, rehash
+}
+
+// Rehash hashes a password that was just checked with the current algorithm. The policy
+// is not applied, an old password stays valid until the user changes it.
+func (p *Passwords) Rehash(plain string) (string, error) {
+	return p.hasher.Hash(plain)
+}
+
+// CheckNobody takes as long as Check, for logins with an unknown email.
+func (p *Passwords) CheckNobody(plain string) {
+	p.dummyOnce.Do(func() {
+		p.dummy, _ = p.hasher.Hash("dummy password")
+	})
+	p.hasher.Verify(plain, p.dummy)
+}
+
+// HashSecret hashes a password that guards something other than an account, such as a
+// public link. The policy is not applied, the owner decides how strong it should be.
+func (p *Passwords) HashSecret(plain string) (string, error) {
+	return p.hasher.Hash(plain)
+}
+
+// CheckSecret reports whether plain matches a hash made by HashSecret.
+func (p *Passwords) CheckSecret(hash string, plain string) bool {
+	ok, _, err := p.hasher.Verify(plain, hash)
+	if err != nil {
+		slog.Error("Failed to verify secret hash", "error", err)
+		return false
+	}
+	return ok
+}
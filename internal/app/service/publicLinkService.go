@@ -0,0 +1,195 @@
+package service
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"2/internal/interface/http/dto"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"github.com/google/uuid"
+	"log/slog"
+	"time"
+)
+
+var (
+	ErrPublicLinkNotFound   = errors.New("link does not exist or has expired")
+	ErrLinkPasswordRequired = errors.New("the link is protected with a password")
+	ErrWrongLinkPassword    = errors.New("wrong link password")
+)
+
+// PublicLinkService lets owners open a note to anyone who has the link.
+type PublicLinkService struct {
+	linkRepo  repository.PublicLinksRepository
+	noteRepo  repository.NotesRepository
+	userRepo  repository.UserRepository
+	passwords *Passwords
+	guard     *LoginGuard
+	access    noteAccess
+	// baseURL is where the API is reachable from outside, links are baseURL/p/{slug}
+	baseURL string
+}
+
+func NewPublicLinkService(linkRepo repository.PublicLinksRepository, noteRepo repository.NotesRepository, shareRepo repository.SharesRepository, userRepo repository.UserRepository, passwords *Passwords, guard *LoginGuard, baseURL string) *PublicLinkService {
+	return &PublicLinkService{
+		linkRepo:  linkRepo,
+		noteRepo:  noteRepo,
+		userRepo:  userRepo,
+		passwords: passwords,
+		guard:     guard,
+		access:    noteAccess{noteRepo: noteRepo, shareRepo: shareRepo},
+		baseURL:   baseURL,
+	}
+}
+
+// CreateLink mints a new link to the note. The slug carries 128 random bits, it cannot
+// be guessed, so the link is as secret as the person it was sent to keeps it.
+func (s *PublicLinkService) CreateLink(ownerId uuid.UUID, noteId uuid.UUID, req dto.CreatePublicLinkRequest) (dto.PublicLinkResponse, error) {
+	if _, err := s.access.check(ownerId, noteId, models.PermissionOwner); err != nil {
+		return dto.PublicLinkResponse{}, err
+	}
+
+	now := time.Now()
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		return dto.PublicLinkResponse{}, errors.New("expires_at must be in the future")
+	}
+
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return dto.PublicLinkResponse{}, err
+	}
+
+	link := models.PublicLink{
+		ID:        uuid.New(),
+		NoteId:    noteId,
+		Slug:      base64.RawURLEncoding.EncodeToString(raw),
+		CreatedAt: now,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if req.Password != "" {
+		hash, err := s.passwords.HashSecret(req.Password)
+		if err != nil {
+			return dto.PublicLinkResponse{}, err
+		}
+		link.PasswordHash = &hash
+	}
+
+	if err := s.linkRepo.Create(link); err != nil {
+		return dto.PublicLinkResponse{}, err
+	}
+	return s.toResponse(link), nil
+}
+
+// GetLinks lists the links of the note with their view counts, only the owner sees them.
+func (s *PublicLinkService) GetLinks(ownerId uuid.UUID, noteId uuid.UUID) ([]dto.PublicLinkResponse, error) {
+	if _, err := s.access.check(ownerId, noteId, models.PermissionOwner); err != nil {
+		return nil, err
+	}
+
+	links, err := s.linkRepo.GetAllByNoteId(noteId)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]dto.PublicLinkResponse, len(links))
+	for i, link := range links {
+		resp[i] = s.toResponse(link)
+	}
+	return resp, nil
+}
+
+// RevokeLink deletes the link, it stops working at once.
+func (s *PublicLinkService) RevokeLink(ownerId uuid.UUID, noteId uuid.UUID, id uuid.UUID) error {
+	if _, err := s.access.check(ownerId, noteId, models.PermissionOwner); err != nil {
+		return err
+	}
+	return s.linkRepo.Delete(noteId, id)
+}
+
+// View opens the note behind the link for anyone and counts the view. Links that expired,
+// were revoked or lead to a trashed note, or to a disabled account, all look the same.
+// Wrong passwords are delayed and locked out per link and per address like logins.
+func (s *PublicLinkService) View(slug string, password string, client models.ClientInfo) (dto.PublicNoteResponse, error) {
+	link, err := s.linkRepo.GetBySlug(slug)
+	if errors.Is(err, repository.ErrNotFound) {
+		return dto.PublicNoteResponse{}, ErrPublicLinkNotFound
+	}
+	if err != nil {
+		return dto.PublicNoteResponse{}, err
+	}
+
+	now := time.Now()
+	if !link.Active(now) {
+		return dto.PublicNoteResponse{}, ErrPublicLinkNotFound
+	}
+
+	note, err := s.noteRepo.Get(link.NoteId)
+	if errors.Is(err, repository.ErrNotFound) {
+		return dto.PublicNoteResponse{}, ErrPublicLinkNotFound
+	}
+	if err != nil {
+		return dto.PublicNoteResponse{}, err
+	}
+
+	owner, err := s.userRepo.GetUserById(note.UserId)
+	if err != nil {
+		return dto.PublicNoteResponse{}, err
+	}
+	if owner.Disabled() {
+		return dto.PublicNoteResponse{}, ErrPublicLinkNotFound
+	}
+
+	if link.HasPassword() {
+		if password == "" {
+			return dto.PublicNoteResponse{}, ErrLinkPasswordRequired
+		}
+		if err = s.checkPassword(link, password, client.IP); err != nil {
+			return dto.PublicNoteResponse{}, err
+		}
+	}
+
+	// просмотр засчитывается, только когда заметку действительно показали
+	if err = s.linkRepo.RecordView(link.ID, now); err != nil {
+		slog.Error("Failed to record public link view", "link", link.ID, "error", err)
+	}
+
+	return dto.PublicNoteResponse{
+		Title:     note.Title,
+		Content:   note.Content,
+		Tags:      note.Tags,
+		UpdatedAt: note.UpdatedAt,
+	}, nil
+}
+
+// checkPassword checks the password of the link through the guard, so that it cannot be
+// guessed faster than an account password.
+func (s *PublicLinkService) checkPassword(link models.PublicLink, password string, ip string) error {
+	if err := s.guard.CheckLink(link.Slug, ip); err != nil {
+		return err
+	}
+	if !s.passwords.CheckSecret(*link.PasswordHash, password) {
+		if err := s.guard.FailLink(link.Slug, ip); err != nil {
+			return err
+		}
+		return ErrWrongLinkPassword
+	}
+	return s.guard.SucceedLink(link.Slug)
+}
+
+// PurgeLinks deletes expired links, for the background job.
+func (s *PublicLinkService) PurgeLinks() (int64, error) {
+	return s.linkRepo.DeleteExpired(time.Now())
+}
+
+func (s *PublicLinkService) toResponse(link models.PublicLink) dto.PublicLinkResponse {
+	return dto.PublicLinkResponse{
+		ID:           link.ID,
+		Slug:         link.Slug,
+		URL:          s.baseURL + "/p/" + link.Slug,
+		HasPassword:  link.HasPassword(),
+		CreatedAt:    link.CreatedAt,
+		ExpiresAt:    link.ExpiresAt,
+		Views:        link.Views,
+		LastViewedAt: link.LastViewedAt,
+	}
+}
@@ -0,0 +1,79 @@
+package service_test
+
+import (
+	"2/internal/app/events"
+	"2/internal/app/service"
+	"2/internal/domain/models"
+	"2/internal/infrastructure/storage/memory"
+	"2/internal/interface/http/dto"
+	"errors"
+	"testing"
+)
+
+// Passwords of public links are guessed no faster than account passwords: wrong ones are
+// delayed per link and per address, and the right one does not help while delayed.
+func TestPublicLinkPasswordThrottled(t *testing.T) {
+	env := newAuthEnv(t, guardPolicy)
+	ownerId := env.register(t, "ann@notes.test", "long password")
+
+	notes := memory.NewNotesRepository()
+	shares := memory.NewSharesRepository(notes, env.users.(*memory.UserRepository))
+	noteSvc := service.NewNoteService(notes, memory.NewNotebooksRepository(notes), shares, events.NewBus(16))
+	links := service.NewPublicLinkService(memory.NewPublicLinksRepository(notes), notes, shares, env.users, env.passwords, env.guard, "http://notes.test")
+
+	note, err := noteSvc.CreateNote(ownerId, dto.CreateNoteRequest{Title: "secret", Content: "behind a password"})
+	if err != nil {
+		t.Fatalf("create note: %v", err)
+	}
+	create := func() string {
+		link, err := links.CreateLink(ownerId, note.ID, dto.CreatePublicLinkRequest{Password: "link password"})
+		if err != nil {
+			t.Fatalf("create link: %v", err)
+		}
+		return link.Slug
+	}
+	guessed, other := create(), create()
+	attacker := models.ClientInfo{IP: "192.0.2.1"}
+	viewer := models.ClientInfo{IP: "198.51.100.7"}
+
+	for i := 0; i < guardPolicy.Account.FreeAttempts; i++ {
+		if _, err = links.View(guessed, "guess", attacker); !errors.Is(err, service.ErrWrongLinkPassword) {
+			t.Fatalf("free guess %d: got %v, want ErrWrongLinkPassword", i+1, err)
+		}
+	}
+	if _, err = links.View(guessed, "guess", attacker); !errors.Is(err, service.ErrWrongLinkPassword) {
+		t.Fatalf("first delayed guess: got %v, want ErrWrongLinkPassword", err)
+	}
+
+	// ссылка задержана для всех адресов, а адрес — только для этой ссылки
+	for name, client := range map[string]models.ClientInfo{"attacker": attacker, "viewer": viewer} {
+		if _, err = links.View(guessed, "link password", client); !errors.Is(err, service.ErrTooManyAttempts) {
+			t.Errorf("right password from the %s while delayed: got %v, want ErrTooManyAttempts", name, err)
+		}
+	}
+	if _, err = links.View(other, "link password", attacker); err != nil {
+		t.Errorf("other link from the same address: %v", err)
+	}
+	// пустой пароль не считается попыткой
+	if _, err = links.View(guessed, "", attacker); !errors.Is(err, service.ErrLinkPasswordRequired) {
+		t.Errorf("no password: got %v, want ErrLinkPasswordRequired", err)
+	}
+
+	if err = env.guard.Unlock("link:" + guessed); err != nil {
+		t.Fatalf("unlock link: %v", err)
+	}
+	if _, err = links.View(guessed, "link password", viewer); err != nil {
+		t.Fatalf("right password after unlock: %v", err)
+	}
+	// верный пароль сбрасывает счётчик ссылки
+	for i := 0; i < guardPolicy.Account.FreeAttempts; i++ {
+		if _, err = links.View(guessed, "guess", viewer); !errors.Is(err, service.ErrWrongLinkPassword) {
+			t.Errorf("free guess %d after a success: got %v, want ErrWrongLinkPassword", i+1, err)
+		}
+	}
+
+	lockouts, err := env.guard.Lockouts(10)
+	if err != nil || len(lockouts) != 0 {
+		t.Errorf("lockouts before LockoutAfter: %v, %v", lockouts, err)
+	}
+}
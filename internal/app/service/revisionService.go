@@ -0,0 +1,121 @@
+package service
+
+import (
+	"2/internal/app/diff"
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"2/internal/interface/http/dto"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+const diffContextLines = 3
+
+type RevisionService struct {
+	revisionRepo repository.RevisionsRepository
+	noteRepo     repository.NotesRepository
+	access       noteAccess
+	events       noteEvents
+	policy       models.RevisionPolicy
+}
+
+func NewRevisionService(revisionRepo repository.RevisionsRepository, noteRepo repository.NotesRepository, shareRepo repository.SharesRepository, publisher NotePublisher, policy models.RevisionPolicy) *RevisionService {
+	return &RevisionService{
+		revisionRepo: revisionRepo,
+		noteRepo:     noteRepo,
+		access:       noteAccess{noteRepo: noteRepo, shareRepo: shareRepo},
+		events:       noteEvents{shareRepo: shareRepo, publisher: publisher},
+		policy:       policy,
+	}
+}
+
+func (s *RevisionService) GetRevisions(userId uuid.UUID, noteId uuid.UUID) ([]models.NoteRevision, error) {
+	if _, err := s.access.check(userId, noteId, models.PermissionViewer); err != nil {
+		return nil, err
+	}
+
+	return s.revisionRepo.GetAllByNoteId(noteId)
+}
+
+func (s *RevisionService) GetRevision(userId uuid.UUID, noteId uuid.UUID, revision int) (models.NoteRevision, error) {
+	if _, err := s.access.check(userId, noteId, models.PermissionViewer); err != nil {
+		return models.NoteRevision{}, err
+	}
+
+	return s.revisionRepo.Get(noteId, revision)
+}
+
+// DiffRevisions compares the content of two revisions, words=true gives word-level
+// changes instead of a unified line diff.
+func (s *RevisionService) DiffRevisions(userId uuid.UUID, noteId uuid.UUID, from int, to int, words bool) (dto.RevisionDiffResponse, error) {
+	if _, err := s.access.check(userId, noteId, models.PermissionViewer); err != nil {
+		return dto.RevisionDiffResponse{}, err
+	}
+
+	fromRev, err := s.revisionRepo.Get(noteId, from)
+	if err != nil {
+		return dto.RevisionDiffResponse{}, fmt.Errorf("revision %d: %w", from, err)
+	}
+	toRev, err := s.revisionRepo.Get(noteId, to)
+	if err != nil {
+		return dto.RevisionDiffResponse{}, fmt.Errorf("revision %d: %w", to, err)
+	}
+
+	result := dto.RevisionDiffResponse{
+		From:      from,
+		To:        to,
+		FromTitle: fromRev.Title,
+		ToTitle:   toRev.Title,
+	}
+	if words {
+		result.Changes = diff.Words(fromRev.Content, toRev.Content)
+	} else {
+		result.Unified = diff.Unified(fromRev.Content, toRev.Content,
+			fmt.Sprintf("revision %d", from), fmt.Sprintf("revision %d", to), diffContextLines)
+	}
+
+	return result, nil
+}
+
+// RestoreRevision makes the revision's title and content current again, which is recorded as a new revision.
+func (s *RevisionService) RestoreRevision(userId uuid.UUID, noteId uuid.UUID, revision int) (models.Note, error) {
+	note, err := s.access.check(userId, noteId, models.PermissionEditor)
+	if err != nil {
+		return models.Note{}, err
+	}
+
+	rev, err := s.revisionRepo.Get(noteId, revision)
+	if err != nil {
+		return models.Note{}, err
+	}
+
+	if rev.Title == note.Title && rev.Content == note.Content {
+		return models.Note{}, errors.New("note already has the content of this revision")
+	}
+
+	note.Title = rev.Title
+	note.Content = rev.Content
+	if err = s.noteRepo.Update(note); err != nil {
+		return models.Note{}, err
+	}
+
+	note, err = s.noteRepo.Get(noteId)
+	if err != nil {
+		return models.Note{}, err
+	}
+
+	s.events.publish(models.EventNoteUpdated, note)
+	return note, nil
+}
+
+// PruneRevisions applies the retention policy to the revisions of all notes.
+func (s *RevisionService) PruneRevisions() (int64, error) {
+	var olderThan time.Time
+	if s.policy.MaxAge > 0 {
+		olderThan = time.Now().Add(-s.policy.MaxAge)
+	}
+
+	return s.revisionRepo.Prune(s.policy.KeepLast, olderThan)
+}
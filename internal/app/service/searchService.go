@@ -0,0 +1,76 @@
+package service
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"errors"
+	"github.com/google/uuid"
+	"html"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+type SearchService struct {
+	searchRepo repository.SearchRepository
+}
+
+func NewSearchService(searchRepo repository.SearchRepository) *SearchService {
+	return &SearchService{searchRepo: searchRepo}
+}
+
+func (s *SearchService) SearchNotes(userId uuid.UUID, query string, limit int) ([]models.SearchResult, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, errors.New("search query is required")
+	}
+
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	limit = min(limit, MaxSearchLimit)
+
+	results, err := s.searchRepo.Search(userId, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].TitleHighlight = highlightHTML(results[i].TitleHighlight)
+		results[i].Snippet = highlightHTML(results[i].Snippet)
+	}
+	return results, nil
+}
+
+// highlightHTML escapes text with match markers and turns the markers into <mark></mark>.
+// Markers that were typed into the note itself cannot leave a tag open.
+func highlightHTML(text string) string {
+	var b strings.Builder
+	open := false
+	for {
+		i := strings.IndexAny(text, models.HighlightStart+models.HighlightStop)
+		if i < 0 {
+			break
+		}
+		b.WriteString(html.EscapeString(text[:i]))
+
+		marker, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case string(marker) == models.HighlightStart && !open:
+			b.WriteString("<mark>")
+			open = true
+		case string(marker) == models.HighlightStop && open:
+			b.WriteString("</mark>")
+			open = false
+		}
+		text = text[i+size:]
+	}
+	b.WriteString(html.EscapeString(text))
+	if open {
+		b.WriteString("</mark>")
+	}
+	return b.String()
+}
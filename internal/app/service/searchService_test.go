@@ -0,0 +1,52 @@
+package service_test
+
+import (
+	"2/internal/app/events"
+	"2/internal/app/service"
+	"2/internal/interface/http/dto"
+	"strings"
+	"testing"
+)
+
+// Highlights are HTML: markup typed into a note comes back escaped, only the matches are
+// wrapped into <mark></mark>.
+func TestSearchHighlightsEscaped(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b testBackend) {
+		notes := service.NewNoteService(b.notes, b.notebooks, b.shares, events.NewBus(16))
+		search := service.NewSearchService(b.search)
+		userId := b.newUser(t, "searcher")
+
+		_, err := notes.CreateNote(userId, dto.CreateNoteRequest{
+			Title:   `<img src=x onerror=alert(1)> recipe`,
+			Content: "Bake the cake & <script>steal()</script> then serve the recipe \uE001 to \uE000 guests",
+		})
+		if err != nil {
+			t.Fatalf("create note: %v", err)
+		}
+
+		results, err := search.SearchNotes(userId, "recipe", 10)
+		if err != nil {
+			t.Fatalf("search: %v", err)
+		}
+		if len(results) != 1 {
+			t.Fatalf("%d results, want 1", len(results))
+		}
+
+		res := results[0]
+		if want := "&lt;img src=x onerror=alert(1)&gt; <mark>recipe</mark>"; res.TitleHighlight != want {
+			t.Errorf("title highlight %q, want %q", res.TitleHighlight, want)
+		}
+		for _, want := range []string{"cake &amp; &lt;script&gt;steal()&lt;/script&gt;", "<mark>recipe</mark>"} {
+			if !strings.Contains(res.Snippet, want) {
+				t.Errorf("snippet %q does not contain %q", res.Snippet, want)
+			}
+		}
+		// маркеры, набранные в самой заметке, не оставляют тег открытым
+		if strings.Count(res.Snippet, "<mark>") != strings.Count(res.Snippet, "</mark>") || strings.ContainsAny(res.Snippet, "\uE000\uE001") {
+			t.Errorf("snippet %q has unbalanced or raw markers", res.Snippet)
+		}
+		if res.Note.Title != `<img src=x onerror=alert(1)> recipe` {
+			t.Errorf("note title changed to %q", res.Note.Title)
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"2/internal/interface/http/dto"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrShareUserNotFound = errors.New("there is no user with this email")
+	ErrInvalidPermission = fmt.Errorf("permission must be %s", strings.Join(models.SharePermissions, " or "))
+)
+
+// ShareService lets owners open their notes to other users.
+type ShareService struct {
+	shareRepo repository.SharesRepository
+	userRepo  repository.UserRepository
+	access    noteAccess
+}
+
+func NewShareService(shareRepo repository.SharesRepository, noteRepo repository.NotesRepository, userRepo repository.UserRepository) *ShareService {
+	return &ShareService{
+		shareRepo: shareRepo,
+		userRepo:  userRepo,
+		access:    noteAccess{noteRepo: noteRepo, shareRepo: shareRepo},
+	}
+}
+
+// ShareNote shares the note with the user who has the email, sharing again changes the permission.
+func (s *ShareService) ShareNote(ownerId uuid.UUID, noteId uuid.UUID, req dto.ShareNoteRequest) (dto.NoteShareResponse, error) {
+	if !slices.Contains(models.SharePermissions, req.Permission) {
+		return dto.NoteShareResponse{}, ErrInvalidPermission
+	}
+	if _, err := s.access.check(ownerId, noteId, models.PermissionOwner); err != nil {
+		return dto.NoteShareResponse{}, err
+	}
+
+	user, exists, err := s.userRepo.GetUserByEmail(strings.TrimSpace(req.Email))
+	if err != nil {
+		return dto.NoteShareResponse{}, err
+	}
+	if !exists {
+		return dto.NoteShareResponse{}, ErrShareUserNotFound
+	}
+	if user.UserId == ownerId {
+		return dto.NoteShareResponse{}, errors.New("you cannot share a note with yourself")
+	}
+
+	err = s.shareRepo.Save(models.NoteShare{
+		NoteId:     noteId,
+		UserId:     user.UserId,
+		Permission: req.Permission,
+		CreatedAt:  time.Now(),
+	})
+	if err != nil {
+		return dto.NoteShareResponse{}, err
+	}
+
+	share, err := s.shareRepo.Get(noteId, user.UserId)
+	if err != nil {
+		return dto.NoteShareResponse{}, err
+	}
+	return toNoteShareResponse(share), nil
+}
+
+// GetShares lists who the note is shared with, only the owner sees it.
+func (s *ShareService) GetShares(ownerId uuid.UUID, noteId uuid.UUID) ([]dto.NoteShareResponse, error) {
+	if _, err := s.access.check(ownerId, noteId, models.PermissionOwner); err != nil {
+		return nil, err
+	}
+
+	shares, err := s.shareRepo.GetAllByNoteId(noteId)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := []dto.NoteShareResponse{}
+	for _, share := range shares {
+		resp = append(resp, toNoteShareResponse(share))
+	}
+	return resp, nil
+}
+
+// RevokeShare takes the access away. The owner revokes anyone's share, any other user
+// can only give up their own.
+func (s *ShareService) RevokeShare(userId uuid.UUID, noteId uuid.UUID, sharedWith uuid.UUID) error {
+	if userId != sharedWith {
+		if _, err := s.access.check(userId, noteId, models.PermissionOwner); err != nil {
+			return err
+		}
+	}
+	return s.shareRepo.Delete(noteId, sharedWith)
+}
+
+// GetSharedWithMe lists notes other users shared with the user, recently updated first.
+func (s *ShareService) GetSharedWithMe(userId uuid.UUID) ([]dto.SharedNoteResponse, error) {
+	shared, err := s.shareRepo.GetSharedWith(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := []dto.SharedNoteResponse{}
+	for _, note := range shared {
+		resp = append(resp, dto.SharedNoteResponse{
+			Note:       note.Note,
+			Permission: note.Permission,
+			OwnerEmail: note.OwnerEmail,
+			SharedAt:   note.SharedAt,
+		})
+	}
+	return resp, nil
+}
+
+func toNoteShareResponse(share models.NoteShare) dto.NoteShareResponse {
+	return dto.NoteShareResponse{
+		UserId:     share.UserId,
+		Email:      share.Email,
+		Username:   share.Username,
+		Permission: share.Permission,
+		CreatedAt:  share.CreatedAt,
+	}
+}
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"2/internal/interface/http/dto"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"slices"
+	"time"
+)
+
+var (
+	ErrInvalidSyncToken = errors.New("sync token is invalid")
+	ErrSyncTokenExpired = errors.New("sync token is too old, sync again from scratch without since")
+)
+
+const (
+	SyncApplied  = "applied"
+	SyncConflict = "conflict"
+	SyncDeleted  = "deleted"
+	SyncRejected = "rejected"
+
+	DefaultSyncLimit = 500
+	MaxSyncLimit     = 1000
+	MaxSyncBatch     = 500
+)
+
+// SyncService lets offline clients keep a copy of the user's notes. Pull returns what changed
+// since a token, Push applies changes made offline one by one.
+//
+// Conflicts are resolved per note, the server never merges texts:
+//   - a change made on the version the server has is applied;
+//   - a change made on an older version is a conflict, the result carries the server copy and
+//     the client decides: keep the server copy, or merge and send again with its version;
+//   - a change that leaves the note as the server already has it is applied, so a batch
+//     resent after a lost response does no harm;
+//   - a change of a note deleted on the server reports deleted, the client may drop it or
+//     create it again under a new id;
+//   - deleting a note that is already gone is applied, deleting one changed since is a conflict,
+//     so a stale delete never throws away edits made elsewhere.
+type SyncService struct {
+	notes              *NoteService
+	noteRepo           repository.NotesRepository
+	tombstoneRetention time.Duration
+}
+
+func NewSyncService(notes *NoteService, noteRepo repository.NotesRepository, tombstoneRetention time.Duration) *SyncService {
+	return &SyncService{
+		notes:              notes,
+		noteRepo:           noteRepo,
+		tombstoneRetention: tombstoneRetention,
+	}
+}
+
+// Pull returns the user's notes changed, trashed or removed since the token, an empty token
+// starts from the beginning. Shared notes are not part of the feed.
+func (s *SyncService) Pull(userId uuid.UUID, since string, limit int) (dto.SyncPullResponse, error) {
+	switch {
+	case limit == 0:
+		limit = DefaultSyncLimit
+	case limit < 0 || limit > MaxSyncLimit:
+		return dto.SyncPullResponse{}, fmt.Errorf("limit must be between 1 and %d", MaxSyncLimit)
+	}
+
+	var after models.SyncCursor
+	if since != "" {
+		var err error
+		if after, err = decodeSyncToken(since); err != nil {
+			return dto.SyncPullResponse{}, err
+		}
+	}
+
+	// one extra change tells whether there is more
+	changes, pruned, err := s.noteRepo.Changes(userId, after, limit+1)
+	if err != nil {
+		return dto.SyncPullResponse{}, err
+	}
+	// удаления до pruned уже забыты: клиент со старым токеном о них не узнает
+	if since != "" && after.Seq < pruned {
+		return dto.SyncPullResponse{}, ErrSyncTokenExpired
+	}
+
+	resp := dto.SyncPullResponse{Changes: []dto.SyncChangeResponse{}}
+	if len(changes) > limit {
+		changes = changes[:limit]
+		resp.HasMore = true
+	}
+	for _, change := range changes {
+		resp.Changes = append(resp.Changes, dto.SyncChangeResponse{
+			ID:        change.NoteId,
+			Deleted:   change.Note == nil,
+			Note:      change.Note,
+			DeletedAt: change.DeletedAt,
+		})
+		after = change.Cursor()
+	}
+	resp.NextToken = encodeSyncToken(after)
+	return resp, nil
+}
+
+// Push applies a batch of offline changes in order, every change gets its own result.
+func (s *SyncService) Push(userId uuid.UUID, req dto.SyncPushRequest) (dto.SyncPushResponse, error) {
+	if len(req.Changes) > MaxSyncBatch {
+		return dto.SyncPushResponse{}, fmt.Errorf("a batch holds at most %d changes", MaxSyncBatch)
+	}
+
+	resp := dto.SyncPushResponse{Results: make([]dto.SyncResultResponse, 0, len(req.Changes))}
+	for _, change := range req.Changes {
+		result := dto.SyncResultResponse{ID: change.ID}
+
+		var note *models.Note
+		var err error
+		switch {
+		case change.ID == uuid.Nil:
+			err = errors.New("id is required")
+		case change.Deleted:
+			result.Status, note, err = s.delete(userId, change)
+		case change.BaseVersion == 0:
+			result.Status, note, err = s.create(userId, change)
+		default:
+			result.Status, note, err = s.update(userId, change)
+		}
+
+		if err != nil {
+			result.Status = SyncRejected
+			result.Error = err.Error()
+		}
+		result.Note = note
+		resp.Results = append(resp.Results, result)
+	}
+	return resp, nil
+}
+
+func (s *SyncService) create(userId uuid.UUID, change dto.SyncChangeRequest) (string, *models.Note, error) {
+	current, err := s.noteRepo.Get(change.ID)
+	switch {
+	case err == nil && current.UserId != userId:
+		return "", nil, errors.New("id is taken, choose another one")
+	case err == nil:
+		// заметку уже создали этим же пакетом, ответ на который до клиента не дошёл
+		if sameNote(current, change) {
+			return SyncApplied, &current, nil
+		}
+		return SyncConflict, &current, nil
+	case !errors.Is(err, repository.ErrNotFound):
+		return "", nil, err
+	}
+
+	note, err := s.notes.create(userId, change.ID, dto.CreateNoteRequest{
+		Title:      change.Title,
+		Content:    change.Content,
+		Tags:       change.Tags,
+		NotebookId: change.NotebookId,
+	})
+	if err != nil {
+		// id мог остаться за заметкой в корзине
+		if trashed, trashErr := s.inTrash(userId, change.ID); trashErr == nil && trashed {
+			return SyncDeleted, nil, nil
+		}
+		return "", nil, err
+	}
+	return SyncApplied, &note, nil
+}
+
+func (s *SyncService) update(userId uuid.UUID, change dto.SyncChangeRequest) (string, *models.Note, error) {
+	current, err := s.notes.GetNote(userId, change.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return SyncDeleted, nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	if sameNote(current, change) {
+		return SyncApplied, &current, nil
+	}
+	if current.Version != change.BaseVersion {
+		return SyncConflict, &current, nil
+	}
+
+	note, err := s.notes.UpdateNote(userId, change.ID, dto.UpdateNoteRequest{
+		Title:   change.Title,
+		Content: change.Content,
+		Tags:    change.Tags,
+	}, change.BaseVersion)
+	return s.outcome(userId, change.ID, note, err)
+}
+
+func (s *SyncService) delete(userId uuid.UUID, change dto.SyncChangeRequest) (string, *models.Note, error) {
+	if change.BaseVersion == 0 {
+		return "", nil, errors.New("base_version is required to delete a note")
+	}
+
+	current, err := s.notes.GetNote(userId, change.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return SyncApplied, nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if current.Version != change.BaseVersion {
+		return SyncConflict, &current, nil
+	}
+
+	err = s.notes.DeleteNote(userId, change.ID, change.BaseVersion)
+	return s.outcome(userId, change.ID, models.Note{}, err)
+}
+
+// outcome turns the result of a write into a sync result. A write that lost to another one
+// after the version was checked is a conflict like any other.
+func (s *SyncService) outcome(userId uuid.UUID, noteId uuid.UUID, note models.Note, err error) (string, *models.Note, error) {
+	switch {
+	case err == nil && note.ID == uuid.Nil:
+		return SyncApplied, nil, nil
+	case err == nil:
+		return SyncApplied, &note, nil
+	case errors.Is(err, repository.ErrVersionConflict):
+		current, err := s.notes.GetNote(userId, noteId)
+		if errors.Is(err, repository.ErrNotFound) {
+			return SyncDeleted, nil, nil
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		return SyncConflict, &current, nil
+	case errors.Is(err, repository.ErrNotFound):
+		return SyncDeleted, nil, nil
+	default:
+		return "", nil, err
+	}
+}
+
+func (s *SyncService) inTrash(userId uuid.UUID, noteId uuid.UUID) (bool, error) {
+	trash, err := s.noteRepo.GetTrash(userId)
+	if err != nil {
+		return false, err
+	}
+	return slices.ContainsFunc(trash, func(note models.Note) bool {
+		return note.ID == noteId
+	}), nil
+}
+
+// PurgeTombstones forgets notes removed for good longer ago than the retention, for the
+// background job. Clients that have not synced since then start over.
+func (s *SyncService) PurgeTombstones() (int64, error) {
+	if s.tombstoneRetention <= 0 {
+		return 0, nil
+	}
+
+	return s.noteRepo.PurgeTombstones(time.Now().Add(-s.tombstoneRetention))
+}
+
+// sameNote tells whether the change leaves the note as it is, omitted tags match any.
+func sameNote(note models.Note, change dto.SyncChangeRequest) bool {
+	if note.Title != change.Title || note.Content != change.Content {
+		return false
+	}
+	if change.Tags == nil {
+		return true
+	}
+	tags, err := normalizeTags(change.Tags)
+	return err == nil && slices.Equal(note.Tags, tags)
+}
+
+func encodeSyncToken(cursor models.SyncCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSyncToken(token string) (models.SyncCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return models.SyncCursor{}, ErrInvalidSyncToken
+	}
+
+	var cursor models.SyncCursor
+	if err = json.Unmarshal(data, &cursor); err != nil || cursor.Seq < 0 {
+		return models.SyncCursor{}, ErrInvalidSyncToken
+	}
+	return cursor, nil
+}
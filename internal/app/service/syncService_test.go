@@ -0,0 +1,298 @@
+package service_test
+
+import (
+	"2/internal/app/events"
+	"2/internal/app/service"
+	"2/internal/domain/models"
+	"2/internal/interface/http/dto"
+	"errors"
+	"github.com/google/uuid"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type syncEnv struct {
+	b         testBackend
+	notes     *service.NoteService
+	notebooks *service.NotebookService
+	sync      *service.SyncService
+	userId    uuid.UUID
+}
+
+func newSyncEnv(t *testing.T, b testBackend, retention time.Duration) *syncEnv {
+	t.Helper()
+
+	bus := events.NewBus(16)
+	notes := service.NewNoteService(b.notes, b.notebooks, b.shares, bus)
+	return &syncEnv{
+		b:         b,
+		notes:     notes,
+		notebooks: service.NewNotebookService(b.notebooks, b.notes, b.shares, bus),
+		sync:      service.NewSyncService(notes, b.notes, retention),
+		userId:    b.newUser(t, "sync-"+uuid.NewString()[:8]),
+	}
+}
+
+func (e *syncEnv) createNote(t *testing.T, title string, notebookId *uuid.UUID) models.Note {
+	t.Helper()
+	note, err := e.notes.CreateNote(e.userId, dto.CreateNoteRequest{Title: title, Content: "content", NotebookId: notebookId})
+	if err != nil {
+		t.Fatalf("create note %s: %v", title, err)
+	}
+	return note
+}
+
+func (e *syncEnv) pull(t *testing.T, since string, limit int) dto.SyncPullResponse {
+	t.Helper()
+	resp, err := e.sync.Pull(e.userId, since, limit)
+	if err != nil {
+		t.Fatalf("pull since %q: %v", since, err)
+	}
+	return resp
+}
+
+// feed describes the changes as "id" for a live note and "-id" for a tombstone.
+func feed(changes []dto.SyncChangeResponse) []string {
+	var ids []string
+	for _, change := range changes {
+		id := change.ID.String()
+		if change.Deleted {
+			if change.Note != nil || change.DeletedAt == nil {
+				id = "broken tombstone " + id
+			}
+			id = "-" + id
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestSyncPullOrder(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b testBackend) {
+		env := newSyncEnv(t, b, 0)
+
+		a := env.createNote(t, "a", nil)
+		trashed := env.createNote(t, "trashed", nil)
+		removed := env.createNote(t, "removed", nil)
+		untouched := env.createNote(t, "untouched", nil)
+		notebook, err := env.notebooks.CreateNotebook(env.userId, dto.CreateNotebookRequest{Name: "nb"})
+		if err != nil {
+			t.Fatalf("create notebook: %v", err)
+		}
+		inNotebook := []models.Note{
+			env.createNote(t, "nb 1", &notebook.ID),
+			env.createNote(t, "nb 2", &notebook.ID),
+		}
+
+		if _, err = env.notes.UpdateNote(env.userId, a.ID, dto.UpdateNoteRequest{Title: "a", Content: "changed"}, 0); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+		if err = env.notes.DeleteNote(env.userId, trashed.ID, 0); err != nil {
+			t.Fatalf("trash: %v", err)
+		}
+		if err = b.notes.Delete(removed.ID); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		// заметки блокнота уходят в корзину одним изменением и упорядочены по id
+		if err = env.notebooks.DeleteNotebook(env.userId, notebook.ID, true); err != nil {
+			t.Fatalf("delete notebook: %v", err)
+		}
+		slices.SortFunc(inNotebook, func(x, y models.Note) int {
+			return strings.Compare(x.ID.String(), y.ID.String())
+		})
+
+		want := []string{
+			untouched.ID.String(),
+			a.ID.String(),
+			"-" + trashed.ID.String(),
+			"-" + removed.ID.String(),
+			"-" + inNotebook[0].ID.String(),
+			"-" + inNotebook[1].ID.String(),
+		}
+
+		full := env.pull(t, "", 0)
+		if got := feed(full.Changes); !slices.Equal(got, want) {
+			t.Fatalf("feed:\n got %v\nwant %v", got, want)
+		}
+		if full.HasMore {
+			t.Errorf("full pull: has_more is set")
+		}
+		if full.Changes[1].Note.Content != "changed" {
+			t.Errorf("feed carries content %q, want the updated one", full.Changes[1].Note.Content)
+		}
+
+		// по одной: каждая страница продолжает с места предыдущей, в том числе посреди равных номеров
+		var paged []dto.SyncChangeResponse
+		since := ""
+		for i := 0; ; i++ {
+			if i > len(want) {
+				t.Fatalf("paging does not stop, got %v", feed(paged))
+			}
+			page := env.pull(t, since, 1)
+			paged = append(paged, page.Changes...)
+			since = page.NextToken
+			if !page.HasMore {
+				break
+			}
+		}
+		if got := feed(paged); !slices.Equal(got, want) {
+			t.Fatalf("paged feed:\n got %v\nwant %v", got, want)
+		}
+		if since != full.NextToken {
+			t.Errorf("paged pull ends at %s, full pull at %s", since, full.NextToken)
+		}
+
+		// дальше конца ничего нет, токен остаётся прежним
+		tail := env.pull(t, since, 0)
+		if len(tail.Changes) != 0 || tail.HasMore || tail.NextToken != since {
+			t.Errorf("pull at the end: %d changes, has_more %v, token %s, want nothing and %s", len(tail.Changes), tail.HasMore, tail.NextToken, since)
+		}
+
+		// восстановленная заметка снова живая и в конце ленты
+		if err = b.notes.Restore(env.userId, trashed.ID); err != nil {
+			t.Fatalf("restore: %v", err)
+		}
+		if got := feed(env.pull(t, since, 0).Changes); !slices.Equal(got, []string{trashed.ID.String()}) {
+			t.Errorf("feed after restore: %v, want the restored note", got)
+		}
+	})
+}
+
+func TestSyncPullBadInput(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b testBackend) {
+		env := newSyncEnv(t, b, 0)
+
+		for _, since := range []string{"not base64!", "bm90IGpzb24", "eyJzIjotMX0"} {
+			if _, err := env.sync.Pull(env.userId, since, 0); !errors.Is(err, service.ErrInvalidSyncToken) {
+				t.Errorf("since %q: got %v, want ErrInvalidSyncToken", since, err)
+			}
+		}
+		for _, limit := range []int{-1, service.MaxSyncLimit + 1} {
+			if _, err := env.sync.Pull(env.userId, "", limit); err == nil {
+				t.Errorf("limit %d: got no error", limit)
+			}
+		}
+	})
+}
+
+func TestSyncTokenExpired(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b testBackend) {
+		env := newSyncEnv(t, b, time.Nanosecond)
+
+		kept := env.createNote(t, "kept", nil)
+		removed := env.createNote(t, "removed", nil)
+		old := env.pull(t, "", 0).NextToken
+
+		if err := b.notes.Delete(removed.ID); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		caughtUp := env.pull(t, old, 0)
+		if got := feed(caughtUp.Changes); !slices.Equal(got, []string{"-" + removed.ID.String()}) {
+			t.Fatalf("feed after delete: %v, want the tombstone", got)
+		}
+
+		// при нулевом сроке хранения надгробия не трогаются
+		if purged, err := newSyncEnv(t, b, 0).sync.PurgeTombstones(); err != nil || purged != 0 {
+			t.Fatalf("purge without retention: %d, %v, want 0", purged, err)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		purged, err := env.sync.PurgeTombstones()
+		if err != nil || purged != 1 {
+			t.Fatalf("purge: %d, %v, want 1", purged, err)
+		}
+
+		// клиент, не видевший удаления, о нём уже не узнает
+		if _, err = env.sync.Pull(env.userId, old, 0); !errors.Is(err, service.ErrSyncTokenExpired) {
+			t.Errorf("pull with a token older than the purge: got %v, want ErrSyncTokenExpired", err)
+		}
+		if resp := env.pull(t, caughtUp.NextToken, 0); len(resp.Changes) != 0 {
+			t.Errorf("pull with a token past the purge: %v, want nothing", feed(resp.Changes))
+		}
+		if got := feed(env.pull(t, "", 0).Changes); !slices.Equal(got, []string{kept.ID.String()}) {
+			t.Errorf("pull from scratch: %v, want only the kept note", got)
+		}
+	})
+}
+
+func TestSyncPush(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b testBackend) {
+		env := newSyncEnv(t, b, 0)
+		push := func(changes ...dto.SyncChangeRequest) []dto.SyncResultResponse {
+			t.Helper()
+			resp, err := env.sync.Push(env.userId, dto.SyncPushRequest{Changes: changes})
+			if err != nil {
+				t.Fatalf("push: %v", err)
+			}
+			if len(resp.Results) != len(changes) {
+				t.Fatalf("push: %d results for %d changes", len(resp.Results), len(changes))
+			}
+			return resp.Results
+		}
+		expect := func(what string, result dto.SyncResultResponse, status string, version int64) {
+			t.Helper()
+			if result.Status != status {
+				t.Fatalf("%s: status %s (%s), want %s", what, result.Status, result.Error, status)
+			}
+			switch {
+			case version == 0 && result.Note != nil:
+				t.Errorf("%s: result carries a note", what)
+			case version != 0 && (result.Note == nil || result.Note.Version != version):
+				t.Errorf("%s: result note %+v, want version %d", what, result.Note, version)
+			}
+		}
+
+		id := uuid.New()
+		create := dto.SyncChangeRequest{ID: id, Title: "offline", Content: "v1", Tags: []string{"sync"}}
+		expect("create", push(create)[0], service.SyncApplied, 1)
+		// ответ потерялся, клиент шлёт пакет ещё раз
+		expect("create again", push(create)[0], service.SyncApplied, 1)
+		expect("create with other content", push(dto.SyncChangeRequest{ID: id, Title: "offline", Content: "other"})[0], service.SyncConflict, 1)
+
+		update := dto.SyncChangeRequest{ID: id, BaseVersion: 1, Title: "offline", Content: "v2"}
+		expect("update", push(update)[0], service.SyncApplied, 2)
+		expect("update again", push(update)[0], service.SyncApplied, 2)
+
+		stale := push(dto.SyncChangeRequest{ID: id, BaseVersion: 1, Title: "offline", Content: "elsewhere"})[0]
+		expect("stale update", stale, service.SyncConflict, 2)
+		if stale.Note.Content != "v2" {
+			t.Errorf("stale update: server copy %q, want v2", stale.Note.Content)
+		}
+		expect("stale delete", push(dto.SyncChangeRequest{ID: id, BaseVersion: 1, Deleted: true})[0], service.SyncConflict, 2)
+		if note, err := env.notes.GetNote(env.userId, id); err != nil || note.Content != "v2" {
+			t.Fatalf("note after stale changes: %+v, %v, want v2", note, err)
+		}
+
+		remove := dto.SyncChangeRequest{ID: id, BaseVersion: 2, Deleted: true}
+		expect("delete", push(remove)[0], service.SyncApplied, 0)
+		expect("delete again", push(remove)[0], service.SyncApplied, 0)
+		expect("update of a deleted note", push(update)[0], service.SyncDeleted, 0)
+		expect("create with a trashed id", push(create)[0], service.SyncDeleted, 0)
+
+		// пакет применяется по порядку, ошибка одного изменения не мешает остальным
+		other := newSyncEnv(t, b, 0)
+		foreign := other.createNote(t, "foreign", nil)
+		results := push(
+			dto.SyncChangeRequest{Title: "no id"},
+			dto.SyncChangeRequest{ID: foreign.ID, Title: "taken"},
+			dto.SyncChangeRequest{ID: uuid.New(), Deleted: true},
+			dto.SyncChangeRequest{ID: uuid.New(), Title: "fine"},
+		)
+		for i, what := range []string{"nil id", "id of another user", "delete without base_version"} {
+			if results[i].Status != service.SyncRejected || results[i].Error == "" {
+				t.Errorf("%s: status %s, error %q, want rejected with an error", what, results[i].Status, results[i].Error)
+			}
+		}
+		expect("create after rejected ones", results[3], service.SyncApplied, 1)
+		if note, err := other.notes.GetNote(other.userId, foreign.ID); err != nil || note.Title != "foreign" {
+			t.Errorf("note of another user: %+v, %v, want it untouched", note, err)
+		}
+
+		batch := make([]dto.SyncChangeRequest, service.MaxSyncBatch+1)
+		if _, err := env.sync.Push(env.userId, dto.SyncPushRequest{Changes: batch}); err == nil {
+			t.Errorf("push of %d changes: got no error", len(batch))
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const maxTagLength = 64
+
+type TagService struct {
+	tagRepo repository.TagsRepository
+}
+
+func NewTagService(tagRepo repository.TagsRepository) *TagService {
+	return &TagService{tagRepo: tagRepo}
+}
+
+func (s *TagService) GetUserTags(userId uuid.UUID) ([]models.TagCount, error) {
+	return s.tagRepo.GetAllByUserId(userId)
+}
+
+func (s *TagService) RenameTag(userId uuid.UUID, from string, to string) error {
+	names, err := normalizeTags([]string{from, to})
+	if err != nil {
+		return err
+	}
+	if len(names) != 2 {
+		return errors.New("new tag name must differ from the old one")
+	}
+
+	return s.tagRepo.Rename(userId, normalizeTag(from), normalizeTag(to))
+}
+
+func (s *TagService) MergeTags(userId uuid.UUID, sources []string, target string) error {
+	if len(sources) == 0 {
+		return errors.New("at least one source tag is required")
+	}
+
+	sources, err := normalizeTags(sources)
+	if err != nil {
+		return err
+	}
+	targets, err := normalizeTags([]string{target})
+	if err != nil {
+		return err
+	}
+
+	return s.tagRepo.Merge(userId, sources, targets[0])
+}
+
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// normalizeTags lower-cases, validates, deduplicates and sorts tag names.
+func normalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = normalizeTag(tag)
+		if tag == "" {
+			return nil, errors.New("tag name is required")
+		}
+		if utf8.RuneCountInString(tag) > maxTagLength {
+			return nil, fmt.Errorf("tag %s is longer than %d characters", tag, maxTagLength)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+
+	sort.Strings(normalized)
+	return normalized, nil
+}
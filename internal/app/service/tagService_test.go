@@ -0,0 +1,213 @@
+package service_test
+
+import (
+	"2/internal/app/events"
+	"2/internal/app/service"
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"2/internal/interface/http/dto"
+	"errors"
+	"github.com/google/uuid"
+	"slices"
+	"testing"
+)
+
+type tagEnv struct {
+	notes  *service.NoteService
+	tags   *service.TagService
+	userId uuid.UUID
+	// byTitle finds the notes of the user by their titles
+	byTitle map[string]uuid.UUID
+}
+
+// newTagEnv stores notes of ann with overlapping tags, a note of bob with one of them and
+// a trashed note of ann.
+func newTagEnv(t *testing.T, b testBackend) *tagEnv {
+	t.Helper()
+
+	env := &tagEnv{
+		notes:   service.NewNoteService(b.notes, b.notebooks, b.shares, events.NewBus(16)),
+		tags:    service.NewTagService(b.tags),
+		userId:  b.newUser(t, "ann"),
+		byTitle: make(map[string]uuid.UUID),
+	}
+	for title, tags := range map[string][]string{
+		"exam notes":   {"Math", "exam"},
+		"algebra":      {"math"},
+		"mechanics":    {"exam", "physics"},
+		"shopping":     nil,
+		"trashed math": {"math", "exam"},
+	} {
+		note, err := env.notes.CreateNote(env.userId, dto.CreateNoteRequest{Title: title, Content: title, Tags: tags})
+		if err != nil {
+			t.Fatalf("create %s: %v", title, err)
+		}
+		env.byTitle[title] = note.ID
+	}
+	if err := env.notes.DeleteNote(env.userId, env.byTitle["trashed math"], 0); err != nil {
+		t.Fatalf("trash note: %v", err)
+	}
+
+	bob := b.newUser(t, "bob")
+	if _, err := env.notes.CreateNote(bob, dto.CreateNoteRequest{Title: "bob's math", Content: "mine", Tags: []string{"math"}}); err != nil {
+		t.Fatalf("create note of bob: %v", err)
+	}
+	return env
+}
+
+// filter returns the titles of the notes of ann carrying the tags, sorted.
+func (e *tagEnv) filter(t *testing.T, tags []string, all bool) []string {
+	t.Helper()
+
+	page, err := e.notes.GetUserNotes(e.userId, models.NoteFilter{Tags: tags, MatchAllTags: all}, models.NotePage{Sort: models.NoteSortTitle}, "")
+	if err != nil {
+		t.Fatalf("filter %v: %v", tags, err)
+	}
+	titles := []string{}
+	for _, note := range page.Notes {
+		titles = append(titles, note.Title)
+	}
+	return titles
+}
+
+func (e *tagEnv) counts(t *testing.T) map[string]int {
+	t.Helper()
+
+	tags, err := e.tags.GetUserTags(e.userId)
+	if err != nil {
+		t.Fatalf("get tags: %v", err)
+	}
+	counts := make(map[string]int)
+	for _, tag := range tags {
+		counts[tag.Name] = tag.Count
+	}
+	return counts
+}
+
+func (e *tagEnv) noteTags(t *testing.T, title string) []string {
+	t.Helper()
+
+	note, err := e.notes.GetNote(e.userId, e.byTitle[title])
+	if err != nil {
+		t.Fatalf("get %s: %v", title, err)
+	}
+	return note.Tags
+}
+
+func TestFilterNotesByTags(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b testBackend) {
+		env := newTagEnv(t, b)
+
+		for _, tc := range []struct {
+			tags []string
+			all  bool
+			want []string
+		}{
+			{[]string{"math"}, false, []string{"algebra", "exam notes"}},
+			{[]string{" MATH "}, false, []string{"algebra", "exam notes"}},
+			{[]string{"math", "physics"}, false, []string{"algebra", "exam notes", "mechanics"}},
+			{[]string{"math", "exam"}, true, []string{"exam notes"}},
+			{[]string{"math", "Math"}, true, []string{"algebra", "exam notes"}},
+			{[]string{"math", "physics"}, true, []string{}},
+			{[]string{"history"}, false, []string{}},
+			{nil, false, []string{"algebra", "exam notes", "mechanics", "shopping"}},
+		} {
+			if got := env.filter(t, tc.tags, tc.all); !slices.Equal(got, tc.want) {
+				t.Errorf("tags %q, all %v: got %q, want %q", tc.tags, tc.all, got, tc.want)
+			}
+		}
+
+		if _, err := env.notes.GetUserNotes(env.userId, models.NoteFilter{Tags: []string{" "}}, models.NotePage{}, ""); err == nil {
+			t.Errorf("blank tag: got no error")
+		}
+	})
+}
+
+func TestRenameTag(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b testBackend) {
+		env := newTagEnv(t, b)
+
+		if err := env.tags.RenameTag(env.userId, "Math", "Algebra"); err != nil {
+			t.Fatalf("rename: %v", err)
+		}
+		if got := env.noteTags(t, "exam notes"); !slices.Equal(got, []string{"algebra", "exam"}) {
+			t.Errorf("tags of the renamed note: %q, want algebra and exam", got)
+		}
+		if got := env.filter(t, []string{"algebra"}, false); !slices.Equal(got, []string{"algebra", "exam notes"}) {
+			t.Errorf("notes with the new name: %q", got)
+		}
+		if got := env.filter(t, []string{"math"}, false); len(got) != 0 {
+			t.Errorf("notes with the old name: %q, want none", got)
+		}
+		if got := env.counts(t); got["algebra"] != 2 || got["exam"] != 2 || got["math"] != 0 {
+			t.Errorf("tag counts after the rename: %v", got)
+		}
+
+		// теги другого пользователя не трогаются
+		bob, _, err := b.users.GetUserByEmail("bob@notes.test")
+		if err != nil {
+			t.Fatalf("get bob: %v", err)
+		}
+		if tags, err := env.tags.GetUserTags(bob.UserId); err != nil || len(tags) != 1 || tags[0] != (models.TagCount{Name: "math", Count: 1}) {
+			t.Errorf("tags of bob: %v, %v, want math once", tags, err)
+		}
+
+		if err = env.tags.RenameTag(env.userId, "algebra", "exam"); err == nil || errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("rename onto an existing tag: got %v, want a hint to merge", err)
+		}
+		if err = env.tags.RenameTag(env.userId, "history", "past"); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("rename of an unknown tag: got %v, want ErrNotFound", err)
+		}
+		if err = env.tags.RenameTag(env.userId, "exam", " EXAM"); err == nil {
+			t.Errorf("rename to the same name: got no error")
+		}
+		if got := env.counts(t); got["algebra"] != 2 || got["exam"] != 2 {
+			t.Errorf("tag counts after the refused renames: %v", got)
+		}
+	})
+}
+
+func TestMergeTags(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b testBackend) {
+		env := newTagEnv(t, b)
+
+		// в существующий тег: у заметки с обоими тегами он остаётся один раз
+		if err := env.tags.MergeTags(env.userId, []string{"math", "Physics"}, "exam"); err != nil {
+			t.Fatalf("merge into exam: %v", err)
+		}
+		for _, title := range []string{"exam notes", "algebra", "mechanics"} {
+			if got := env.noteTags(t, title); !slices.Equal(got, []string{"exam"}) {
+				t.Errorf("tags of %s: %q, want exam", title, got)
+			}
+		}
+		if got := env.counts(t); len(got) != 1 || got["exam"] != 3 {
+			t.Errorf("tag counts after the merge: %v, want exam on 3 notes", got)
+		}
+
+		// в новое имя
+		if err := env.tags.MergeTags(env.userId, []string{"exam"}, "study"); err != nil {
+			t.Fatalf("merge into a new tag: %v", err)
+		}
+		if got := env.filter(t, []string{"study"}, false); !slices.Equal(got, []string{"algebra", "exam notes", "mechanics"}) {
+			t.Errorf("notes of the new tag: %q", got)
+		}
+
+		if err := env.tags.MergeTags(env.userId, []string{"study", "history"}, "past"); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("merge of an unknown tag: got %v, want ErrNotFound", err)
+		}
+		if got := env.counts(t); len(got) != 1 || got["study"] != 3 {
+			t.Errorf("tag counts after the refused merge: %v, want study on 3 notes", got)
+		}
+		if err := env.tags.MergeTags(env.userId, nil, "past"); err == nil {
+			t.Errorf("merge without sources: got no error")
+		}
+
+		bob, _, err := b.users.GetUserByEmail("bob@notes.test")
+		if err != nil {
+			t.Fatalf("get bob: %v", err)
+		}
+		if tags, err := env.tags.GetUserTags(bob.UserId); err != nil || len(tags) != 1 || tags[0].Name != "math" {
+			t.Errorf("tags of bob: %v, %v, want math", tags, err)
+		}
+	})
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"github.com/google/uuid"
+	"time"
+)
+
+type TrashService struct {
+	noteRepo repository.NotesRepository
+	events   noteEvents
+	// retention is how long a note stays in the trash, 0 keeps it until the trash is emptied.
+	retention time.Duration
+}
+
+func NewTrashService(noteRepo repository.NotesRepository, shareRepo repository.SharesRepository, publisher NotePublisher, retention time.Duration) *TrashService {
+	return &TrashService{
+		noteRepo:  noteRepo,
+		events:    noteEvents{shareRepo: shareRepo, publisher: publisher},
+		retention: retention,
+	}
+}
+
+func (s *TrashService) GetTrash(userId uuid.UUID) ([]models.Note, error) {
+	return s.noteRepo.GetTrash(userId)
+}
+
+// RestoreNote takes the note out of the trash. Listeners see it as created again.
+func (s *TrashService) RestoreNote(userId uuid.UUID, noteId uuid.UUID) (models.Note, error) {
+	if err := s.noteRepo.Restore(userId, noteId); err != nil {
+		return models.Note{}, err
+	}
+
+	note, err := s.noteRepo.Get(noteId)
+	if err != nil {
+		return models.Note{}, err
+	}
+
+	s.events.publish(models.EventNoteCreated, note)
+	return note, nil
+}
+
+// EmptyTrash permanently deletes every trashed note of the user.
+func (s *TrashService) EmptyTrash(userId uuid.UUID) (int64, error) {
+	return s.noteRepo.EmptyTrash(userId)
+}
+
+// PurgeTrash permanently deletes notes that stayed in the trash longer than the retention.
+func (s *TrashService) PurgeTrash() (int64, error) {
+	if s.retention <= 0 {
+		return 0, nil
+	}
+
+	return s.noteRepo.PurgeTrash(time.Now().Add(-s.retention))
+}
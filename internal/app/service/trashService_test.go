@@ -0,0 +1,189 @@
+package service_test
+
+import (
+	"2/internal/app/events"
+	"2/internal/app/service"
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"2/internal/interface/http/dto"
+	"errors"
+	"github.com/google/uuid"
+	"slices"
+	"testing"
+	"time"
+)
+
+// trashIds returns the ids of the notes in the user's trash, newest first.
+func trashIds(t *testing.T, trash *service.TrashService, userId uuid.UUID) []uuid.UUID {
+	t.Helper()
+
+	notes, err := trash.GetTrash(userId)
+	if err != nil {
+		t.Fatalf("get trash: %v", err)
+	}
+	ids := []uuid.UUID{}
+	for _, note := range notes {
+		ids = append(ids, note.ID)
+	}
+	return ids
+}
+
+func TestRestoreNote(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b testBackend) {
+		env := newSyncEnv(t, b, 0)
+		trash := service.NewTrashService(b.notes, b.shares, events.NewBus(16), 0)
+		other := newSyncEnv(t, b, 0)
+
+		note, err := env.notes.CreateNote(env.userId, dto.CreateNoteRequest{Title: "note", Content: "text", Tags: []string{"kept"}})
+		if err != nil {
+			t.Fatalf("create note: %v", err)
+		}
+		live := env.createNote(t, "live", nil)
+		if err = env.notes.DeleteNote(env.userId, note.ID, 0); err != nil {
+			t.Fatalf("trash: %v", err)
+		}
+		if got := trashIds(t, trash, env.userId); !slices.Equal(got, []uuid.UUID{note.ID}) {
+			t.Fatalf("trash: %v, want the deleted note", got)
+		}
+		if _, err = env.notes.GetNote(env.userId, note.ID); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("get a trashed note: got %v, want ErrNotFound", err)
+		}
+		since := env.pull(t, "", 0).NextToken
+
+		// чужую корзину и живые заметки не восстановить
+		if _, err = trash.RestoreNote(other.userId, note.ID); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("restore by another user: got %v, want ErrNotFound", err)
+		}
+		if _, err = trash.RestoreNote(env.userId, live.ID); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("restore of a live note: got %v, want ErrNotFound", err)
+		}
+
+		restored, err := trash.RestoreNote(env.userId, note.ID)
+		if err != nil {
+			t.Fatalf("restore: %v", err)
+		}
+		if restored.DeletedAt != nil || restored.Content != "text" || !slices.Equal(restored.Tags, []string{"kept"}) {
+			t.Errorf("restored note: %+v, want it live with its content and tags", restored)
+		}
+		if got := trashIds(t, trash, env.userId); len(got) != 0 {
+			t.Errorf("trash after the restore: %v, want it empty", got)
+		}
+		if _, err = env.notes.GetNote(env.userId, note.ID); err != nil {
+			t.Errorf("get the restored note: %v", err)
+		}
+		if _, err = trash.RestoreNote(env.userId, note.ID); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("second restore: got %v, want ErrNotFound", err)
+		}
+		if got := feed(env.pull(t, since, 0).Changes); !slices.Equal(got, []string{note.ID.String()}) {
+			t.Errorf("feed after the restore: %v, want the restored note", got)
+		}
+	})
+}
+
+func TestEmptyTrash(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b testBackend) {
+		env := newSyncEnv(t, b, 0)
+		other := newSyncEnv(t, b, 0)
+		trash := service.NewTrashService(b.notes, b.shares, events.NewBus(16), 0)
+
+		live := env.createNote(t, "live", nil)
+		var trashed []string
+		for _, title := range []string{"first", "second"} {
+			note := env.createNote(t, title, nil)
+			if err := env.notes.DeleteNote(env.userId, note.ID, 0); err != nil {
+				t.Fatalf("trash %s: %v", title, err)
+			}
+			trashed = append(trashed, "-"+note.ID.String())
+		}
+		foreign := other.createNote(t, "foreign", nil)
+		if err := other.notes.DeleteNote(other.userId, foreign.ID, 0); err != nil {
+			t.Fatalf("trash a note of the other user: %v", err)
+		}
+		since := env.pull(t, "", 0).NextToken
+
+		emptied, err := trash.EmptyTrash(env.userId)
+		if err != nil || emptied != 2 {
+			t.Fatalf("empty trash: %d, %v, want 2", emptied, err)
+		}
+		if got := trashIds(t, trash, env.userId); len(got) != 0 {
+			t.Errorf("trash after emptying: %v", got)
+		}
+		if got := trashIds(t, trash, other.userId); !slices.Equal(got, []uuid.UUID{foreign.ID}) {
+			t.Errorf("trash of the other user: %v, want it untouched", got)
+		}
+		if _, err = env.notes.GetNote(env.userId, live.ID); err != nil {
+			t.Errorf("live note after emptying the trash: %v", err)
+		}
+
+		// удаление клиенты уже видели при переносе в корзину, в ленте остаются надгробия на прежних местах
+		if got := feed(env.pull(t, since, 0).Changes); len(got) != 0 {
+			t.Errorf("feed after emptying the trash: %v, want nothing new", got)
+		}
+		want := sortedCopy(append(trashed, live.ID.String()))
+		if got := feed(env.pull(t, "", 0).Changes); !slices.Equal(sortedCopy(got), want) {
+			t.Errorf("full feed: %v, want the live note and the tombstones %v", got, trashed)
+		}
+		if emptied, err = trash.EmptyTrash(env.userId); err != nil || emptied != 0 {
+			t.Errorf("empty an empty trash: %d, %v, want 0", emptied, err)
+		}
+	})
+}
+
+// The purge job removes only notes that stayed in the trash longer than the retention, of
+// every user, and leaves tombstones for them in the change feed.
+func TestPurgeTrash(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b testBackend) {
+		env := newSyncEnv(t, b, 0)
+		other := newSyncEnv(t, b, 0)
+		retention := 24 * time.Hour
+		trash := service.NewTrashService(b.notes, b.shares, events.NewBus(16), retention)
+
+		trashAt := func(env *syncEnv, title string, at time.Time) models.Note {
+			note := env.createNote(t, title, nil)
+			if err := b.notes.Trash(note.ID, at, 0); err != nil {
+				t.Fatalf("trash %s: %v", title, err)
+			}
+			return note
+		}
+		now := time.Now()
+		expired := trashAt(env, "expired", now.Add(-retention-time.Hour))
+		recent := trashAt(env, "recent", now.Add(-retention+time.Hour))
+		foreign := trashAt(other, "foreign", now.Add(-2*retention))
+		live := env.createNote(t, "live", nil)
+
+		if purged, err := service.NewTrashService(b.notes, b.shares, events.NewBus(16), 0).PurgeTrash(); err != nil || purged != 0 {
+			t.Fatalf("purge without retention: %d, %v, want 0", purged, err)
+		}
+
+		purged, err := trash.PurgeTrash()
+		if err != nil || purged != 2 {
+			t.Fatalf("purge: %d, %v, want the expired note and the foreign one", purged, err)
+		}
+		if got := trashIds(t, trash, env.userId); !slices.Equal(got, []uuid.UUID{recent.ID}) {
+			t.Errorf("trash after the purge: %v, want only the recent note %s", got, recent.ID)
+		}
+		if got := trashIds(t, trash, other.userId); len(got) != 0 {
+			t.Errorf("trash of the other user after the purge: %v, want it empty", got)
+		}
+		if _, err = env.notes.GetNote(env.userId, live.ID); err != nil {
+			t.Errorf("live note after the purge: %v", err)
+		}
+
+		want := sortedCopy([]string{"-" + expired.ID.String(), "-" + recent.ID.String(), live.ID.String()})
+		if got := feed(env.pull(t, "", 0).Changes); !slices.Equal(sortedCopy(got), want) {
+			t.Errorf("feed after the purge: %v, want %v", got, want)
+		}
+		if got := feed(other.pull(t, "", 0).Changes); !slices.Equal(got, []string{"-" + foreign.ID.String()}) {
+			t.Errorf("feed of the other user: %v, want the tombstone of the purged note", got)
+		}
+		if purged, err = trash.PurgeTrash(); err != nil || purged != 0 {
+			t.Errorf("second purge: %d, %v, want 0", purged, err)
+		}
+	})
+}
+
+func sortedCopy(ids []string) []string {
+	ids = slices.Clone(ids)
+	slices.Sort(ids)
+	return ids
+}
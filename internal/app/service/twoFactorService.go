@@ -0,0 +1,190 @@
+package service
+
+import (
+	"2/internal/app/totp"
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"2/internal/interface/http/dto"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/skip2/go-qrcode"
+	"strings"
+	"time"
+)
+
+const recoveryCodeCount = 10
+
+var (
+	ErrInvalidCode          = errors.New("two-factor code is invalid")
+	ErrTwoFactorEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnrolled = errors.New("two-factor authentication is not enrolled")
+)
+
+type TwoFactorService struct {
+	twoFactorRepo repository.TwoFactorRepository
+	userRepo      repository.UserRepository
+	passwords     *Passwords
+	// issuer is the name authenticator apps show next to the code
+	issuer string
+}
+
+func NewTwoFactorService(twoFactorRepo repository.TwoFactorRepository, userRepo repository.UserRepository, passwords *Passwords, issuer string) *TwoFactorService {
+	return &TwoFactorService{twoFactorRepo: twoFactorRepo, userRepo: userRepo, passwords: passwords, issuer: issuer}
+}
+
+// Enroll creates a new pending secret, replacing an unconfirmed one. It starts protecting
+// logins only after Confirm.
+func (s *TwoFactorService) Enroll(userId uuid.UUID) (dto.TwoFactorEnrollResponse, error) {
+	current, err := s.twoFactorRepo.GetTOTP(userId)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return dto.TwoFactorEnrollResponse{}, err
+	}
+	if err == nil && current.Enabled {
+		return dto.TwoFactorEnrollResponse{}, ErrTwoFactorEnabled
+	}
+
+	user, err := s.userRepo.GetUserById(userId)
+	if err != nil {
+		return dto.TwoFactorEnrollResponse{}, err
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return dto.TwoFactorEnrollResponse{}, err
+	}
+
+	err = s.twoFactorRepo.SaveTOTP(models.TOTP{
+		UserId:    userId,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return dto.TwoFactorEnrollResponse{}, err
+	}
+
+	uri := totp.URI(s.issuer, user.Email, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		return dto.TwoFactorEnrollResponse{}, err
+	}
+
+	return dto.TwoFactorEnrollResponse{
+		Secret: secret,
+		URI:    uri,
+		QRCode: png,
+	}, nil
+}
+
+// Confirm enables the pending secret once the user proves the app produces valid codes,
+// and returns recovery codes. They are shown only here.
+func (s *TwoFactorService) Confirm(userId uuid.UUID, code string) (dto.RecoveryCodesResponse, error) {
+	current, err := s.twoFactorRepo.GetTOTP(userId)
+	if errors.Is(err, repository.ErrNotFound) {
+		return dto.RecoveryCodesResponse{}, ErrTwoFactorNotEnrolled
+	}
+	if err != nil {
+		return dto.RecoveryCodesResponse{}, err
+	}
+	if current.Enabled {
+		return dto.RecoveryCodesResponse{}, ErrTwoFactorEnabled
+	}
+
+	now := time.Now()
+	step, ok := totp.Validate(current.Secret, code, now)
+	if !ok {
+		return dto.RecoveryCodesResponse{}, ErrInvalidCode
+	}
+	if err = s.twoFactorRepo.UseStep(userId, step); err != nil {
+		return dto.RecoveryCodesResponse{}, ErrInvalidCode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return dto.RecoveryCodesResponse{}, err
+	}
+	if err = s.twoFactorRepo.Enable(userId, now, hashes); err != nil {
+		return dto.RecoveryCodesResponse{}, err
+	}
+
+	return dto.RecoveryCodesResponse{Codes: codes}, nil
+}
+
+// Disable turns two-factor authentication off, it needs both the password and a code.
+func (s *TwoFactorService) Disable(userId uuid.UUID, req dto.DisableTwoFactorRequest) error {
+	user, err := s.userRepo.GetUserById(userId)
+	if err != nil {
+		return err
+	}
+	if ok, _ := s.passwords.Check(user, req.Password); !ok {
+		return errors.New("Invalid password")
+	}
+
+	current, err := s.twoFactorRepo.GetTOTP(userId)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrTwoFactorNotEnrolled
+	}
+	if err != nil {
+		return err
+	}
+
+	// неподтверждённую регистрацию можно снять одним паролем
+	if current.Enabled {
+		if err = verifySecondFactor(s.twoFactorRepo, current, req.Code); err != nil {
+			return err
+		}
+	}
+
+	return s.twoFactorRepo.DeleteTOTP(userId)
+}
+
+// verifySecondFactor accepts either a current TOTP code or an unused recovery code,
+// each of them works only once.
+func verifySecondFactor(repo repository.TwoFactorRepository, current models.TOTP, code string) error {
+	now := time.Now()
+	code = strings.TrimSpace(code)
+
+	if len(strings.ReplaceAll(code, " ", "")) == totp.Digits {
+		step, ok := totp.Validate(current.Secret, code, now)
+		if !ok {
+			return ErrInvalidCode
+		}
+		if err := repo.UseStep(current.UserId, step); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return ErrInvalidCode
+			}
+			return err
+		}
+		return nil
+	}
+
+	err := repo.UseRecoveryCode(current.UserId, hashToken(normalizeRecoveryCode(code)), now)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrInvalidCode
+	}
+	return err
+}
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newRecoveryCodes returns codes like "k7fq2-mx4ta" for the user and their hashes for storage.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+		value := strings.ToLower(recoveryEncoding.EncodeToString(raw))[:10]
+		codes[i] = value[:5] + "-" + value[5:]
+		hashes[i] = hashToken(normalizeRecoveryCode(codes[i]))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
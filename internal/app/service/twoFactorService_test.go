@@ -0,0 +1,188 @@
+package service_test
+
+import (
+	"2/internal/app/service"
+	"2/internal/app/totp"
+	"2/internal/domain/models"
+	"2/internal/interface/http/dto"
+	"errors"
+	"github.com/google/uuid"
+	"strings"
+	"testing"
+	"time"
+)
+
+type twoFactorEnv struct {
+	*authEnv
+	twoFactorSvc *service.TwoFactorService
+	userId       uuid.UUID
+	secret       string
+	// step is the step of the code that confirmed the enrollment
+	step  int64
+	codes []string
+}
+
+// newTwoFactorEnv registers ann@notes.test and turns two-factor authentication on for her.
+func newTwoFactorEnv(t *testing.T) *twoFactorEnv {
+	t.Helper()
+
+	// неверные коды считаются и для адреса, дадим ему запас
+	policy := defaultLoginPolicy
+	policy.IP = models.LoginLimit{FreeAttempts: 10, LockoutAfter: 20}
+	env := &twoFactorEnv{authEnv: newAuthEnv(t, policy)}
+	env.twoFactorSvc = service.NewTwoFactorService(env.twoFactor, env.users, env.passwords, "Notes")
+	env.userId = env.register(t, "ann@notes.test", "long password")
+
+	if _, err := env.twoFactorSvc.Confirm(env.userId, "123456"); !errors.Is(err, service.ErrTwoFactorNotEnrolled) {
+		t.Fatalf("confirm before enroll: got %v, want ErrTwoFactorNotEnrolled", err)
+	}
+	enroll, err := env.twoFactorSvc.Enroll(env.userId)
+	if err != nil {
+		t.Fatalf("enroll: %v", err)
+	}
+	if !strings.Contains(enroll.URI, "secret="+enroll.Secret) || len(enroll.QRCode) == 0 {
+		t.Errorf("enroll response: uri %s, %d bytes of QR code", enroll.URI, len(enroll.QRCode))
+	}
+	env.secret = enroll.Secret
+
+	// пока регистрацию не подтвердили, вход обходится без кода
+	if resp := env.login(t, "ann@notes.test", "long password"); resp.MFARequired || resp.Token == "" {
+		t.Errorf("login with an unconfirmed enrollment: %+v, want tokens", resp)
+	}
+
+	env.step = totp.Step(time.Now())
+	if _, err = env.twoFactorSvc.Confirm(env.userId, env.code(t, 5)); !errors.Is(err, service.ErrInvalidCode) {
+		t.Errorf("confirm with a code outside the window: got %v, want ErrInvalidCode", err)
+	}
+	recovery, err := env.twoFactorSvc.Confirm(env.userId, env.code(t, 0))
+	if err != nil {
+		t.Fatalf("confirm: %v", err)
+	}
+	if len(recovery.Codes) != 10 {
+		t.Fatalf("confirm: got %d recovery codes, want 10", len(recovery.Codes))
+	}
+	env.codes = recovery.Codes
+
+	if _, err = env.twoFactorSvc.Enroll(env.userId); !errors.Is(err, service.ErrTwoFactorEnabled) {
+		t.Errorf("enroll again: got %v, want ErrTwoFactorEnabled", err)
+	}
+	return env
+}
+
+// code returns the code of the step that many steps after the confirming one.
+func (e *twoFactorEnv) code(t *testing.T, offset int64) string {
+	t.Helper()
+
+	code, err := totp.Code(e.secret, e.step+offset)
+	if err != nil {
+		t.Fatalf("code: %v", err)
+	}
+	return code
+}
+
+// mfaToken logs in with the password and returns the token for the second step.
+func (e *twoFactorEnv) mfaToken(t *testing.T) string {
+	t.Helper()
+
+	resp := e.login(t, "ann@notes.test", "long password")
+	if !resp.MFARequired || resp.MFAToken == "" || resp.Token != "" || resp.RefreshToken != "" {
+		t.Fatalf("login with two-factor authentication: %+v, want only an mfa token", resp)
+	}
+	return resp.MFAToken
+}
+
+func (e *twoFactorEnv) secondFactor(token string, code string) (dto.AuthResponse, error) {
+	return e.auth.LoginSecondFactor(dto.LoginTwoFactorRequest{MFAToken: token, Code: code}, models.ClientInfo{IP: "192.0.2.1"})
+}
+
+func (e *twoFactorEnv) sessionCount(t *testing.T) int {
+	t.Helper()
+
+	sessions, err := e.sessions.GetAllByUserId(e.userId)
+	if err != nil {
+		t.Fatalf("sessions: %v", err)
+	}
+	return len(sessions)
+}
+
+func TestLoginSecondFactor(t *testing.T) {
+	env := newTwoFactorEnv(t)
+	before := env.sessionCount(t)
+
+	token := env.mfaToken(t)
+	if _, err := env.tokens.Parse(token, "access"); err == nil {
+		t.Errorf("mfa token accepted as an access token")
+	}
+	// кодом, подтвердившим регистрацию, второй раз не войти
+	if _, err := env.secondFactor(token, env.code(t, 0)); !errors.Is(err, service.ErrInvalidCode) {
+		t.Errorf("code of the confirming step: got %v, want ErrInvalidCode", err)
+	}
+	resp, err := env.secondFactor(token, env.code(t, 1))
+	if err != nil {
+		t.Fatalf("second factor: %v", err)
+	}
+	if resp.Token == "" || resp.RefreshToken == "" || resp.MFARequired {
+		t.Errorf("second factor: %+v, want tokens", resp)
+	}
+	if got := env.sessionCount(t); got != before+1 {
+		t.Errorf("sessions after the second factor: got %d, want %d", got, before+1)
+	}
+
+	// использованный mfa-токен новой сессии не даёт даже с верным кодом
+	if _, err = env.secondFactor(token, env.codes[1]); !errors.Is(err, service.ErrInvalidMFAToken) {
+		t.Errorf("replayed mfa token: got %v, want ErrInvalidMFAToken", err)
+	}
+	if got := env.sessionCount(t); got != before+1 {
+		t.Errorf("sessions after the replay: got %d, want %d", got, before+1)
+	}
+	// тот же код не принимается и с новым токеном
+	if _, err = env.secondFactor(env.mfaToken(t), env.code(t, 1)); !errors.Is(err, service.ErrInvalidCode) {
+		t.Errorf("reused code: got %v, want ErrInvalidCode", err)
+	}
+	if _, err = env.secondFactor("not a token", env.code(t, 2)); !errors.Is(err, service.ErrInvalidMFAToken) {
+		t.Errorf("malformed mfa token: got %v, want ErrInvalidMFAToken", err)
+	}
+}
+
+func TestRecoveryCodesWorkOnce(t *testing.T) {
+	env := newTwoFactorEnv(t)
+
+	if _, err := env.secondFactor(env.mfaToken(t), env.codes[0]); err != nil {
+		t.Fatalf("recovery code: %v", err)
+	}
+	if _, err := env.secondFactor(env.mfaToken(t), env.codes[0]); !errors.Is(err, service.ErrInvalidCode) {
+		t.Errorf("used recovery code: got %v, want ErrInvalidCode", err)
+	}
+	// регистр, дефис и пробелы не важны
+	typed := strings.ToUpper(strings.Replace(env.codes[2], "-", " ", 1))
+	if _, err := env.secondFactor(env.mfaToken(t), typed); err != nil {
+		t.Errorf("recovery code %q typed as %q: %v", env.codes[2], typed, err)
+	}
+	if _, err := env.secondFactor(env.mfaToken(t), "aaaaa-bbbbb"); !errors.Is(err, service.ErrInvalidCode) {
+		t.Errorf("unknown recovery code: got %v, want ErrInvalidCode", err)
+	}
+}
+
+func TestDisableTwoFactor(t *testing.T) {
+	env := newTwoFactorEnv(t)
+
+	if err := env.twoFactorSvc.Disable(env.userId, dto.DisableTwoFactorRequest{Password: "wrong password", Code: env.codes[0]}); err == nil {
+		t.Errorf("disable with a wrong password: got no error")
+	}
+	if err := env.twoFactorSvc.Disable(env.userId, dto.DisableTwoFactorRequest{Password: "long password", Code: "aaaaa-bbbbb"}); !errors.Is(err, service.ErrInvalidCode) {
+		t.Errorf("disable with a wrong code: got %v, want ErrInvalidCode", err)
+	}
+	if env.mfaToken(t) == "" {
+		t.Fatalf("two-factor authentication was turned off by failed attempts")
+	}
+
+	if err := env.twoFactorSvc.Disable(env.userId, dto.DisableTwoFactorRequest{Password: "long password", Code: env.code(t, 1)}); err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+	if resp := env.login(t, "ann@notes.test", "long password"); resp.MFARequired || resp.Token == "" {
+		t.Errorf("login after disable: %+v, want tokens", resp)
+	}
+	if err := env.twoFactorSvc.Disable(env.userId, dto.DisableTwoFactorRequest{Password: "long password"}); !errors.Is(err, service.ErrTwoFactorNotEnrolled) {
+		t.Errorf("disable twice: got %v, want ErrTwoFactorNotEnrolled", err)
+	}
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"2/internal/interface/http/dto"
+	"errors"
+	"github.com/google/uuid"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+var (
+	ErrEmailTaken    = errors.New("email is already used by another account")
+	ErrWrongPassword = errors.New("current password is incorrect")
+)
+
+// UserService manages the account of the current user.
+type UserService struct {
+	userRepo    repository.UserRepository
+	sessionRepo repository.SessionsRepository
+	passwords   *Passwords
+	accounts    *AccountService
+	guard       *LoginGuard
+}
+
+func NewUserService(userRepo repository.UserRepository, sessionRepo repository.SessionsRepository, passwords *Passwords, accounts *AccountService, guard *LoginGuard) *UserService {
+	return &UserService{
+		userRepo:    userRepo,
+		sessionRepo: sessionRepo,
+		passwords:   passwords,
+		accounts:    accounts,
+		guard:       guard,
+	}
+}
+
+func (s *UserService) GetMe(userId uuid.UUID) (dto.UserResponse, error) {
+	user, err := s.userRepo.GetUserById(userId)
+	if err != nil {
+		return dto.UserResponse{}, err
+	}
+	return toUserResponse(user), nil
+}
+
+// UpdateMe changes username and email. A new email is unverified until the user follows
+// the link sent to it, links sent to the old address stop working.
+func (s *UserService) UpdateMe(userId uuid.UUID, req dto.UpdateUserRequest) (dto.UserResponse, error) {
+	user, err := s.userRepo.GetUserById(userId)
+	if err != nil {
+		return dto.UserResponse{}, err
+	}
+
+	if req.Username != nil {
+		username := strings.TrimSpace(*req.Username)
+		if username == "" {
+			return dto.UserResponse{}, errors.New("username cannot be empty")
+		}
+		user.Username = username
+	}
+
+	emailChanged := false
+	if req.Email != nil && strings.TrimSpace(*req.Email) != user.Email {
+		email := strings.TrimSpace(*req.Email)
+		if email == "" {
+			return dto.UserResponse{}, errors.New("email cannot be empty")
+		}
+		_, exists, err := s.userRepo.GetUserByEmail(email)
+		if err != nil {
+			return dto.UserResponse{}, err
+		}
+		if exists {
+			return dto.UserResponse{}, ErrEmailTaken
+		}
+		user.Email = email
+		user.EmailVerified = false
+		emailChanged = true
+	}
+
+	if err = s.userRepo.UpdateProfile(user); err != nil {
+		return dto.UserResponse{}, err
+	}
+
+	if emailChanged {
+		// профиль уже сохранён, письмо можно запросить повторно
+		if err = s.accounts.SendVerification(user.Email); err != nil {
+			slog.Error("Failed to send verification email", "error", err)
+		}
+	}
+
+	return toUserResponse(user), nil
+}
+
+// ChangePassword sets a new password and logs the user out on every other device.
+// Accounts created by an identity provider have a password nobody knows, their users set
+// one through the password reset instead.
+func (s *UserService) ChangePassword(userId uuid.UUID, currentSession uuid.UUID, req dto.ChangePasswordRequest, client models.ClientInfo) error {
+	if req.NewPassword == "" {
+		return errors.New("new_password is required")
+	}
+
+	user, err := s.userRepo.GetUserById(userId)
+	if err != nil {
+		return err
+	}
+	if err = s.checkPassword(user, req.CurrentPassword, client); err != nil {
+		return err
+	}
+
+	if err = s.passwords.Set(&user, req.NewPassword); err != nil {
+		return err
+	}
+	if err = s.userRepo.UpdatePassword(userId, user.Password); err != nil {
+		return err
+	}
+
+	sessions, err := s.sessionRepo.GetAllByUserId(userId)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, session := range sessions {
+		if session.ID == currentSession || session.RevokedAt != nil {
+			continue
+		}
+		if err = s.sessionRepo.Revoke(session.ID, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteMe removes the account with all notes, notebooks and sessions. It needs the
+// password, users of an identity provider set one through the password reset first.
+func (s *UserService) DeleteMe(userId uuid.UUID, req dto.DeleteAccountRequest, client models.ClientInfo) error {
+	user, err := s.userRepo.GetUserById(userId)
+	if err != nil {
+		return err
+	}
+	if err = s.checkPassword(user, req.Password, client); err != nil {
+		return err
+	}
+
+	return s.userRepo.Delete(userId)
+}
+
+// checkPassword asks for the current password before a change of the account. Failures
+// count with failed logins, or a stolen access token would guess the password unthrottled.
+func (s *UserService) checkPassword(user models.User, plain string, client models.ClientInfo) error {
+	if err := s.guard.Check(user.Email, client.IP); err != nil {
+		return err
+	}
+	if ok, _ := s.passwords.Check(user, plain); !ok {
+		if err := s.guard.Fail(user.Email, client.IP); err != nil {
+			return err
+		}
+		return ErrWrongPassword
+	}
+	return s.guard.Succeed(user.Email)
+}
+
+func toUserResponse(user models.User) dto.UserResponse {
+	return dto.UserResponse{
+		Email:         user.Email,
+		Username:      user.Username,
+		UserId:        user.UserId,
+		EmailVerified: user.EmailVerified,
+		Role:          user.Role,
+		Created:       user.Created,
+	}
+}
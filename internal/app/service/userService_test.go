@@ -0,0 +1,172 @@
+package service_test
+
+import (
+	"2/internal/app/service"
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"2/internal/infrastructure/mail"
+	"2/internal/infrastructure/storage/memory"
+	"2/internal/interface/http/dto"
+	"errors"
+	"github.com/google/uuid"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type userEnv struct {
+	*authEnv
+	profile *service.UserService
+	// mailbox is the file the verification emails are written to
+	mailbox string
+	userId  uuid.UUID
+}
+
+func newUserEnv(t *testing.T) *userEnv {
+	t.Helper()
+
+	env := newAuthEnv(t, guardPolicy)
+	mailbox := filepath.Join(t.TempDir(), "mail.txt")
+	accounts := service.NewAccountService(env.users, memory.NewUserTokensRepository(), env.sessions, env.tokens, env.passwords, mail.NewFileMailer(mailbox), defaultPolicy)
+	return &userEnv{
+		authEnv: env,
+		profile: service.NewUserService(env.users, env.sessions, env.passwords, accounts, env.guard),
+		mailbox: mailbox,
+		userId:  env.register(t, "ann@notes.test", "long password"),
+	}
+}
+
+func (e *userEnv) mail(t *testing.T) string {
+	t.Helper()
+
+	data, err := os.ReadFile(e.mailbox)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("read mailbox: %v", err)
+	}
+	return string(data)
+}
+
+var thief = models.ClientInfo{IP: "192.0.2.66"}
+
+func TestUpdateMe(t *testing.T) {
+	env := newUserEnv(t)
+	env.register(t, "bob@notes.test", "long password")
+
+	me, err := env.profile.GetMe(env.userId)
+	if err != nil || me.Email != "ann@notes.test" || me.UserId != env.userId {
+		t.Fatalf("get me: %+v, %v", me, err)
+	}
+
+	taken := "bob@notes.test"
+	if _, err = env.profile.UpdateMe(env.userId, dto.UpdateUserRequest{Email: &taken}); !errors.Is(err, service.ErrEmailTaken) {
+		t.Errorf("email of another account: got %v, want ErrEmailTaken", err)
+	}
+	blank := "  "
+	if _, err = env.profile.UpdateMe(env.userId, dto.UpdateUserRequest{Username: &blank}); err == nil {
+		t.Errorf("blank username: got nil, want an error")
+	}
+
+	// имя меняется без письма, адрес — с письмом на новый адрес
+	name := "anna"
+	if me, err = env.profile.UpdateMe(env.userId, dto.UpdateUserRequest{Username: &name}); err != nil || me.Username != "anna" {
+		t.Fatalf("rename: %+v, %v", me, err)
+	}
+	if got := env.mail(t); got != "" {
+		t.Errorf("mail after a rename:\n%s", got)
+	}
+
+	email := "anna@notes.test"
+	if me, err = env.profile.UpdateMe(env.userId, dto.UpdateUserRequest{Email: &email}); err != nil {
+		t.Fatalf("change email: %v", err)
+	}
+	if me.Email != email || me.EmailVerified {
+		t.Errorf("after the email change: %+v, want %s unverified", me, email)
+	}
+	if got := env.mail(t); !strings.Contains(got, "To: "+email) {
+		t.Errorf("no verification mail to the new address:\n%s", got)
+	}
+	if me, err = env.profile.GetMe(env.userId); err != nil || me.Email != email || me.Username != "anna" {
+		t.Errorf("stored profile: %+v, %v", me, err)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	env := newUserEnv(t)
+	current := env.login(t, "ann@notes.test", "long password")
+	other := env.login(t, "ann@notes.test", "long password")
+	sid := env.session(t, current)
+
+	req := dto.ChangePasswordRequest{CurrentPassword: "guess", NewPassword: "new long password"}
+	if err := env.profile.ChangePassword(env.userId, sid, req, thief); !errors.Is(err, service.ErrWrongPassword) {
+		t.Fatalf("wrong current password: got %v, want ErrWrongPassword", err)
+	}
+
+	req.CurrentPassword = "long password"
+	if err := env.profile.ChangePassword(env.userId, sid, req, models.ClientInfo{IP: "192.0.2.1"}); err != nil {
+		t.Fatalf("change password: %v", err)
+	}
+
+	if err := env.auth.CheckSession(env.userId, sid); err != nil {
+		t.Errorf("current session: %v", err)
+	}
+	if err := env.auth.CheckSession(env.userId, env.session(t, other)); !errors.Is(err, service.ErrSessionRevoked) {
+		t.Errorf("other session: got %v, want ErrSessionRevoked", err)
+	}
+	if _, err := env.auth.LoginUser(dto.LoginRequest{Email: "ann@notes.test", Password: "long password"}, models.ClientInfo{}); err == nil {
+		t.Errorf("login with the old password succeeded")
+	}
+	env.login(t, "ann@notes.test", "new long password")
+}
+
+// A stolen access token does not let anyone guess the password through the profile: wrong
+// passwords count with failed logins of the account.
+func TestProfilePasswordThrottled(t *testing.T) {
+	for name, check := range map[string]func(env *userEnv, plain string) error{
+		"change password": func(env *userEnv, plain string) error {
+			req := dto.ChangePasswordRequest{CurrentPassword: plain, NewPassword: "new long password"}
+			return env.profile.ChangePassword(env.userId, uuid.New(), req, thief)
+		},
+		"delete account": func(env *userEnv, plain string) error {
+			return env.profile.DeleteMe(env.userId, dto.DeleteAccountRequest{Password: plain}, thief)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			env := newUserEnv(t)
+
+			for i := 0; i <= guardPolicy.Account.FreeAttempts; i++ {
+				if err := check(env, "guess"); !errors.Is(err, service.ErrWrongPassword) {
+					t.Fatalf("guess %d: got %v, want ErrWrongPassword", i+1, err)
+				}
+			}
+			if err := check(env, "long password"); !errors.Is(err, service.ErrTooManyAttempts) {
+				t.Errorf("right password while delayed: got %v, want ErrTooManyAttempts", err)
+			}
+			if _, err := env.auth.LoginUser(dto.LoginRequest{Email: "ann@notes.test", Password: "long password"}, models.ClientInfo{}); !errors.Is(err, service.ErrTooManyAttempts) {
+				t.Errorf("login while delayed: got %v, want ErrTooManyAttempts", err)
+			}
+			if _, err := env.users.GetUserById(env.userId); err != nil {
+				t.Errorf("account after the guesses: %v", err)
+			}
+		})
+	}
+}
+
+func TestDeleteMe(t *testing.T) {
+	env := newUserEnv(t)
+	resp := env.login(t, "ann@notes.test", "long password")
+
+	if err := env.profile.DeleteMe(env.userId, dto.DeleteAccountRequest{Password: "guess"}, thief); !errors.Is(err, service.ErrWrongPassword) {
+		t.Fatalf("wrong password: got %v, want ErrWrongPassword", err)
+	}
+	if err := env.profile.DeleteMe(env.userId, dto.DeleteAccountRequest{Password: "long password"}, thief); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	if _, err := env.profile.GetMe(env.userId); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("get me after the delete: got %v, want ErrNotFound", err)
+	}
+	if _, err := env.refresh(resp.RefreshToken); err == nil {
+		t.Errorf("refresh of a deleted account succeeded")
+	}
+}
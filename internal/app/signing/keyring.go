@@ -0,0 +1,350 @@
+// Package signing keeps the asymmetric keys JWTs are signed with. Keys are rotated on
+// a schedule, a replaced key keeps verifying tokens for a grace period, and the public
+// halves are published as a JWKS so other services can verify tokens on their own.
+package signing
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"sync"
+	"time"
+)
+
+// Поддерживаемые алгоритмы, имена как в заголовке alg.
+const (
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+const (
+	rsaKeyBits = 2048
+	// не чаще этого перечитываем ключи из базы, встретив незнакомый kid
+	reloadInterval = 30 * time.Second
+)
+
+var ErrUnknownKey = errors.New("token is signed with an unknown or expired key")
+
+// Policy configures key rotation. Grace must outlive the longest token the keys sign,
+// otherwise such tokens stop verifying before they expire.
+type Policy struct {
+	Algorithm string
+	// Rotation is how long a key signs before a new one replaces it
+	Rotation time.Duration
+	// Grace is how long a replaced key still verifies tokens
+	Grace time.Duration
+}
+
+type key struct {
+	id        string
+	method    jwt.SigningMethod
+	private   crypto.Signer
+	createdAt time.Time
+	expiresAt *time.Time
+}
+
+func (k *key) public() crypto.PublicKey {
+	return k.private.Public()
+}
+
+// Keyring signs tokens with the current key and verifies them with any key that has
+// not expired. Keys are shared through the repository by every instance of the service.
+type Keyring struct {
+	repo   repository.SigningKeysRepository
+	aead   cipher.AEAD
+	policy Policy
+
+	mu         sync.RWMutex
+	current    *key
+	keys       map[string]*key
+	lastReload time.Time
+}
+
+// NewKeyring loads the keys and creates the first one when there is none yet.
+// secret encrypts private keys in the repository.
+func NewKeyring(repo repository.SigningKeysRepository, secret string, policy Policy) (*Keyring, error) {
+	if _, err := signingMethod(policy.Algorithm); err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	k := &Keyring{
+		repo:   repo,
+		aead:   aead,
+		policy: policy,
+		keys:   make(map[string]*key),
+	}
+	if _, err = k.Rotate(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Rotate replaces the signing key once it is older than the rotation period or uses
+// another algorithm than configured, and removes keys past their grace period.
+// It returns how many keys were created, retired or removed.
+func (k *Keyring) Rotate() (int64, error) {
+	if err := k.Reload(); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	var changed int64
+
+	k.mu.RLock()
+	current := k.current
+	k.mu.RUnlock()
+
+	if current == nil || current.method.Alg() != k.policy.Algorithm || !now.Before(current.createdAt.Add(k.policy.Rotation)) {
+		if err := k.createKey(now); err != nil {
+			return changed, err
+		}
+		changed++
+		if err := k.Reload(); err != nil {
+			return changed, err
+		}
+	}
+
+	k.mu.RLock()
+	current = k.current
+	var retired []string
+	for id, key := range k.keys {
+		if key != current && key.expiresAt == nil {
+			retired = append(retired, id)
+		}
+	}
+	k.mu.RUnlock()
+
+	// старые ключи ещё проверяют выданные ими токены, но уже ничего не подписывают
+	for _, id := range retired {
+		if err := k.repo.Expire(id, now.Add(k.policy.Grace)); err != nil {
+			return changed, err
+		}
+		changed++
+	}
+
+	deleted, err := k.repo.DeleteExpired(now)
+	if err != nil {
+		return changed, err
+	}
+	changed += deleted
+
+	if len(retired) > 0 || deleted > 0 {
+		return changed, k.Reload()
+	}
+	return changed, nil
+}
+
+// Reload reads the keys from the repository, picking up keys other instances created.
+func (k *Keyring) Reload() error {
+	stored, err := k.repo.GetAll()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	keys := make(map[string]*key, len(stored))
+	var current *key
+	for _, s := range stored {
+		if s.ExpiresAt != nil && !now.Before(*s.ExpiresAt) {
+			continue
+		}
+		parsed, err := k.decode(s)
+		if err != nil {
+			return fmt.Errorf("signing key %s: %w", s.ID, err)
+		}
+		keys[s.ID] = parsed
+		// подписывает самый новый ключ, которому ещё не назначен срок
+		if parsed.expiresAt == nil && (current == nil || parsed.createdAt.After(current.createdAt)) {
+			current = parsed
+		}
+	}
+
+	k.mu.Lock()
+	k.keys = keys
+	k.current = current
+	k.lastReload = now
+	k.mu.Unlock()
+	return nil
+}
+
+// Sign signs the claims with the current key, the kid header names the key.
+func (k *Keyring) Sign(claims jwt.MapClaims) (string, error) {
+	k.mu.RLock()
+	current := k.current
+	k.mu.RUnlock()
+	if current == nil {
+		return "", errors.New("there is no signing key")
+	}
+
+	token := jwt.NewWithClaims(current.method, claims)
+	token.Header["kid"] = current.id
+	return token.SignedString(current.private)
+}
+
+// Keyfunc finds the public key of a token by its kid, for jwt.Parse.
+func (k *Keyring) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, ErrUnknownKey
+	}
+
+	found := k.lookup(kid)
+	if found == nil {
+		k.mu.RLock()
+		stale := time.Since(k.lastReload) > reloadInterval
+		k.mu.RUnlock()
+		if stale {
+			if err := k.Reload(); err != nil {
+				return nil, err
+			}
+			found = k.lookup(kid)
+		}
+	}
+	if found == nil || found.expiresAt != nil && !time.Now().Before(*found.expiresAt) {
+		return nil, ErrUnknownKey
+	}
+
+	// ключ подписывает только своим алгоритмом, подмена alg в заголовке не пройдёт
+	if token.Method.Alg() != found.method.Alg() {
+		return nil, fmt.Errorf("token algorithm %s does not match the key", token.Method.Alg())
+	}
+	return found.public(), nil
+}
+
+// Methods lists the algorithms tokens may be signed with.
+func (k *Keyring) Methods() []string {
+	return []string{RS256, EdDSA}
+}
+
+func (k *Keyring) lookup(kid string) *key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.keys[kid]
+}
+
+func (k *Keyring) createKey(now time.Time) error {
+	var private crypto.Signer
+	var err error
+	switch k.policy.Algorithm {
+	case RS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case EdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		return err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return err
+	}
+	sealed, err := k.seal(der)
+	if err != nil {
+		return err
+	}
+
+	id := make([]byte, 12)
+	if _, err = rand.Read(id); err != nil {
+		return err
+	}
+
+	return k.repo.Create(models.SigningKey{
+		ID:         base64.RawURLEncoding.EncodeToString(id),
+		Algorithm:  k.policy.Algorithm,
+		PrivateKey: sealed,
+		CreatedAt:  now,
+	})
+}
+
+func (k *Keyring) decode(stored models.SigningKey) (*key, error) {
+	method, err := signingMethod(stored.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := k.open(stored.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+
+	var private crypto.Signer
+	switch p := parsed.(type) {
+	case *rsa.PrivateKey:
+		if stored.Algorithm == RS256 {
+			private = p
+		}
+	case ed25519.PrivateKey:
+		if stored.Algorithm == EdDSA {
+			private = p
+		}
+	}
+	if private == nil {
+		return nil, fmt.Errorf("key type %T does not match algorithm %s", parsed, stored.Algorithm)
+	}
+
+	return &key{
+		id:        stored.ID,
+		method:    method,
+		private:   private,
+		createdAt: stored.CreatedAt,
+		expiresAt: stored.ExpiresAt,
+	}, nil
+}
+
+// seal encrypts a private key for storage, the nonce goes first.
+func (k *Keyring) seal(plain []byte) ([]byte, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return k.aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+func (k *Keyring) open(sealed []byte) ([]byte, error) {
+	size := k.aead.NonceSize()
+	if len(sealed) < size {
+		return nil, errors.New("encrypted key is too short")
+	}
+	plain, err := k.aead.Open(nil, sealed[:size], sealed[size:], nil)
+	if err != nil {
+		return nil, errors.New("cannot decrypt the key, was SECRET changed?")
+	}
+	return plain, nil
+}
+
+func signingMethod(algorithm string) (jwt.SigningMethod, error) {
+	switch algorithm {
+	case RS256:
+		return jwt.SigningMethodRS256, nil
+	case EdDSA:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q, use %s or %s", algorithm, RS256, EdDSA)
+	}
+}
@@ -0,0 +1,233 @@
+package signing_test
+
+import (
+	"2/internal/app/signing"
+	"2/internal/infrastructure/storage/memory"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func newKeyring(t *testing.T, repo *memory.SigningKeysRepository, policy signing.Policy) *signing.Keyring {
+	t.Helper()
+
+	keys, err := signing.NewKeyring(repo, "test-secret", policy)
+	if err != nil {
+		t.Fatalf("keyring: %v", err)
+	}
+	return keys
+}
+
+func sign(t *testing.T, keys *signing.Keyring) string {
+	t.Helper()
+
+	token, err := keys.Sign(jwt.MapClaims{"sub": "ann", "exp": time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return token
+}
+
+func verify(keys *signing.Keyring, token string) error {
+	_, err := jwt.Parse(token, keys.Keyfunc, jwt.WithValidMethods(keys.Methods()))
+	return err
+}
+
+func kids(keys *signing.Keyring) []string {
+	var ids []string
+	for _, key := range keys.JWKS().Keys {
+		ids = append(ids, key.Kid)
+	}
+	return ids
+}
+
+func kidOf(t *testing.T, token string) string {
+	t.Helper()
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		t.Fatalf("parse header: %v", err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+func TestRotationWithGrace(t *testing.T) {
+	const rotation, grace = 300 * time.Millisecond, 100 * time.Millisecond
+	keys := newKeyring(t, memory.NewSigningKeysRepository(), signing.Policy{Algorithm: signing.EdDSA, Rotation: rotation, Grace: grace})
+
+	old := sign(t, keys)
+	if changed, err := keys.Rotate(); err != nil || changed != 0 {
+		t.Fatalf("rotate before the period: %d, %v, want nothing changed", changed, err)
+	}
+
+	time.Sleep(rotation)
+	if changed, err := keys.Rotate(); err != nil || changed != 2 {
+		t.Fatalf("rotate: %d, %v, want a new key and the old one retired", changed, err)
+	}
+	fresh := sign(t, keys)
+	if kidOf(t, fresh) == kidOf(t, old) {
+		t.Fatalf("the new token is signed with the old key %s", kidOf(t, old))
+	}
+
+	// старый ключ больше не подписывает, но выданные им токены ещё проверяются
+	if err := verify(keys, old); err != nil {
+		t.Errorf("token of the retired key within the grace period: %v", err)
+	}
+	if got := kids(keys); len(got) != 2 {
+		t.Errorf("JWKS during the grace period: %v, want both keys", got)
+	}
+
+	time.Sleep(grace)
+	if err := verify(keys, old); !errors.Is(err, signing.ErrUnknownKey) {
+		t.Errorf("token of the retired key after the grace period: got %v, want ErrUnknownKey", err)
+	}
+	if _, err := keys.Rotate(); err != nil {
+		t.Fatalf("rotate after the grace period: %v", err)
+	}
+	if got := kids(keys); len(got) != 1 || got[0] != kidOf(t, fresh) {
+		t.Errorf("JWKS after the grace period: %v, want only %s", got, kidOf(t, fresh))
+	}
+	if err := verify(keys, fresh); err != nil {
+		t.Errorf("token of the current key: %v", err)
+	}
+}
+
+// Instances share keys through the repository: a key created by one verifies on another,
+// and changing the algorithm replaces the key on the next rotation.
+func TestKeysSharedByInstances(t *testing.T) {
+	repo := memory.NewSigningKeysRepository()
+	policy := signing.Policy{Algorithm: signing.EdDSA, Rotation: time.Hour, Grace: time.Hour}
+	first := newKeyring(t, repo, policy)
+	second := newKeyring(t, repo, policy)
+
+	token := sign(t, first)
+	if kidOf(t, sign(t, second)) != kidOf(t, token) {
+		t.Errorf("the second instance created its own key instead of using the stored one")
+	}
+	if err := verify(second, token); err != nil {
+		t.Errorf("token of the first instance on the second one: %v", err)
+	}
+
+	policy.Algorithm = signing.RS256
+	rsaKeys := newKeyring(t, repo, policy)
+	rsaToken := sign(t, rsaKeys)
+	if alg := jwtAlg(t, rsaToken); alg != signing.RS256 {
+		t.Fatalf("token after switching to RS256 is signed with %s", alg)
+	}
+	if err := second.Reload(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	for name, tok := range map[string]string{"EdDSA": token, "RS256": rsaToken} {
+		if err := verify(second, tok); err != nil {
+			t.Errorf("%s token after the switch: %v", name, err)
+		}
+	}
+
+	if _, err := signing.NewKeyring(repo, "another-secret", policy); err == nil {
+		t.Errorf("keyring with another secret decrypted the stored keys")
+	}
+	if _, err := signing.NewKeyring(memory.NewSigningKeysRepository(), "test-secret", signing.Policy{Algorithm: "HS256"}); err == nil {
+		t.Errorf("keyring accepted HS256")
+	}
+}
+
+func jwtAlg(t *testing.T, token string) string {
+	t.Helper()
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		t.Fatalf("parse header: %v", err)
+	}
+	return parsed.Method.Alg()
+}
+
+func TestRejectedTokens(t *testing.T) {
+	keys := newKeyring(t, memory.NewSigningKeysRepository(), signing.Policy{Algorithm: signing.EdDSA, Rotation: time.Hour, Grace: time.Hour})
+	token := sign(t, keys)
+
+	// подпись чужим ключом с тем же kid
+	_, foreign, _ := ed25519.GenerateKey(nil)
+	forged := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{"sub": "ann"})
+	forged.Header["kid"] = kidOf(t, token)
+	forgedString, err := forged.SignedString(foreign)
+	if err != nil {
+		t.Fatalf("sign forged: %v", err)
+	}
+
+	noKid := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{"sub": "ann"})
+	noKidString, _ := noKid.SignedString(foreign)
+
+	// HS256 с публичным ключом вместо секрета: классическая подмена alg
+	hs := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "ann"})
+	hs.Header["kid"] = kidOf(t, token)
+	hsString, _ := hs.SignedString([]byte("whatever"))
+
+	for name, tok := range map[string]string{
+		"foreign key": forgedString,
+		"no kid":      noKidString,
+		"HS256":       hsString,
+	} {
+		if err := verify(keys, tok); err == nil {
+			t.Errorf("%s: token verified", name)
+		}
+	}
+}
+
+// A verifier that only has the JWKS document checks tokens on its own.
+func TestJWKSVerifies(t *testing.T) {
+	for _, algorithm := range []string{signing.EdDSA, signing.RS256} {
+		keys := newKeyring(t, memory.NewSigningKeysRepository(), signing.Policy{Algorithm: algorithm, Rotation: time.Hour, Grace: time.Hour})
+		token := sign(t, keys)
+
+		set := keys.JWKS()
+		if len(set.Keys) != 1 {
+			t.Fatalf("%s: JWKS has %d keys, want 1", algorithm, len(set.Keys))
+		}
+		jwk := set.Keys[0]
+		if jwk.Use != "sig" || jwk.Alg != algorithm || jwk.Kid != kidOf(t, token) {
+			t.Errorf("%s: JWK %+v", algorithm, jwk)
+		}
+
+		public := publicKey(t, jwk)
+		_, err := jwt.Parse(token, func(*jwt.Token) (interface{}, error) { return public, nil }, jwt.WithValidMethods([]string{algorithm}))
+		if err != nil {
+			t.Errorf("%s: token does not verify with the JWK: %v", algorithm, err)
+		}
+	}
+}
+
+func publicKey(t *testing.T, jwk signing.JWK) interface{} {
+	t.Helper()
+
+	decode := func(value string) []byte {
+		data, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil {
+			t.Fatalf("JWK %s: %v", jwk.Kid, err)
+		}
+		return data
+	}
+
+	switch jwk.Kty {
+	case "OKP":
+		if jwk.Crv != "Ed25519" || jwk.N != "" {
+			t.Fatalf("OKP key %+v", jwk)
+		}
+		return ed25519.PublicKey(decode(jwk.X))
+	case "RSA":
+		if jwk.X != "" {
+			t.Fatalf("RSA key %+v", jwk)
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(decode(jwk.N)),
+			E: int(new(big.Int).SetBytes(decode(jwk.E)).Int64()),
+		}
+	}
+	t.Fatalf("JWK of unknown type %q", jwk.Kty)
+	return nil
+}
@@ -0,0 +1,91 @@
+package totp_test
+
+import (
+	"2/internal/app/totp"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// ключ из приложения B RFC 6238 для SHA1, "12345678901234567890" в base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// TestRFC6238Vectors checks the SHA1 vectors of RFC 6238 appendix B. The RFC lists eight
+// digits, six digit codes are their last six.
+func TestRFC6238Vectors(t *testing.T) {
+	for _, tc := range []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	} {
+		got, err := totp.Code(rfcSecret, totp.Step(time.Unix(tc.unix, 0)))
+		if err != nil {
+			t.Fatalf("code at %d: %v", tc.unix, err)
+		}
+		if got != tc.code {
+			t.Errorf("code at %d: got %s, want %s", tc.unix, got, tc.code)
+		}
+	}
+
+	// приложения иногда отдают секрет строчными буквами
+	if got, _ := totp.Code("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", 1); got != "287082" {
+		t.Errorf("lower case secret: got %s, want 287082", got)
+	}
+	if _, err := totp.Code("not base32!", 1); err == nil {
+		t.Errorf("secret that is not base32: got no error")
+	}
+}
+
+func TestValidateWindow(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := totp.Step(now)
+
+	for offset := int64(-2); offset <= 2; offset++ {
+		code, _ := totp.Code(rfcSecret, current+offset)
+		step, ok := totp.Validate(rfcSecret, code, now)
+		want := offset >= -totp.Skew && offset <= totp.Skew
+		if ok != want {
+			t.Errorf("code of step %+d: accepted %v, want %v", offset, ok, want)
+		}
+		if ok && step != current+offset {
+			t.Errorf("code of step %+d: matched step %d, want %d", offset, step, current+offset)
+		}
+	}
+
+	if step, ok := totp.Validate(rfcSecret, "050 471", now); !ok || step != current {
+		t.Errorf("code with a space: step %d, ok %v", step, ok)
+	}
+	for _, code := range []string{"", "05047", "0504711", "abcdef", "050472"} {
+		if _, ok := totp.Validate(rfcSecret, code, now); ok {
+			t.Errorf("code %q accepted", code)
+		}
+	}
+}
+
+func TestURI(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatalf("secret: %v", err)
+	}
+	if len(secret) != 32 {
+		t.Errorf("secret %q: got %d characters, want 32 for 160 bits", secret, len(secret))
+	}
+
+	parsed, err := url.Parse(totp.URI("Notes App", "ann@notes.test", secret))
+	if err != nil {
+		t.Fatalf("parse uri: %v", err)
+	}
+	query := parsed.Query()
+	if parsed.Scheme != "otpauth" || parsed.Host != "totp" || parsed.Path != "/Notes App:ann@notes.test" {
+		t.Errorf("uri %s", parsed)
+	}
+	if query.Get("secret") != secret || query.Get("issuer") != "Notes App" || query.Get("digits") != "6" || query.Get("period") != "30" {
+		t.Errorf("uri parameters %v", query)
+	}
+}
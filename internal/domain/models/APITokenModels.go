@@ -0,0 +1,46 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"slices"
+	"time"
+)
+
+// Права персональных токенов. Сессия из логина ограничений не имеет.
+const (
+	ScopeNotesRead      = "notes:read"
+	ScopeNotesWrite     = "notes:write"
+	ScopeNotebooksRead  = "notebooks:read"
+	ScopeNotebooksWrite = "notebooks:write"
+	ScopeUserRead       = "user:read"
+)
+
+// APITokenPrefix starts every personal access token, it tells them apart from JWTs
+// and makes leaked tokens easy to find with secret scanners.
+const APITokenPrefix = "pat_"
+
+// Scopes lists every scope a personal access token can be given.
+var Scopes = []string{ScopeNotesRead, ScopeNotesWrite, ScopeNotebooksRead, ScopeNotebooksWrite, ScopeUserRead}
+
+// APIToken is a personal access token a user creates for scripts. Only the hash of the
+// token is kept, Prefix is its beginning so the user can tell tokens apart.
+type APIToken struct {
+	ID         uuid.UUID
+	UserId     uuid.UUID
+	Name       string
+	Prefix     string
+	TokenHash  string
+	Scopes     []string
+	CreatedAt  time.Time
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+}
+
+// Active reports whether the token may still be used at the moment now.
+func (t APIToken) Active(now time.Time) bool {
+	return t.ExpiresAt == nil || now.Before(*t.ExpiresAt)
+}
+
+func (t APIToken) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, scope)
+}
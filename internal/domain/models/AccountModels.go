@@ -0,0 +1,31 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Назначение одноразового токена, оно же claim typ подписанного токена из письма.
+const (
+	PasswordResetToken     = "password_reset"
+	EmailVerificationToken = "email_verification"
+)
+
+// UserToken records a token sent by email. The token itself is signed and carries
+// its own expiry, the record only makes sure it works once.
+type UserToken struct {
+	ID        uuid.UUID
+	UserId    uuid.UUID
+	Purpose   string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// AccountPolicy configures the links sent by email.
+type AccountPolicy struct {
+	// AppURL is where the links point, the frontend reads the token from the query.
+	AppURL           string
+	PasswordResetTTL time.Duration
+	VerificationTTL  time.Duration
+}
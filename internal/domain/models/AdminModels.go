@@ -0,0 +1,17 @@
+package models
+
+// UserFilter narrows the user list of the admin API. Zero values do not filter.
+type UserFilter struct {
+	// Query matches a part of the email or the username, case-insensitive
+	Query    string
+	Role     string
+	Disabled *bool
+	Limit    int
+	Offset   int
+}
+
+// NoteCounts is how many notes a user keeps, live and in the trash.
+type NoteCounts struct {
+	Notes   int
+	Trashed int
+}
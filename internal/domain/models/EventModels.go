@@ -0,0 +1,25 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Типы событий потока /events.
+const (
+	EventNoteCreated = "note.created"
+	EventNoteUpdated = "note.updated"
+	EventNoteDeleted = "note.deleted"
+)
+
+// NoteEvent tells that a note changed. Recipients are the owner and the users the note
+// was shared with at that moment, only they receive the event.
+type NoteEvent struct {
+	ID         int64
+	Type       string
+	NoteId     uuid.UUID
+	Recipients []uuid.UUID
+	// Note is the note after the change, nil for deleted notes.
+	Note *Note
+	At   time.Time
+}
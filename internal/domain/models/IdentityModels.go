@@ -0,0 +1,27 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// ExternalIdentity links an account at an OpenID Connect provider to a user.
+// Subject is the sub claim, stable for the account at its provider.
+type ExternalIdentity struct {
+	Provider  string
+	Subject   string
+	UserId    uuid.UUID
+	Email     string
+	CreatedAt time.Time
+}
+
+// OIDCState is a login started at a provider and not finished yet. State comes back
+// with the callback and finds the PKCE verifier and nonce the login was started with.
+type OIDCState struct {
+	State        string
+	Provider     string
+	CodeVerifier string
+	Nonce        string
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+}
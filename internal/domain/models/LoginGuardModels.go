@@ -0,0 +1,57 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Что именно заблокировано: аккаунт (по email), публичная ссылка с паролем (по slug)
+// или адрес, с которого подбирают пароли.
+const (
+	LockoutAccount = "account"
+	LockoutLink    = "link"
+	LockoutIP      = "ip"
+)
+
+// LoginThrottle counts failed logins of one account or one IP address.
+// Key is the kind and the subject, e.g. "account:ann@notes.test" or "ip:10.0.0.1".
+type LoginThrottle struct {
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	BlockedUntil  *time.Time
+}
+
+// LockoutEvent records that an account or an address was locked out, for admins.
+type LockoutEvent struct {
+	ID      uuid.UUID
+	Kind    string
+	Subject string
+	// IP is the address of the attempt that caused the lockout
+	IP          string
+	Failures    int
+	LockedAt    time.Time
+	LockedUntil time.Time
+	UnlockedAt  *time.Time
+}
+
+// LoginLimit sets how many failures in a row one account or one address is allowed.
+type LoginLimit struct {
+	// FreeAttempts is how many failures pass before logins get delayed
+	FreeAttempts int
+	// LockoutAfter is the failure that locks out, zero never locks out
+	LockoutAfter int
+}
+
+// LoginPolicy configures brute-force protection of the login. Addresses get more attempts
+// than accounts, a whole campus can be behind one address.
+type LoginPolicy struct {
+	Account LoginLimit
+	IP      LoginLimit
+	// BaseDelay is the first delay, it doubles with every further failure up to MaxDelay
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutDuration time.Duration
+	// ResetAfter forgets failures after this long without a new one
+	ResetAfter time.Duration
+}
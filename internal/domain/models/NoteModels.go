@@ -6,10 +6,73 @@ import (
 )
 
 type Note struct {
-	ID        uuid.UUID `json:"id"`
-	UserId    uuid.UUID `json:"user_id"`
-	Title     string    `json:"titel"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID         uuid.UUID  `json:"id"`
+	UserId     uuid.UUID  `json:"user_id"`
+	NotebookId *uuid.UUID `json:"notebook_id"`
+	Title      string     `json:"titel"`
+	Content    string     `json:"content"`
+	Tags       []string   `json:"tags"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	// Version grows by one with every change of the note, it is also sent as the ETag.
+	Version int64 `json:"version"`
+	// DeletedAt is set while the note lies in the trash.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// NoteFilter narrows down the notes returned for a user.
+type NoteFilter struct {
+	// Tags keeps notes carrying any of the tags, or all of them when MatchAllTags is set.
+	Tags         []string
+	MatchAllTags bool
+	// NotebookId keeps only the notes placed directly in the notebook.
+	NotebookId *uuid.UUID
+	// Date bounds are exclusive, nil leaves the side open.
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+}
+
+// NoteSort is the field notes are ordered by, ties are broken by id.
+type NoteSort string
+
+const (
+	NoteSortUpdatedAt NoteSort = "updated_at"
+	NoteSortCreatedAt NoteSort = "created_at"
+	NoteSortTitle     NoteSort = "title"
+)
+
+// NotePage selects one page of notes in keyset order.
+type NotePage struct {
+	// Sort defaults to created_at.
+	Sort NoteSort
+	Desc bool
+	// Limit 0 returns every matching note.
+	Limit int
+	// After continues the listing behind the last note of the previous page.
+	After *NoteCursor
+}
+
+// NoteCursor is the sort key of the last note on a page.
+type NoteCursor struct {
+	Sort  NoteSort  `json:"s"`
+	Desc  bool      `json:"d,omitempty"`
+	Time  time.Time `json:"t,omitempty"`
+	Title string    `json:"v,omitempty"`
+	ID    uuid.UUID `json:"id"`
+}
+
+// CursorOf returns the cursor pointing right behind the note.
+func (p NotePage) CursorOf(note Note) NoteCursor {
+	cursor := NoteCursor{Sort: p.Sort, Desc: p.Desc, ID: note.ID}
+	switch p.Sort {
+	case NoteSortUpdatedAt:
+		cursor.Time = note.UpdatedAt
+	case NoteSortTitle:
+		cursor.Title = note.Title
+	default:
+		cursor.Time = note.CreatedAt
+	}
+	return cursor
 }
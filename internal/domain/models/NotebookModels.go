@@ -0,0 +1,16 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Notebook groups notes, notebooks without a parent sit at the top level.
+type Notebook struct {
+	ID        uuid.UUID  `json:"id"`
+	UserId    uuid.UUID  `json:"user_id"`
+	ParentId  *uuid.UUID `json:"parent_id"`
+	Name      string     `json:"name"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
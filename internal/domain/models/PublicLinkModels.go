@@ -0,0 +1,28 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// PublicLink opens a note to anyone who knows its slug, no account needed. The link may
+// be protected with a password, only its hash is kept.
+type PublicLink struct {
+	ID           uuid.UUID
+	NoteId       uuid.UUID
+	Slug         string
+	PasswordHash *string
+	CreatedAt    time.Time
+	ExpiresAt    *time.Time
+	Views        int64
+	LastViewedAt *time.Time
+}
+
+// Active reports whether the link still opens the note at the moment now.
+func (l PublicLink) Active(now time.Time) bool {
+	return l.ExpiresAt == nil || now.Before(*l.ExpiresAt)
+}
+
+func (l PublicLink) HasPassword() bool {
+	return l.PasswordHash != nil
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// NoteRevision is an immutable snapshot of a note, written on creation and on every content change.
+type NoteRevision struct {
+	NoteId    uuid.UUID `json:"note_id"`
+	Revision  int       `json:"revision"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RevisionPolicy limits how many old revisions are kept. The latest revision of a note is never removed.
+type RevisionPolicy struct {
+	// KeepLast keeps only this many newest revisions of a note, 0 keeps all.
+	KeepLast int
+	// MaxAge removes revisions older than this, 0 keeps them forever.
+	MaxAge time.Duration
+}
@@ -0,0 +1,19 @@
+package models
+
+// Repositories wrap matched words into these markers. Notes may hold any text, so the
+// service escapes it as HTML first and only then turns the markers into <mark></mark>.
+const (
+	HighlightStart = "\uE000"
+	HighlightStop  = "\uE001"
+)
+
+// SearchResult is a note matched by a full-text query. TitleHighlight and Snippet
+// are HTML: escaped text with matched words wrapped into <mark></mark>.
+type SearchResult struct {
+	Note Note    `json:"note"`
+	Rank float64 `json:"rank"`
+	// TitleHighlight is HTML, the escaped title with matches in <mark></mark>
+	TitleHighlight string `json:"title_highlight" example:"Notes on &lt;b&gt; and <mark>search</mark>"`
+	// Snippet is HTML, an escaped piece of the content around the matches in <mark></mark>
+	Snippet string `json:"snippet" example:"…how to <mark>search</mark> for a &amp; b…"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Session is one login of a user. Access tokens carry its id and stop working once it is revoked.
+type Session struct {
+	ID         uuid.UUID  `json:"id"`
+	UserId     uuid.UUID  `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// ClientInfo describes the device a request came from.
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+// Active reports whether tokens of the session may still be used at the moment now.
+func (s Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// RefreshToken is a single-use token of a session, every refresh replaces it with a new one.
+type RefreshToken struct {
+	ID        uuid.UUID
+	SessionId uuid.UUID
+	TokenHash string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// TokenPolicy sets lifetimes of issued tokens.
+type TokenPolicy struct {
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"slices"
+	"time"
+)
+
+// Права на заметку по возрастанию. Владельцем заметку не делятся, его права полные.
+const (
+	PermissionViewer = "viewer"
+	PermissionEditor = "editor"
+	PermissionOwner  = "owner"
+)
+
+// SharePermissions are the rights a note can be shared with.
+var SharePermissions = []string{PermissionViewer, PermissionEditor}
+
+// PermissionRank orders permissions, each one includes everything the lower ones allow.
+func PermissionRank(permission string) int {
+	return slices.Index([]string{PermissionViewer, PermissionEditor, PermissionOwner}, permission)
+}
+
+// NoteShare gives another user access to a note.
+type NoteShare struct {
+	NoteId     uuid.UUID
+	UserId     uuid.UUID
+	Permission string
+	CreatedAt  time.Time
+	// Email and Username of the user the note is shared with, filled when shares are read
+	Email    string
+	Username string
+}
+
+// SharedNote is a note of another user together with the access the reader was given.
+type SharedNote struct {
+	Note
+	Permission string
+	OwnerEmail string
+	SharedAt   time.Time
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// SigningKey is a key pair JWTs are signed with. The newest key signs, older ones only
+// verify until ExpiresAt, so tokens issued before a rotation keep working for a while.
+type SigningKey struct {
+	// ID is the kid header of tokens signed with the key
+	ID        string
+	Algorithm string
+	// PrivateKey is PKCS #8, encrypted with the application secret
+	PrivateKey []byte
+	CreatedAt  time.Time
+	ExpiresAt  *time.Time
+}
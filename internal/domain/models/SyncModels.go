@@ -0,0 +1,37 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// SyncCursor is how far a client has read the change feed of its notes. Every change of a
+// note takes the next number of the owner's feed, notes changed together share a number
+// and are ordered by id.
+type SyncCursor struct {
+	Seq int64     `json:"s"`
+	ID  uuid.UUID `json:"id"`
+}
+
+// NoteChange is one entry of the change feed: the note as it is now, or a tombstone when
+// the note was trashed or removed.
+type NoteChange struct {
+	Seq    int64
+	NoteId uuid.UUID
+	// Note is nil for a tombstone.
+	Note      *Note
+	DeletedAt *time.Time
+}
+
+// Cursor returns the position right behind the change.
+func (c NoteChange) Cursor() SyncCursor {
+	return SyncCursor{Seq: c.Seq, ID: c.NoteId}
+}
+
+// Before tells whether the cursor comes earlier in the feed than other.
+func (c SyncCursor) Before(other SyncCursor) bool {
+	if c.Seq != other.Seq {
+		return c.Seq < other.Seq
+	}
+	return c.ID.String() < other.ID.String()
+}
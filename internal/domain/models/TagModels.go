@@ -0,0 +1,7 @@
+package models
+
+// TagCount is a user's tag together with the number of notes carrying it.
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// TOTP is the authenticator app of a user. It protects logins only after the user has
+// confirmed it with a first code, until then it is a pending enrollment.
+type TOTP struct {
+	UserId      uuid.UUID
+	Secret      string
+	Enabled     bool
+	LastStep    int64
+	CreatedAt   time.Time
+	ConfirmedAt *time.Time
+}
@@ -2,33 +2,37 @@ package models
 
 import (
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
+	"slices"
 	"time"
 )
 
+// Роли по возрастанию прав, каждая следующая может всё, что может предыдущая.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var Roles = []string{RoleUser, RoleModerator, RoleAdmin}
+
+// RoleRank orders roles by their rights, an unknown role ranks below every known one.
+func RoleRank(role string) int {
+	return slices.Index(Roles, role)
+}
+
 type User struct {
 	UserId   uuid.UUID `json:"user_id"`
 	Username string    `json:"username"`
 	Email    string    `json:"email"`
-	Password string    `json:"password"`
-	Created  time.Time `json:"created"`
-}
-
-func (u *User) HashPassword() error {
-
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
-	if err != nil {
-		return err
-	}
-
-	u.Password = string(hashedPassword)
-	return nil
+	// Password is the hash in PHC format, or a bcrypt hash of accounts created before Argon2id
+	Password      string    `json:"password"`
+	EmailVerified bool      `json:"email_verified"`
+	Role          string    `json:"role"`
+	Created       time.Time `json:"created"`
+	// DisabledAt is set while an admin keeps the account disabled
+	DisabledAt *time.Time `json:"disabled_at"`
 }
 
-func (u *User) CheckPassword(password string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
-	if err != nil {
-		return false
-	}
-	return true
+func (u User) Disabled() bool {
+	return u.DisabledAt != nil
 }
@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"2/internal/domain/models"
+	"github.com/google/uuid"
+	"time"
+)
+
+type APITokensRepository interface {
+	Create(token models.APIToken) error
+	GetAllByUserId(userId uuid.UUID) ([]models.APIToken, error)
+	GetByHash(tokenHash string) (models.APIToken, error)
+	// Delete removes a token of the user, ErrNotFound when the user has no such token.
+	Delete(userId uuid.UUID, id uuid.UUID) error
+	Touch(id uuid.UUID, lastUsedAt time.Time) error
+}
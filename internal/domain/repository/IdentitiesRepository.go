@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"2/internal/domain/models"
+	"time"
+)
+
+type IdentitiesRepository interface {
+	GetIdentity(provider string, subject string) (models.ExternalIdentity, error)
+	CreateIdentity(identity models.ExternalIdentity) error
+
+	SaveState(state models.OIDCState) error
+	// TakeState returns and removes the state, so every state is used once.
+	// It returns ErrNotFound when the state is unknown, already used or expired at the given time.
+	TakeState(state string, at time.Time) (models.OIDCState, error)
+	// DeleteExpiredStates removes states that expired before the given time.
+	DeleteExpiredStates(before time.Time) (int64, error)
+}
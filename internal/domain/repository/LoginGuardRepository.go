@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"2/internal/domain/models"
+	"time"
+)
+
+type LoginGuardRepository interface {
+	// GetThrottles returns the throttles of the keys that have any, missing keys are skipped.
+	GetThrottles(keys ...string) ([]models.LoginThrottle, error)
+	// RecordFailure counts a failure of the key and returns the number of failures in a row.
+	// Failures older than resetBefore are forgotten first.
+	RecordFailure(key string, at time.Time, resetBefore time.Time) (int, error)
+	Block(key string, until time.Time) error
+	// Reset forgets the failures of the key, ErrNotFound when there are none.
+	Reset(key string) error
+	// DeleteIdle removes throttles with no failure since before and no block after it.
+	DeleteIdle(before time.Time) (int64, error)
+
+	CreateLockout(event models.LockoutEvent) error
+	// GetLockouts returns the newest events first.
+	GetLockouts(limit int) ([]models.LockoutEvent, error)
+	// MarkUnlocked sets unlocked_at of the events of the subject that are still in effect.
+	MarkUnlocked(kind string, subject string, at time.Time) error
+}
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"2/internal/domain/models"
+	"github.com/google/uuid"
+	"time"
+)
+
+type NotebooksRepository interface {
+	Create(notebook models.Notebook) error
+	Get(id uuid.UUID) (models.Notebook, error)
+	GetAllByUserId(userId uuid.UUID) ([]models.Notebook, error)
+	Update(notebook models.Notebook) error
+	// DeleteCascade removes the notebooks of the user and moves every note inside them to the trash,
+	// it returns the ids of the trashed notes.
+	DeleteCascade(userId uuid.UUID, ids []uuid.UUID, deletedAt time.Time) ([]uuid.UUID, error)
+	// DeleteReparent removes the notebook and hands its notes and child notebooks over to its parent.
+	DeleteReparent(notebook models.Notebook) error
+}
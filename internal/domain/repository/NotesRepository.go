@@ -3,12 +3,38 @@ package repository
 import (
 	"2/internal/domain/models"
 	"github.com/google/uuid"
+	"time"
 )
 
 type NotesRepository interface {
 	Create(note models.Note) error
-	GetNoteById(idStr string) (models.Note, error)
+	Get(id uuid.UUID) (models.Note, error)
 	GetAllByUserId(id uuid.UUID) ([]models.Note, error)
+	Find(userId uuid.UUID, filter models.NoteFilter, page models.NotePage) ([]models.Note, error)
 	Update(note models.Note) error
+	// Move places the note into a notebook, nil moves it to the top level, and moves its version on.
+	// A non-zero version must still be the version of the note, ErrVersionConflict otherwise.
+	Move(id uuid.UUID, notebookId *uuid.UUID, version int64) error
+	// Delete removes the note permanently.
 	Delete(id uuid.UUID) error
+
+	// Get and Find never return trashed notes, the trash is reached only through the methods below.
+	// Trash only goes through while the note is at a non-zero version, ErrVersionConflict otherwise.
+	Trash(id uuid.UUID, deletedAt time.Time, version int64) error
+	GetTrash(userId uuid.UUID) ([]models.Note, error)
+	// Restore brings the note back at the version it was trashed with, Trash and Restore only
+	// move it through the change feed.
+	Restore(userId uuid.UUID, id uuid.UUID) error
+	EmptyTrash(userId uuid.UUID) (int64, error)
+	// CountByUsers counts live and trashed notes of each user, users without notes are missing from the map.
+	CountByUsers(userIds []uuid.UUID) (map[uuid.UUID]models.NoteCounts, error)
+	// PurgeTrash permanently removes notes of all users trashed before olderThan.
+	PurgeTrash(olderThan time.Time) (int64, error)
+
+	// Changes returns up to limit entries of the user's change feed after the cursor, live notes
+	// with their tags and tombstones alike. pruned is the number up to which tombstones were
+	// already forgotten, a cursor below it may have missed deletions.
+	Changes(userId uuid.UUID, after models.SyncCursor, limit int) (changes []models.NoteChange, pruned int64, err error)
+	// PurgeTombstones forgets notes removed for good before olderThan.
+	PurgeTombstones(olderThan time.Time) (int64, error)
 }
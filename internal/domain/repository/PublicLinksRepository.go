@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"2/internal/domain/models"
+	"github.com/google/uuid"
+	"time"
+)
+
+type PublicLinksRepository interface {
+	Create(link models.PublicLink) error
+	GetBySlug(slug string) (models.PublicLink, error)
+	GetAllByNoteId(noteId uuid.UUID) ([]models.PublicLink, error)
+	// Delete removes a link of the note, ErrNotFound when the note has no such link.
+	Delete(noteId uuid.UUID, id uuid.UUID) error
+	// RecordView counts one more view of the link.
+	RecordView(id uuid.UUID, viewedAt time.Time) error
+	DeleteExpired(before time.Time) (int64, error)
+}
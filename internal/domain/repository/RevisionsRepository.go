@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"2/internal/domain/models"
+	"github.com/google/uuid"
+	"time"
+)
+
+// RevisionsRepository reads note revisions, new ones are written by NotesRepository
+// together with the note change itself.
+type RevisionsRepository interface {
+	GetAllByNoteId(noteId uuid.UUID) ([]models.NoteRevision, error)
+	Get(noteId uuid.UUID, revision int) (models.NoteRevision, error)
+	// Prune removes, for every note, revisions beyond the keepLast newest ones (0 - no limit)
+	// and revisions created before olderThan (zero time - no limit), except the latest one.
+	Prune(keepLast int, olderThan time.Time) (int64, error)
+}
@@ -0,0 +1,11 @@
+package repository
+
+import (
+	"2/internal/domain/models"
+	"github.com/google/uuid"
+)
+
+type SearchRepository interface {
+	// Search returns at most limit notes of the user, the most relevant first.
+	Search(userId uuid.UUID, query string, limit int) ([]models.SearchResult, error)
+}
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"2/internal/domain/models"
+	"github.com/google/uuid"
+	"time"
+)
+
+type SessionsRepository interface {
+	Create(session models.Session, token models.RefreshToken) error
+	Get(id uuid.UUID) (models.Session, error)
+	GetAllByUserId(userId uuid.UUID) ([]models.Session, error)
+	// Touch moves last_seen_at of the sessions forward, it never moves it back.
+	Touch(lastSeen map[uuid.UUID]time.Time) error
+	Revoke(id uuid.UUID, at time.Time) error
+	RevokeAllByUserId(userId uuid.UUID, at time.Time) error
+
+	GetRefreshToken(tokenHash string) (models.RefreshToken, error)
+	// Rotate marks the old token used and stores its replacement, extending the session.
+	// It returns ErrNotFound when the old token has already been used, so two concurrent
+	// refreshes with the same token cannot both succeed.
+	Rotate(oldId uuid.UUID, token models.RefreshToken) error
+	// DeleteExpired removes sessions that expired or were revoked before the moment.
+	DeleteExpired(before time.Time) (int64, error)
+}
@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"2/internal/domain/models"
+	"github.com/google/uuid"
+)
+
+type SharesRepository interface {
+	// Save shares the note or changes the permission of an existing share.
+	Save(share models.NoteShare) error
+	Get(noteId uuid.UUID, userId uuid.UUID) (models.NoteShare, error)
+	GetAllByNoteId(noteId uuid.UUID) ([]models.NoteShare, error)
+	// GetSharedWith lists notes other users shared with the user, trashed notes are left out.
+	GetSharedWith(userId uuid.UUID) ([]models.SharedNote, error)
+	Delete(noteId uuid.UUID, userId uuid.UUID) error
+}
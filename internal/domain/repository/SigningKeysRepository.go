@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"2/internal/domain/models"
+	"time"
+)
+
+type SigningKeysRepository interface {
+	GetAll() ([]models.SigningKey, error)
+	Create(key models.SigningKey) error
+	// Expire sets the moment the key stops verifying tokens, unless it is already set.
+	Expire(id string, at time.Time) error
+	DeleteExpired(before time.Time) (int64, error)
+}
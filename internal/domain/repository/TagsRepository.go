@@ -0,0 +1,13 @@
+package repository
+
+import (
+	"2/internal/domain/models"
+	"github.com/google/uuid"
+)
+
+// TagsRepository manages a user's tags, note-to-tag links are written through NotesRepository.
+type TagsRepository interface {
+	GetAllByUserId(userId uuid.UUID) ([]models.TagCount, error)
+	Rename(userId uuid.UUID, from string, to string) error
+	Merge(userId uuid.UUID, sources []string, target string) error
+}
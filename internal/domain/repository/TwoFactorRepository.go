@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"2/internal/domain/models"
+	"github.com/google/uuid"
+	"time"
+)
+
+type TwoFactorRepository interface {
+	GetTOTP(userId uuid.UUID) (models.TOTP, error)
+	// SaveTOTP creates or replaces the TOTP of the user.
+	SaveTOTP(totp models.TOTP) error
+	// DeleteTOTP removes the TOTP together with the recovery codes.
+	DeleteTOTP(userId uuid.UUID) error
+	// UseStep records the step of an accepted code. It returns ErrNotFound when the step
+	// is not newer than the last accepted one, so a code cannot be replayed.
+	UseStep(userId uuid.UUID, step int64) error
+
+	// Enable turns the TOTP on and replaces the recovery codes in one go.
+	Enable(userId uuid.UUID, confirmedAt time.Time, codeHashes []string) error
+	// UseRecoveryCode marks the code used, ErrNotFound when there is no unused one.
+	UseRecoveryCode(userId uuid.UUID, codeHash string, at time.Time) error
+
+	// UseMFAToken records the id of an mfa_pending token that finished a login. It returns
+	// ErrNotFound when the token was already used, so it cannot start a second session.
+	UseMFAToken(id uuid.UUID, expiresAt time.Time) error
+	// DeleteUsedMFATokens forgets used tokens that expired before the moment.
+	DeleteUsedMFATokens(before time.Time) (int64, error)
+}
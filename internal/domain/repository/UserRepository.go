@@ -3,10 +3,22 @@ package repository
 import (
 	"2/internal/domain/models"
 	"github.com/google/uuid"
+	"time"
 )
 
 type UserRepository interface {
 	Create(user models.User) error
 	GetUserByEmail(email string) (models.User, bool, error)
 	GetUserById(id uuid.UUID) (models.User, error)
+	UpdatePassword(id uuid.UUID, passwordHash string) error
+	SetEmailVerified(id uuid.UUID) error
+	// UpdateProfile saves username, email and email_verified of the user.
+	UpdateProfile(user models.User) error
+	// Find lists users matching the filter, newest first.
+	Find(filter models.UserFilter) ([]models.User, error)
+	SetRole(id uuid.UUID, role string) error
+	// SetDisabled disables the account from the given time, nil enables it again.
+	SetDisabled(id uuid.UUID, at *time.Time) error
+	// Delete removes the user together with everything the user owns.
+	Delete(id uuid.UUID) error
 }
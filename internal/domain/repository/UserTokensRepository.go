@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"2/internal/domain/models"
+	"github.com/google/uuid"
+	"time"
+)
+
+type UserTokensRepository interface {
+	Create(token models.UserToken) error
+	// Use marks the token used. It returns ErrNotFound when the token is unknown,
+	// already used or expired at the given time.
+	Use(id uuid.UUID, at time.Time) error
+	// DeleteExpired removes tokens that expired before the given time.
+	DeleteExpired(before time.Time) (int64, error)
+}
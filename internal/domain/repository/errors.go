@@ -0,0 +1,10 @@
+package repository
+
+import "errors"
+
+var (
+	// ErrNotFound is returned by every storage backend when the requested row does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrVersionConflict is returned when a row was changed by someone else after it was read.
+	ErrVersionConflict = errors.New("version conflict")
+)
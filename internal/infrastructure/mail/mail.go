@@ -0,0 +1,31 @@
+// Package mail delivers the emails the application sends: password reset and
+// email verification links.
+package mail
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Message is a plain text email to a single recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends a message or returns why it could not.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// validate rejects line breaks in header values, they would let a caller inject headers.
+func (m Message) validate() error {
+	if m.To == "" {
+		return fmt.Errorf("mail recipient is empty")
+	}
+	if strings.ContainsAny(m.To, "\r\n") || strings.ContainsAny(m.Subject, "\r\n") {
+		return fmt.Errorf("mail header contains a line break")
+	}
+	return nil
+}
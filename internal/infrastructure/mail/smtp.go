@@ -0,0 +1,74 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// SMTPMailer sends messages through an SMTP relay. STARTTLS is used whenever the server
+// offers it, net/smtp refuses to send credentials over plain text to anything but localhost.
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer creates a mailer for the relay at addr (host:port). Without a username
+// messages are sent unauthenticated.
+func NewSMTPMailer(addr string, username string, password string, from string) *SMTPMailer {
+	var auth smtp.Auth
+	if username != "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &SMTPMailer{addr: addr, from: from, auth: auth}
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+
+	if err := smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, m.format(msg)); err != nil {
+		return fmt.Errorf("send mail to %s: %w", msg.To, err)
+	}
+	return nil
+}
+
+func (m *SMTPMailer) format(msg Message) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", m.from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(toCRLF(msg.Body))
+	return buf.Bytes()
+}
+
+// toCRLF normalizes line endings, SMTP requires CRLF.
+func toCRLF(body string) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(body); i++ {
+		switch {
+		case body[i] == '\r' && i+1 < len(body) && body[i+1] == '\n':
+			buf.WriteString("\r\n")
+			i++
+		case body[i] == '\n' || body[i] == '\r':
+			buf.WriteString("\r\n")
+		default:
+			buf.WriteByte(body[i])
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,313 @@
+package migrations
+
+import (
+	"2/internal/infrastructure/storage"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Файлы миграций лежат в каталоге диалекта и называются <version>_<name>.<up|down>.sql
+//
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// ErrNoDatabase is returned when migrations are requested for a backend without a database.
+var ErrNoDatabase = errors.New("the selected storage backend has no database to migrate")
+
+// Migration is a single versioned schema change.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes whether a migration has been applied to the database.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies the embedded migrations of one dialect and tracks them in schema_migrations.
+type Migrator struct {
+	db         *sql.DB
+	dialect    storage.Dialect
+	migrations []Migration
+}
+
+func NewMigrator(db *sql.DB, dialect storage.Dialect) (*Migrator, error) {
+	migrations, err := load(dialect.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:         db,
+		dialect:    dialect,
+		migrations: migrations,
+	}, nil
+}
+
+func load(dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for dialect %s: %w", dir, err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("unexpected migration file %s", name)
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		versionStr, title, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration file %s has no name", name)
+		}
+		version, err := strconv.ParseInt(versionStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration file %s has invalid version: %w", name, err)
+		}
+
+		body, err := fs.ReadFile(files, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: title}
+			byVersion[version] = m
+		}
+		if m.Name != title {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, title)
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both up and down files", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Latest returns the highest known migration version, 0 if there are none.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies every pending migration and returns the ones it applied.
+func (m *Migrator) Up() ([]Migration, error) {
+	return m.To(m.Latest())
+}
+
+// Down reverts the most recently applied migration.
+func (m *Migrator) Down() ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var current, previous int64
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; ok {
+			previous, current = current, mig.Version
+		}
+	}
+	if current == 0 {
+		return nil, nil
+	}
+
+	return m.To(previous)
+}
+
+// To migrates the schema up or down until exactly the migrations up to version are applied.
+func (m *Migrator) To(version int64) ([]Migration, error) {
+	if version != 0 && !m.known(version) {
+		return nil, fmt.Errorf("unknown migration version %d", version)
+	}
+
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; ok || mig.Version > version {
+			continue
+		}
+		if err := m.apply(mig, true); err != nil {
+			return done, err
+		}
+		done = append(done, mig)
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mig := m.migrations[i]
+		if _, ok := applied[mig.Version]; !ok || mig.Version <= version {
+			continue
+		}
+		if err := m.apply(mig, false); err != nil {
+			return done, err
+		}
+		done = append(done, mig)
+	}
+
+	return done, nil
+}
+
+// Status lists every known migration together with its state in the database.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		appliedAt, ok := applied[mig.Version]
+		statuses = append(statuses, Status{
+			Migration: mig,
+			Applied:   ok,
+			AppliedAt: appliedAt,
+		})
+	}
+
+	return statuses, nil
+}
+
+func (m *Migrator) known(version int64) bool {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Migrator) ensureTable() error {
+	_, err := m.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    BIGINT PRIMARY KEY,
+	name       TEXT      NOT NULL,
+	applied_at TIMESTAMP NOT NULL
+)`)
+	return err
+}
+
+func (m *Migrator) applied() (map[int64]time.Time, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// apply runs one migration and records it in a single transaction, so a failed
+// migration leaves neither half-applied DDL nor a bogus schema_migrations row.
+func (m *Migrator) apply(mig Migration, up bool) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if m.dialect == storage.Postgres {
+		// не даём двум инстансам с AUTO_MIGRATE мигрировать одновременно
+		if _, err := tx.Exec("SELECT pg_advisory_xact_lock(7267400)"); err != nil {
+			return err
+		}
+	}
+
+	// another instance may have applied it while we were waiting for the lock
+	query, args, err := squirrel.Select("COUNT(*)").From("schema_migrations").
+		Where(squirrel.Eq{"version": mig.Version}).
+		PlaceholderFormat(m.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+	var count int
+	if err := tx.QueryRow(query, args...).Scan(&count); err != nil {
+		return err
+	}
+	if (count > 0) == up {
+		return nil
+	}
+
+	body := mig.Up
+	if !up {
+		body = mig.Down
+	}
+	if _, err := tx.Exec(body); err != nil {
+		return fmt.Errorf("migration %d_%s failed: %w", mig.Version, mig.Name, err)
+	}
+
+	if up {
+		query, args, err = squirrel.Insert("schema_migrations").
+			Columns("version", "name", "applied_at").
+			Values(mig.Version, mig.Name, time.Now().UTC()).
+			PlaceholderFormat(m.dialect.Placeholder).ToSql()
+	} else {
+		query, args, err = squirrel.Delete("schema_migrations").
+			Where(squirrel.Eq{"version": mig.Version}).
+			PlaceholderFormat(m.dialect.Placeholder).ToSql()
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
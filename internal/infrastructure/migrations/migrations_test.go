@@ -0,0 +1,197 @@
+package migrations_test
+
+import (
+	"2/internal/infrastructure/migrations"
+	"2/internal/infrastructure/storage"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func openSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := storage.Open(storage.SQLite, filepath.Join(t.TempDir(), "migrate.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func newMigrator(t *testing.T, db *sql.DB, dialect storage.Dialect) *migrations.Migrator {
+	t.Helper()
+
+	m, err := migrations.NewMigrator(db, dialect)
+	if err != nil {
+		t.Fatalf("migrator: %v", err)
+	}
+	return m
+}
+
+// applied returns the versions of the applied migrations in order.
+func applied(t *testing.T, m *migrations.Migrator) []int64 {
+	t.Helper()
+
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	var versions []int64
+	for _, s := range statuses {
+		if s.Applied {
+			versions = append(versions, s.Version)
+			if s.AppliedAt.IsZero() {
+				t.Errorf("migration %d is applied without applied_at", s.Version)
+			}
+		}
+	}
+	return versions
+}
+
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&count)
+	if err != nil {
+		t.Fatalf("look up table %s: %v", name, err)
+	}
+	return count > 0
+}
+
+func TestMigrateUpDown(t *testing.T) {
+	db := openSQLite(t)
+	m := newMigrator(t, db, storage.SQLite)
+
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if len(statuses) == 0 || statuses[len(statuses)-1].Version != m.Latest() {
+		t.Fatalf("status lists %d migrations, latest %d", len(statuses), m.Latest())
+	}
+	for i, s := range statuses {
+		if s.Applied || s.Up == "" || s.Down == "" || i > 0 && s.Version <= statuses[i-1].Version {
+			t.Fatalf("migration %d_%s: applied %v, want a pending migration with both directions in order", s.Version, s.Name, s.Applied)
+		}
+	}
+
+	done, err := m.Up()
+	if err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	if len(done) != len(statuses) || len(applied(t, m)) != len(statuses) {
+		t.Fatalf("up applied %d of %d migrations", len(done), len(statuses))
+	}
+	if !tableExists(t, db, "notes") {
+		t.Fatalf("notes table is missing after up")
+	}
+	if done, err = m.Up(); err != nil || len(done) != 0 {
+		t.Fatalf("second up: %d applied, %v, want nothing", len(done), err)
+	}
+
+	// down откатывает только последнюю
+	if done, err = m.Down(); err != nil || len(done) != 1 || done[0].Version != m.Latest() {
+		t.Fatalf("down: %v, %v, want the latest migration reverted", done, err)
+	}
+	if got := applied(t, m); len(got) != len(statuses)-1 || got[len(got)-1] >= m.Latest() {
+		t.Fatalf("applied after down: %v", got)
+	}
+
+	middle := statuses[len(statuses)/2].Version
+	if _, err = m.To(middle); err != nil {
+		t.Fatalf("to %d: %v", middle, err)
+	}
+	if got := applied(t, m); len(got) == 0 || got[len(got)-1] != middle {
+		t.Fatalf("applied after to %d: %v", middle, got)
+	}
+
+	if _, err = m.To(0); err != nil {
+		t.Fatalf("to 0: %v", err)
+	}
+	if got := applied(t, m); len(got) != 0 || tableExists(t, db, "notes") || tableExists(t, db, "users") {
+		t.Fatalf("after to 0: applied %v, want an empty schema", got)
+	}
+	if done, err = m.Down(); err != nil || len(done) != 0 {
+		t.Fatalf("down on an empty schema: %v, %v, want nothing", done, err)
+	}
+
+	// схема снова поднимается с нуля
+	if _, err = m.Up(); err != nil || len(applied(t, m)) != len(statuses) {
+		t.Fatalf("up after a full down: %v", err)
+	}
+	if _, err = m.To(m.Latest() + 1); err == nil {
+		t.Fatalf("to an unknown version: got no error")
+	}
+}
+
+// migrateConcurrently runs Up from several migrators at once, the way instances with
+// AUTO_MIGRATE start together, and checks every migration is applied exactly once.
+func migrateConcurrently(t *testing.T, db *sql.DB, dialect storage.Dialect) {
+	t.Helper()
+
+	const instances = 4
+	var wg sync.WaitGroup
+	errs := make(chan error, instances)
+	for i := 0; i < instances; i++ {
+		m := newMigrator(t, db, dialect)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := m.Up(); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent up: %v", err)
+	}
+
+	m := newMigrator(t, db, dialect)
+	var rows, versions int
+	if err := db.QueryRow("SELECT COUNT(*), COUNT(DISTINCT version) FROM schema_migrations").Scan(&rows, &versions); err != nil {
+		t.Fatalf("count schema_migrations: %v", err)
+	}
+	if want := len(applied(t, m)); rows != versions || versions != want {
+		t.Errorf("schema_migrations has %d rows of %d versions, want %d", rows, versions, want)
+	}
+	if got := applied(t, m); got[len(got)-1] != m.Latest() {
+		t.Errorf("latest applied %d, want %d", got[len(got)-1], m.Latest())
+	}
+}
+
+func TestMigrateConcurrently(t *testing.T) {
+	migrateConcurrently(t, openSQLite(t), storage.SQLite)
+}
+
+// Без pg_advisory_xact_lock одновременные миграции Postgres падают на CREATE TABLE.
+// Нужна пустая база: TEST_DB_CONNECTION="postgres://..." go test ./...
+func TestMigrateConcurrentlyPostgres(t *testing.T) {
+	dsn := os.Getenv("TEST_DB_CONNECTION")
+	if dsn == "" {
+		t.Skip("TEST_DB_CONNECTION is not set")
+	}
+
+	db, err := storage.Open(storage.Postgres, dsn)
+	if err != nil {
+		t.Fatalf("open postgres: %v", err)
+	}
+	defer db.Close()
+
+	m := newMigrator(t, db, storage.Postgres)
+	if len(applied(t, m)) != 0 {
+		t.Skip("TEST_DB_CONNECTION must point to an empty database")
+	}
+	t.Cleanup(func() {
+		if _, err := m.To(0); err != nil {
+			t.Errorf("clean up: %v", err)
+		}
+	})
+
+	migrateConcurrently(t, db, storage.Postgres)
+}
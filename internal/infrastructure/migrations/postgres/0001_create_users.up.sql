@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS users (
+    user_id  UUID PRIMARY KEY,
+    username TEXT        NOT NULL,
+    email    TEXT        NOT NULL UNIQUE,
+    password TEXT        NOT NULL,
+    created  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS notes (
+    id         UUID PRIMARY KEY,
+    user_id    UUID        NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    title      TEXT        NOT NULL,
+    content    TEXT        NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS notes_user_id_idx ON notes (user_id);
@@ -0,0 +1,15 @@
+CREATE TABLE notebooks (
+    id         UUID PRIMARY KEY,
+    user_id    UUID        NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    parent_id  UUID REFERENCES notebooks (id) ON DELETE CASCADE,
+    name       TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX notebooks_user_id_idx ON notebooks (user_id);
+CREATE INDEX notebooks_parent_id_idx ON notebooks (parent_id);
+
+ALTER TABLE notes ADD COLUMN notebook_id UUID REFERENCES notebooks (id) ON DELETE SET NULL;
+
+CREATE INDEX notes_notebook_id_idx ON notes (notebook_id);
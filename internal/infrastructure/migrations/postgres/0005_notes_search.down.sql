@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS notes_search_vector_update ON notes;
+ALTER TABLE notes DROP COLUMN IF EXISTS search_vector;
+DROP FUNCTION IF EXISTS notes_search_vector_trigger();
+DROP FUNCTION IF EXISTS notes_search_vector(TEXT, TEXT);
+DROP TABLE IF EXISTS search_settings;
@@ -0,0 +1,30 @@
+-- one-row table with the text search configuration used for notes, see SEARCH_LANGUAGE
+CREATE TABLE search_settings (
+    id       BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    language REGCONFIG NOT NULL DEFAULT 'simple'
+);
+
+INSERT INTO search_settings DEFAULT VALUES;
+
+CREATE FUNCTION notes_search_vector(title TEXT, content TEXT) RETURNS TSVECTOR AS $$
+    SELECT setweight(to_tsvector(s.language, coalesce(title, '')), 'A') ||
+           setweight(to_tsvector(s.language, coalesce(content, '')), 'B')
+    FROM search_settings s
+$$ LANGUAGE SQL STABLE;
+
+CREATE FUNCTION notes_search_vector_trigger() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.search_vector := notes_search_vector(NEW.title, NEW.content);
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE notes ADD COLUMN search_vector TSVECTOR;
+
+UPDATE notes SET search_vector = notes_search_vector(title, content);
+
+CREATE TRIGGER notes_search_vector_update
+    BEFORE INSERT OR UPDATE OF title, content ON notes
+    FOR EACH ROW EXECUTE FUNCTION notes_search_vector_trigger();
+
+CREATE INDEX notes_search_vector_idx ON notes USING GIN (search_vector);
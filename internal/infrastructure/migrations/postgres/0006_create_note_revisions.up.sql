@@ -0,0 +1,12 @@
+CREATE TABLE note_revisions (
+    note_id    UUID        NOT NULL REFERENCES notes (id) ON DELETE CASCADE,
+    revision   INTEGER     NOT NULL,
+    title      TEXT        NOT NULL,
+    content    TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (note_id, revision)
+);
+
+-- existing notes start their history from the current state
+INSERT INTO note_revisions (note_id, revision, title, content, created_at)
+SELECT id, 1, title, content, updated_at FROM notes;
@@ -0,0 +1,5 @@
+ALTER TABLE notes ADD COLUMN deleted_at TIMESTAMPTZ;
+
+-- обычные выборки идут только по живым заметкам
+CREATE INDEX notes_user_id_live_idx ON notes (user_id) WHERE deleted_at IS NULL;
+CREATE INDEX notes_deleted_at_idx ON notes (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,5 @@
+CREATE INDEX IF NOT EXISTS notes_user_id_live_idx ON notes (user_id) WHERE deleted_at IS NULL;
+
+DROP INDEX IF EXISTS notes_user_title_idx;
+DROP INDEX IF EXISTS notes_user_created_at_idx;
+DROP INDEX IF EXISTS notes_user_updated_at_idx;
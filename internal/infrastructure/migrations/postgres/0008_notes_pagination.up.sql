@@ -0,0 +1,7 @@
+-- keyset pagination of GET /notes: (user_id, sort field, id) for every sort order
+CREATE INDEX notes_user_updated_at_idx ON notes (user_id, updated_at, id) WHERE deleted_at IS NULL;
+CREATE INDEX notes_user_created_at_idx ON notes (user_id, created_at, id) WHERE deleted_at IS NULL;
+CREATE INDEX notes_user_title_idx ON notes (user_id, title, id) WHERE deleted_at IS NULL;
+
+-- покрыт новыми индексами
+DROP INDEX IF EXISTS notes_user_id_live_idx;
@@ -0,0 +1,21 @@
+CREATE TABLE sessions (
+    id         UUID PRIMARY KEY,
+    user_id    UUID        NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
+
+-- хранится только sha-256 от токена, сам токен видит лишь клиент
+CREATE TABLE refresh_tokens (
+    id         UUID PRIMARY KEY,
+    session_id UUID        NOT NULL REFERENCES sessions (id) ON DELETE CASCADE,
+    token_hash TEXT        NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at    TIMESTAMPTZ
+);
+
+CREATE INDEX refresh_tokens_session_id_idx ON refresh_tokens (session_id);
@@ -0,0 +1,24 @@
+CREATE TABLE user_totp (
+    user_id      UUID PRIMARY KEY REFERENCES users (user_id) ON DELETE CASCADE,
+    secret       TEXT        NOT NULL,
+    enabled      BOOLEAN     NOT NULL DEFAULT FALSE,
+    -- последний принятый шаг, код того же или более раннего шага повторно не принимается
+    last_step    BIGINT      NOT NULL DEFAULT 0,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    confirmed_at TIMESTAMPTZ
+);
+
+CREATE TABLE recovery_codes (
+    id        UUID PRIMARY KEY,
+    user_id   UUID NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    used_at   TIMESTAMPTZ
+);
+
+CREATE INDEX recovery_codes_user_id_idx ON recovery_codes (user_id);
+
+-- использованные mfa_pending токены, до истечения каждый принимается один раз
+CREATE TABLE used_mfa_tokens (
+    id         UUID PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
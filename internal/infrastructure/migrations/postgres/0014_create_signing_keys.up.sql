@@ -0,0 +1,8 @@
+-- закрытые ключи зашифрованы секретом приложения, открытые получаются из них
+CREATE TABLE signing_keys (
+    kid         TEXT PRIMARY KEY,
+    algorithm   TEXT        NOT NULL,
+    private_key BYTEA       NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at  TIMESTAMPTZ
+);
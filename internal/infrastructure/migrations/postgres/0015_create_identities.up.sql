@@ -0,0 +1,21 @@
+-- внешние аккаунты (OpenID Connect), sub уникален только в пределах провайдера
+CREATE TABLE user_identities (
+    provider   TEXT        NOT NULL,
+    subject    TEXT        NOT NULL,
+    user_id    UUID        NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    email      TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (provider, subject)
+);
+
+CREATE INDEX user_identities_user_id_idx ON user_identities (user_id);
+
+-- начатые входы через провайдера, живут до callback
+CREATE TABLE oidc_states (
+    state         TEXT PRIMARY KEY,
+    provider      TEXT        NOT NULL,
+    code_verifier TEXT        NOT NULL,
+    nonce         TEXT        NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at    TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,21 @@
+-- счётчики неудачных входов, key = "account:<email>" или "ip:<адрес>"
+CREATE TABLE login_throttles (
+    key             TEXT PRIMARY KEY,
+    failures        INTEGER     NOT NULL,
+    last_failure_at TIMESTAMPTZ NOT NULL,
+    blocked_until   TIMESTAMPTZ
+);
+
+-- история блокировок для админов, email не ссылается на users: блокируют и несуществующие
+CREATE TABLE lockout_events (
+    id           UUID PRIMARY KEY,
+    kind         TEXT        NOT NULL,
+    subject      TEXT        NOT NULL,
+    ip           TEXT        NOT NULL,
+    failures     INTEGER     NOT NULL,
+    locked_at    TIMESTAMPTZ NOT NULL,
+    locked_until TIMESTAMPTZ NOT NULL,
+    unlocked_at  TIMESTAMPTZ
+);
+
+CREATE INDEX lockout_events_locked_at_idx ON lockout_events (locked_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS users_role_idx;
+ALTER TABLE users DROP COLUMN disabled_at;
+ALTER TABLE users DROP COLUMN role;
@@ -0,0 +1,5 @@
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user';
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMPTZ;
+
+-- первого админа назначают из командной строки: users role <email> admin
+CREATE INDEX users_role_idx ON users (role);
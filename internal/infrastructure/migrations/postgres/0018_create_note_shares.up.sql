@@ -0,0 +1,10 @@
+-- кому владелец открыл заметку: viewer читает, editor ещё и правит
+CREATE TABLE note_shares (
+    note_id    UUID        NOT NULL REFERENCES notes (id) ON DELETE CASCADE,
+    user_id    UUID        NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    permission TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (note_id, user_id)
+);
+
+CREATE INDEX note_shares_user_id_idx ON note_shares (user_id);
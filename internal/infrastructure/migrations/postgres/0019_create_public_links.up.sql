@@ -0,0 +1,13 @@
+-- открытые ссылки на заметку, по slug её читает кто угодно
+CREATE TABLE public_links (
+    id             UUID PRIMARY KEY,
+    note_id        UUID        NOT NULL REFERENCES notes (id) ON DELETE CASCADE,
+    slug           TEXT        NOT NULL UNIQUE,
+    password_hash  TEXT,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at     TIMESTAMPTZ,
+    views          BIGINT      NOT NULL DEFAULT 0,
+    last_viewed_at TIMESTAMPTZ
+);
+
+CREATE INDEX public_links_note_id_idx ON public_links (note_id);
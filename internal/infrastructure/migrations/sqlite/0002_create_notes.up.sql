@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS notes (
+    id         TEXT PRIMARY KEY,
+    user_id    TEXT      NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    title      TEXT      NOT NULL,
+    content    TEXT      NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS notes_user_id_idx ON notes (user_id);
@@ -0,0 +1,14 @@
+CREATE TABLE tags (
+    id      TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    name    TEXT NOT NULL,
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE note_tags (
+    note_id TEXT NOT NULL REFERENCES notes (id) ON DELETE CASCADE,
+    tag_id  TEXT NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    PRIMARY KEY (note_id, tag_id)
+);
+
+CREATE INDEX note_tags_tag_id_idx ON note_tags (tag_id);
@@ -0,0 +1,16 @@
+CREATE TABLE notebooks (
+    id         TEXT PRIMARY KEY,
+    user_id    TEXT      NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    parent_id  TEXT REFERENCES notebooks (id) ON DELETE CASCADE,
+    name       TEXT      NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX notebooks_user_id_idx ON notebooks (user_id);
+CREATE INDEX notebooks_parent_id_idx ON notebooks (parent_id);
+
+-- sqlite cannot drop a column with a foreign key, the link is kept consistent by the repository
+ALTER TABLE notes ADD COLUMN notebook_id TEXT;
+
+CREATE INDEX notes_notebook_id_idx ON notes (notebook_id);
@@ -0,0 +1,21 @@
+-- porter stems english words, unicode61 splits and folds every other script
+CREATE VIRTUAL TABLE notes_fts USING fts5(
+    note_id UNINDEXED,
+    title,
+    content,
+    tokenize = 'porter unicode61 remove_diacritics 2'
+);
+
+INSERT INTO notes_fts (note_id, title, content) SELECT id, title, content FROM notes;
+
+CREATE TRIGGER notes_fts_insert AFTER INSERT ON notes BEGIN
+    INSERT INTO notes_fts (note_id, title, content) VALUES (new.id, new.title, new.content);
+END;
+
+CREATE TRIGGER notes_fts_update AFTER UPDATE OF title, content ON notes BEGIN
+    UPDATE notes_fts SET title = new.title, content = new.content WHERE note_id = old.id;
+END;
+
+CREATE TRIGGER notes_fts_delete AFTER DELETE ON notes BEGIN
+    DELETE FROM notes_fts WHERE note_id = old.id;
+END;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS notes_deleted_at_idx;
+DROP INDEX IF EXISTS notes_user_id_live_idx;
+ALTER TABLE notes DROP COLUMN deleted_at;
@@ -0,0 +1,21 @@
+CREATE TABLE sessions (
+    id         TEXT PRIMARY KEY,
+    user_id    TEXT      NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
+
+-- хранится только sha-256 от токена, сам токен видит лишь клиент
+CREATE TABLE refresh_tokens (
+    id         TEXT PRIMARY KEY,
+    session_id TEXT      NOT NULL REFERENCES sessions (id) ON DELETE CASCADE,
+    token_hash TEXT      NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    used_at    TIMESTAMP
+);
+
+CREATE INDEX refresh_tokens_session_id_idx ON refresh_tokens (session_id);
@@ -0,0 +1,24 @@
+CREATE TABLE user_totp (
+    user_id      TEXT PRIMARY KEY REFERENCES users (user_id) ON DELETE CASCADE,
+    secret       TEXT      NOT NULL,
+    enabled      BOOLEAN   NOT NULL DEFAULT FALSE,
+    -- последний принятый шаг, код того же или более раннего шага повторно не принимается
+    last_step    INTEGER   NOT NULL DEFAULT 0,
+    created_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    confirmed_at TIMESTAMP
+);
+
+CREATE TABLE recovery_codes (
+    id        TEXT PRIMARY KEY,
+    user_id   TEXT NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    used_at   TIMESTAMP
+);
+
+CREATE INDEX recovery_codes_user_id_idx ON recovery_codes (user_id);
+
+-- использованные mfa_pending токены, до истечения каждый принимается один раз
+CREATE TABLE used_mfa_tokens (
+    id         TEXT PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,15 @@
+-- как и у refresh-токенов, хранится только sha-256, prefix нужен, чтобы токены различать в списке
+CREATE TABLE api_tokens (
+    id           TEXT PRIMARY KEY,
+    user_id      TEXT      NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    name         TEXT      NOT NULL,
+    prefix       TEXT      NOT NULL,
+    token_hash   TEXT      NOT NULL UNIQUE,
+    -- права через пробел, например "notes:read notes:write"
+    scopes       TEXT      NOT NULL,
+    created_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at   TIMESTAMP,
+    last_used_at TIMESTAMP
+);
+
+CREATE INDEX api_tokens_user_id_idx ON api_tokens (user_id);
@@ -0,0 +1,8 @@
+-- закрытые ключи зашифрованы секретом приложения, открытые получаются из них
+CREATE TABLE signing_keys (
+    kid         TEXT PRIMARY KEY,
+    algorithm   TEXT      NOT NULL,
+    private_key BLOB      NOT NULL,
+    created_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at  TIMESTAMP
+);
@@ -0,0 +1,21 @@
+-- счётчики неудачных входов, key = "account:<email>" или "ip:<адрес>"
+CREATE TABLE login_throttles (
+    key             TEXT PRIMARY KEY,
+    failures        INTEGER   NOT NULL,
+    last_failure_at TIMESTAMP NOT NULL,
+    blocked_until   TIMESTAMP
+);
+
+-- история блокировок для админов, email не ссылается на users: блокируют и несуществующие
+CREATE TABLE lockout_events (
+    id           TEXT PRIMARY KEY,
+    kind         TEXT      NOT NULL,
+    subject      TEXT      NOT NULL,
+    ip           TEXT      NOT NULL,
+    failures     INTEGER   NOT NULL,
+    locked_at    TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP NOT NULL,
+    unlocked_at  TIMESTAMP
+);
+
+CREATE INDEX lockout_events_locked_at_idx ON lockout_events (locked_at);
@@ -0,0 +1,10 @@
+-- кому владелец открыл заметку: viewer читает, editor ещё и правит
+CREATE TABLE note_shares (
+    note_id    TEXT      NOT NULL REFERENCES notes (id) ON DELETE CASCADE,
+    user_id    TEXT      NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    permission TEXT      NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (note_id, user_id)
+);
+
+CREATE INDEX note_shares_user_id_idx ON note_shares (user_id);
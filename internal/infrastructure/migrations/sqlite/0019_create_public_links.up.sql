@@ -0,0 +1,13 @@
+-- открытые ссылки на заметку, по slug её читает кто угодно
+CREATE TABLE public_links (
+    id             TEXT PRIMARY KEY,
+    note_id        TEXT      NOT NULL REFERENCES notes (id) ON DELETE CASCADE,
+    slug           TEXT      NOT NULL UNIQUE,
+    password_hash  TEXT,
+    created_at     TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at     TIMESTAMP,
+    views          INTEGER   NOT NULL DEFAULT 0,
+    last_viewed_at TIMESTAMP
+);
+
+CREATE INDEX public_links_note_id_idx ON public_links (note_id);
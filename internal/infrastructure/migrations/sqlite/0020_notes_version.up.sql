@@ -0,0 +1,2 @@
+-- версия растёт с каждым изменением заметки, по ней работают ETag и If-Match
+ALTER TABLE notes ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS note_tombstones;
+DROP INDEX IF EXISTS notes_user_change_seq_idx;
+ALTER TABLE notes DROP COLUMN change_seq;
+DROP TABLE IF EXISTS note_sync;
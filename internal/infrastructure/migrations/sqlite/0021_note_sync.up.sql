@@ -0,0 +1,23 @@
+-- номер последнего изменения заметок каждого пользователя, по нему клиенты забирают дельту;
+-- строка держится заблокированной до конца транзакции, так что номера коммитятся по порядку
+CREATE TABLE note_sync (
+    user_id    TEXT    PRIMARY KEY REFERENCES users (user_id) ON DELETE CASCADE,
+    seq        INTEGER NOT NULL DEFAULT 0,
+    -- удалённые заметки с номером не выше этого уже забыты, клиенту с более старым токеном нужна полная синхронизация
+    pruned_seq INTEGER NOT NULL DEFAULT 0
+);
+
+-- у заметок до миграции номер 0, первая синхронизация их всё равно получает
+ALTER TABLE notes ADD COLUMN change_seq INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX notes_user_change_seq_idx ON notes (user_id, change_seq, id);
+
+-- заметки, удалённые из корзины насовсем, чтобы клиенты узнали об удалении
+CREATE TABLE note_tombstones (
+    note_id    TEXT      PRIMARY KEY,
+    user_id    TEXT      NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    change_seq INTEGER   NOT NULL,
+    deleted_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX note_tombstones_user_change_seq_idx ON note_tombstones (user_id, change_seq, note_id);
+CREATE INDEX note_tombstones_deleted_at_idx ON note_tombstones (deleted_at);
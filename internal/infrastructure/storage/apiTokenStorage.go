@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"database/sql"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+var apiTokenColumns = []string{"id", "user_id", "name", "prefix", "token_hash", "scopes", "created_at", "expires_at", "last_used_at"}
+
+func scanAPIToken(row rowScanner) (models.APIToken, error) {
+	var token models.APIToken
+	var scopes string
+	err := row.Scan(
+		&token.ID,
+		&token.UserId,
+		&token.Name,
+		&token.Prefix,
+		&token.TokenHash,
+		&scopes,
+		&token.CreatedAt,
+		&token.ExpiresAt,
+		&token.LastUsedAt)
+	token.Scopes = strings.Fields(scopes)
+	return token, err
+}
+
+type APITokensRepository struct {
+	Db      *sql.DB
+	dialect Dialect
+}
+
+func NewAPITokensRepository(db *sql.DB, dialect Dialect) *APITokensRepository {
+	return &APITokensRepository{
+		Db:      db,
+		dialect: dialect,
+	}
+}
+
+func (r *APITokensRepository) Create(token models.APIToken) error {
+
+	query, args, err := squirrel.Insert("api_tokens").
+		Columns(apiTokenColumns...).
+		Values(token.ID, token.UserId, token.Name, token.Prefix, token.TokenHash,
+			strings.Join(token.Scopes, " "), token.CreatedAt, token.ExpiresAt, token.LastUsedAt).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Db.Exec(query, args...)
+	return err
+}
+
+func (r *APITokensRepository) GetAllByUserId(userId uuid.UUID) ([]models.APIToken, error) {
+
+	query, args, err := squirrel.Select(apiTokenColumns...).
+		From("api_tokens").
+		Where(squirrel.Eq{"user_id": userId}).
+		OrderBy("created_at DESC").
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.Db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []models.APIToken{}
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+func (r *APITokensRepository) GetByHash(tokenHash string) (models.APIToken, error) {
+
+	query, args, err := squirrel.Select(apiTokenColumns...).
+		From("api_tokens").
+		Where(squirrel.Eq{"token_hash": tokenHash}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return models.APIToken{}, err
+	}
+
+	token, err := scanAPIToken(r.Db.QueryRow(query, args...))
+	if err == sql.ErrNoRows {
+		return models.APIToken{}, repository.ErrNotFound
+	}
+	return token, err
+}
+
+func (r *APITokensRepository) Delete(userId uuid.UUID, id uuid.UUID) error {
+
+	query, args, err := squirrel.Delete("api_tokens").
+		Where(squirrel.Eq{"id": id, "user_id": userId}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	return execAffected(r.Db, query, args)
+}
+
+func (r *APITokensRepository) Touch(id uuid.UUID, lastUsedAt time.Time) error {
+
+	query, args, err := squirrel.Update("api_tokens").
+		Set("last_used_at", lastUsedAt).
+		Where(squirrel.Eq{"id": id}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Db.Exec(query, args...)
+	return err
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"database/sql"
+	"github.com/Masterminds/squirrel"
+	"strings"
+
+	//nolint
+	_ "github.com/jackc/pgx/v5/stdlib" // pgx driver for database/sql
+	//nolint
+	_ "modernc.org/sqlite" // pure Go sqlite driver for database/sql
+)
+
+// Dialect describes the differences between the SQL databases the repositories can talk to.
+type Dialect struct {
+	Name        string
+	Driver      string
+	Placeholder squirrel.PlaceholderFormat
+}
+
+var (
+	Postgres = Dialect{Name: "postgres", Driver: "pgx", Placeholder: squirrel.Dollar}
+	SQLite   = Dialect{Name: "sqlite", Driver: "sqlite", Placeholder: squirrel.Question}
+)
+
+// Open opens a database handle for the given dialect.
+func Open(dialect Dialect, dsn string) (*sql.DB, error) {
+	if dialect == SQLite {
+		// pragmas in the DSN are applied to every new connection of the pool,
+		// the sqlite time format keeps timestamps readable by sqlite date functions
+		separator := "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+		dsn += separator + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_time_format=sqlite"
+	}
+
+	db, err := sql.Open(dialect.Driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if dialect == SQLite {
+		// sqlite allows a single writer, one connection avoids SQLITE_BUSY between goroutines
+		db.SetMaxOpenConns(1)
+	}
+
+	return db, nil
+}
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"database/sql"
+	"github.com/Masterminds/squirrel"
+	"time"
+)
+
+type IdentitiesRepository struct {
+	Db      *sql.DB
+	dialect Dialect
+}
+
+func NewIdentitiesRepository(db *sql.DB, dialect Dialect) *IdentitiesRepository {
+	return &IdentitiesRepository{
+		Db:      db,
+		dialect: dialect,
+	}
+}
+
+func (r *IdentitiesRepository) GetIdentity(provider string, subject string) (models.ExternalIdentity, error) {
+
+	query, args, err := squirrel.Select("provider", "subject", "user_id", "email", "created_at").
+		From("user_identities").
+		Where(squirrel.Eq{"provider": provider, "subject": subject}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return models.ExternalIdentity{}, err
+	}
+
+	var identity models.ExternalIdentity
+	err = r.Db.QueryRow(query, args...).Scan(
+		&identity.Provider,
+		&identity.Subject,
+		&identity.UserId,
+		&identity.Email,
+		&identity.CreatedAt)
+	if err == sql.ErrNoRows {
+		return models.ExternalIdentity{}, repository.ErrNotFound
+	}
+	return identity, err
+}
+
+func (r *IdentitiesRepository) CreateIdentity(identity models.ExternalIdentity) error {
+
+	query, args, err := squirrel.Insert("user_identities").
+		Columns("provider", "subject", "user_id", "email", "created_at").
+		Values(identity.Provider, identity.Subject, identity.UserId, identity.Email, identity.CreatedAt).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Db.Exec(query, args...)
+	return err
+}
+
+func (r *IdentitiesRepository) SaveState(state models.OIDCState) error {
+
+	query, args, err := squirrel.Insert("oidc_states").
+		Columns("state", "provider", "code_verifier", "nonce", "created_at", "expires_at").
+		Values(state.State, state.Provider, state.CodeVerifier, state.Nonce, state.CreatedAt, state.ExpiresAt).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Db.Exec(query, args...)
+	return err
+}
+
+func (r *IdentitiesRepository) TakeState(state string, at time.Time) (models.OIDCState, error) {
+
+	// DELETE ... RETURNING, чтобы два callback с одним state не прошли оба
+	query, args, err := squirrel.Delete("oidc_states").
+		Where(squirrel.Eq{"state": state}).
+		Where(squirrel.Gt{"expires_at": at}).
+		Suffix("RETURNING state, provider, code_verifier, nonce, created_at, expires_at").
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return models.OIDCState{}, err
+	}
+
+	var taken models.OIDCState
+	err = r.Db.QueryRow(query, args...).Scan(
+		&taken.State,
+		&taken.Provider,
+		&taken.CodeVerifier,
+		&taken.Nonce,
+		&taken.CreatedAt,
+		&taken.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return models.OIDCState{}, repository.ErrNotFound
+	}
+	return taken, err
+}
+
+func (r *IdentitiesRepository) DeleteExpiredStates(before time.Time) (int64, error) {
+
+	query, args, err := squirrel.Delete("oidc_states").
+		Where(squirrel.Lt{"expires_at": before}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := r.Db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"2/internal/domain/models"
+	"database/sql"
+	"github.com/Masterminds/squirrel"
+	"time"
+)
+
+var lockoutColumns = []string{"id", "kind", "subject", "ip", "failures", "locked_at", "locked_until", "unlocked_at"}
+
+type LoginGuardRepository struct {
+	Db      *sql.DB
+	dialect Dialect
+}
+
+func NewLoginGuardRepository(db *sql.DB, dialect Dialect) *LoginGuardRepository {
+	return &LoginGuardRepository{
+		Db:      db,
+		dialect: dialect,
+	}
+}
+
+func (r *LoginGuardRepository) GetThrottles(keys ...string) ([]models.LoginThrottle, error) {
+
+	query, args, err := squirrel.Select("key", "failures", "last_failure_at", "blocked_until").
+		From("login_throttles").
+		Where(squirrel.Eq{"key": keys}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.Db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	throttles := []models.LoginThrottle{}
+	for rows.Next() {
+		var throttle models.LoginThrottle
+		if err = rows.Scan(&throttle.Key, &throttle.Failures, &throttle.LastFailureAt, &throttle.BlockedUntil); err != nil {
+			return nil, err
+		}
+		throttles = append(throttles, throttle)
+	}
+	return throttles, rows.Err()
+}
+
+func (r *LoginGuardRepository) RecordFailure(key string, at time.Time, resetBefore time.Time) (int, error) {
+
+	// один upsert, чтобы параллельные попытки не потеряли ни одной неудачи
+	query, args, err := squirrel.Insert("login_throttles").
+		Columns("key", "failures", "last_failure_at").
+		Values(key, 1, at).
+		Suffix(`ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_throttles.last_failure_at < ? THEN 1 ELSE login_throttles.failures + 1 END,
+			last_failure_at = excluded.last_failure_at
+			RETURNING failures`, resetBefore).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	var failures int
+	err = r.Db.QueryRow(query, args...).Scan(&failures)
+	return failures, err
+}
+
+func (r *LoginGuardRepository) Block(key string, until time.Time) error {
+
+	query, args, err := squirrel.Update("login_throttles").
+		Set("blocked_until", until).
+		Where(squirrel.Eq{"key": key}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Db.Exec(query, args...)
+	return err
+}
+
+func (r *LoginGuardRepository) Reset(key string) error {
+
+	query, args, err := squirrel.Delete("login_throttles").
+		Where(squirrel.Eq{"key": key}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	return execAffected(r.Db, query, args)
+}
+
+func (r *LoginGuardRepository) DeleteIdle(before time.Time) (int64, error) {
+
+	query, args, err := squirrel.Delete("login_throttles").
+		Where(squirrel.Lt{"last_failure_at": before}).
+		Where(squirrel.Or{
+			squirrel.Eq{"blocked_until": nil},
+			squirrel.Lt{"blocked_until": before},
+		}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := r.Db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *LoginGuardRepository) CreateLockout(event models.LockoutEvent) error {
+
+	query, args, err := squirrel.Insert("lockout_events").
+		Columns(lockoutColumns...).
+		Values(event.ID, event.Kind, event.Subject, event.IP, event.Failures, event.LockedAt, event.LockedUntil, event.UnlockedAt).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Db.Exec(query, args...)
+	return err
+}
+
+func (r *LoginGuardRepository) GetLockouts(limit int) ([]models.LockoutEvent, error) {
+
+	query, args, err := squirrel.Select(lockoutColumns...).
+		From("lockout_events").
+		OrderBy("locked_at DESC").
+		Limit(uint64(limit)).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.Db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.LockoutEvent{}
+	for rows.Next() {
+		var event models.LockoutEvent
+		err = rows.Scan(
+			&event.ID,
+			&event.Kind,
+			&event.Subject,
+			&event.IP,
+			&event.Failures,
+			&event.LockedAt,
+			&event.LockedUntil,
+			&event.UnlockedAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+func (r *LoginGuardRepository) MarkUnlocked(kind string, subject string, at time.Time) error {
+
+	query, args, err := squirrel.Update("lockout_events").
+		Set("unlocked_at", at).
+		Where(squirrel.Eq{"kind": kind, "subject": subject, "unlocked_at": nil}).
+		Where(squirrel.Gt{"locked_until": at}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Db.Exec(query, args...)
+	return err
+}
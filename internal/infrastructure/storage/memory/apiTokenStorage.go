@@ -0,0 +1,96 @@
+package memory
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"github.com/google/uuid"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// APITokensRepository keeps personal access tokens in process memory.
+type APITokensRepository struct {
+	mu     sync.RWMutex
+	tokens map[uuid.UUID]models.APIToken
+}
+
+func NewAPITokensRepository() *APITokensRepository {
+	return &APITokensRepository{
+		tokens: make(map[uuid.UUID]models.APIToken),
+	}
+}
+
+func (r *APITokensRepository) Create(token models.APIToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token.Scopes = slices.Clone(token.Scopes)
+	r.tokens[token.ID] = token
+	return nil
+}
+
+func (r *APITokensRepository) GetAllByUserId(userId uuid.UUID) ([]models.APIToken, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tokens := []models.APIToken{}
+	for _, token := range r.tokens {
+		if token.UserId == userId {
+			tokens = append(tokens, token)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.After(tokens[j].CreatedAt)
+	})
+	return tokens, nil
+}
+
+func (r *APITokensRepository) GetByHash(tokenHash string) (models.APIToken, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, token := range r.tokens {
+		if token.TokenHash == tokenHash {
+			return token, nil
+		}
+	}
+	return models.APIToken{}, repository.ErrNotFound
+}
+
+func (r *APITokensRepository) Delete(userId uuid.UUID, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.tokens[id]
+	if !ok || token.UserId != userId {
+		return repository.ErrNotFound
+	}
+	delete(r.tokens, id)
+	return nil
+}
+
+func (r *APITokensRepository) Touch(id uuid.UUID, lastUsedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.tokens[id]
+	if !ok {
+		return nil
+	}
+	token.LastUsedAt = &lastUsedAt
+	r.tokens[id] = token
+	return nil
+}
+
+func (r *APITokensRepository) deleteUserData(userId uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, token := range r.tokens {
+		if token.UserId == userId {
+			delete(r.tokens, id)
+		}
+	}
+}
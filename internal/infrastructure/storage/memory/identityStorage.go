@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"errors"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+type identityKey struct {
+	provider string
+	subject  string
+}
+
+// IdentitiesRepository keeps linked external accounts and pending logins in process memory.
+type IdentitiesRepository struct {
+	mu         sync.Mutex
+	identities map[identityKey]models.ExternalIdentity
+	states     map[string]models.OIDCState
+}
+
+func NewIdentitiesRepository() *IdentitiesRepository {
+	return &IdentitiesRepository{
+		identities: make(map[identityKey]models.ExternalIdentity),
+		states:     make(map[string]models.OIDCState),
+	}
+}
+
+func (r *IdentitiesRepository) GetIdentity(provider string, subject string) (models.ExternalIdentity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	identity, ok := r.identities[identityKey{provider, subject}]
+	if !ok {
+		return models.ExternalIdentity{}, repository.ErrNotFound
+	}
+	return identity, nil
+}
+
+func (r *IdentitiesRepository) CreateIdentity(identity models.ExternalIdentity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := identityKey{identity.Provider, identity.Subject}
+	if _, exists := r.identities[key]; exists {
+		return errors.New("identity is already linked")
+	}
+	r.identities[key] = identity
+	return nil
+}
+
+func (r *IdentitiesRepository) SaveState(state models.OIDCState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.states[state.State] = state
+	return nil
+}
+
+func (r *IdentitiesRepository) TakeState(state string, at time.Time) (models.OIDCState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	taken, ok := r.states[state]
+	if !ok || !taken.ExpiresAt.After(at) {
+		return models.OIDCState{}, repository.ErrNotFound
+	}
+	delete(r.states, state)
+	return taken, nil
+}
+
+func (r *IdentitiesRepository) DeleteExpiredStates(before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	for id, state := range r.states {
+		if state.ExpiresAt.Before(before) {
+			delete(r.states, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *IdentitiesRepository) deleteUserData(userId uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, identity := range r.identities {
+		if identity.UserId == userId {
+			delete(r.identities, key)
+		}
+	}
+}
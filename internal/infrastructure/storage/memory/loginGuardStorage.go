@@ -0,0 +1,121 @@
+package memory
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LoginGuardRepository keeps failed login counters and lockout events in process memory.
+type LoginGuardRepository struct {
+	mu        sync.Mutex
+	throttles map[string]models.LoginThrottle
+	lockouts  []models.LockoutEvent
+}
+
+func NewLoginGuardRepository() *LoginGuardRepository {
+	return &LoginGuardRepository{
+		throttles: make(map[string]models.LoginThrottle),
+	}
+}
+
+func (r *LoginGuardRepository) GetThrottles(keys ...string) ([]models.LoginThrottle, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	throttles := []models.LoginThrottle{}
+	for _, key := range keys {
+		if throttle, ok := r.throttles[key]; ok {
+			throttles = append(throttles, throttle)
+		}
+	}
+	return throttles, nil
+}
+
+func (r *LoginGuardRepository) RecordFailure(key string, at time.Time, resetBefore time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	throttle, ok := r.throttles[key]
+	if !ok || throttle.LastFailureAt.Before(resetBefore) {
+		throttle.Key = key
+		throttle.Failures = 0
+	}
+	throttle.Failures++
+	throttle.LastFailureAt = at
+	r.throttles[key] = throttle
+	return throttle.Failures, nil
+}
+
+func (r *LoginGuardRepository) Block(key string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if throttle, ok := r.throttles[key]; ok {
+		throttle.BlockedUntil = &until
+		r.throttles[key] = throttle
+	}
+	return nil
+}
+
+func (r *LoginGuardRepository) Reset(key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.throttles[key]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(r.throttles, key)
+	return nil
+}
+
+func (r *LoginGuardRepository) DeleteIdle(before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	for key, throttle := range r.throttles {
+		if throttle.LastFailureAt.Before(before) && (throttle.BlockedUntil == nil || throttle.BlockedUntil.Before(before)) {
+			delete(r.throttles, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *LoginGuardRepository) CreateLockout(event models.LockoutEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lockouts = append(r.lockouts, event)
+	return nil
+}
+
+func (r *LoginGuardRepository) GetLockouts(limit int) ([]models.LockoutEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events := make([]models.LockoutEvent, len(r.lockouts))
+	copy(events, r.lockouts)
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].LockedAt.After(events[j].LockedAt)
+	})
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+func (r *LoginGuardRepository) MarkUnlocked(kind string, subject string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, event := range r.lockouts {
+		if event.Kind == kind && event.Subject == subject && event.UnlockedAt == nil && event.LockedUntil.After(at) {
+			r.lockouts[i].UnlockedAt = &at
+		}
+	}
+	return nil
+}
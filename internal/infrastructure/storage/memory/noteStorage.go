@@ -0,0 +1,400 @@
+package memory
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"errors"
+	"github.com/google/uuid"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NotesRepository keeps notes in process memory. Everything is lost on restart.
+type NotesRepository struct {
+	mu        sync.RWMutex
+	notes     map[uuid.UUID]models.Note
+	index     *searchIndex
+	revisions map[uuid.UUID][]models.NoteRevision
+	// shares по заметке, затем по пользователю, которому её открыли
+	shares map[uuid.UUID]map[uuid.UUID]models.NoteShare
+	// публичные ссылки по id ссылки
+	links map[uuid.UUID]models.PublicLink
+	// лента изменений для синхронизации: последний номер пользователя, номер каждой заметки,
+	// заметки, удалённые насовсем, и до какого номера такие уже забыты
+	changeSeq       map[uuid.UUID]int64
+	noteSeq         map[uuid.UUID]int64
+	tombstones      map[uuid.UUID]models.NoteChange
+	tombstoneOwners map[uuid.UUID]uuid.UUID
+	prunedSeq       map[uuid.UUID]int64
+}
+
+func NewNotesRepository() *NotesRepository {
+	return &NotesRepository{
+		notes:     make(map[uuid.UUID]models.Note),
+		index:     newSearchIndex(),
+		revisions: make(map[uuid.UUID][]models.NoteRevision),
+		shares:    make(map[uuid.UUID]map[uuid.UUID]models.NoteShare),
+		links:     make(map[uuid.UUID]models.PublicLink),
+
+		changeSeq:       make(map[uuid.UUID]int64),
+		noteSeq:         make(map[uuid.UUID]int64),
+		tombstones:      make(map[uuid.UUID]models.NoteChange),
+		tombstoneOwners: make(map[uuid.UUID]uuid.UUID),
+		prunedSeq:       make(map[uuid.UUID]int64),
+	}
+}
+
+func (s *NotesRepository) Create(note models.Note) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.notes[note.ID]; ok {
+		return errors.New("Error inserting note into database: duplicate id")
+	}
+
+	note.Tags = slices.Clone(note.Tags)
+	s.notes[note.ID] = note
+	s.index.add(note)
+	s.addRevision(note, note.CreatedAt)
+	s.touchLocked(note)
+	delete(s.tombstones, note.ID)
+	delete(s.tombstoneOwners, note.ID)
+	return nil
+}
+
+func (s *NotesRepository) Get(id uuid.UUID) (models.Note, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	note, ok := s.live(id)
+	if !ok {
+		return models.Note{}, repository.ErrNotFound
+	}
+
+	return copyNote(note), nil
+}
+
+func (s *NotesRepository) GetAllByUserId(id uuid.UUID) ([]models.Note, error) {
+	return s.Find(id, models.NoteFilter{}, models.NotePage{})
+}
+
+func (s *NotesRepository) Find(userId uuid.UUID, filter models.NoteFilter, page models.NotePage) ([]models.Note, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var after models.Note
+	if page.After != nil {
+		after = models.Note{
+			ID:        page.After.ID,
+			Title:     page.After.Title,
+			CreatedAt: page.After.Time,
+			UpdatedAt: page.After.Time,
+		}
+	}
+
+	var notes []models.Note
+	for _, note := range s.notes {
+		if note.UserId != userId || note.DeletedAt != nil || !matchFilter(note, filter) {
+			continue
+		}
+		if page.After != nil && compareNotes(note, after, page) <= 0 {
+			continue
+		}
+		notes = append(notes, copyNote(note))
+	}
+
+	sort.Slice(notes, func(i, j int) bool {
+		return compareNotes(notes[i], notes[j], page) < 0
+	})
+	if page.Limit > 0 && len(notes) > page.Limit {
+		notes = notes[:page.Limit]
+	}
+
+	return notes, nil
+}
+
+// compareNotes orders two notes the way the page lists them, ties are broken by id.
+func compareNotes(a, b models.Note, page models.NotePage) int {
+	var c int
+	switch page.Sort {
+	case models.NoteSortUpdatedAt:
+		c = a.UpdatedAt.Compare(b.UpdatedAt)
+	case models.NoteSortTitle:
+		c = strings.Compare(a.Title, b.Title)
+	default:
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	if c == 0 {
+		c = strings.Compare(a.ID.String(), b.ID.String())
+	}
+
+	if page.Desc {
+		return -c
+	}
+	return c
+}
+
+func matchFilter(note models.Note, filter models.NoteFilter) bool {
+	if filter.NotebookId != nil && (note.NotebookId == nil || *note.NotebookId != *filter.NotebookId) {
+		return false
+	}
+	if filter.CreatedAfter != nil && !note.CreatedAt.After(*filter.CreatedAfter) ||
+		filter.CreatedBefore != nil && !note.CreatedAt.Before(*filter.CreatedBefore) ||
+		filter.UpdatedAfter != nil && !note.UpdatedAt.After(*filter.UpdatedAfter) ||
+		filter.UpdatedBefore != nil && !note.UpdatedAt.Before(*filter.UpdatedBefore) {
+		return false
+	}
+
+	return matchTags(note.Tags, filter)
+}
+
+func matchTags(tags []string, filter models.NoteFilter) bool {
+	if len(filter.Tags) == 0 {
+		return true
+	}
+
+	matched := 0
+	for _, tag := range filter.Tags {
+		if slices.Contains(tags, tag) {
+			matched++
+		}
+	}
+
+	if filter.MatchAllTags {
+		return matched == len(filter.Tags)
+	}
+	return matched > 0
+}
+
+// copyNote detaches the returned note from the slices kept in the map.
+func copyNote(note models.Note) models.Note {
+	note.Tags = slices.Clone(note.Tags)
+	if note.Tags == nil {
+		note.Tags = []string{}
+	}
+	return note
+}
+
+func (s *NotesRepository) Update(note models.Note) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, ok := s.live(note.ID)
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if prev.Version != note.Version {
+		return repository.ErrVersionConflict
+	}
+
+	if prev.Content == note.Content && prev.Title == note.Title && slices.Equal(prev.Tags, note.Tags) {
+		return errors.New("There is no updates")
+	}
+
+	contentChanged := prev.Content != note.Content || prev.Title != note.Title
+
+	prev.Title = note.Title
+	prev.Content = note.Content
+	prev.Tags = slices.Clone(note.Tags)
+	prev.UpdatedAt = time.Now()
+	prev.Version++
+	s.notes[note.ID] = prev
+	s.touchLocked(prev)
+	s.index.add(prev)
+	if contentChanged {
+		s.addRevision(prev, prev.UpdatedAt)
+	}
+	return nil
+}
+
+func (s *NotesRepository) Move(id uuid.UUID, notebookId *uuid.UUID, version int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	note, ok := s.live(id)
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if version != 0 && note.Version != version {
+		return repository.ErrVersionConflict
+	}
+
+	note.NotebookId = notebookId
+	note.UpdatedAt = time.Now()
+	note.Version++
+	s.notes[id] = note
+	s.touchLocked(note)
+	return nil
+}
+
+func (s *NotesRepository) Delete(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if note, ok := s.notes[id]; ok {
+		s.buryLocked(note, s.nextSeqLocked(note.UserId))
+	}
+	s.deleteLocked(id)
+	return nil
+}
+
+// live returns the note unless it is missing or lies in the trash, the caller holds the lock.
+func (s *NotesRepository) live(id uuid.UUID) (models.Note, bool) {
+	note, ok := s.notes[id]
+	if !ok || note.DeletedAt != nil {
+		return models.Note{}, false
+	}
+	return note, true
+}
+
+func (s *NotesRepository) Trash(id uuid.UUID, deletedAt time.Time, version int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	note, ok := s.live(id)
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if version != 0 && note.Version != version {
+		return repository.ErrVersionConflict
+	}
+
+	note.DeletedAt = &deletedAt
+	s.notes[id] = note
+	s.touchLocked(note)
+	return nil
+}
+
+func (s *NotesRepository) GetTrash(userId uuid.UUID) ([]models.Note, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	notes := []models.Note{}
+	for _, note := range s.notes {
+		if note.UserId == userId && note.DeletedAt != nil {
+			notes = append(notes, copyNote(note))
+		}
+	}
+	sort.Slice(notes, func(i, j int) bool {
+		return notes[i].DeletedAt.After(*notes[j].DeletedAt)
+	})
+
+	return notes, nil
+}
+
+func (s *NotesRepository) Restore(userId uuid.UUID, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	note, ok := s.notes[id]
+	if !ok || note.UserId != userId || note.DeletedAt == nil {
+		return repository.ErrNotFound
+	}
+
+	note.DeletedAt = nil
+	s.notes[id] = note
+	s.touchLocked(note)
+	return nil
+}
+
+func (s *NotesRepository) EmptyTrash(userId uuid.UUID) (int64, error) {
+	return s.deleteTrashed(func(note models.Note) bool {
+		return note.UserId == userId
+	}), nil
+}
+
+func (s *NotesRepository) CountByUsers(userIds []uuid.UUID) (map[uuid.UUID]models.NoteCounts, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[uuid.UUID]models.NoteCounts)
+	for _, note := range s.notes {
+		if !slices.Contains(userIds, note.UserId) {
+			continue
+		}
+		c := counts[note.UserId]
+		if note.DeletedAt == nil {
+			c.Notes++
+		} else {
+			c.Trashed++
+		}
+		counts[note.UserId] = c
+	}
+	return counts, nil
+}
+
+func (s *NotesRepository) PurgeTrash(olderThan time.Time) (int64, error) {
+	return s.deleteTrashed(func(note models.Note) bool {
+		return note.DeletedAt.Before(olderThan)
+	}), nil
+}
+
+func (s *NotesRepository) deleteTrashed(match func(models.Note) bool) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for id, note := range s.notes {
+		if note.DeletedAt != nil && match(note) {
+			s.buryLocked(note, 0)
+			s.deleteLocked(id)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// deleteLocked removes the note with its search entries, revisions, shares and public links,
+// the caller holds the write lock.
+func (s *NotesRepository) deleteLocked(id uuid.UUID) {
+	delete(s.notes, id)
+	delete(s.revisions, id)
+	delete(s.shares, id)
+	delete(s.noteSeq, id)
+	for linkId, link := range s.links {
+		if link.NoteId == id {
+			delete(s.links, linkId)
+		}
+	}
+	s.index.remove(id)
+}
+
+func (s *NotesRepository) addRevision(note models.Note, createdAt time.Time) {
+	revisions := s.revisions[note.ID]
+	next := 1
+	if len(revisions) > 0 {
+		next = revisions[len(revisions)-1].Revision + 1
+	}
+	s.revisions[note.ID] = append(revisions, models.NoteRevision{
+		NoteId:    note.ID,
+		Revision:  next,
+		Title:     note.Title,
+		Content:   note.Content,
+		CreatedAt: createdAt,
+	})
+}
+
+func (s *NotesRepository) deleteUserData(userId uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, note := range s.notes {
+		if note.UserId == userId {
+			s.deleteLocked(id)
+		}
+	}
+	for _, shares := range s.shares {
+		delete(shares, userId)
+	}
+	for id, owner := range s.tombstoneOwners {
+		if owner == userId {
+			delete(s.tombstones, id)
+			delete(s.tombstoneOwners, id)
+		}
+	}
+	delete(s.changeSeq, userId)
+	delete(s.prunedSeq, userId)
+}
@@ -0,0 +1,158 @@
+package memory
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"github.com/google/uuid"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// NotebooksRepository keeps notebooks in process memory and moves or removes
+// the notes of a memory NotesRepository when a notebook is deleted.
+type NotebooksRepository struct {
+	mu        sync.RWMutex
+	notebooks map[uuid.UUID]models.Notebook
+	notes     *NotesRepository
+}
+
+func NewNotebooksRepository(notes *NotesRepository) *NotebooksRepository {
+	return &NotebooksRepository{
+		notebooks: make(map[uuid.UUID]models.Notebook),
+		notes:     notes,
+	}
+}
+
+func (r *NotebooksRepository) Create(notebook models.Notebook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.notebooks[notebook.ID] = notebook
+	return nil
+}
+
+func (r *NotebooksRepository) Get(id uuid.UUID) (models.Notebook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	notebook, ok := r.notebooks[id]
+	if !ok {
+		return models.Notebook{}, repository.ErrNotFound
+	}
+
+	return notebook, nil
+}
+
+func (r *NotebooksRepository) GetAllByUserId(userId uuid.UUID) ([]models.Notebook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	notebooks := []models.Notebook{}
+	for _, notebook := range r.notebooks {
+		if notebook.UserId == userId {
+			notebooks = append(notebooks, notebook)
+		}
+	}
+	sort.Slice(notebooks, func(i, j int) bool {
+		return notebooks[i].Name < notebooks[j].Name
+	})
+
+	return notebooks, nil
+}
+
+func (r *NotebooksRepository) Update(notebook models.Notebook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prev, ok := r.notebooks[notebook.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+
+	prev.Name = notebook.Name
+	prev.ParentId = notebook.ParentId
+	prev.UpdatedAt = time.Now()
+	r.notebooks[notebook.ID] = prev
+	return nil
+}
+
+func (r *NotebooksRepository) DeleteCascade(userId uuid.UUID, ids []uuid.UUID, deletedAt time.Time) ([]uuid.UUID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notes.mu.Lock()
+	defer r.notes.mu.Unlock()
+
+	var seq int64
+	var trashed []uuid.UUID
+	for id, note := range r.notes.notes {
+		if note.UserId != userId || note.NotebookId == nil || !slices.Contains(ids, *note.NotebookId) {
+			continue
+		}
+		note.NotebookId = nil
+		// заметки, лежавшие в корзине раньше, клиенты уже видели удалёнными: только отвязываем
+		if note.DeletedAt == nil {
+			if seq == 0 {
+				seq = r.notes.nextSeqLocked(userId)
+			}
+			note.DeletedAt = &deletedAt
+			note.Version++
+			r.notes.noteSeq[id] = seq
+			trashed = append(trashed, id)
+		}
+		r.notes.notes[id] = note
+	}
+	for _, id := range ids {
+		delete(r.notebooks, id)
+	}
+
+	return trashed, nil
+}
+
+func (r *NotebooksRepository) DeleteReparent(notebook models.Notebook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notes.mu.Lock()
+	defer r.notes.mu.Unlock()
+
+	for id, child := range r.notebooks {
+		if child.ParentId != nil && *child.ParentId == notebook.ID {
+			child.ParentId = notebook.ParentId
+			r.notebooks[id] = child
+		}
+	}
+
+	var seq int64
+	now := time.Now()
+	for id, note := range r.notes.notes {
+		if note.NotebookId == nil || *note.NotebookId != notebook.ID {
+			continue
+		}
+		note.NotebookId = notebook.ParentId
+		// заметки в корзине клиенты видят удалёнными, изменением их не считаем: только переносим
+		if note.DeletedAt == nil {
+			if seq == 0 {
+				seq = r.notes.nextSeqLocked(notebook.UserId)
+			}
+			note.UpdatedAt = now
+			note.Version++
+			r.notes.noteSeq[id] = seq
+		}
+		r.notes.notes[id] = note
+	}
+	delete(r.notebooks, notebook.ID)
+
+	return nil
+}
+
+func (r *NotebooksRepository) deleteUserData(userId uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, notebook := range r.notebooks {
+		if notebook.UserId == userId {
+			delete(r.notebooks, id)
+		}
+	}
+}
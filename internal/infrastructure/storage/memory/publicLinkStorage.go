@@ -0,0 +1,105 @@
+package memory
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"errors"
+	"github.com/google/uuid"
+	"sort"
+	"time"
+)
+
+// PublicLinksRepository works on the public links kept inside a memory NotesRepository,
+// so they go away together with the note.
+type PublicLinksRepository struct {
+	notes *NotesRepository
+}
+
+func NewPublicLinksRepository(notes *NotesRepository) *PublicLinksRepository {
+	return &PublicLinksRepository{notes: notes}
+}
+
+func (r *PublicLinksRepository) Create(link models.PublicLink) error {
+	r.notes.mu.Lock()
+	defer r.notes.mu.Unlock()
+
+	if _, ok := r.notes.notes[link.NoteId]; !ok {
+		return repository.ErrNotFound
+	}
+	for _, existing := range r.notes.links {
+		if existing.ID == link.ID || existing.Slug == link.Slug {
+			return errors.New("Error inserting public link: duplicate id or slug")
+		}
+	}
+
+	r.notes.links[link.ID] = link
+	return nil
+}
+
+func (r *PublicLinksRepository) GetBySlug(slug string) (models.PublicLink, error) {
+	r.notes.mu.RLock()
+	defer r.notes.mu.RUnlock()
+
+	for _, link := range r.notes.links {
+		if link.Slug == slug {
+			return link, nil
+		}
+	}
+	return models.PublicLink{}, repository.ErrNotFound
+}
+
+func (r *PublicLinksRepository) GetAllByNoteId(noteId uuid.UUID) ([]models.PublicLink, error) {
+	r.notes.mu.RLock()
+	defer r.notes.mu.RUnlock()
+
+	links := []models.PublicLink{}
+	for _, link := range r.notes.links {
+		if link.NoteId == noteId {
+			links = append(links, link)
+		}
+	}
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].CreatedAt.After(links[j].CreatedAt)
+	})
+	return links, nil
+}
+
+func (r *PublicLinksRepository) Delete(noteId uuid.UUID, id uuid.UUID) error {
+	r.notes.mu.Lock()
+	defer r.notes.mu.Unlock()
+
+	link, ok := r.notes.links[id]
+	if !ok || link.NoteId != noteId {
+		return repository.ErrNotFound
+	}
+	delete(r.notes.links, id)
+	return nil
+}
+
+func (r *PublicLinksRepository) RecordView(id uuid.UUID, viewedAt time.Time) error {
+	r.notes.mu.Lock()
+	defer r.notes.mu.Unlock()
+
+	link, ok := r.notes.links[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	link.Views++
+	link.LastViewedAt = &viewedAt
+	r.notes.links[id] = link
+	return nil
+}
+
+func (r *PublicLinksRepository) DeleteExpired(before time.Time) (int64, error) {
+	r.notes.mu.Lock()
+	defer r.notes.mu.Unlock()
+
+	var deleted int64
+	for id, link := range r.notes.links {
+		if link.ExpiresAt != nil && link.ExpiresAt.Before(before) {
+			delete(r.notes.links, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
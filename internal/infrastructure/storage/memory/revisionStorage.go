@@ -0,0 +1,65 @@
+package memory
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"github.com/google/uuid"
+	"time"
+)
+
+// RevisionsRepository reads the revisions kept by a memory NotesRepository, oldest first in the slice.
+type RevisionsRepository struct {
+	notes *NotesRepository
+}
+
+func NewRevisionsRepository(notes *NotesRepository) *RevisionsRepository {
+	return &RevisionsRepository{notes: notes}
+}
+
+func (r *RevisionsRepository) GetAllByNoteId(noteId uuid.UUID) ([]models.NoteRevision, error) {
+	r.notes.mu.RLock()
+	defer r.notes.mu.RUnlock()
+
+	stored := r.notes.revisions[noteId]
+	revisions := make([]models.NoteRevision, 0, len(stored))
+	for i := len(stored) - 1; i >= 0; i-- {
+		revisions = append(revisions, stored[i])
+	}
+
+	return revisions, nil
+}
+
+func (r *RevisionsRepository) Get(noteId uuid.UUID, revision int) (models.NoteRevision, error) {
+	r.notes.mu.RLock()
+	defer r.notes.mu.RUnlock()
+
+	for _, rev := range r.notes.revisions[noteId] {
+		if rev.Revision == revision {
+			return rev, nil
+		}
+	}
+
+	return models.NoteRevision{}, repository.ErrNotFound
+}
+
+func (r *RevisionsRepository) Prune(keepLast int, olderThan time.Time) (int64, error) {
+	r.notes.mu.Lock()
+	defer r.notes.mu.Unlock()
+
+	var removed int64
+	for noteId, revisions := range r.notes.revisions {
+		kept := make([]models.NoteRevision, 0, len(revisions))
+		for i, rev := range revisions {
+			fromEnd := len(revisions) - i
+			expired := (keepLast > 0 && fromEnd > keepLast) || (!olderThan.IsZero() && rev.CreatedAt.Before(olderThan))
+			if fromEnd > 1 && expired {
+				removed++
+				continue
+			}
+			kept = append(kept, rev)
+		}
+		r.notes.revisions[noteId] = kept
+	}
+
+	return removed, nil
+}
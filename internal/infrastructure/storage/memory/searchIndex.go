@@ -0,0 +1,219 @@
+package memory
+
+import (
+	"2/internal/domain/models"
+	"github.com/google/uuid"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	snippetWords = 24
+	// title hits weigh ten times more than content ones, same as the sqlite backend
+	titleWeight = 10
+	bm25K1      = 1.2
+	bm25B       = 0.75
+)
+
+type posting struct {
+	title   int
+	content int
+}
+
+// searchIndex is an inverted index over note titles and contents for the
+// memory backend, which has no full-text engine of its own. It is guarded by
+// the mutex of the NotesRepository that owns it.
+type searchIndex struct {
+	postings    map[string]map[uuid.UUID]posting
+	terms       map[uuid.UUID][]string
+	lengths     map[uuid.UUID]int
+	totalLength int
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		postings: make(map[string]map[uuid.UUID]posting),
+		terms:    make(map[uuid.UUID][]string),
+		lengths:  make(map[uuid.UUID]int),
+	}
+}
+
+func (idx *searchIndex) add(note models.Note) {
+	idx.remove(note.ID)
+
+	counts := make(map[string]posting)
+	length := 0
+	for _, span := range tokenize(note.Title) {
+		p := counts[span.term]
+		p.title++
+		counts[span.term] = p
+		length++
+	}
+	for _, span := range tokenize(note.Content) {
+		p := counts[span.term]
+		p.content++
+		counts[span.term] = p
+		length++
+	}
+
+	terms := make([]string, 0, len(counts))
+	for term, p := range counts {
+		if idx.postings[term] == nil {
+			idx.postings[term] = make(map[uuid.UUID]posting)
+		}
+		idx.postings[term][note.ID] = p
+		terms = append(terms, term)
+	}
+
+	idx.terms[note.ID] = terms
+	idx.lengths[note.ID] = length
+	idx.totalLength += length
+}
+
+func (idx *searchIndex) remove(id uuid.UUID) {
+	for _, term := range idx.terms[id] {
+		delete(idx.postings[term], id)
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+
+	idx.totalLength -= idx.lengths[id]
+	delete(idx.terms, id)
+	delete(idx.lengths, id)
+}
+
+// search ranks with BM25 the notes containing every word of the query.
+func (idx *searchIndex) search(notes map[uuid.UUID]models.Note, userId uuid.UUID, query string, limit int) []models.SearchResult {
+	var queryTerms []string
+	for _, span := range tokenize(query) {
+		queryTerms = append(queryTerms, span.term)
+	}
+	if len(queryTerms) == 0 || len(idx.lengths) == 0 {
+		return []models.SearchResult{}
+	}
+
+	docs := float64(len(idx.lengths))
+	avgLength := float64(idx.totalLength) / docs
+	scores := make(map[uuid.UUID]float64)
+	for i, term := range queryTerms {
+		postings := idx.postings[term]
+		idf := math.Log(1 + (docs-float64(len(postings))+0.5)/(float64(len(postings))+0.5))
+
+		matched := make(map[uuid.UUID]float64)
+		for id, p := range postings {
+			if note := notes[id]; note.UserId != userId || note.DeletedAt != nil {
+				continue
+			}
+			if _, ok := scores[id]; i > 0 && !ok {
+				continue
+			}
+			tf := float64(titleWeight*p.title + p.content)
+			norm := bm25K1 * (1 - bm25B + bm25B*float64(idx.lengths[id])/avgLength)
+			matched[id] = scores[id] + idf*tf*(bm25K1+1)/(tf+norm)
+		}
+		scores = matched
+	}
+
+	terms := make(map[string]bool, len(queryTerms))
+	for _, term := range queryTerms {
+		terms[term] = true
+	}
+
+	results := make([]models.SearchResult, 0, len(scores))
+	for id, score := range scores {
+		note := notes[id]
+		results = append(results, models.SearchResult{
+			Note:           copyNote(note),
+			Rank:           score,
+			TitleHighlight: highlight(note.Title, terms),
+			Snippet:        snippet(note.Content, terms),
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Rank > results[j].Rank
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+type tokenSpan struct {
+	term       string
+	start, end int
+}
+
+// tokenize splits text into lower-cased words made of letters and digits.
+func tokenize(text string) []tokenSpan {
+	var spans []tokenSpan
+	start := -1
+	for i, r := range text {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if word && start < 0 {
+			start = i
+		}
+		if !word && start >= 0 {
+			spans = append(spans, tokenSpan{term: strings.ToLower(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, tokenSpan{term: strings.ToLower(text[start:]), start: start, end: len(text)})
+	}
+	return spans
+}
+
+func highlight(text string, terms map[string]bool) string {
+	return mark(text, tokenize(text), terms, 0, len(text))
+}
+
+// snippet cuts a window of words around the first match in text and highlights it.
+func snippet(text string, terms map[string]bool) string {
+	spans := tokenize(text)
+	if len(spans) <= snippetWords {
+		return highlight(text, terms)
+	}
+
+	first := 0
+	for i, span := range spans {
+		if terms[span.term] {
+			first = i
+			break
+		}
+	}
+
+	from := max(first-snippetWords/3, 0)
+	to := min(from+snippetWords, len(spans)) - 1
+	from = max(to+1-snippetWords, 0)
+
+	start, end := spans[from].start, spans[to].end
+	result := mark(text, spans[from:to+1], terms, start, end)
+	if start > 0 {
+		result = "…" + result
+	}
+	if end < len(text) {
+		result += "…"
+	}
+	return result
+}
+
+func mark(text string, spans []tokenSpan, terms map[string]bool, start int, end int) string {
+	var b strings.Builder
+	pos := start
+	for _, span := range spans {
+		if !terms[span.term] {
+			continue
+		}
+		b.WriteString(text[pos:span.start])
+		b.WriteString(models.HighlightStart)
+		b.WriteString(text[span.start:span.end])
+		b.WriteString(models.HighlightStop)
+		pos = span.end
+	}
+	b.WriteString(text[pos:end])
+	return b.String()
+}
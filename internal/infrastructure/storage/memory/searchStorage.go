@@ -0,0 +1,22 @@
+package memory
+
+import (
+	"2/internal/domain/models"
+	"github.com/google/uuid"
+)
+
+// SearchRepository searches the inverted index kept by a memory NotesRepository.
+type SearchRepository struct {
+	notes *NotesRepository
+}
+
+func NewSearchRepository(notes *NotesRepository) *SearchRepository {
+	return &SearchRepository{notes: notes}
+}
+
+func (r *SearchRepository) Search(userId uuid.UUID, query string, limit int) ([]models.SearchResult, error) {
+	r.notes.mu.RLock()
+	defer r.notes.mu.RUnlock()
+
+	return r.notes.index.search(r.notes.notes, userId, query, limit), nil
+}
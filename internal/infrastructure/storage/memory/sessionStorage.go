@@ -0,0 +1,181 @@
+package memory
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"errors"
+	"github.com/google/uuid"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SessionsRepository keeps sessions and their refresh tokens in process memory.
+type SessionsRepository struct {
+	mu       sync.RWMutex
+	sessions map[uuid.UUID]models.Session
+	// tokens are keyed by hash, the only thing a client can present
+	tokens map[string]models.RefreshToken
+}
+
+func NewSessionsRepository() *SessionsRepository {
+	return &SessionsRepository{
+		sessions: make(map[uuid.UUID]models.Session),
+		tokens:   make(map[string]models.RefreshToken),
+	}
+}
+
+func (r *SessionsRepository) Create(session models.Session, token models.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sessions[session.ID]; ok {
+		return errors.New("duplicate session id")
+	}
+	if _, ok := r.tokens[token.TokenHash]; ok {
+		return errors.New("duplicate refresh token")
+	}
+
+	r.sessions[session.ID] = session
+	r.tokens[token.TokenHash] = token
+	return nil
+}
+
+func (r *SessionsRepository) Get(id uuid.UUID) (models.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	session, ok := r.sessions[id]
+	if !ok {
+		return models.Session{}, repository.ErrNotFound
+	}
+	return session, nil
+}
+
+func (r *SessionsRepository) GetAllByUserId(userId uuid.UUID) ([]models.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sessions := []models.Session{}
+	for _, session := range r.sessions {
+		if session.UserId == userId {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+
+	return sessions, nil
+}
+
+func (r *SessionsRepository) Touch(lastSeen map[uuid.UUID]time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, at := range lastSeen {
+		session, ok := r.sessions[id]
+		if ok && session.LastSeenAt.Before(at) {
+			session.LastSeenAt = at
+			r.sessions[id] = session
+		}
+	}
+	return nil
+}
+
+func (r *SessionsRepository) Revoke(id uuid.UUID, at time.Time) error {
+	return r.revoke(func(session models.Session) bool {
+		return session.ID == id
+	}, at)
+}
+
+func (r *SessionsRepository) RevokeAllByUserId(userId uuid.UUID, at time.Time) error {
+	return r.revoke(func(session models.Session) bool {
+		return session.UserId == userId
+	}, at)
+}
+
+func (r *SessionsRepository) revoke(match func(models.Session) bool, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, session := range r.sessions {
+		if session.RevokedAt == nil && match(session) {
+			session.RevokedAt = &at
+			r.sessions[id] = session
+		}
+	}
+	return nil
+}
+
+func (r *SessionsRepository) GetRefreshToken(tokenHash string) (models.RefreshToken, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	token, ok := r.tokens[tokenHash]
+	if !ok {
+		return models.RefreshToken{}, repository.ErrNotFound
+	}
+	return token, nil
+}
+
+func (r *SessionsRepository) Rotate(oldId uuid.UUID, token models.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for hash, old := range r.tokens {
+		if old.ID != oldId {
+			continue
+		}
+		if old.UsedAt != nil {
+			return repository.ErrNotFound
+		}
+
+		old.UsedAt = &token.CreatedAt
+		r.tokens[hash] = old
+		r.tokens[token.TokenHash] = token
+
+		session := r.sessions[token.SessionId]
+		session.ExpiresAt = token.ExpiresAt
+		r.sessions[token.SessionId] = session
+		return nil
+	}
+
+	return repository.ErrNotFound
+}
+
+func (r *SessionsRepository) DeleteExpired(before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	for id, session := range r.sessions {
+		if session.ExpiresAt.Before(before) || session.RevokedAt != nil && session.RevokedAt.Before(before) {
+			delete(r.sessions, id)
+			deleted++
+		}
+	}
+	for hash, token := range r.tokens {
+		if _, ok := r.sessions[token.SessionId]; !ok {
+			delete(r.tokens, hash)
+		}
+	}
+
+	return deleted, nil
+}
+
+func (r *SessionsRepository) deleteUserData(userId uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, session := range r.sessions {
+		if session.UserId == userId {
+			delete(r.sessions, id)
+		}
+	}
+	for hash, token := range r.tokens {
+		if _, ok := r.sessions[token.SessionId]; !ok {
+			delete(r.tokens, hash)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package memory
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"github.com/google/uuid"
+	"sort"
+)
+
+// SharesRepository works on the shares kept inside a memory NotesRepository, names and
+// emails come from the memory UserRepository.
+type SharesRepository struct {
+	notes *NotesRepository
+	users *UserRepository
+}
+
+func NewSharesRepository(notes *NotesRepository, users *UserRepository) *SharesRepository {
+	return &SharesRepository{notes: notes, users: users}
+}
+
+func (r *SharesRepository) Save(share models.NoteShare) error {
+	r.notes.mu.Lock()
+	defer r.notes.mu.Unlock()
+
+	if _, ok := r.notes.notes[share.NoteId]; !ok {
+		return repository.ErrNotFound
+	}
+
+	shares := r.notes.shares[share.NoteId]
+	if shares == nil {
+		shares = make(map[uuid.UUID]models.NoteShare)
+		r.notes.shares[share.NoteId] = shares
+	}
+	if current, ok := shares[share.UserId]; ok {
+		share.CreatedAt = current.CreatedAt
+	}
+	share.Email, share.Username = "", ""
+	shares[share.UserId] = share
+	return nil
+}
+
+func (r *SharesRepository) Get(noteId uuid.UUID, userId uuid.UUID) (models.NoteShare, error) {
+	r.notes.mu.RLock()
+	share, ok := r.notes.shares[noteId][userId]
+	r.notes.mu.RUnlock()
+
+	if !ok {
+		return models.NoteShare{}, repository.ErrNotFound
+	}
+	return r.withUser(share), nil
+}
+
+func (r *SharesRepository) GetAllByNoteId(noteId uuid.UUID) ([]models.NoteShare, error) {
+	r.notes.mu.RLock()
+	shares := []models.NoteShare{}
+	for _, share := range r.notes.shares[noteId] {
+		shares = append(shares, share)
+	}
+	r.notes.mu.RUnlock()
+
+	// пользователей читаем уже без блокировки заметок: Delete пользователя берёт их в обратном порядке
+	for i := range shares {
+		shares[i] = r.withUser(shares[i])
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		if !shares[i].CreatedAt.Equal(shares[j].CreatedAt) {
+			return shares[i].CreatedAt.Before(shares[j].CreatedAt)
+		}
+		return shares[i].Email < shares[j].Email
+	})
+	return shares, nil
+}
+
+func (r *SharesRepository) GetSharedWith(userId uuid.UUID) ([]models.SharedNote, error) {
+	r.notes.mu.RLock()
+	shared := []models.SharedNote{}
+	for noteId, shares := range r.notes.shares {
+		share, ok := shares[userId]
+		if !ok {
+			continue
+		}
+		note, ok := r.notes.live(noteId)
+		if !ok {
+			continue
+		}
+		shared = append(shared, models.SharedNote{
+			Note:       copyNote(note),
+			Permission: share.Permission,
+			SharedAt:   share.CreatedAt,
+		})
+	}
+	r.notes.mu.RUnlock()
+
+	for i := range shared {
+		if owner, err := r.users.GetUserById(shared[i].UserId); err == nil {
+			shared[i].OwnerEmail = owner.Email
+		}
+	}
+	sort.Slice(shared, func(i, j int) bool {
+		if !shared[i].UpdatedAt.Equal(shared[j].UpdatedAt) {
+			return shared[i].UpdatedAt.After(shared[j].UpdatedAt)
+		}
+		return shared[i].ID.String() < shared[j].ID.String()
+	})
+	return shared, nil
+}
+
+func (r *SharesRepository) Delete(noteId uuid.UUID, userId uuid.UUID) error {
+	r.notes.mu.Lock()
+	defer r.notes.mu.Unlock()
+
+	if _, ok := r.notes.shares[noteId][userId]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(r.notes.shares[noteId], userId)
+	return nil
+}
+
+func (r *SharesRepository) withUser(share models.NoteShare) models.NoteShare {
+	if user, err := r.users.GetUserById(share.UserId); err == nil {
+		share.Email = user.Email
+		share.Username = user.Username
+	}
+	return share
+}
@@ -0,0 +1,73 @@
+package memory
+
+import (
+	"2/internal/domain/models"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SigningKeysRepository keeps signing keys in process memory, tokens issued before
+// a restart cannot be verified after it.
+type SigningKeysRepository struct {
+	mu   sync.Mutex
+	keys map[string]models.SigningKey
+}
+
+func NewSigningKeysRepository() *SigningKeysRepository {
+	return &SigningKeysRepository{
+		keys: make(map[string]models.SigningKey),
+	}
+}
+
+func (r *SigningKeysRepository) GetAll() ([]models.SigningKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := make([]models.SigningKey, 0, len(r.keys))
+	for _, key := range r.keys {
+		key.PrivateKey = slices.Clone(key.PrivateKey)
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+func (r *SigningKeysRepository) Create(key models.SigningKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key.PrivateKey = slices.Clone(key.PrivateKey)
+	r.keys[key.ID] = key
+	return nil
+}
+
+func (r *SigningKeysRepository) Expire(id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.keys[id]
+	if !ok || key.ExpiresAt != nil {
+		return nil
+	}
+	key.ExpiresAt = &at
+	r.keys[id] = key
+	return nil
+}
+
+func (r *SigningKeysRepository) DeleteExpired(before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	for id, key := range r.keys {
+		if key.ExpiresAt != nil && key.ExpiresAt.Before(before) {
+			delete(r.keys, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
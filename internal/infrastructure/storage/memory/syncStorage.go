@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"2/internal/domain/models"
+	"github.com/google/uuid"
+	"slices"
+	"time"
+)
+
+// nextSeqLocked takes the next number of the user's change feed, the caller holds the write lock.
+func (s *NotesRepository) nextSeqLocked(userId uuid.UUID) int64 {
+	s.changeSeq[userId]++
+	return s.changeSeq[userId]
+}
+
+// touchLocked puts the note at the end of its owner's change feed, the caller holds the write lock.
+func (s *NotesRepository) touchLocked(note models.Note) {
+	s.noteSeq[note.ID] = s.nextSeqLocked(note.UserId)
+}
+
+// buryLocked leaves a tombstone for a note that is about to be removed, a zero seq keeps
+// the number the note got when it was trashed. The caller holds the write lock.
+func (s *NotesRepository) buryLocked(note models.Note, seq int64) {
+	if seq == 0 {
+		seq = s.noteSeq[note.ID]
+	}
+	deletedAt := time.Now()
+	if note.DeletedAt != nil {
+		deletedAt = *note.DeletedAt
+	}
+	s.tombstones[note.ID] = models.NoteChange{Seq: seq, NoteId: note.ID, DeletedAt: &deletedAt}
+	s.tombstoneOwners[note.ID] = note.UserId
+	delete(s.noteSeq, note.ID)
+}
+
+func (s *NotesRepository) Changes(userId uuid.UUID, after models.SyncCursor, limit int) ([]models.NoteChange, int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var changes []models.NoteChange
+	for id, note := range s.notes {
+		if note.UserId != userId {
+			continue
+		}
+		change := models.NoteChange{Seq: s.noteSeq[id], NoteId: id, DeletedAt: note.DeletedAt}
+		if note.DeletedAt == nil {
+			note = copyNote(note)
+			change.Note = &note
+		}
+		changes = append(changes, change)
+	}
+	for id, tombstone := range s.tombstones {
+		if s.tombstoneOwners[id] == userId {
+			changes = append(changes, tombstone)
+		}
+	}
+
+	changes = slices.DeleteFunc(changes, func(c models.NoteChange) bool {
+		return !after.Before(c.Cursor())
+	})
+	slices.SortFunc(changes, func(a, b models.NoteChange) int {
+		if a.Cursor().Before(b.Cursor()) {
+			return -1
+		}
+		return 1
+	})
+	if len(changes) > limit {
+		changes = changes[:limit]
+	}
+	return changes, s.prunedSeq[userId], nil
+}
+
+func (s *NotesRepository) PurgeTombstones(olderThan time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	for id, tombstone := range s.tombstones {
+		if !tombstone.DeletedAt.Before(olderThan) {
+			continue
+		}
+		userId := s.tombstoneOwners[id]
+		s.prunedSeq[userId] = max(s.prunedSeq[userId], tombstone.Seq)
+		delete(s.tombstones, id)
+		delete(s.tombstoneOwners, id)
+		purged++
+	}
+	return purged, nil
+}
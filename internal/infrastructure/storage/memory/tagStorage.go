@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"fmt"
+	"github.com/google/uuid"
+	"slices"
+	"sort"
+)
+
+// TagsRepository works directly on the tags kept inside the notes of a memory NotesRepository.
+type TagsRepository struct {
+	notes *NotesRepository
+}
+
+func NewTagsRepository(notes *NotesRepository) *TagsRepository {
+	return &TagsRepository{notes: notes}
+}
+
+func (r *TagsRepository) GetAllByUserId(userId uuid.UUID) ([]models.TagCount, error) {
+	r.notes.mu.RLock()
+	defer r.notes.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, note := range r.notes.notes {
+		if note.UserId != userId || note.DeletedAt != nil {
+			continue
+		}
+		for _, tag := range note.Tags {
+			counts[tag]++
+		}
+	}
+
+	tags := make([]models.TagCount, 0, len(counts))
+	for name, count := range counts {
+		tags = append(tags, models.TagCount{Name: name, Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Name < tags[j].Name
+	})
+
+	return tags, nil
+}
+
+func (r *TagsRepository) Rename(userId uuid.UUID, from string, to string) error {
+	r.notes.mu.Lock()
+	defer r.notes.mu.Unlock()
+
+	found := false
+	for _, note := range r.notes.notes {
+		if note.UserId != userId {
+			continue
+		}
+		if slices.Contains(note.Tags, to) {
+			return fmt.Errorf("tag %s already exists, merge the tags instead", to)
+		}
+		if slices.Contains(note.Tags, from) {
+			found = true
+		}
+	}
+	if !found {
+		return repository.ErrNotFound
+	}
+
+	r.replace(userId, []string{from}, to)
+	return nil
+}
+
+func (r *TagsRepository) Merge(userId uuid.UUID, sources []string, target string) error {
+	r.notes.mu.Lock()
+	defer r.notes.mu.Unlock()
+
+	for _, source := range sources {
+		found := false
+		for _, note := range r.notes.notes {
+			if note.UserId == userId && slices.Contains(note.Tags, source) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return repository.ErrNotFound
+		}
+	}
+
+	r.replace(userId, sources, target)
+	return nil
+}
+
+// replace swaps sources for target on every note of the user, the caller holds the write lock.
+func (r *TagsRepository) replace(userId uuid.UUID, sources []string, target string) {
+	var seq int64
+	for id, note := range r.notes.notes {
+		if note.UserId != userId {
+			continue
+		}
+
+		tags := make([]string, 0, len(note.Tags))
+		changed := false
+		for _, tag := range note.Tags {
+			if slices.Contains(sources, tag) {
+				tag = target
+				changed = true
+			}
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+		if changed {
+			sort.Strings(tags)
+			note.Tags = tags
+			r.notes.notes[id] = note
+
+			// все заметки, которых коснулась замена, меняются в ленте синхронизации одним номером
+			if seq == 0 {
+				seq = r.notes.nextSeqLocked(userId)
+			}
+			r.notes.noteSeq[id] = seq
+		}
+	}
+}
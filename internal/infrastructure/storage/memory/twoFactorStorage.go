@@ -0,0 +1,136 @@
+package memory
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+type recoveryCode struct {
+	hash string
+	used bool
+}
+
+// TwoFactorRepository keeps TOTP secrets, recovery codes and used mfa tokens in process memory.
+type TwoFactorRepository struct {
+	mu    sync.Mutex
+	totp  map[uuid.UUID]models.TOTP
+	codes map[uuid.UUID][]recoveryCode
+	// usedTokens maps ids of used mfa tokens to their expiry
+	usedTokens map[uuid.UUID]time.Time
+}
+
+func NewTwoFactorRepository() *TwoFactorRepository {
+	return &TwoFactorRepository{
+		totp:       make(map[uuid.UUID]models.TOTP),
+		codes:      make(map[uuid.UUID][]recoveryCode),
+		usedTokens: make(map[uuid.UUID]time.Time),
+	}
+}
+
+func (r *TwoFactorRepository) GetTOTP(userId uuid.UUID) (models.TOTP, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	totp, ok := r.totp[userId]
+	if !ok {
+		return models.TOTP{}, repository.ErrNotFound
+	}
+	return totp, nil
+}
+
+func (r *TwoFactorRepository) SaveTOTP(totp models.TOTP) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.totp[totp.UserId] = totp
+	return nil
+}
+
+func (r *TwoFactorRepository) DeleteTOTP(userId uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.totp, userId)
+	delete(r.codes, userId)
+	return nil
+}
+
+func (r *TwoFactorRepository) UseStep(userId uuid.UUID, step int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	totp, ok := r.totp[userId]
+	if !ok || totp.LastStep >= step {
+		return repository.ErrNotFound
+	}
+
+	totp.LastStep = step
+	r.totp[userId] = totp
+	return nil
+}
+
+func (r *TwoFactorRepository) Enable(userId uuid.UUID, confirmedAt time.Time, codeHashes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	totp, ok := r.totp[userId]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	totp.Enabled = true
+	totp.ConfirmedAt = &confirmedAt
+	r.totp[userId] = totp
+
+	codes := make([]recoveryCode, len(codeHashes))
+	for i, hash := range codeHashes {
+		codes[i] = recoveryCode{hash: hash}
+	}
+	r.codes[userId] = codes
+	return nil
+}
+
+func (r *TwoFactorRepository) UseRecoveryCode(userId uuid.UUID, codeHash string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	codes := r.codes[userId]
+	for i := range codes {
+		if codes[i].hash == codeHash && !codes[i].used {
+			codes[i].used = true
+			return nil
+		}
+	}
+	return repository.ErrNotFound
+}
+
+func (r *TwoFactorRepository) UseMFAToken(id uuid.UUID, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.usedTokens[id]; ok {
+		return repository.ErrNotFound
+	}
+	r.usedTokens[id] = expiresAt
+	return nil
+}
+
+func (r *TwoFactorRepository) DeleteUsedMFATokens(before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int64
+	for id, expiresAt := range r.usedTokens {
+		if expiresAt.Before(before) {
+			delete(r.usedTokens, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *TwoFactorRepository) deleteUserData(userId uuid.UUID) {
+	r.DeleteTOTP(userId)
+}
@@ -0,0 +1,192 @@
+package memory
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"errors"
+	"github.com/google/uuid"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// userData is a memory repository with rows owned by users, Delete clears them
+// the way ON DELETE CASCADE does in the database.
+type userData interface {
+	deleteUserData(userId uuid.UUID)
+}
+
+// UserRepository keeps users in process memory. Everything is lost on restart.
+type UserRepository struct {
+	mu    sync.RWMutex
+	users map[uuid.UUID]models.User
+	owned []userData
+}
+
+func NewUserRepository(owned ...userData) *UserRepository {
+	return &UserRepository{
+		users: make(map[uuid.UUID]models.User),
+		owned: owned,
+	}
+}
+
+func (r *UserRepository) Create(user models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, u := range r.users {
+		if u.Email == user.Email {
+			return errors.New("duplicate email")
+		}
+	}
+
+	user.Created = time.Now()
+	r.users[user.UserId] = user
+	return nil
+}
+
+func (r *UserRepository) GetUserByEmail(email string) (models.User, bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, u := range r.users {
+		if u.Email == email {
+			return u, true, nil
+		}
+	}
+
+	return models.User{}, false, nil
+}
+
+func (r *UserRepository) GetUserById(id uuid.UUID) (models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return models.User{}, repository.ErrNotFound
+	}
+
+	return user, nil
+}
+
+func (r *UserRepository) UpdatePassword(id uuid.UUID, passwordHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	user.Password = passwordHash
+	r.users[id] = user
+	return nil
+}
+
+func (r *UserRepository) SetEmailVerified(id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	user.EmailVerified = true
+	r.users[id] = user
+	return nil
+}
+
+func (r *UserRepository) UpdateProfile(user models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.users[user.UserId]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	for id, u := range r.users {
+		if id != user.UserId && u.Email == user.Email {
+			return errors.New("duplicate email")
+		}
+	}
+
+	current.Username = user.Username
+	current.Email = user.Email
+	current.EmailVerified = user.EmailVerified
+	r.users[user.UserId] = current
+	return nil
+}
+
+func (r *UserRepository) Find(filter models.UserFilter) ([]models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	query := strings.ToLower(filter.Query)
+	users := []models.User{}
+	for _, u := range r.users {
+		if query != "" && !strings.Contains(strings.ToLower(u.Email), query) && !strings.Contains(strings.ToLower(u.Username), query) {
+			continue
+		}
+		if filter.Role != "" && u.Role != filter.Role {
+			continue
+		}
+		if filter.Disabled != nil && u.Disabled() != *filter.Disabled {
+			continue
+		}
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if !users[i].Created.Equal(users[j].Created) {
+			return users[i].Created.After(users[j].Created)
+		}
+		return users[i].UserId.String() < users[j].UserId.String()
+	})
+
+	users = users[min(filter.Offset, len(users)):]
+	if filter.Limit > 0 && len(users) > filter.Limit {
+		users = users[:filter.Limit]
+	}
+	return users, nil
+}
+
+func (r *UserRepository) SetRole(id uuid.UUID, role string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	user.Role = role
+	r.users[id] = user
+	return nil
+}
+
+func (r *UserRepository) SetDisabled(id uuid.UUID, at *time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	user.DisabledAt = at
+	r.users[id] = user
+	return nil
+}
+
+func (r *UserRepository) Delete(id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[id]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(r.users, id)
+
+	for _, data := range r.owned {
+		data.deleteUserData(id)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+// UserTokensRepository keeps the records of emailed tokens in process memory.
+type UserTokensRepository struct {
+	mu     sync.Mutex
+	tokens map[uuid.UUID]models.UserToken
+}
+
+func NewUserTokensRepository() *UserTokensRepository {
+	return &UserTokensRepository{
+		tokens: make(map[uuid.UUID]models.UserToken),
+	}
+}
+
+func (r *UserTokensRepository) Create(token models.UserToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tokens[token.ID] = token
+	return nil
+}
+
+func (r *UserTokensRepository) Use(id uuid.UUID, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.tokens[id]
+	if !ok || token.UsedAt != nil || !token.ExpiresAt.After(at) {
+		return repository.ErrNotFound
+	}
+
+	token.UsedAt = &at
+	r.tokens[id] = token
+	return nil
+}
+
+func (r *UserTokensRepository) DeleteExpired(before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	for id, token := range r.tokens {
+		if token.ExpiresAt.Before(before) {
+			delete(r.tokens, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *UserTokensRepository) deleteUserData(userId uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, token := range r.tokens {
+		if token.UserId == userId {
+			delete(r.tokens, id)
+		}
+	}
+}
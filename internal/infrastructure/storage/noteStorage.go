@@ -2,78 +2,177 @@ package storage
 
 import (
 	"2/internal/domain/models"
+	"2/internal/domain/repository"
 	"database/sql"
 	"errors"
 	"fmt"
 	"github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
+	"slices"
 	"time"
 )
 
+var noteColumns = []string{"id", "user_id", "notebook_id", "title", "content", "created_at", "updated_at", "version"}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanNote(row rowScanner) (models.Note, error) {
+	var note models.Note
+	err := row.Scan(
+		&note.ID,
+		&note.UserId,
+		&note.NotebookId,
+		&note.Title,
+		&note.Content,
+		&note.CreatedAt,
+		&note.UpdatedAt,
+		&note.Version)
+	return note, err
+}
+
 type NotesRepository struct {
-	Db *sql.DB
+	Db      *sql.DB
+	dialect Dialect
 }
 
-func NewNotesRepository(db *sql.DB) *NotesRepository {
+func NewNotesRepository(db *sql.DB, dialect Dialect) *NotesRepository {
 	return &NotesRepository{
-		Db: db,
+		Db:      db,
+		dialect: dialect,
 	}
 }
 
 func (s *NotesRepository) Create(note models.Note) error {
 
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	seq, err := nextChangeSeq(tx, s.dialect, note.UserId)
+	if err != nil {
+		return err
+	}
+
 	query, args, err := squirrel.Insert("notes").
-		Columns("id", "user_id", "title", "content", "created_at", "updated_at").
-		Values(note.ID, note.UserId, note.Title, note.Content, note.CreatedAt, note.UpdatedAt).
-		PlaceholderFormat(squirrel.Dollar).
+		Columns(noteColumns...).
+		Columns("change_seq").
+		Values(note.ID, note.UserId, note.NotebookId, note.Title, note.Content, note.CreatedAt, note.UpdatedAt, note.Version, seq).
+		PlaceholderFormat(s.dialect.Placeholder).
 		ToSql()
 
 	if err != nil {
 		return err
 	}
-	_, err = s.Db.Query(query, args...)
+
+	_, err = tx.Exec(query, args...)
 	if err != nil {
 		return errors.New(fmt.Sprint("Error inserting note into database: ", err))
 	}
 
-	return nil
+	// заметку с тем же id могли удалить раньше, теперь она снова живая
+	query, args, err = squirrel.Delete("note_tombstones").
+		Where(squirrel.Eq{"note_id": note.ID}).
+		PlaceholderFormat(s.dialect.Placeholder).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(query, args...); err != nil {
+		return err
+	}
+
+	err = setNoteTags(tx, s.dialect, note.UserId, note.ID, note.Tags)
+	if err != nil {
+		return err
+	}
+
+	err = insertRevision(tx, s.dialect, note, note.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (s *NotesRepository) Get(id uuid.UUID) (models.Note, error) {
 
-	query, args, err := squirrel.Select("id", "user_id", "title", "content", "created_at", "updated_at").
-		From("notes").Where(squirrel.Eq{"id": id}).
-		PlaceholderFormat(squirrel.Dollar).
+	query, args, err := squirrel.Select(noteColumns...).
+		From("notes").Where(squirrel.Eq{"id": id, "deleted_at": nil}).
+		PlaceholderFormat(s.dialect.Placeholder).
 		ToSql()
 
 	if err != nil {
 		return models.Note{}, err
 	}
 
-	var note models.Note
-	row := s.Db.QueryRow(query, args...)
-	err = row.Scan(
-		&note.ID,
-		&note.UserId,
-		&note.Title,
-		&note.Content,
-		&note.CreatedAt,
-		&note.UpdatedAt)
-
+	note, err := scanNote(s.Db.QueryRow(query, args...))
+	if err == sql.ErrNoRows {
+		return models.Note{}, repository.ErrNotFound
+	}
 	if err != nil {
 		return models.Note{}, err
 	}
 
-	return note, nil
+	notes := []models.Note{note}
+	if err = loadNoteTags(s.Db, s.dialect, notes); err != nil {
+		return models.Note{}, err
+	}
+
+	return notes[0], nil
 }
 
 func (s *NotesRepository) GetAllByUserId(id uuid.UUID) ([]models.Note, error) {
+	return s.Find(id, models.NoteFilter{}, models.NotePage{})
+}
 
-	query, args, err := squirrel.Select("*").
+func (s *NotesRepository) Find(userId uuid.UUID, filter models.NoteFilter, page models.NotePage) ([]models.Note, error) {
+
+	builder := squirrel.Select(noteColumns...).
 		From("notes").
 		Where(squirrel.Eq{
-			"user_id": id,
-		}).PlaceholderFormat(squirrel.Dollar).ToSql()
+			"user_id":    userId,
+			"deleted_at": nil,
+		})
+
+	if len(filter.Tags) > 0 {
+		sub := squirrel.Select("nt.note_id").
+			From("note_tags nt").
+			Join("tags t ON t.id = nt.tag_id").
+			Where(squirrel.Eq{"t.user_id": userId, "t.name": filter.Tags})
+		if filter.MatchAllTags {
+			sub = sub.GroupBy("nt.note_id").Having("COUNT(*) = ?", len(filter.Tags))
+		}
+
+		subQuery, subArgs, err := sub.ToSql()
+		if err != nil {
+			return []models.Note{}, err
+		}
+		builder = builder.Where("id IN ("+subQuery+")", subArgs...)
+	}
+
+	if filter.NotebookId != nil {
+		builder = builder.Where(squirrel.Eq{"notebook_id": *filter.NotebookId})
+	}
+	if filter.CreatedAfter != nil {
+		builder = builder.Where(squirrel.Gt{"created_at": *filter.CreatedAfter})
+	}
+	if filter.CreatedBefore != nil {
+		builder = builder.Where(squirrel.Lt{"created_at": *filter.CreatedBefore})
+	}
+	if filter.UpdatedAfter != nil {
+		builder = builder.Where(squirrel.Gt{"updated_at": *filter.UpdatedAfter})
+	}
+	if filter.UpdatedBefore != nil {
+		builder = builder.Where(squirrel.Lt{"updated_at": *filter.UpdatedBefore})
+	}
+
+	builder = orderNotes(builder, page)
+
+	query, args, err := builder.PlaceholderFormat(s.dialect.Placeholder).ToSql()
 	if err != nil {
 		return []models.Note{}, err
 	}
@@ -86,14 +185,7 @@ func (s *NotesRepository) GetAllByUserId(id uuid.UUID) ([]models.Note, error) {
 
 	var notes []models.Note
 	for rows.Next() {
-		var note models.Note
-		err = rows.Scan(
-			&note.ID,
-			&note.UserId,
-			&note.Title,
-			&note.Content,
-			&note.CreatedAt,
-			&note.UpdatedAt)
+		note, err := scanNote(rows)
 		if err != nil {
 			return []models.Note{}, err
 		}
@@ -104,45 +196,421 @@ func (s *NotesRepository) GetAllByUserId(id uuid.UUID) ([]models.Note, error) {
 	if err != nil {
 		return []models.Note{}, err
 	}
+	rows.Close()
+
+	if err = loadNoteTags(s.Db, s.dialect, notes); err != nil {
+		return []models.Note{}, err
+	}
 	return notes, nil
 }
 
+// orderNotes sorts by the page field with id as a tie-breaker and seeks past the page cursor,
+// so every page is an index range scan instead of an OFFSET.
+func orderNotes(builder squirrel.SelectBuilder, page models.NotePage) squirrel.SelectBuilder {
+	column := string(models.NoteSortCreatedAt)
+	switch page.Sort {
+	case models.NoteSortUpdatedAt, models.NoteSortTitle:
+		column = string(page.Sort)
+	}
+
+	direction, cmp := "ASC", ">"
+	if page.Desc {
+		direction, cmp = "DESC", "<"
+	}
+
+	if c := page.After; c != nil {
+		var value interface{} = c.Time
+		if c.Sort == models.NoteSortTitle {
+			value = c.Title
+		}
+		builder = builder.Where(
+			fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", column, cmp),
+			value, value, c.ID)
+	}
+
+	builder = builder.OrderBy(column+" "+direction, "id "+direction)
+	if page.Limit > 0 {
+		builder = builder.Limit(uint64(page.Limit))
+	}
+	return builder
+}
+
+// Update saves the title, content and tags of the note and moves its version on. The write
+// only goes through while the note still has the version it was read with, a note changed
+// in between is reported as ErrVersionConflict.
 func (s *NotesRepository) Update(note models.Note) error {
 	prev, err := s.Get(note.ID)
 	if err != nil {
 		return err
 	}
+	if prev.Version != note.Version {
+		return repository.ErrVersionConflict
+	}
 
-	if prev.Content == note.Content && prev.Title == note.Title {
+	if prev.Content == note.Content && prev.Title == note.Title && slices.Equal(prev.Tags, note.Tags) {
 		return errors.New("There is no updates")
 	}
 
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	seq, err := nextChangeSeq(tx, s.dialect, prev.UserId)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
 	query, args, err := squirrel.Update("notes").
 		Set("title", note.Title).
 		Set("content", note.Content).
-		Set("updated_at", time.Now()).
-		Where(squirrel.Eq{"id": note.ID}).
-		PlaceholderFormat(squirrel.Dollar).
+		Set("updated_at", now).
+		Set("version", squirrel.Expr("version + 1")).
+		Set("change_seq", seq).
+		Where(squirrel.Eq{"id": note.ID, "deleted_at": nil, "version": note.Version}).
+		PlaceholderFormat(s.dialect.Placeholder).
 		ToSql()
 
 	if err != nil {
 		return err
 	}
 
-	_, err = s.Db.Exec(query, args...)
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	// prev мог устареть за это время, решает только условие на version
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		tx.Rollback()
+		if _, err = s.Get(note.ID); err != nil {
+			return err
+		}
+		return repository.ErrVersionConflict
+	}
+
+	err = setNoteTags(tx, s.dialect, prev.UserId, note.ID, note.Tags)
+	if err != nil {
+		return err
+	}
+
+	if prev.Content != note.Content || prev.Title != note.Title {
+		err = insertRevision(tx, s.dialect, note, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Move places the note into the notebook and moves its version on. A non-zero version must
+// still be the version of the note, ErrVersionConflict otherwise.
+func (s *NotesRepository) Move(id uuid.UUID, notebookId *uuid.UUID, version int64) error {
+	note, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+
+	pred := squirrel.Eq{"id": id, "deleted_at": nil}
+	if version != 0 {
+		pred["version"] = version
+	}
+	err = s.change(note.UserId, squirrel.Update("notes").
+		Set("notebook_id", notebookId).
+		Set("updated_at", time.Now()).
+		Set("version", squirrel.Expr("version + 1")).
+		Where(pred))
+	if errors.Is(err, repository.ErrNotFound) && version != 0 {
+		if _, err = s.Get(id); err != nil {
+			return err
+		}
+		return repository.ErrVersionConflict
+	}
 	return err
 }
 
+// change runs an update that must touch a note of the user and gives the note the next
+// number of the user's change feed.
+func (s *NotesRepository) change(userId uuid.UUID, update squirrel.UpdateBuilder) error {
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	seq, err := nextChangeSeq(tx, s.dialect, userId)
+	if err != nil {
+		return err
+	}
+
+	query, args, err := update.
+		Set("change_seq", seq).
+		Where(squirrel.Eq{"user_id": userId}).
+		PlaceholderFormat(s.dialect.Placeholder).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return repository.ErrNotFound
+	}
+
+	return tx.Commit()
+}
+
 func (s *NotesRepository) Delete(id uuid.UUID) error {
+	note, err := s.Get(id)
+	if err == repository.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	seq, err := nextChangeSeq(tx, s.dialect, note.UserId)
+	if err != nil {
+		return err
+	}
+	if _, err = removeNotes(tx, s.dialect, squirrel.Eq{"id": id}, seq); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
 
-	query, args, err := squirrel.Delete("notes").Where(squirrel.Eq{
-		"user_id": id,
-	}).PlaceholderFormat(squirrel.Dollar).ToSql()
+// Trash moves the note to the trash, a note already in the trash is reported as not found.
+// A non-zero version must still be the version of the note, ErrVersionConflict otherwise.
+func (s *NotesRepository) Trash(id uuid.UUID, deletedAt time.Time, version int64) error {
+	note, err := s.Get(id)
 	if err != nil {
 		return err
 	}
 
-	_, err = s.Db.Exec(query, args...)
+	pred := squirrel.Eq{"id": id, "deleted_at": nil}
+	if version != 0 {
+		pred["version"] = version
+	}
+	err = s.change(note.UserId, squirrel.Update("notes").
+		Set("deleted_at", deletedAt).
+		Where(pred))
+	// ни одной строки: заметку либо уже убрали, либо успели изменить
+	if errors.Is(err, repository.ErrNotFound) && version != 0 {
+		if _, err = s.Get(id); err != nil {
+			return err
+		}
+		return repository.ErrVersionConflict
+	}
 	return err
+}
+
+func (s *NotesRepository) GetTrash(userId uuid.UUID) ([]models.Note, error) {
+
+	query, args, err := squirrel.Select(noteColumns...).
+		Column("deleted_at").
+		From("notes").
+		Where(squirrel.Eq{"user_id": userId}).
+		Where(squirrel.NotEq{"deleted_at": nil}).
+		OrderBy("deleted_at DESC").
+		PlaceholderFormat(s.dialect.Placeholder).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.Db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
+	notes := []models.Note{}
+	for rows.Next() {
+		var note models.Note
+		err = rows.Scan(
+			&note.ID,
+			&note.UserId,
+			&note.NotebookId,
+			&note.Title,
+			&note.Content,
+			&note.CreatedAt,
+			&note.UpdatedAt,
+			&note.Version,
+			&note.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, note)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err = loadNoteTags(s.Db, s.dialect, notes); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+func (s *NotesRepository) Restore(userId uuid.UUID, id uuid.UUID) error {
+	return s.change(userId, squirrel.Update("notes").
+		Set("deleted_at", nil).
+		Where(squirrel.Eq{"id": id}).
+		Where(squirrel.NotEq{"deleted_at": nil}))
+}
+
+func (s *NotesRepository) EmptyTrash(userId uuid.UUID) (int64, error) {
+	return s.deleteTrashed(squirrel.Eq{"user_id": userId})
+}
+
+func (s *NotesRepository) CountByUsers(userIds []uuid.UUID) (map[uuid.UUID]models.NoteCounts, error) {
+	counts := make(map[uuid.UUID]models.NoteCounts)
+	if len(userIds) == 0 {
+		return counts, nil
+	}
+
+	query, args, err := squirrel.Select(
+		"user_id",
+		"SUM(CASE WHEN deleted_at IS NULL THEN 1 ELSE 0 END)",
+		"SUM(CASE WHEN deleted_at IS NULL THEN 0 ELSE 1 END)").
+		From("notes").
+		Where(squirrel.Eq{"user_id": userIds}).
+		GroupBy("user_id").
+		PlaceholderFormat(s.dialect.Placeholder).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.Db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userId uuid.UUID
+		var c models.NoteCounts
+		if err = rows.Scan(&userId, &c.Notes, &c.Trashed); err != nil {
+			return nil, err
+		}
+		counts[userId] = c
+	}
+	return counts, rows.Err()
+}
+
+func (s *NotesRepository) PurgeTrash(olderThan time.Time) (int64, error) {
+	return s.deleteTrashed(squirrel.Lt{"deleted_at": olderThan})
+}
+
+// deleteTrashed permanently removes trashed notes matching pred, tags and revisions go with them by cascade.
+func (s *NotesRepository) deleteTrashed(pred squirrel.Sqlizer) (int64, error) {
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// клиенты уже получили заметку удалённой, когда её убрали в корзину: номер остаётся тот же
+	n, err := removeNotes(tx, s.dialect, squirrel.And{squirrel.NotEq{"deleted_at": nil}, pred}, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	return n, tx.Commit()
+}
+
+// removeNotes deletes the notes matching pred and leaves tombstones for the change feed.
+// A zero seq keeps the number each note got when it was trashed.
+func removeNotes(tx *sql.Tx, dialect Dialect, pred squirrel.Sqlizer, seq int64) (int64, error) {
+	query, args, err := squirrel.Delete("notes").
+		Where(pred).
+		Suffix("RETURNING id, user_id, change_seq, deleted_at").
+		PlaceholderFormat(dialect.Placeholder).
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	type tombstone struct {
+		noteId, userId uuid.UUID
+		seq            int64
+		deletedAt      *time.Time
+	}
+	var removed []tombstone
+	for rows.Next() {
+		var t tombstone
+		if err = rows.Scan(&t.noteId, &t.userId, &t.seq, &t.deletedAt); err != nil {
+			return 0, err
+		}
+		removed = append(removed, t)
+	}
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	now := time.Now()
+	for batch := range slices.Chunk(removed, 500) {
+		insert := squirrel.Insert("note_tombstones").
+			Columns("note_id", "user_id", "change_seq", "deleted_at").
+			Suffix("ON CONFLICT (note_id) DO UPDATE SET user_id = excluded.user_id, change_seq = excluded.change_seq, deleted_at = excluded.deleted_at")
+		for _, t := range batch {
+			if seq != 0 {
+				t.seq = seq
+			}
+			if t.deletedAt == nil {
+				t.deletedAt = &now
+			}
+			insert = insert.Values(t.noteId, t.userId, t.seq, *t.deletedAt)
+		}
+
+		query, args, err = insert.PlaceholderFormat(dialect.Placeholder).ToSql()
+		if err != nil {
+			return 0, err
+		}
+		if _, err = tx.Exec(query, args...); err != nil {
+			return 0, err
+		}
+	}
+
+	return int64(len(removed)), nil
+}
+
+// execAffected runs a statement that must touch at least one row, ErrNotFound otherwise.
+func execAffected(db *sql.DB, query string, args []interface{}) error {
+	res, err := db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
 }
@@ -0,0 +1,265 @@
+package storage
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"database/sql"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"time"
+)
+
+var notebookColumns = []string{"id", "user_id", "parent_id", "name", "created_at", "updated_at"}
+
+type NotebooksRepository struct {
+	Db      *sql.DB
+	dialect Dialect
+}
+
+func NewNotebooksRepository(db *sql.DB, dialect Dialect) *NotebooksRepository {
+	return &NotebooksRepository{
+		Db:      db,
+		dialect: dialect,
+	}
+}
+
+func scanNotebook(row rowScanner) (models.Notebook, error) {
+	var notebook models.Notebook
+	err := row.Scan(
+		&notebook.ID,
+		&notebook.UserId,
+		&notebook.ParentId,
+		&notebook.Name,
+		&notebook.CreatedAt,
+		&notebook.UpdatedAt)
+	return notebook, err
+}
+
+func (r *NotebooksRepository) Create(notebook models.Notebook) error {
+
+	query, args, err := squirrel.Insert("notebooks").
+		Columns(notebookColumns...).
+		Values(notebook.ID, notebook.UserId, notebook.ParentId, notebook.Name, notebook.CreatedAt, notebook.UpdatedAt).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Db.Exec(query, args...)
+	return err
+}
+
+func (r *NotebooksRepository) Get(id uuid.UUID) (models.Notebook, error) {
+
+	query, args, err := squirrel.Select(notebookColumns...).
+		From("notebooks").
+		Where(squirrel.Eq{"id": id}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return models.Notebook{}, err
+	}
+
+	notebook, err := scanNotebook(r.Db.QueryRow(query, args...))
+	if err == sql.ErrNoRows {
+		return models.Notebook{}, repository.ErrNotFound
+	}
+	if err != nil {
+		return models.Notebook{}, err
+	}
+
+	return notebook, nil
+}
+
+func (r *NotebooksRepository) GetAllByUserId(userId uuid.UUID) ([]models.Notebook, error) {
+
+	query, args, err := squirrel.Select(notebookColumns...).
+		From("notebooks").
+		Where(squirrel.Eq{"user_id": userId}).
+		OrderBy("name").
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.Db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notebooks := []models.Notebook{}
+	for rows.Next() {
+		notebook, err := scanNotebook(rows)
+		if err != nil {
+			return nil, err
+		}
+		notebooks = append(notebooks, notebook)
+	}
+
+	return notebooks, rows.Err()
+}
+
+func (r *NotebooksRepository) Update(notebook models.Notebook) error {
+
+	query, args, err := squirrel.Update("notebooks").
+		Set("name", notebook.Name).
+		Set("parent_id", notebook.ParentId).
+		Set("updated_at", time.Now()).
+		Where(squirrel.Eq{"id": notebook.ID}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	res, err := r.Db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+// DeleteCascade moves the notes of the notebooks to the trash in one change of the user's feed
+// and removes the notebooks. Trashed notes leave the notebook, a restored note comes back at the
+// top level.
+func (r *NotebooksRepository) DeleteCascade(userId uuid.UUID, ids []uuid.UUID, deletedAt time.Time) ([]uuid.UUID, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	tx, err := r.Db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	seq, err := nextChangeSeq(tx, r.dialect, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := squirrel.Update("notes").
+		Set("deleted_at", deletedAt).
+		Set("notebook_id", nil).
+		Set("version", squirrel.Expr("version + 1")).
+		Set("change_seq", seq).
+		Where(squirrel.Eq{"user_id": userId, "notebook_id": ids, "deleted_at": nil}).
+		Suffix("RETURNING id").
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var trashed []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		trashed = append(trashed, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	// заметки, лежавшие в корзине раньше, клиенты уже видели удалёнными: только отвязываем
+	query, args, err = squirrel.Update("notes").
+		Set("notebook_id", nil).
+		Where(squirrel.Eq{"notebook_id": ids}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = tx.Exec(query, args...); err != nil {
+		return nil, err
+	}
+
+	query, args, err = squirrel.Delete("notebooks").
+		Where(squirrel.Eq{"id": ids}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = tx.Exec(query, args...); err != nil {
+		return nil, err
+	}
+
+	return trashed, tx.Commit()
+}
+
+// DeleteReparent hands the notes over to the parent the way Move does, with a new version and
+// one change of the user's feed for all of them. Notes in the trash only change the notebook.
+func (r *NotebooksRepository) DeleteReparent(notebook models.Notebook) error {
+	tx, err := r.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query, args, err := squirrel.Update("notebooks").
+		Set("parent_id", notebook.ParentId).
+		Where(squirrel.Eq{"parent_id": notebook.ID}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(query, args...); err != nil {
+		return err
+	}
+
+	seq, err := nextChangeSeq(tx, r.dialect, notebook.UserId)
+	if err != nil {
+		return err
+	}
+
+	query, args, err = squirrel.Update("notes").
+		Set("notebook_id", notebook.ParentId).
+		Set("updated_at", time.Now()).
+		Set("version", squirrel.Expr("version + 1")).
+		Set("change_seq", seq).
+		Where(squirrel.Eq{"notebook_id": notebook.ID, "deleted_at": nil}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(query, args...); err != nil {
+		return err
+	}
+
+	// заметки в корзине клиенты видят удалёнными, изменением их не считаем: только переносим,
+	// чтобы восстановленные вернулись к родителю
+	query, args, err = squirrel.Update("notes").
+		Set("notebook_id", notebook.ParentId).
+		Where(squirrel.Eq{"notebook_id": notebook.ID}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(query, args...); err != nil {
+		return err
+	}
+
+	query, args, err = squirrel.Delete("notebooks").
+		Where(squirrel.Eq{"id": notebook.ID}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(query, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"database/sql"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"time"
+)
+
+var publicLinkColumns = []string{"id", "note_id", "slug", "password_hash", "created_at", "expires_at", "views", "last_viewed_at"}
+
+func scanPublicLink(row rowScanner) (models.PublicLink, error) {
+	var link models.PublicLink
+	err := row.Scan(
+		&link.ID,
+		&link.NoteId,
+		&link.Slug,
+		&link.PasswordHash,
+		&link.CreatedAt,
+		&link.ExpiresAt,
+		&link.Views,
+		&link.LastViewedAt)
+	return link, err
+}
+
+type PublicLinksRepository struct {
+	Db      *sql.DB
+	dialect Dialect
+}
+
+func NewPublicLinksRepository(db *sql.DB, dialect Dialect) *PublicLinksRepository {
+	return &PublicLinksRepository{
+		Db:      db,
+		dialect: dialect,
+	}
+}
+
+func (r *PublicLinksRepository) Create(link models.PublicLink) error {
+
+	query, args, err := squirrel.Insert("public_links").
+		Columns(publicLinkColumns...).
+		Values(link.ID, link.NoteId, link.Slug, link.PasswordHash, link.CreatedAt, link.ExpiresAt, link.Views, link.LastViewedAt).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Db.Exec(query, args...)
+	return err
+}
+
+func (r *PublicLinksRepository) GetBySlug(slug string) (models.PublicLink, error) {
+
+	query, args, err := squirrel.Select(publicLinkColumns...).
+		From("public_links").
+		Where(squirrel.Eq{"slug": slug}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return models.PublicLink{}, err
+	}
+
+	link, err := scanPublicLink(r.Db.QueryRow(query, args...))
+	if err == sql.ErrNoRows {
+		return models.PublicLink{}, repository.ErrNotFound
+	}
+	return link, err
+}
+
+func (r *PublicLinksRepository) GetAllByNoteId(noteId uuid.UUID) ([]models.PublicLink, error) {
+
+	query, args, err := squirrel.Select(publicLinkColumns...).
+		From("public_links").
+		Where(squirrel.Eq{"note_id": noteId}).
+		OrderBy("created_at DESC").
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.Db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []models.PublicLink{}
+	for rows.Next() {
+		link, err := scanPublicLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+func (r *PublicLinksRepository) Delete(noteId uuid.UUID, id uuid.UUID) error {
+
+	query, args, err := squirrel.Delete("public_links").
+		Where(squirrel.Eq{"id": id, "note_id": noteId}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	return execAffected(r.Db, query, args)
+}
+
+func (r *PublicLinksRepository) RecordView(id uuid.UUID, viewedAt time.Time) error {
+
+	// счётчик растёт в самой базе, чтобы одновременные просмотры не терялись
+	query, args, err := squirrel.Update("public_links").
+		Set("views", squirrel.Expr("views + 1")).
+		Set("last_viewed_at", viewedAt).
+		Where(squirrel.Eq{"id": id}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	return execAffected(r.Db, query, args)
+}
+
+func (r *PublicLinksRepository) DeleteExpired(before time.Time) (int64, error) {
+
+	query, args, err := squirrel.Delete("public_links").
+		Where(squirrel.Lt{"expires_at": before}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := r.Db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"database/sql"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"time"
+)
+
+var revisionColumns = []string{"note_id", "revision", "title", "content", "created_at"}
+
+type RevisionsRepository struct {
+	Db      *sql.DB
+	dialect Dialect
+}
+
+func NewRevisionsRepository(db *sql.DB, dialect Dialect) *RevisionsRepository {
+	return &RevisionsRepository{
+		Db:      db,
+		dialect: dialect,
+	}
+}
+
+func scanRevision(row rowScanner) (models.NoteRevision, error) {
+	var revision models.NoteRevision
+	err := row.Scan(
+		&revision.NoteId,
+		&revision.Revision,
+		&revision.Title,
+		&revision.Content,
+		&revision.CreatedAt)
+	return revision, err
+}
+
+// insertRevision stores the current title and content of the note as its next revision.
+func insertRevision(tx *sql.Tx, dialect Dialect, note models.Note, createdAt time.Time) error {
+	query, args, err := squirrel.Select("COALESCE(MAX(revision), 0) + 1").
+		From("note_revisions").
+		Where(squirrel.Eq{"note_id": note.ID}).
+		PlaceholderFormat(dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	var revision int
+	if err = tx.QueryRow(query, args...).Scan(&revision); err != nil {
+		return err
+	}
+
+	query, args, err = squirrel.Insert("note_revisions").
+		Columns(revisionColumns...).
+		Values(note.ID, revision, note.Title, note.Content, createdAt).
+		PlaceholderFormat(dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(query, args...)
+	return err
+}
+
+func (r *RevisionsRepository) GetAllByNoteId(noteId uuid.UUID) ([]models.NoteRevision, error) {
+
+	query, args, err := squirrel.Select(revisionColumns...).
+		From("note_revisions").
+		Where(squirrel.Eq{"note_id": noteId}).
+		OrderBy("revision DESC").
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.Db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []models.NoteRevision{}
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, rows.Err()
+}
+
+func (r *RevisionsRepository) Get(noteId uuid.UUID, revision int) (models.NoteRevision, error) {
+
+	query, args, err := squirrel.Select(revisionColumns...).
+		From("note_revisions").
+		Where(squirrel.Eq{"note_id": noteId, "revision": revision}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return models.NoteRevision{}, err
+	}
+
+	rev, err := scanRevision(r.Db.QueryRow(query, args...))
+	if err == sql.ErrNoRows {
+		return models.NoteRevision{}, repository.ErrNotFound
+	}
+	if err != nil {
+		return models.NoteRevision{}, err
+	}
+
+	return rev, nil
+}
+
+func (r *RevisionsRepository) Prune(keepLast int, olderThan time.Time) (int64, error) {
+	if keepLast <= 0 && olderThan.IsZero() {
+		return 0, nil
+	}
+
+	// rn = 1 is the latest revision of a note, it survives any policy
+	expired := squirrel.Or{}
+	if keepLast > 0 {
+		expired = append(expired, squirrel.Gt{"rn": keepLast})
+	}
+	if !olderThan.IsZero() {
+		expired = append(expired, squirrel.Lt{"created_at": olderThan})
+	}
+
+	sub, subArgs, err := squirrel.Select("note_id", "revision").
+		FromSelect(squirrel.Select("note_id", "revision", "created_at",
+			"ROW_NUMBER() OVER (PARTITION BY note_id ORDER BY revision DESC) AS rn").
+			From("note_revisions"), "r").
+		Where(squirrel.Gt{"rn": 1}).
+		Where(expired).
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	query, args, err := squirrel.Delete("note_revisions").
+		Where("(note_id, revision) IN ("+sub+")", subArgs...).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := r.Db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"2/internal/domain/models"
+	"database/sql"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"strings"
+	"unicode"
+)
+
+// SearchRepository searches notes with the native full-text engine of the database:
+// tsvector/GIN on postgres and FTS5 on sqlite.
+type SearchRepository struct {
+	Db      *sql.DB
+	dialect Dialect
+}
+
+func NewSearchRepository(db *sql.DB, dialect Dialect) *SearchRepository {
+	return &SearchRepository{
+		Db:      db,
+		dialect: dialect,
+	}
+}
+
+// SetLanguage switches the postgres text search configuration (english, russian, simple...)
+// and rebuilds the search vectors when it changes. The sqlite tokenizer does not depend on
+// the language, so there it does nothing.
+func (r *SearchRepository) SetLanguage(language string) error {
+	if r.dialect != Postgres || language == "" {
+		return nil
+	}
+
+	tx, err := r.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("UPDATE search_settings SET language = $1::regconfig WHERE language <> $1::regconfig", language)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return err
+	}
+
+	if _, err = tx.Exec("UPDATE notes SET search_vector = notes_search_vector(title, content)"); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *SearchRepository) Search(userId uuid.UUID, query string, limit int) ([]models.SearchResult, error) {
+	var builder squirrel.SelectBuilder
+	if r.dialect == Postgres {
+		selectors := "StartSel=" + models.HighlightStart + ", StopSel=" + models.HighlightStop
+		builder = squirrel.Select(prefixed("n", noteColumns)...).
+			Column("ts_rank_cd(n.search_vector, q) AS rank").
+			Column("ts_headline(s.language, n.title, q, ?)", selectors+", HighlightAll=true").
+			Column("ts_headline(s.language, n.content, q, ?)", selectors+", MaxFragments=2, MaxWords=30, MinWords=10").
+			From("notes n").
+			JoinClause("CROSS JOIN search_settings s").
+			JoinClause("CROSS JOIN websearch_to_tsquery(s.language, ?) q", query).
+			Where(squirrel.Eq{"n.user_id": userId, "n.deleted_at": nil}).
+			Where("n.search_vector @@ q").
+			OrderBy("rank DESC")
+	} else {
+		match := ftsQuery(query)
+		if match == "" {
+			return []models.SearchResult{}, nil
+		}
+
+		// bm25 is lower for better matches, title hits weigh ten times more than content ones
+		builder = squirrel.Select(prefixed("n", noteColumns)...).
+			Column("-bm25(notes_fts, 0, 10.0, 1.0) AS rank").
+			Column("highlight(notes_fts, 1, ?, ?)", models.HighlightStart, models.HighlightStop).
+			Column("snippet(notes_fts, 2, ?, ?, '…', 24)", models.HighlightStart, models.HighlightStop).
+			From("notes_fts").
+			Join("notes n ON n.id = notes_fts.note_id").
+			Where("notes_fts MATCH ?", match).
+			Where(squirrel.Eq{"n.user_id": userId, "n.deleted_at": nil}).
+			OrderBy("rank DESC")
+	}
+
+	sqlQuery, args, err := builder.
+		Limit(uint64(limit)).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.Db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.SearchResult
+	for rows.Next() {
+		var res models.SearchResult
+		note := &res.Note
+		err = rows.Scan(
+			&note.ID,
+			&note.UserId,
+			&note.NotebookId,
+			&note.Title,
+			&note.Content,
+			&note.CreatedAt,
+			&note.UpdatedAt,
+			&note.Version,
+			&res.Rank,
+			&res.TitleHighlight,
+			&res.Snippet)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	notes := make([]models.Note, len(results))
+	for i := range results {
+		notes[i] = results[i].Note
+	}
+	if err = loadNoteTags(r.Db, r.dialect, notes); err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Note = notes[i]
+	}
+
+	if results == nil {
+		results = []models.SearchResult{}
+	}
+	return results, nil
+}
+
+// ftsQuery turns free user input into an FTS5 query: every word is quoted so that
+// operators and punctuation in the input can not break the MATCH syntax.
+func ftsQuery(query string) string {
+	words := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	for i, word := range words {
+		words[i] = `"` + word + `"`
+	}
+	return strings.Join(words, " ")
+}
+
+func prefixed(alias string, columns []string) []string {
+	result := make([]string, len(columns))
+	for i, column := range columns {
+		result[i] = alias + "." + column
+	}
+	return result
+}
@@ -0,0 +1,262 @@
+package storage
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"database/sql"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"time"
+)
+
+var sessionColumns = []string{"id", "user_id", "user_agent", "ip", "created_at", "last_seen_at", "expires_at", "revoked_at"}
+
+func scanSession(row rowScanner) (models.Session, error) {
+	var session models.Session
+	err := row.Scan(
+		&session.ID,
+		&session.UserId,
+		&session.UserAgent,
+		&session.IP,
+		&session.CreatedAt,
+		&session.LastSeenAt,
+		&session.ExpiresAt,
+		&session.RevokedAt)
+	return session, err
+}
+
+type SessionsRepository struct {
+	Db      *sql.DB
+	dialect Dialect
+}
+
+func NewSessionsRepository(db *sql.DB, dialect Dialect) *SessionsRepository {
+	return &SessionsRepository{
+		Db:      db,
+		dialect: dialect,
+	}
+}
+
+func (r *SessionsRepository) Create(session models.Session, token models.RefreshToken) error {
+
+	query, args, err := squirrel.Insert("sessions").
+		Columns(sessionColumns...).
+		Values(session.ID, session.UserId, session.UserAgent, session.IP,
+			session.CreatedAt, session.LastSeenAt, session.ExpiresAt, session.RevokedAt).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(query, args...); err != nil {
+		return err
+	}
+	if err = insertRefreshToken(tx, r.dialect, token); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertRefreshToken(tx *sql.Tx, dialect Dialect, token models.RefreshToken) error {
+	query, args, err := squirrel.Insert("refresh_tokens").
+		Columns("id", "session_id", "token_hash", "created_at", "expires_at").
+		Values(token.ID, token.SessionId, token.TokenHash, token.CreatedAt, token.ExpiresAt).
+		PlaceholderFormat(dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(query, args...)
+	return err
+}
+
+func (r *SessionsRepository) Get(id uuid.UUID) (models.Session, error) {
+
+	query, args, err := squirrel.Select(sessionColumns...).
+		From("sessions").
+		Where(squirrel.Eq{"id": id}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	session, err := scanSession(r.Db.QueryRow(query, args...))
+	if err == sql.ErrNoRows {
+		return models.Session{}, repository.ErrNotFound
+	}
+	return session, err
+}
+
+func (r *SessionsRepository) GetAllByUserId(userId uuid.UUID) ([]models.Session, error) {
+
+	query, args, err := squirrel.Select(sessionColumns...).
+		From("sessions").
+		Where(squirrel.Eq{"user_id": userId}).
+		OrderBy("last_seen_at DESC").
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.Db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+func (r *SessionsRepository) Touch(lastSeen map[uuid.UUID]time.Time) error {
+	if len(lastSeen) == 0 {
+		return nil
+	}
+
+	tx, err := r.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for id, at := range lastSeen {
+		query, args, err := squirrel.Update("sessions").
+			Set("last_seen_at", at).
+			Where(squirrel.Eq{"id": id}).
+			Where(squirrel.Lt{"last_seen_at": at}).
+			PlaceholderFormat(r.dialect.Placeholder).ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err = tx.Exec(query, args...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *SessionsRepository) Revoke(id uuid.UUID, at time.Time) error {
+	return r.revoke(squirrel.Eq{"id": id}, at)
+}
+
+func (r *SessionsRepository) RevokeAllByUserId(userId uuid.UUID, at time.Time) error {
+	return r.revoke(squirrel.Eq{"user_id": userId}, at)
+}
+
+func (r *SessionsRepository) revoke(pred squirrel.Eq, at time.Time) error {
+
+	query, args, err := squirrel.Update("sessions").
+		Set("revoked_at", at).
+		Where(pred).
+		Where(squirrel.Eq{"revoked_at": nil}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Db.Exec(query, args...)
+	return err
+}
+
+func (r *SessionsRepository) GetRefreshToken(tokenHash string) (models.RefreshToken, error) {
+
+	query, args, err := squirrel.Select("id", "session_id", "token_hash", "created_at", "expires_at", "used_at").
+		From("refresh_tokens").
+		Where(squirrel.Eq{"token_hash": tokenHash}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return models.RefreshToken{}, err
+	}
+
+	var token models.RefreshToken
+	err = r.Db.QueryRow(query, args...).Scan(
+		&token.ID,
+		&token.SessionId,
+		&token.TokenHash,
+		&token.CreatedAt,
+		&token.ExpiresAt,
+		&token.UsedAt)
+	if err == sql.ErrNoRows {
+		return models.RefreshToken{}, repository.ErrNotFound
+	}
+	return token, err
+}
+
+func (r *SessionsRepository) Rotate(oldId uuid.UUID, token models.RefreshToken) error {
+
+	query, args, err := squirrel.Update("refresh_tokens").
+		Set("used_at", token.CreatedAt).
+		Where(squirrel.Eq{"id": oldId, "used_at": nil}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return repository.ErrNotFound
+	}
+
+	if err = insertRefreshToken(tx, r.dialect, token); err != nil {
+		return err
+	}
+
+	query, args, err = squirrel.Update("sessions").
+		Set("expires_at", token.ExpiresAt).
+		Where(squirrel.Eq{"id": token.SessionId}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(query, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *SessionsRepository) DeleteExpired(before time.Time) (int64, error) {
+
+	query, args, err := squirrel.Delete("sessions").
+		Where(squirrel.Or{
+			squirrel.Lt{"expires_at": before},
+			squirrel.Lt{"revoked_at": before},
+		}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := r.Db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
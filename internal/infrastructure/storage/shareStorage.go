@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"database/sql"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+type SharesRepository struct {
+	Db      *sql.DB
+	dialect Dialect
+}
+
+func NewSharesRepository(db *sql.DB, dialect Dialect) *SharesRepository {
+	return &SharesRepository{
+		Db:      db,
+		dialect: dialect,
+	}
+}
+
+func (r *SharesRepository) Save(share models.NoteShare) error {
+
+	// повторная выдача меняет только права, дата первой выдачи остаётся
+	query, args, err := squirrel.Insert("note_shares").
+		Columns("note_id", "user_id", "permission", "created_at").
+		Values(share.NoteId, share.UserId, share.Permission, share.CreatedAt).
+		Suffix("ON CONFLICT (note_id, user_id) DO UPDATE SET permission = excluded.permission").
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Db.Exec(query, args...)
+	return err
+}
+
+func (r *SharesRepository) Get(noteId uuid.UUID, userId uuid.UUID) (models.NoteShare, error) {
+
+	query, args, err := r.selectShares().
+		Where(squirrel.Eq{"s.note_id": noteId, "s.user_id": userId}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return models.NoteShare{}, err
+	}
+
+	share, err := scanShare(r.Db.QueryRow(query, args...))
+	if err == sql.ErrNoRows {
+		return models.NoteShare{}, repository.ErrNotFound
+	}
+	return share, err
+}
+
+func (r *SharesRepository) GetAllByNoteId(noteId uuid.UUID) ([]models.NoteShare, error) {
+
+	query, args, err := r.selectShares().
+		Where(squirrel.Eq{"s.note_id": noteId}).
+		OrderBy("s.created_at", "u.email").
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.Db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := []models.NoteShare{}
+	for rows.Next() {
+		share, err := scanShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, share)
+	}
+	return shares, rows.Err()
+}
+
+func (r *SharesRepository) selectShares() squirrel.SelectBuilder {
+	return squirrel.Select("s.note_id", "s.user_id", "s.permission", "s.created_at", "u.email", "u.username").
+		From("note_shares s").
+		Join("users u ON u.user_id = s.user_id")
+}
+
+func scanShare(row rowScanner) (models.NoteShare, error) {
+	var share models.NoteShare
+	err := row.Scan(
+		&share.NoteId,
+		&share.UserId,
+		&share.Permission,
+		&share.CreatedAt,
+		&share.Email,
+		&share.Username)
+	return share, err
+}
+
+func (r *SharesRepository) GetSharedWith(userId uuid.UUID) ([]models.SharedNote, error) {
+
+	columns := make([]string, 0, len(noteColumns)+3)
+	for _, column := range noteColumns {
+		columns = append(columns, "n."+column)
+	}
+	columns = append(columns, "s.permission", "s.created_at", "u.email")
+
+	query, args, err := squirrel.Select(columns...).
+		From("note_shares s").
+		Join("notes n ON n.id = s.note_id").
+		Join("users u ON u.user_id = n.user_id").
+		Where(squirrel.Eq{"s.user_id": userId, "n.deleted_at": nil}).
+		OrderBy("n.updated_at DESC", "n.id").
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.Db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shared := []models.SharedNote{}
+	for rows.Next() {
+		var s models.SharedNote
+		err = rows.Scan(
+			&s.ID,
+			&s.UserId,
+			&s.NotebookId,
+			&s.Title,
+			&s.Content,
+			&s.CreatedAt,
+			&s.UpdatedAt,
+			&s.Version,
+			&s.Permission,
+			&s.SharedAt,
+			&s.OwnerEmail)
+		if err != nil {
+			return nil, err
+		}
+		shared = append(shared, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	notes := make([]models.Note, len(shared))
+	for i := range shared {
+		notes[i] = shared[i].Note
+	}
+	if err = loadNoteTags(r.Db, r.dialect, notes); err != nil {
+		return nil, err
+	}
+
+	for i := range shared {
+		shared[i].Note = notes[i]
+	}
+	return shared, nil
+}
+
+func (r *SharesRepository) Delete(noteId uuid.UUID, userId uuid.UUID) error {
+
+	query, args, err := squirrel.Delete("note_shares").
+		Where(squirrel.Eq{"note_id": noteId, "user_id": userId}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	return execAffected(r.Db, query, args)
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"2/internal/domain/models"
+	"database/sql"
+	"github.com/Masterminds/squirrel"
+	"time"
+)
+
+type SigningKeysRepository struct {
+	Db      *sql.DB
+	dialect Dialect
+}
+
+func NewSigningKeysRepository(db *sql.DB, dialect Dialect) *SigningKeysRepository {
+	return &SigningKeysRepository{
+		Db:      db,
+		dialect: dialect,
+	}
+}
+
+func (r *SigningKeysRepository) GetAll() ([]models.SigningKey, error) {
+
+	query, args, err := squirrel.Select("kid", "algorithm", "private_key", "created_at", "expires_at").
+		From("signing_keys").
+		OrderBy("created_at").
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.Db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.SigningKey{}
+	for rows.Next() {
+		var key models.SigningKey
+		if err = rows.Scan(&key.ID, &key.Algorithm, &key.PrivateKey, &key.CreatedAt, &key.ExpiresAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (r *SigningKeysRepository) Create(key models.SigningKey) error {
+
+	query, args, err := squirrel.Insert("signing_keys").
+		Columns("kid", "algorithm", "private_key", "created_at", "expires_at").
+		Values(key.ID, key.Algorithm, key.PrivateKey, key.CreatedAt, key.ExpiresAt).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Db.Exec(query, args...)
+	return err
+}
+
+func (r *SigningKeysRepository) Expire(id string, at time.Time) error {
+
+	query, args, err := squirrel.Update("signing_keys").
+		Set("expires_at", at).
+		Where(squirrel.Eq{"kid": id, "expires_at": nil}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Db.Exec(query, args...)
+	return err
+}
+
+func (r *SigningKeysRepository) DeleteExpired(before time.Time) (int64, error) {
+
+	query, args, err := squirrel.Delete("signing_keys").
+		Where(squirrel.Lt{"expires_at": before}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := r.Db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
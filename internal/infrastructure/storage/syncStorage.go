@@ -0,0 +1,225 @@
+package storage
+
+import (
+	"2/internal/domain/models"
+	"database/sql"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"time"
+)
+
+// nextChangeSeq takes the next number of the user's change feed. The counter row stays
+// locked until the transaction ends, so changes of one user commit in the order of their
+// numbers and a client that has read up to some number never misses a smaller one.
+func nextChangeSeq(tx *sql.Tx, dialect Dialect, userId uuid.UUID) (int64, error) {
+	query, args, err := squirrel.Insert("note_sync").
+		Columns("user_id", "seq").
+		Values(userId, 1).
+		Suffix("ON CONFLICT (user_id) DO UPDATE SET seq = note_sync.seq + 1 RETURNING seq").
+		PlaceholderFormat(dialect.Placeholder).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	var seq int64
+	err = tx.QueryRow(query, args...).Scan(&seq)
+	return seq, err
+}
+
+// touchNotes gives the user's notes matching pred the next number of the change feed,
+// for changes that do not go through the notes row itself, such as a renamed tag.
+func touchNotes(tx *sql.Tx, dialect Dialect, userId uuid.UUID, pred squirrel.Sqlizer) error {
+	seq, err := nextChangeSeq(tx, dialect, userId)
+	if err != nil {
+		return err
+	}
+
+	query, args, err := squirrel.Update("notes").
+		Set("change_seq", seq).
+		Where(squirrel.Eq{"user_id": userId}).
+		Where(pred).
+		PlaceholderFormat(dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(query, args...)
+	return err
+}
+
+// feedAfter selects the rows of the feed behind the cursor in feed order.
+func feedAfter(builder squirrel.SelectBuilder, idColumn string, after models.SyncCursor, limit int) squirrel.SelectBuilder {
+	return builder.
+		Where("(change_seq > ? OR (change_seq = ? AND "+idColumn+" > ?))", after.Seq, after.Seq, after.ID).
+		OrderBy("change_seq", idColumn).
+		Limit(uint64(limit))
+}
+
+func (s *NotesRepository) Changes(userId uuid.UUID, after models.SyncCursor, limit int) ([]models.NoteChange, int64, error) {
+	query, args, err := feedAfter(
+		squirrel.Select(noteColumns...).Columns("deleted_at", "change_seq").
+			From("notes").
+			Where(squirrel.Eq{"user_id": userId}),
+		"id", after, limit).
+		PlaceholderFormat(s.dialect.Placeholder).ToSql()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.Db.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var changes []models.NoteChange
+	var live []models.Note
+	for rows.Next() {
+		var note models.Note
+		var change models.NoteChange
+		err = rows.Scan(
+			&note.ID,
+			&note.UserId,
+			&note.NotebookId,
+			&note.Title,
+			&note.Content,
+			&note.CreatedAt,
+			&note.UpdatedAt,
+			&note.Version,
+			&change.DeletedAt,
+			&change.Seq)
+		if err != nil {
+			return nil, 0, err
+		}
+		change.NoteId = note.ID
+		if change.DeletedAt == nil {
+			live = append(live, note)
+		}
+		changes = append(changes, change)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	rows.Close()
+
+	if err = loadNoteTags(s.Db, s.dialect, live); err != nil {
+		return nil, 0, err
+	}
+	for i, j := 0, 0; i < len(changes); i++ {
+		if changes[i].DeletedAt == nil {
+			changes[i].Note = &live[j]
+			j++
+		}
+	}
+
+	tombstones, err := s.tombstones(userId, after, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	changes = mergeChanges(changes, tombstones, limit)
+
+	query, args, err = squirrel.Select("pruned_seq").
+		From("note_sync").
+		Where(squirrel.Eq{"user_id": userId}).
+		PlaceholderFormat(s.dialect.Placeholder).ToSql()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var pruned int64
+	err = s.Db.QueryRow(query, args...).Scan(&pruned)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, 0, err
+	}
+	return changes, pruned, nil
+}
+
+func (s *NotesRepository) tombstones(userId uuid.UUID, after models.SyncCursor, limit int) ([]models.NoteChange, error) {
+	query, args, err := feedAfter(
+		squirrel.Select("note_id", "change_seq", "deleted_at").
+			From("note_tombstones").
+			Where(squirrel.Eq{"user_id": userId}),
+		"note_id", after, limit).
+		PlaceholderFormat(s.dialect.Placeholder).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.Db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []models.NoteChange
+	for rows.Next() {
+		var change models.NoteChange
+		var deletedAt time.Time
+		if err = rows.Scan(&change.NoteId, &change.Seq, &deletedAt); err != nil {
+			return nil, err
+		}
+		change.DeletedAt = &deletedAt
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+// mergeChanges joins two runs of the feed, each in feed order, and keeps the first limit entries.
+func mergeChanges(a, b []models.NoteChange, limit int) []models.NoteChange {
+	merged := make([]models.NoteChange, 0, min(len(a)+len(b), limit))
+	for len(merged) < limit && (len(a) > 0 || len(b) > 0) {
+		if len(b) == 0 || len(a) > 0 && a[0].Cursor().Before(b[0].Cursor()) {
+			merged, a = append(merged, a[0]), a[1:]
+		} else {
+			merged, b = append(merged, b[0]), b[1:]
+		}
+	}
+	return merged
+}
+
+// PurgeTombstones also moves pruned_seq of every user past the forgotten tombstones, in the
+// same transaction, so a client can tell it has to sync from scratch.
+func (s *NotesRepository) PurgeTombstones(olderThan time.Time) (int64, error) {
+	sub, subArgs, err := squirrel.Select("MAX(t.change_seq)").
+		From("note_tombstones t").
+		Where("t.user_id = note_sync.user_id").
+		Where(squirrel.Lt{"t.deleted_at": olderThan}).
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query, args, err := squirrel.Update("note_sync").
+		Set("pruned_seq", squirrel.Expr("COALESCE(("+sub+"), pruned_seq)", subArgs...)).
+		PlaceholderFormat(s.dialect.Placeholder).ToSql()
+	if err != nil {
+		return 0, err
+	}
+	if _, err = tx.Exec(query, args...); err != nil {
+		return 0, err
+	}
+
+	query, args, err = squirrel.Delete("note_tombstones").
+		Where(squirrel.Lt{"deleted_at": olderThan}).
+		PlaceholderFormat(s.dialect.Placeholder).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return n, tx.Commit()
+}
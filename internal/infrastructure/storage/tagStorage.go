@@ -0,0 +1,278 @@
+package storage
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"database/sql"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+type TagsRepository struct {
+	Db      *sql.DB
+	dialect Dialect
+}
+
+func NewTagsRepository(db *sql.DB, dialect Dialect) *TagsRepository {
+	return &TagsRepository{
+		Db:      db,
+		dialect: dialect,
+	}
+}
+
+func (r *TagsRepository) GetAllByUserId(userId uuid.UUID) ([]models.TagCount, error) {
+
+	query, args, err := squirrel.Select("t.name", "COUNT(nt.note_id)").
+		From("tags t").
+		Join("note_tags nt ON nt.tag_id = t.id").
+		Join("notes n ON n.id = nt.note_id").
+		Where(squirrel.Eq{"t.user_id": userId, "n.deleted_at": nil}).
+		GroupBy("t.name").
+		OrderBy("t.name").
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.Db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []models.TagCount{}
+	for rows.Next() {
+		var tag models.TagCount
+		if err = rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+func (r *TagsRepository) Rename(userId uuid.UUID, from string, to string) error {
+	tx, err := r.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, exists, err := tagId(tx, r.dialect, userId, to); err != nil {
+		return err
+	} else if exists {
+		return fmt.Errorf("tag %s already exists, merge the tags instead", to)
+	}
+
+	fromId, exists, err := tagId(tx, r.dialect, userId, from)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return repository.ErrNotFound
+	}
+
+	query, args, err := squirrel.Update("tags").
+		Set("name", to).
+		Where(squirrel.Eq{"user_id": userId, "name": from}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return repository.ErrNotFound
+	}
+
+	// у заметок с этим тегом поменялись теги, клиенты синхронизации должны их перечитать
+	if err = touchNotes(tx, r.dialect, userId, taggedWith(fromId)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func taggedWith(tagId uuid.UUID) squirrel.Sqlizer {
+	return squirrel.Expr("id IN (SELECT note_id FROM note_tags WHERE tag_id = ?)", tagId)
+}
+
+// Merge moves every note of the source tags onto target and removes the sources.
+func (r *TagsRepository) Merge(userId uuid.UUID, sources []string, target string) error {
+	tx, err := r.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	targetId, err := upsertTag(tx, r.dialect, userId, target)
+	if err != nil {
+		return err
+	}
+
+	for _, source := range sources {
+		if source == target {
+			continue
+		}
+
+		sourceId, exists, err := tagId(tx, r.dialect, userId, source)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return repository.ErrNotFound
+		}
+
+		query, args, err := squirrel.Insert("note_tags").
+			Columns("note_id", "tag_id").
+			Select(squirrel.Select("nt.note_id", "t.id").
+				From("note_tags nt, tags t").
+				Where(squirrel.Eq{"nt.tag_id": sourceId, "t.id": targetId})).
+			Suffix("ON CONFLICT (note_id, tag_id) DO NOTHING").
+			PlaceholderFormat(r.dialect.Placeholder).ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err = tx.Exec(query, args...); err != nil {
+			return err
+		}
+
+		if err = touchNotes(tx, r.dialect, userId, taggedWith(sourceId)); err != nil {
+			return err
+		}
+
+		query, args, err = squirrel.Delete("tags").
+			Where(squirrel.Eq{"id": sourceId}).
+			PlaceholderFormat(r.dialect.Placeholder).ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err = tx.Exec(query, args...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func tagId(tx *sql.Tx, dialect Dialect, userId uuid.UUID, name string) (uuid.UUID, bool, error) {
+	query, args, err := squirrel.Select("id").
+		From("tags").
+		Where(squirrel.Eq{"user_id": userId, "name": name}).
+		PlaceholderFormat(dialect.Placeholder).ToSql()
+	if err != nil {
+		return uuid.UUID{}, false, err
+	}
+
+	var id uuid.UUID
+	err = tx.QueryRow(query, args...).Scan(&id)
+	if err == sql.ErrNoRows {
+		return uuid.UUID{}, false, nil
+	}
+	if err != nil {
+		return uuid.UUID{}, false, err
+	}
+
+	return id, true, nil
+}
+
+func upsertTag(tx *sql.Tx, dialect Dialect, userId uuid.UUID, name string) (uuid.UUID, error) {
+	query, args, err := squirrel.Insert("tags").
+		Columns("id", "user_id", "name").
+		Values(uuid.New(), userId, name).
+		Suffix("ON CONFLICT (user_id, name) DO NOTHING").
+		PlaceholderFormat(dialect.Placeholder).ToSql()
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	if _, err = tx.Exec(query, args...); err != nil {
+		return uuid.UUID{}, err
+	}
+
+	id, _, err := tagId(tx, dialect, userId, name)
+	return id, err
+}
+
+// setNoteTags replaces the tags of a note, creating the user's tags that do not exist yet.
+func setNoteTags(tx *sql.Tx, dialect Dialect, userId uuid.UUID, noteId uuid.UUID, names []string) error {
+	query, args, err := squirrel.Delete("note_tags").
+		Where(squirrel.Eq{"note_id": noteId}).
+		PlaceholderFormat(dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(query, args...); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		id, err := upsertTag(tx, dialect, userId, name)
+		if err != nil {
+			return err
+		}
+
+		query, args, err := squirrel.Insert("note_tags").
+			Columns("note_id", "tag_id").
+			Values(noteId, id).
+			PlaceholderFormat(dialect.Placeholder).ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err = tx.Exec(query, args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadNoteTags fills Tags of the given notes in place with a single query.
+func loadNoteTags(db *sql.DB, dialect Dialect, notes []models.Note) error {
+	if len(notes) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(notes))
+	byId := make(map[uuid.UUID]*models.Note, len(notes))
+	for i := range notes {
+		notes[i].Tags = []string{}
+		ids = append(ids, notes[i].ID)
+		byId[notes[i].ID] = &notes[i]
+	}
+
+	query, args, err := squirrel.Select("nt.note_id", "t.name").
+		From("note_tags nt").
+		Join("tags t ON t.id = nt.tag_id").
+		Where(squirrel.Eq{"nt.note_id": ids}).
+		OrderBy("t.name").
+		PlaceholderFormat(dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var noteId uuid.UUID
+		var name string
+		if err = rows.Scan(&noteId, &name); err != nil {
+			return err
+		}
+		if note, ok := byId[noteId]; ok {
+			note.Tags = append(note.Tags, name)
+		}
+	}
+
+	return rows.Err()
+}
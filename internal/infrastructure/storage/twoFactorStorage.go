@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"database/sql"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"time"
+)
+
+type TwoFactorRepository struct {
+	Db      *sql.DB
+	dialect Dialect
+}
+
+func NewTwoFactorRepository(db *sql.DB, dialect Dialect) *TwoFactorRepository {
+	return &TwoFactorRepository{
+		Db:      db,
+		dialect: dialect,
+	}
+}
+
+func (r *TwoFactorRepository) GetTOTP(userId uuid.UUID) (models.TOTP, error) {
+
+	query, args, err := squirrel.Select("user_id", "secret", "enabled", "last_step", "created_at", "confirmed_at").
+		From("user_totp").
+		Where(squirrel.Eq{"user_id": userId}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return models.TOTP{}, err
+	}
+
+	var totp models.TOTP
+	err = r.Db.QueryRow(query, args...).Scan(
+		&totp.UserId,
+		&totp.Secret,
+		&totp.Enabled,
+		&totp.LastStep,
+		&totp.CreatedAt,
+		&totp.ConfirmedAt)
+	if err == sql.ErrNoRows {
+		return models.TOTP{}, repository.ErrNotFound
+	}
+	return totp, err
+}
+
+func (r *TwoFactorRepository) SaveTOTP(totp models.TOTP) error {
+
+	query, args, err := squirrel.Insert("user_totp").
+		Columns("user_id", "secret", "enabled", "last_step", "created_at", "confirmed_at").
+		Values(totp.UserId, totp.Secret, totp.Enabled, totp.LastStep, totp.CreatedAt, totp.ConfirmedAt).
+		Suffix(`ON CONFLICT (user_id) DO UPDATE SET
+	secret = excluded.secret,
+	enabled = excluded.enabled,
+	last_step = excluded.last_step,
+	created_at = excluded.created_at,
+	confirmed_at = excluded.confirmed_at`).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Db.Exec(query, args...)
+	return err
+}
+
+func (r *TwoFactorRepository) DeleteTOTP(userId uuid.UUID) error {
+	tx, err := r.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"recovery_codes", "user_totp"} {
+		query, args, err := squirrel.Delete(table).
+			Where(squirrel.Eq{"user_id": userId}).
+			PlaceholderFormat(r.dialect.Placeholder).ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err = tx.Exec(query, args...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *TwoFactorRepository) UseStep(userId uuid.UUID, step int64) error {
+
+	query, args, err := squirrel.Update("user_totp").
+		Set("last_step", step).
+		Where(squirrel.Eq{"user_id": userId}).
+		Where(squirrel.Lt{"last_step": step}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	return execAffected(r.Db, query, args)
+}
+
+func (r *TwoFactorRepository) Enable(userId uuid.UUID, confirmedAt time.Time, codeHashes []string) error {
+	tx, err := r.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query, args, err := squirrel.Update("user_totp").
+		Set("enabled", true).
+		Set("confirmed_at", confirmedAt).
+		Where(squirrel.Eq{"user_id": userId}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(query, args...); err != nil {
+		return err
+	}
+
+	query, args, err = squirrel.Delete("recovery_codes").
+		Where(squirrel.Eq{"user_id": userId}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(query, args...); err != nil {
+		return err
+	}
+
+	insert := squirrel.Insert("recovery_codes").Columns("id", "user_id", "code_hash")
+	for _, hash := range codeHashes {
+		insert = insert.Values(uuid.New(), userId, hash)
+	}
+	query, args, err = insert.PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(query, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *TwoFactorRepository) UseRecoveryCode(userId uuid.UUID, codeHash string, at time.Time) error {
+
+	query, args, err := squirrel.Update("recovery_codes").
+		Set("used_at", at).
+		Where(squirrel.Eq{"user_id": userId, "code_hash": codeHash, "used_at": nil}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	return execAffected(r.Db, query, args)
+}
+
+func (r *TwoFactorRepository) UseMFAToken(id uuid.UUID, expiresAt time.Time) error {
+
+	query, args, err := squirrel.Insert("used_mfa_tokens").
+		Columns("id", "expires_at").
+		Values(id, expiresAt).
+		Suffix("ON CONFLICT (id) DO NOTHING").
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	return execAffected(r.Db, query, args)
+}
+
+func (r *TwoFactorRepository) DeleteUsedMFATokens(before time.Time) (int64, error) {
+
+	query, args, err := squirrel.Delete("used_mfa_tokens").
+		Where(squirrel.Lt{"expires_at": before}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := r.Db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -2,34 +2,54 @@ package storage
 
 import (
 	"2/internal/domain/models"
+	"2/internal/domain/repository"
 	"database/sql"
 	"github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
-
+	"strings"
 	"time"
 )
 
+var userColumns = []string{"user_id", "username", "email", "password", "email_verified", "role", "created", "disabled_at"}
+
+func scanUser(row rowScanner) (models.User, error) {
+	var user models.User
+	err := row.Scan(
+		&user.UserId,
+		&user.Username,
+		&user.Email,
+		&user.Password,
+		&user.EmailVerified,
+		&user.Role,
+		&user.Created,
+		&user.DisabledAt,
+	)
+	return user, err
+}
+
 type UserRepository struct {
-	Db *sql.DB
+	Db      *sql.DB
+	dialect Dialect
 }
 
-func NewUserRepository(db *sql.DB) *UserRepository {
+func NewUserRepository(db *sql.DB, dialect Dialect) *UserRepository {
 	return &UserRepository{
-		Db: db,
+		Db:      db,
+		dialect: dialect,
 	}
 }
 
 func (r *UserRepository) Create(user models.User) error {
 
 	query, args, err := squirrel.Insert("users").
-		Columns("user_id", "username", "email", "password", "created").
-		Values(user.UserId, user.Username, user.Email, user.Password, time.Now()).
-		PlaceholderFormat(squirrel.Dollar).ToSql()
+		Columns("user_id", "username", "email", "password", "email_verified", "role", "created").
+		Values(user.UserId, user.Username, user.Email, user.Password, user.EmailVerified, user.Role, time.Now()).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
 	if err != nil {
 		return err
 	}
 
-	_, err = r.Db.Query(query, args...)
+	_, err = r.Db.Exec(query, args...)
 	if err != nil {
 		return err
 	}
@@ -37,24 +57,16 @@ func (r *UserRepository) Create(user models.User) error {
 }
 
 func (r *UserRepository) GetUserByEmail(email string) (models.User, bool, error) {
-	query, args, err := squirrel.Select("user_id", "username", "email", "password", "created").
+	query, args, err := squirrel.Select(userColumns...).
 		From("users").
 		Where(squirrel.Eq{
 			"email": email,
-		}).PlaceholderFormat(squirrel.Dollar).ToSql()
+		}).PlaceholderFormat(r.dialect.Placeholder).ToSql()
 	if err != nil {
 		return models.User{}, false, err
 	}
 
-	row := r.Db.QueryRow(query, args...)
-	user := models.User{}
-	err = row.Scan(
-		&user.UserId,
-		&user.Username,
-		&user.Email,
-		&user.Password,
-		&user.Created,
-	)
+	user, err := scanUser(r.Db.QueryRow(query, args...))
 
 	// Проверяем, был ли найден пользователь
 	if err != nil {
@@ -71,25 +83,157 @@ func (r *UserRepository) GetUserByEmail(email string) (models.User, bool, error)
 }
 func (r *UserRepository) GetUserById(id uuid.UUID) (models.User, error) {
 
-	query, args, err := squirrel.Select("user_id", "username", "email", "password", "created").
+	query, args, err := squirrel.Select(userColumns...).
 		From("users").
 		Where(squirrel.Eq{
 			"user_id": id,
-		}).PlaceholderFormat(squirrel.Dollar).ToSql()
+		}).PlaceholderFormat(r.dialect.Placeholder).ToSql()
 	if err != nil {
 		return models.User{}, err
 	}
 
-	row := r.Db.QueryRow(query, args...)
-	user := models.User{}
-	row.Scan(
-		&user.UserId,
-		&user.Username,
-		&user.Email,
-		&user.Password,
-		&user.Created,
-	)
+	user, err := scanUser(r.Db.QueryRow(query, args...))
+	if err == sql.ErrNoRows {
+		return models.User{}, repository.ErrNotFound
+	}
+	if err != nil {
+		return models.User{}, err
+	}
 
 	return user, nil
 
 }
+
+func (r *UserRepository) UpdatePassword(id uuid.UUID, passwordHash string) error {
+
+	query, args, err := squirrel.Update("users").
+		Set("password", passwordHash).
+		Where(squirrel.Eq{"user_id": id}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	return execAffected(r.Db, query, args)
+}
+
+func (r *UserRepository) SetEmailVerified(id uuid.UUID) error {
+
+	query, args, err := squirrel.Update("users").
+		Set("email_verified", true).
+		Where(squirrel.Eq{"user_id": id}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	return execAffected(r.Db, query, args)
+}
+
+func (r *UserRepository) UpdateProfile(user models.User) error {
+
+	query, args, err := squirrel.Update("users").
+		Set("username", user.Username).
+		Set("email", user.Email).
+		Set("email_verified", user.EmailVerified).
+		Where(squirrel.Eq{"user_id": user.UserId}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	return execAffected(r.Db, query, args)
+}
+
+func (r *UserRepository) Find(filter models.UserFilter) ([]models.User, error) {
+
+	builder := squirrel.Select(userColumns...).
+		From("users").
+		OrderBy("created DESC", "user_id")
+	if filter.Query != "" {
+		pattern := "%" + likeEscaper.Replace(strings.ToLower(filter.Query)) + "%"
+		builder = builder.Where(squirrel.Or{
+			squirrel.Expr(`LOWER(email) LIKE ? ESCAPE '\'`, pattern),
+			squirrel.Expr(`LOWER(username) LIKE ? ESCAPE '\'`, pattern),
+		})
+	}
+	if filter.Role != "" {
+		builder = builder.Where(squirrel.Eq{"role": filter.Role})
+	}
+	if filter.Disabled != nil {
+		if *filter.Disabled {
+			builder = builder.Where(squirrel.NotEq{"disabled_at": nil})
+		} else {
+			builder = builder.Where(squirrel.Eq{"disabled_at": nil})
+		}
+	}
+	if filter.Limit > 0 {
+		builder = builder.Limit(uint64(filter.Limit))
+	}
+	if filter.Offset > 0 {
+		builder = builder.Offset(uint64(filter.Offset))
+	}
+
+	query, args, err := builder.PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.Db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// likeEscaper keeps % and _ typed by the admin from working as LIKE wildcards.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (r *UserRepository) SetRole(id uuid.UUID, role string) error {
+
+	query, args, err := squirrel.Update("users").
+		Set("role", role).
+		Where(squirrel.Eq{"user_id": id}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	return execAffected(r.Db, query, args)
+}
+
+func (r *UserRepository) SetDisabled(id uuid.UUID, at *time.Time) error {
+
+	query, args, err := squirrel.Update("users").
+		Set("disabled_at", at).
+		Where(squirrel.Eq{"user_id": id}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	return execAffected(r.Db, query, args)
+}
+
+// Delete relies on ON DELETE CASCADE of every table referencing users.
+func (r *UserRepository) Delete(id uuid.UUID) error {
+
+	query, args, err := squirrel.Delete("users").
+		Where(squirrel.Eq{"user_id": id}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	return execAffected(r.Db, query, args)
+}
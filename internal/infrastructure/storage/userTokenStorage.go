@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"2/internal/domain/models"
+	"database/sql"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"time"
+)
+
+type UserTokensRepository struct {
+	Db      *sql.DB
+	dialect Dialect
+}
+
+func NewUserTokensRepository(db *sql.DB, dialect Dialect) *UserTokensRepository {
+	return &UserTokensRepository{
+		Db:      db,
+		dialect: dialect,
+	}
+}
+
+func (r *UserTokensRepository) Create(token models.UserToken) error {
+
+	query, args, err := squirrel.Insert("user_tokens").
+		Columns("id", "user_id", "purpose", "created_at", "expires_at", "used_at").
+		Values(token.ID, token.UserId, token.Purpose, token.CreatedAt, token.ExpiresAt, token.UsedAt).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Db.Exec(query, args...)
+	return err
+}
+
+func (r *UserTokensRepository) Use(id uuid.UUID, at time.Time) error {
+
+	// одним UPDATE, чтобы два параллельных запроса не использовали токен оба
+	query, args, err := squirrel.Update("user_tokens").
+		Set("used_at", at).
+		Where(squirrel.Eq{"id": id, "used_at": nil}).
+		Where(squirrel.Gt{"expires_at": at}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return err
+	}
+
+	return execAffected(r.Db, query, args)
+}
+
+func (r *UserTokensRepository) DeleteExpired(before time.Time) (int64, error) {
+
+	query, args, err := squirrel.Delete("user_tokens").
+		Where(squirrel.Lt{"expires_at": before}).
+		PlaceholderFormat(r.dialect.Placeholder).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := r.Db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
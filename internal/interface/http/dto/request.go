@@ -1,5 +1,10 @@
 package dto
 
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
 // RegistrationRequest represents user registration data
 type RegistrationRequest struct {
 	Email    string `json:"email" example:"user@example.com"`
@@ -13,14 +18,168 @@ type LoginRequest struct {
 	Password string `json:"password" example:"P@ssw0rd!"`
 }
 
+// LoginTwoFactorRequest represents the second login step, code is a TOTP code or a recovery code
+type LoginTwoFactorRequest struct {
+	MFAToken string `json:"mfa_token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+	Code     string `json:"code" example:"123456"`
+}
+
+// TwoFactorCodeRequest represents an authenticator app code
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" example:"123456"`
+}
+
+// DisableTwoFactorRequest represents two-factor disable data, code is a TOTP code or a recovery code
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" example:"P@ssw0rd!"`
+	Code     string `json:"code" example:"123456"`
+}
+
+// RefreshRequest represents refresh token exchange data
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" example:"Q2hhbmdlIG1lIHRvIGEgcmFuZG9tIHZhbHVlIHBsZWFzZQ"`
+}
+
+// EmailRequest asks for a link to be sent to an email address
+type EmailRequest struct {
+	Email string `json:"email" example:"user@example.com"`
+}
+
+// ResetPasswordRequest sets a new password with the token from a reset link
+type ResetPasswordRequest struct {
+	Token    string `json:"token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+	Password string `json:"password" example:"N3wP@ssw0rd!"`
+}
+
+// CreateAPITokenRequest describes a new personal access token, without expires_at it never expires
+type CreateAPITokenRequest struct {
+	Name      string     `json:"name" example:"backup script"`
+	Scopes    []string   `json:"scopes" example:"notes:read,notebooks:read"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" example:"2025-01-01T00:00:00Z"`
+}
+
+// UpdateUserRequest changes the profile, omitted fields stay as they are
+type UpdateUserRequest struct {
+	Username *string `json:"username,omitempty" example:"john_doe"`
+	Email    *string `json:"email,omitempty" example:"new@example.com"`
+}
+
+// ChangePasswordRequest replaces the password, the current one is required
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" example:"P@ssw0rd!"`
+	NewPassword     string `json:"new_password" example:"N3wP@ssw0rd!"`
+}
+
+// DeleteAccountRequest confirms account deletion with the password
+type DeleteAccountRequest struct {
+	Password string `json:"password" example:"P@ssw0rd!"`
+}
+
+// VerifyEmailRequest confirms an email address with the token from a verification link
+type VerifyEmailRequest struct {
+	Token string `json:"token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+}
+
 // CreateNoteRequest represents note creation data
 type CreateNoteRequest struct {
-	Title   string `json:"title" example:"My First Note"`
-	Content string `json:"content" example:"Note content here"`
+	Title      string     `json:"title" example:"My First Note"`
+	Content    string     `json:"content" example:"Note content here"`
+	Tags       []string   `json:"tags" example:"math,exam"`
+	NotebookId *uuid.UUID `json:"notebook_id" example:"550e8400-e29b-41d4-a716-446655440000"`
 }
 
-// UpdateNoteRequest represents note update data
+// UpdateNoteRequest represents note update data, omitted tags are left unchanged
 type UpdateNoteRequest struct {
-	Title   string `json:"title" example:"Updated Note Title"`
-	Content string `json:"content" example:"Updated note content"`
+	Title   string   `json:"title" example:"Updated Note Title"`
+	Content string   `json:"content" example:"Updated note content"`
+	Tags    []string `json:"tags" example:"math,exam"`
+}
+
+// SyncChangeRequest is one change of a note made on the client while it was offline. The client sends the whole
+// note as it has it, omitted tags are left unchanged
+type SyncChangeRequest struct {
+	// ID is chosen by the client for notes it creates
+	ID uuid.UUID `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	// BaseVersion is the version of the note the change was made on, 0 creates the note
+	BaseVersion int64    `json:"base_version" example:"3"`
+	Deleted     bool     `json:"deleted,omitempty"`
+	Title       string   `json:"title,omitempty" example:"My First Note"`
+	Content     string   `json:"content,omitempty" example:"Note content here"`
+	Tags        []string `json:"tags,omitempty" example:"math,exam"`
+	// NotebookId is used only when the note is created
+	NotebookId *uuid.UUID `json:"notebook_id,omitempty" example:"550e8400-e29b-41d4-a716-446655440000"`
+}
+
+// SyncPushRequest represents a batch of offline changes, they are applied in order
+type SyncPushRequest struct {
+	Changes []SyncChangeRequest `json:"changes"`
+}
+
+// RenameTagRequest represents tag rename data
+type RenameTagRequest struct {
+	Name string `json:"name" example:"algebra"`
+}
+
+// MergeTagsRequest represents tags merge data
+type MergeTagsRequest struct {
+	Sources []string `json:"sources" example:"algebra,linear-algebra"`
+	Target  string   `json:"target" example:"math"`
+}
+
+// MoveNoteRequest represents target notebook of a note, null moves the note to the top level
+type MoveNoteRequest struct {
+	NotebookId *uuid.UUID `json:"notebook_id" example:"550e8400-e29b-41d4-a716-446655440000"`
+}
+
+// CreateNotebookRequest represents notebook creation data
+type CreateNotebookRequest struct {
+	Name     string     `json:"name" example:"Linear Algebra"`
+	ParentId *uuid.UUID `json:"parent_id" example:"550e8400-e29b-41d4-a716-446655440000"`
+}
+
+// RenameNotebookRequest represents notebook rename data
+type RenameNotebookRequest struct {
+	Name string `json:"name" example:"Calculus"`
+}
+
+// MoveNotebookRequest represents new parent of a notebook, null moves the notebook to the top level
+type MoveNotebookRequest struct {
+	ParentId *uuid.UUID `json:"parent_id" example:"550e8400-e29b-41d4-a716-446655440000"`
+}
+
+// OIDCCallbackRequest represents the query the identity provider redirects back with
+type OIDCCallbackRequest struct {
+	Code  string `json:"code"`
+	State string `json:"state"`
+	// Error is set instead of Code when the user or the provider refused the login
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// SetRoleRequest represents the new role of a user
+type SetRoleRequest struct {
+	Role string `json:"role" example:"moderator" enums:"user,moderator,admin"`
+}
+
+// UnlockRequest represents the account email, the address or "link:<slug>" of a public link to lift a lockout from
+type UnlockRequest struct {
+	Subject string `json:"subject" example:"user@example.com"`
+}
+
+// ShareNoteRequest represents the user to share a note with and their rights
+type ShareNoteRequest struct {
+	Email      string `json:"email" example:"classmate@example.com"`
+	Permission string `json:"permission" example:"viewer" enums:"viewer,editor"`
+}
+
+// CreatePublicLinkRequest describes a new public link, both fields are optional:
+// without a password anyone with the link reads the note, without expires_at it never expires
+type CreatePublicLinkRequest struct {
+	Password  string     `json:"password,omitempty" example:"open sesame"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" example:"2025-01-01T00:00:00Z"`
+}
+
+// UnlockPublicLinkRequest carries the password of a protected public link
+type UnlockPublicLinkRequest struct {
+	Password string `json:"password" example:"open sesame"`
 }
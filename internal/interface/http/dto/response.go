@@ -1,20 +1,269 @@
 package dto
 
-import "github.com/google/uuid"
+import (
+	"2/internal/app/crdt"
+	"2/internal/app/diff"
+	"2/internal/domain/models"
+	"github.com/google/uuid"
+	"time"
+)
 
 // AuthResponse represents authentication token response
 type AuthResponse struct {
-	Token string `json:"token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+	Token        string `json:"token,omitempty" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+	RefreshToken string `json:"refresh_token,omitempty" example:"Q2hhbmdlIG1lIHRvIGEgcmFuZG9tIHZhbHVlIHBsZWFzZQ"`
+	// ExpiresIn is the access token lifetime in seconds
+	ExpiresIn int64 `json:"expires_in,omitempty" example:"900"`
+	// MFARequired means the password was right but the login must be finished at /user/login/2fa with MFAToken
+	MFARequired bool   `json:"mfa_required,omitempty"`
+	MFAToken    string `json:"mfa_token,omitempty" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+}
+
+// TwoFactorEnrollResponse represents a pending authenticator app secret, qr_png is a base64 PNG of otpauth_uri
+type TwoFactorEnrollResponse struct {
+	Secret string `json:"secret" example:"JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"`
+	URI    string `json:"otpauth_uri" example:"otpauth://totp/Notes:user@example.com?secret=JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP&issuer=Notes"`
+	QRCode []byte `json:"qr_png" swaggertype:"string" format:"base64"`
+}
+
+// RecoveryCodesResponse represents one-time recovery codes, they are shown only once
+type RecoveryCodesResponse struct {
+	Codes []string `json:"codes" example:"k7fq2-mx4ta,p3rzd-9wbn6"`
 }
 
 // UserResponse represents user data response
 type UserResponse struct {
-	Email    string    `json:"email" example:"user@example.com"`
-	Username string    `json:"username" example:"john_doe"`
-	UserId   uuid.UUID `json:"userid" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Email         string    `json:"email" example:"user@example.com"`
+	Username      string    `json:"username" example:"john_doe"`
+	UserId        uuid.UUID `json:"userid" example:"550e8400-e29b-41d4-a716-446655440000"`
+	EmailVerified bool      `json:"email_verified" example:"true"`
+	Role          string    `json:"role" example:"user"`
+	Created       time.Time `json:"created" example:"2024-01-01T12:00:00Z"`
+}
+
+// APITokenResponse represents a personal access token without its secret part
+type APITokenResponse struct {
+	ID         uuid.UUID  `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Name       string     `json:"name" example:"backup script"`
+	Prefix     string     `json:"prefix" example:"pat_Xk2mQ9aB"`
+	Scopes     []string   `json:"scopes" example:"notes:read,notebooks:read"`
+	CreatedAt  time.Time  `json:"created_at" example:"2024-01-01T12:00:00Z"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" example:"2025-01-01T00:00:00Z"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" example:"2024-01-02T08:30:00Z"`
+}
+
+// CreatedAPITokenResponse carries the token itself, it is shown only once
+type CreatedAPITokenResponse struct {
+	APITokenResponse
+	Token string `json:"token" example:"pat_Xk2mQ9aBv7TnR1sLw0yZc4dE8fGh2jK5mN6pQ3rS"`
+}
+
+// SessionResponse represents an active login of the user on some device
+type SessionResponse struct {
+	ID         uuid.UUID `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	UserAgent  string    `json:"user_agent" example:"Mozilla/5.0 (X11; Linux x86_64)"`
+	IP         string    `json:"ip" example:"203.0.113.7"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	// Current marks the session the request was made with
+	Current bool `json:"current"`
 }
 
 // StandartResponse represents standart response with message
 type StandartResponse struct {
 	Message string `json:"message" example:"Hello World"`
 }
+
+// RevisionDiffResponse represents difference between two note revisions,
+// either as a unified line diff or as word-level changes
+type RevisionDiffResponse struct {
+	From      int         `json:"from" example:"1"`
+	To        int         `json:"to" example:"3"`
+	FromTitle string      `json:"from_title" example:"Old title"`
+	ToTitle   string      `json:"to_title" example:"New title"`
+	Unified   string      `json:"unified,omitempty" example:"--- revision 1\n+++ revision 3\n@@ -1,1 +1,1 @@\n-old\n+new\n"`
+	Changes   []diff.Edit `json:"changes,omitempty"`
+}
+
+// EmptyTrashResponse reports how many notes were deleted permanently
+type EmptyTrashResponse struct {
+	Deleted int64 `json:"deleted" example:"12"`
+}
+
+// NotesPageResponse represents one page of notes, next_cursor is empty on the last page
+type NotesPageResponse struct {
+	Notes      []models.Note `json:"notes"`
+	NextCursor string        `json:"next_cursor,omitempty" example:"eyJzIjoidXBkYXRlZF9hdCIsImQiOnRydWV9"`
+}
+
+// OIDCProviderResponse represents an identity provider users can sign in with
+type OIDCProviderResponse struct {
+	Name        string `json:"name" example:"university"`
+	DisplayName string `json:"display_name" example:"University account"`
+	LoginURL    string `json:"login_url" example:"/user/oidc/university/login"`
+}
+
+// AdminUserResponse represents a user in the admin API, with the number of their notes
+type AdminUserResponse struct {
+	UserId        uuid.UUID  `json:"userid" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Email         string     `json:"email" example:"user@example.com"`
+	Username      string     `json:"username" example:"john_doe"`
+	Role          string     `json:"role" example:"user"`
+	EmailVerified bool       `json:"email_verified" example:"true"`
+	Disabled      bool       `json:"disabled" example:"false"`
+	DisabledAt    *time.Time `json:"disabled_at,omitempty" example:"2024-03-01T09:00:00Z"`
+	Created       time.Time  `json:"created" example:"2024-01-01T12:00:00Z"`
+	NoteCount     int        `json:"note_count" example:"42"`
+	TrashedCount  int        `json:"trashed_count" example:"3"`
+}
+
+// AdminUserDetailsResponse represents one user in the admin API with their active sessions
+type AdminUserDetailsResponse struct {
+	AdminUserResponse
+	ActiveSessions int `json:"active_sessions" example:"2"`
+}
+
+// AdminUsersPageResponse represents one page of users, next_offset is absent on the last page
+type AdminUsersPageResponse struct {
+	Users      []AdminUserResponse `json:"users"`
+	NextOffset *int                `json:"next_offset,omitempty" example:"50"`
+}
+
+// LockoutResponse represents a lockout of an account, a public link or an address after wrong passwords
+type LockoutResponse struct {
+	ID          uuid.UUID  `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Kind        string     `json:"kind" example:"account" enums:"account,link,ip"`
+	Subject     string     `json:"subject" example:"user@example.com"`
+	IP          string     `json:"ip" example:"203.0.113.7"`
+	Failures    int        `json:"failures" example:"10"`
+	LockedAt    time.Time  `json:"locked_at" example:"2024-01-01T12:00:00Z"`
+	LockedUntil time.Time  `json:"locked_until" example:"2024-01-01T12:15:00Z"`
+	UnlockedAt  *time.Time `json:"unlocked_at,omitempty" example:"2024-01-01T12:05:00Z"`
+}
+
+// NoteShareResponse represents a user a note is shared with
+type NoteShareResponse struct {
+	UserId     uuid.UUID `json:"userid" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Email      string    `json:"email" example:"classmate@example.com"`
+	Username   string    `json:"username" example:"jane_doe"`
+	Permission string    `json:"permission" example:"viewer" enums:"viewer,editor"`
+	CreatedAt  time.Time `json:"created_at" example:"2024-01-01T12:00:00Z"`
+}
+
+// SharedNoteResponse represents a note another user shared with the current one
+type SharedNoteResponse struct {
+	models.Note
+	Permission string    `json:"permission" example:"editor" enums:"viewer,editor"`
+	OwnerEmail string    `json:"owner_email" example:"user@example.com"`
+	SharedAt   time.Time `json:"shared_at" example:"2024-01-01T12:00:00Z"`
+}
+
+// PublicLinkResponse represents a public link of a note as its owner sees it
+type PublicLinkResponse struct {
+	ID           uuid.UUID  `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Slug         string     `json:"slug" example:"q3T9xWk2LmZ8vR1bN0yA4c"`
+	URL          string     `json:"url" example:"http://localhost:8080/p/q3T9xWk2LmZ8vR1bN0yA4c"`
+	HasPassword  bool       `json:"has_password" example:"true"`
+	CreatedAt    time.Time  `json:"created_at" example:"2024-01-01T12:00:00Z"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty" example:"2025-01-01T00:00:00Z"`
+	Views        int64      `json:"views" example:"42"`
+	LastViewedAt *time.Time `json:"last_viewed_at,omitempty" example:"2024-01-02T08:30:00Z"`
+}
+
+// PublicNoteResponse is what anyone with a public link sees, nothing about the owner
+type PublicNoteResponse struct {
+	Title     string    `json:"title" example:"My First Note"`
+	Content   string    `json:"content" example:"This is the content of my note"`
+	Tags      []string  `json:"tags" example:"study,math"`
+	UpdatedAt time.Time `json:"updated_at" example:"2024-01-01T12:00:00Z"`
+}
+
+// NoteEventResponse is the data of a note event in the /events stream
+type NoteEventResponse struct {
+	NoteId uuid.UUID `json:"note_id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	// Note is the note after the change, absent for note.deleted
+	Note *models.Note `json:"note,omitempty"`
+	At   time.Time    `json:"at" example:"2024-01-01T12:00:00Z"`
+}
+
+// SyncChangeResponse is one entry of the change feed, a deleted note comes as a tombstone without its content
+type SyncChangeResponse struct {
+	ID        uuid.UUID    `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Deleted   bool         `json:"deleted"`
+	Note      *models.Note `json:"note,omitempty"`
+	DeletedAt *time.Time   `json:"deleted_at,omitempty" example:"2024-01-01T12:00:00Z"`
+}
+
+// SyncPullResponse represents the changes since a sync token, next_token is since for the next request
+type SyncPullResponse struct {
+	Changes   []SyncChangeResponse `json:"changes"`
+	NextToken string               `json:"next_token" example:"eyJzIjo0MiwiaWQiOiI1NTBlODQwMCJ9"`
+	HasMore   bool                 `json:"has_more"`
+}
+
+// SyncResultResponse tells what became of one change of a batch: applied, conflict (note is the server copy),
+// deleted (the note no longer exists on the server) or rejected (error tells why)
+type SyncResultResponse struct {
+	ID     uuid.UUID    `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Status string       `json:"status" example:"applied" enums:"applied,conflict,deleted,rejected"`
+	Note   *models.Note `json:"note,omitempty"`
+	Error  string       `json:"error,omitempty"`
+}
+
+// SyncPushResponse represents the results of a batch, in the order of its changes
+type SyncPushResponse struct {
+	Results []SyncResultResponse `json:"results"`
+}
+
+// VersionConflictResponse is the answer to If-Match with a version the note no longer has
+type VersionConflictResponse struct {
+	Error          string `json:"error" example:"the note was changed since you read it, its version is now 4"`
+	CurrentVersion int64  `json:"current_version" example:"4"`
+}
+
+// Типы сообщений сокета совместного редактирования.
+const (
+	CollabHello    = "hello"
+	CollabOps      = "ops"
+	CollabCursor   = "cursor"
+	CollabPresence = "presence"
+	CollabReset    = "reset"
+	CollabError    = "error"
+	CollabPing     = "ping"
+)
+
+// CollabMessage is one frame of the collaborative editing socket, Type tells which fields are set
+type CollabMessage struct {
+	Type string `json:"type" enums:"hello,ops,cursor,presence,reset,error,ping"`
+	// Epoch grows every time the server reloads the document, ops of an older epoch are refused
+	Epoch int `json:"epoch,omitempty"`
+	// Site is the sender of ops, cursor and presence, in hello the site given to the client
+	Site       uint32     `json:"site,omitempty"`
+	UserId     *uuid.UUID `json:"user_id,omitempty"`
+	Username   string     `json:"username,omitempty"`
+	Permission string     `json:"permission,omitempty" enums:"viewer,editor,owner"`
+	Event      string     `json:"event,omitempty" enums:"join,leave"`
+	// Clock and Elements are the document in hello and reset, new characters need counters above Clock
+	Clock    uint64               `json:"clock,omitempty"`
+	Elements []crdt.Element       `json:"elements,omitempty"`
+	Peers    []CollabPeerResponse `json:"peers,omitempty"`
+	// Ops are at most 1000 per message, a longer batch is refused whole
+	Ops    []crdt.Op          `json:"ops,omitempty"`
+	Cursor *CollabCursorRange `json:"cursor,omitempty"`
+	Error  string             `json:"error,omitempty"`
+}
+
+// CollabCursorRange is a selection, each end is the character it follows (zero id is the beginning)
+type CollabCursorRange struct {
+	Anchor crdt.ID `json:"anchor"`
+	Head   crdt.ID `json:"head"`
+}
+
+// CollabPeerResponse represents another participant of the editing session
+type CollabPeerResponse struct {
+	Site       uint32             `json:"site" example:"2"`
+	UserId     uuid.UUID          `json:"user_id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Username   string             `json:"username" example:"jane_doe"`
+	Permission string             `json:"permission" example:"editor" enums:"viewer,editor,owner"`
+	Cursor     *CollabCursorRange `json:"cursor,omitempty"`
+}
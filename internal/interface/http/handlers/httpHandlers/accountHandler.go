@@ -0,0 +1,121 @@
+package httpHandlers
+
+import (
+	"2/internal/app/service"
+	"2/internal/interface/http/dto"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+type AccountHandler struct {
+	accountService *service.AccountService
+}
+
+func NewAccountHandler(service *service.AccountService) *AccountHandler {
+	return &AccountHandler{
+		accountService: service,
+	}
+}
+
+// linkSent is the answer to every request for a link, whether the email is registered or not.
+var linkSent = dto.StandartResponse{
+	Message: "If the email is registered, a link has been sent to it",
+}
+
+// ForgotPassword godoc
+// @Summary Request password reset
+// @Description Send a password reset link to the email. The answer is the same for unknown emails
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Param input body dto.EmailRequest true "Account email"
+// @Success 202 {object} dto.StandartResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Router /user/password/forgot [post]
+func (h *AccountHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req dto.EmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		writeError(w, http.StatusBadRequest, "email is required")
+		return
+	}
+
+	// ошибку не показываем, иначе по ней видно, что такой email зарегистрирован
+	if err := h.accountService.ForgotPassword(req.Email); err != nil {
+		slog.Error("Failed to send password reset email", "error", err)
+	}
+
+	writeJSON(w, http.StatusAccepted, linkSent)
+}
+
+// ResetPassword godoc
+// @Summary Reset password
+// @Description Set a new password with the token from a reset link. The link works once, every session of the user is revoked
+// @Tags Account
+// @Accept json
+// @Param input body dto.ResetPasswordRequest true "Reset token and new password"
+// @Success 204
+// @Failure 400 {object} errors.ErrorResponse
+// @Router /user/password/reset [post]
+func (h *AccountHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req dto.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" || req.Password == "" {
+		writeError(w, http.StatusBadRequest, "token and password are required")
+		return
+	}
+
+	if err := h.accountService.ResetPassword(req); err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// VerifyEmail godoc
+// @Summary Verify email
+// @Description Confirm the email address with the token from a verification link
+// @Tags Account
+// @Accept json
+// @Param input body dto.VerifyEmailRequest true "Verification token"
+// @Success 204
+// @Failure 400 {object} errors.ErrorResponse
+// @Router /user/verify-email [post]
+func (h *AccountHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req dto.VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		writeError(w, http.StatusBadRequest, "token is required")
+		return
+	}
+
+	if err := h.accountService.VerifyEmail(req); err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResendVerification godoc
+// @Summary Resend verification link
+// @Description Send a new verification link. The answer is the same for unknown and already verified emails
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Param input body dto.EmailRequest true "Account email"
+// @Success 202 {object} dto.StandartResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Router /user/verify-email/resend [post]
+func (h *AccountHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var req dto.EmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		writeError(w, http.StatusBadRequest, "email is required")
+		return
+	}
+
+	if err := h.accountService.SendVerification(req.Email); err != nil {
+		slog.Error("Failed to send verification email", "error", err)
+	}
+
+	writeJSON(w, http.StatusAccepted, linkSent)
+}
@@ -0,0 +1,289 @@
+package httpHandlers
+
+import (
+	"2/internal/app/service"
+	"2/internal/domain/models"
+	"2/internal/interface/http/dto"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+type AdminHandler struct {
+	adminService *service.AdminService
+}
+
+func NewAdminHandler(service *service.AdminService) *AdminHandler {
+	return &AdminHandler{
+		adminService: service,
+	}
+}
+
+// GetUsers godoc
+// @Summary List users
+// @Description Search users by a part of email or username, newest first, with the number of their notes. Moderators and admins only
+// @Tags Admin
+// @Security JWTAuth
+// @Produce json
+// @Param q query string false "Part of email or username"
+// @Param role query string false "Only users with the role" Enums(user, moderator, admin)
+// @Param disabled query bool false "Only disabled (true) or only active (false) accounts"
+// @Param limit query int false "Page size" default(50) maximum(200)
+// @Param offset query int false "Offset, next_offset of the previous page"
+// @Success 200 {object} dto.AdminUsersPageResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse
+// @Router /admin/users [get]
+func (h *AdminHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.UserFilter{
+		Query: query.Get("q"),
+		Role:  query.Get("role"),
+	}
+
+	if value := query.Get("disabled"); value != "" {
+		disabled, err := strconv.ParseBool(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "disabled must be true or false")
+			return
+		}
+		filter.Disabled = &disabled
+	}
+	for name, target := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+		if value := query.Get(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("%s must be a non-negative number", name))
+				return
+			}
+			*target = n
+		}
+	}
+
+	page, err := h.adminService.FindUsers(filter)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+// GetUser godoc
+// @Summary Get user
+// @Description Get a user with the number of their notes and active sessions. Moderators and admins only
+// @Tags Admin
+// @Security JWTAuth
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} dto.AdminUserDetailsResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /admin/users/{id} [get]
+func (h *AdminHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	id, err := pathUUID(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, err := h.adminService.GetUser(id)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+// DisableUser godoc
+// @Summary Disable user
+// @Description Disable an account: the user is logged out everywhere, cannot log in and their personal access tokens stop working.
+// @Description Moderators manage plain users, admins manage everyone but themselves
+// @Tags Admin
+// @Security JWTAuth
+// @Param id path string true "User ID"
+// @Success 204
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /admin/users/{id}/disable [post]
+func (h *AdminHandler) DisableUser(w http.ResponseWriter, r *http.Request) {
+	h.setDisabled(w, r, true)
+}
+
+// EnableUser godoc
+// @Summary Enable user
+// @Description Enable a disabled account, the user can log in again
+// @Tags Admin
+// @Security JWTAuth
+// @Param id path string true "User ID"
+// @Success 204
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /admin/users/{id}/enable [post]
+func (h *AdminHandler) EnableUser(w http.ResponseWriter, r *http.Request) {
+	h.setDisabled(w, r, false)
+}
+
+func (h *AdminHandler) setDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	actorId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	id, err := pathUUID(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err = h.adminService.SetDisabled(actorId, id, disabled); err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ForceLogout godoc
+// @Summary Log user out
+// @Description Revoke every session of the user, access tokens stop working immediately
+// @Tags Admin
+// @Security JWTAuth
+// @Param id path string true "User ID"
+// @Success 204
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /admin/users/{id}/logout [post]
+func (h *AdminHandler) ForceLogout(w http.ResponseWriter, r *http.Request) {
+	actorId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	id, err := pathUUID(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err = h.adminService.ForceLogout(actorId, id); err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetRole godoc
+// @Summary Change user role
+// @Description Make a user a moderator or an admin, or take the role away. The user is logged out so that new tokens carry the role. Admins only
+// @Tags Admin
+// @Security JWTAuth
+// @Accept json
+// @Param id path string true "User ID"
+// @Param input body dto.SetRoleRequest true "New role"
+// @Success 204
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /admin/users/{id}/role [put]
+func (h *AdminHandler) SetRole(w http.ResponseWriter, r *http.Request) {
+	actorId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	id, err := pathUUID(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var req dto.SetRoleRequest
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err = h.adminService.SetRole(actorId, id, req); err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetLockouts godoc
+// @Summary List login lockouts
+// @Description Latest lockouts of accounts and addresses after failed logins, newest first. Moderators and admins only
+// @Tags Admin
+// @Security JWTAuth
+// @Produce json
+// @Param limit query int false "How many lockouts to return" default(50)
+// @Success 200 {array} dto.LockoutResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse
+// @Router /admin/lockouts [get]
+func (h *AdminHandler) GetLockouts(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+	}
+
+	lockouts, err := h.adminService.Lockouts(limit)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, lockouts)
+}
+
+// Unlock godoc
+// @Summary Lift login lockout
+// @Description Lift the delay or lockout of an account (by email), of an IP address or of a public link (link:<slug>)
+// @Tags Admin
+// @Security JWTAuth
+// @Accept json
+// @Param input body dto.UnlockRequest true "Email, IP address or link:<slug>"
+// @Success 204
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /admin/lockouts/unlock [post]
+func (h *AdminHandler) Unlock(w http.ResponseWriter, r *http.Request) {
+	var req dto.UnlockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.adminService.Unlock(req); err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,111 @@
+package httpHandlers
+
+import (
+	"2/internal/app/service"
+	"2/internal/interface/http/dto"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type APITokenHandler struct {
+	apiTokenService *service.APITokenService
+}
+
+func NewAPITokenHandler(service *service.APITokenService) *APITokenHandler {
+	return &APITokenHandler{
+		apiTokenService: service,
+	}
+}
+
+// CreateToken godoc
+// @Summary Create personal access token
+// @Description Create a token for scripts and integrations, send it as "Authorization: Bearer pat_...". It works only on routes its scopes allow.
+// @Description The token is in this response only, store it right away
+// @Tags Tokens
+// @Security JWTAuth
+// @Accept json
+// @Produce json
+// @Param input body dto.CreateAPITokenRequest true "Token name, scopes and optional expiry"
+// @Success 201 {object} dto.CreatedAPITokenResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Router /user/tokens [post]
+func (h *APITokenHandler) CreateToken(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	var req dto.CreateAPITokenRequest
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	token, err := h.apiTokenService.CreateToken(userId, req)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, token)
+}
+
+// GetTokens godoc
+// @Summary Get personal access tokens
+// @Description List tokens of the current user, without their secret part
+// @Tags Tokens
+// @Security JWTAuth
+// @Produce json
+// @Success 200 {array} dto.APITokenResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Router /user/tokens [get]
+func (h *APITokenHandler) GetTokens(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	tokens, err := h.apiTokenService.GetTokens(userId)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tokens)
+}
+
+// RevokeToken godoc
+// @Summary Revoke personal access token
+// @Description Delete a token, it stops working immediately
+// @Tags Tokens
+// @Security JWTAuth
+// @Param id path string true "Token ID"
+// @Success 204
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /user/tokens/{id} [delete]
+func (h *APITokenHandler) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	id, err := pathUUID(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err = h.apiTokenService.RevokeToken(userId, id); err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -5,20 +5,26 @@ import (
 	"2/internal/errors"
 	"2/internal/interface/http/dto"
 	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
 )
 
 type AuthHandler struct {
-	AuthService *service.AuthService
+	AuthService    *service.AuthService
+	AccountService *service.AccountService
 }
 
-func NewAuthHandler(authService *service.AuthService) *AuthHandler {
-	return &AuthHandler{AuthService: authService}
+func NewAuthHandler(authService *service.AuthService, accountService *service.AccountService) *AuthHandler {
+	return &AuthHandler{AuthService: authService, AccountService: accountService}
 }
 
 // Register godoc
 // @Summary User registration
-// @Description Create new user account
+// @Description Create new user account and send a verification link to its email.
+// @Description The password must pass the password policy: length, no email or username in it, not a leaked password
 // @Tags Auth
 // @Accept json
 // @Produce json
@@ -57,6 +63,11 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// аккаунт уже создан, письмо можно запросить повторно через /user/verify-email/resend
+	if err = h.AccountService.SendVerification(req.Email); err != nil {
+		slog.Error("Failed to send verification email", "error", err)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	response := dto.StandartResponse{
@@ -69,7 +80,11 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 
 // Login godoc
 // @Summary User authentication
-// @Description Get JWT access token
+// @Description Start a session, get a short-lived JWT access token and a refresh token.
+// @Description With two-factor authentication enabled the response carries mfa_required and mfa_token instead, finish the login at /user/login/2fa
+// @Description When the server requires verified emails, unverified accounts get 403.
+// @Description Unknown emails and wrong passwords get the same 401. After repeated failures the account
+// @Description and the address have to wait, 429 with Retry-After until then
 // @Tags Auth
 // @Accept json
 // @Produce json
@@ -77,6 +92,8 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 // @Success 200 {object} dto.AuthResponse
 // @Failure 400 {object} errors.ErrorResponse
 // @Failure 401 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse
+// @Failure 429 {object} errors.ErrorResponse
 // @Failure 500 {object} errors.ErrorResponse
 // @Router /user/login [post]
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
@@ -85,27 +102,191 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-
+		writeError(w, http.StatusBadRequest, "email and password are required")
+		return
 	}
 
-	token, err := h.AuthService.LoginUser(req)
+	response, err := h.AuthService.LoginUser(req, clientInfo(r))
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		resp := errors.ErrorResponse{
-			Error: err.Error(),
-		}
-		json.NewEncoder(w).Encode(resp)
+		setRetryAfter(w, err)
+		writeError(w, errorStatus(err), err.Error())
 		return
 	}
 
-	response := dto.AuthResponse{
-		Token: token,
-	}
-
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
 	json.NewEncoder(w).Encode(response)
 }
+
+// setRetryAfter tells a throttled client when to try again.
+func setRetryAfter(w http.ResponseWriter, err error) {
+	var throttled *service.LoginThrottledError
+	if stderrors.As(err, &throttled) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(throttled.RetryAfter.Seconds())))
+	}
+}
+
+// Refresh godoc
+// @Summary Refresh tokens
+// @Description Exchange a refresh token for a new access and refresh token pair. A refresh token works once, reusing it revokes the session
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param input body dto.RefreshRequest true "Refresh token"
+// @Success 200 {object} dto.AuthResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Router /user/refresh [post]
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req dto.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		writeError(w, http.StatusBadRequest, "refresh_token is required")
+		return
+	}
+
+	response, err := h.AuthService.Refresh(req)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// Logout godoc
+// @Summary Log out
+// @Description Revoke the current session, its access and refresh tokens stop working
+// @Tags Auth
+// @Security JWTAuth
+// @Success 204
+// @Failure 401 {object} errors.ErrorResponse
+// @Router /user/logout [post]
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	sessionId, err := getSessionIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	if err = h.AuthService.Logout(sessionId); err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAll godoc
+// @Summary Log out everywhere
+// @Description Revoke every session of the current user
+// @Tags Auth
+// @Security JWTAuth
+// @Success 204
+// @Failure 401 {object} errors.ErrorResponse
+// @Router /user/logout-all [post]
+func (h *AuthHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	if err = h.AuthService.LogoutAll(userId); err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetSessions godoc
+// @Summary Get sessions
+// @Description List devices the current user is logged in on
+// @Tags Auth
+// @Security JWTAuth
+// @Produce json
+// @Success 200 {array} dto.SessionResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Router /user/sessions [get]
+func (h *AuthHandler) GetSessions(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+	sessionId, err := getSessionIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	sessions, err := h.AuthService.GetSessions(userId, sessionId)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, sessions)
+}
+
+// RevokeSession godoc
+// @Summary Revoke session
+// @Description Log out a single device
+// @Tags Auth
+// @Security JWTAuth
+// @Param id path string true "Session ID"
+// @Success 204
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /user/sessions/{id} [delete]
+func (h *AuthHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	sessionId, err := pathUUID(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err = h.AuthService.RevokeSession(userId, sessionId); err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LoginTwoFactor godoc
+// @Summary Second login step
+// @Description Finish a login with two-factor authentication using mfa_token from /user/login and an authenticator code or a recovery code
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param input body dto.LoginTwoFactorRequest true "MFA token and code"
+// @Success 200 {object} dto.AuthResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 429 {object} errors.ErrorResponse
+// @Router /user/login/2fa [post]
+func (h *AuthHandler) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req dto.LoginTwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MFAToken == "" || req.Code == "" {
+		writeError(w, http.StatusBadRequest, "mfa_token and code are required")
+		return
+	}
+
+	response, err := h.AuthService.LoginSecondFactor(req, clientInfo(r))
+	if err != nil {
+		setRetryAfter(w, err)
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,171 @@
+package httpHandlers
+
+import (
+	"2/internal/app/service"
+	"2/internal/domain/models"
+	"2/internal/interface/http/dto"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"github.com/google/uuid"
+	"golang.org/x/net/websocket"
+	"net/http"
+	"slices"
+	"time"
+)
+
+const (
+	// кадр больше мегабайта честный редактор не пришлёт
+	maxCollabFrame = 1 << 20
+	// столько ждём отправки одного кадра медленному клиенту
+	collabWriteTimeout = 10 * time.Second
+)
+
+type CollabHandler struct {
+	collabService *service.CollabService
+	guard         StreamGuard
+	heartbeat     time.Duration
+}
+
+// NewCollabHandler returns a handler that pings every heartbeat, or every 30 seconds
+// when it is not positive.
+func NewCollabHandler(service *service.CollabService, guard StreamGuard, heartbeat time.Duration) *CollabHandler {
+	if heartbeat <= 0 {
+		heartbeat = defaultHeartbeat
+	}
+	return &CollabHandler{
+		collabService: service,
+		guard:         guard,
+		heartbeat:     heartbeat,
+	}
+}
+
+// Collaborate godoc
+// @Summary Edit note together
+// @Description WebSocket for live editing of the note with other users, every frame is dto.CollabMessage as JSON. Browsers pass
+// @Description the token as the subprotocols "bearer" and the token, the server answers with "bearer". The server first sends
+// @Description hello with the site of the client, the document as CRDT elements and the other participants. The client then
+// @Description sends ops with the epoch of the document: inserts get ids of its own site with counters above every counter it
+// @Description has seen, deletes name the character, at most 1000 ops per message. The server applies ops in order and passes them to the others, so the
+// @Description client must apply ops it receives even if they arrive after its own edits. cursor shares the selection and
+// @Description ping keeps the connection, which is closed after two heartbeats of silence. When the note is changed outside
+// @Description the session the server sends reset with the new document and a new epoch, ops of the old epoch are refused.
+// @Description The text is saved to the note every COLLAB_COMPACT_INTERVAL and when the last participant leaves. Viewers and
+// @Description tokens without notes:write only watch
+// @Tags Collaboration
+// @Security JWTAuth
+// @Param id path string true "Note ID"
+// @Success 101 {object} dto.CollabMessage
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /notes/{id}/collab [get]
+func (h *CollabHandler) Collaborate(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	noteId, err := pathUUID(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// токен без notes:write может только смотреть
+	scopes, ok := r.Context().Value("scopes").([]string)
+	readOnly := ok && !slices.Contains(scopes, models.ScopeNotesWrite)
+
+	// подключаемся до рукопожатия, чтобы отказ пришёл обычным HTTP-ответом
+	peer, err := h.collabService.Join(userId, noteId, readOnly)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+	defer peer.Leave()
+
+	server := websocket.Server{
+		Handshake: func(config *websocket.Config, r *http.Request) error {
+			// токен из подпротоколов обратно не отдаём, подтверждаем только bearer
+			offered := config.Protocol
+			config.Protocol = nil
+			if slices.Contains(offered, "bearer") {
+				config.Protocol = []string{"bearer"}
+			}
+			return nil
+		},
+		Handler: func(conn *websocket.Conn) {
+			h.serve(conn, r, userId, peer)
+		},
+	}
+	server.ServeHTTP(w, r)
+}
+
+func (h *CollabHandler) serve(conn *websocket.Conn, r *http.Request, userId uuid.UUID, peer *service.CollabPeer) {
+	conn.MaxPayloadBytes = maxCollabFrame
+
+	done := make(chan struct{})
+	defer close(done)
+
+	// пишет только эта горутина; канал участника закрывается, когда его отключили
+	go func() {
+		defer conn.Close()
+
+		heartbeat := time.NewTicker(h.heartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			var msg dto.CollabMessage
+			select {
+			case <-done:
+				return
+			case m, ok := <-peer.Messages():
+				if !ok {
+					return
+				}
+				msg = m
+			case <-heartbeat.C:
+				if checkStream(h.guard, r, userId) != nil {
+					return
+				}
+				msg = dto.CollabMessage{Type: dto.CollabPing}
+			}
+
+			conn.SetWriteDeadline(time.Now().Add(collabWriteTimeout))
+			if websocket.JSON.Send(conn, msg) != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(2 * h.heartbeat))
+
+		var msg dto.CollabMessage
+		if err := websocket.JSON.Receive(conn, &msg); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if !stderrors.As(err, &syntaxErr) && !stderrors.As(err, &typeErr) {
+				return
+			}
+			peer.SendError(fmt.Errorf("invalid message: %w", err))
+			continue
+		}
+
+		var err error
+		switch msg.Type {
+		case dto.CollabOps:
+			err = peer.Apply(msg.Epoch, msg.Ops)
+		case dto.CollabCursor:
+			err = peer.SetCursor(msg.Cursor)
+		case dto.CollabPing:
+		default:
+			err = fmt.Errorf("unknown message type %q", msg.Type)
+		}
+		if err != nil {
+			peer.SendError(err)
+		}
+	}
+}
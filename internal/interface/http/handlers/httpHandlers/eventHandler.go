@@ -0,0 +1,159 @@
+package httpHandlers
+
+import (
+	"2/internal/app/events"
+	"2/internal/domain/models"
+	"2/internal/interface/http/dto"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// через сколько миллисекунд браузер переподключается после обрыва
+	eventRetryMillis = 3000
+	// тикер с нулевым периодом паникует, поэтому без настройки берём этот
+	defaultHeartbeat = 30 * time.Second
+)
+
+// StreamGuard is asked on every heartbeat whether the stream may go on, so that a logout
+// or a disabled account ends it instead of waiting for the client to reconnect.
+type StreamGuard interface {
+	CheckSession(userId uuid.UUID, sessionId uuid.UUID) error
+	CheckAccount(userId uuid.UUID) error
+}
+
+type EventHandler struct {
+	bus       *events.Bus
+	guard     StreamGuard
+	heartbeat time.Duration
+}
+
+// NewEventHandler returns a handler that sends a heartbeat every heartbeat, or every
+// 30 seconds when it is not positive.
+func NewEventHandler(bus *events.Bus, guard StreamGuard, heartbeat time.Duration) *EventHandler {
+	if heartbeat <= 0 {
+		heartbeat = defaultHeartbeat
+	}
+	return &EventHandler{
+		bus:       bus,
+		guard:     guard,
+		heartbeat: heartbeat,
+	}
+}
+
+// Stream godoc
+// @Summary Note change stream
+// @Description Server-Sent Events about notes of the user and notes shared with them: note.created (also a note restored
+// @Description from the trash), note.updated and note.deleted, each with dto.NoteEventResponse as data. Every connection
+// @Description first gets the events missed since Last-Event-ID, then ready, or reset when the missed events are no longer
+// @Description kept: the client should then reload its notes. Browsers resume with the Last-Event-ID header on their own,
+// @Description last_event_id does the same for the first connection
+// @Tags Events
+// @Security JWTAuth
+// @Produce text/event-stream
+// @Param Last-Event-ID header string false "Id of the last event the client received"
+// @Param last_event_id query string false "Same as Last-Event-ID"
+// @Success 200 {object} dto.NoteEventResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Router /events [get]
+func (h *EventHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	after, err := lastEventID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sub, missed, complete := h.bus.Subscribe(userId, after)
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	// иначе nginx копит поток в буфере
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", eventRetryMillis)
+	for _, event := range missed {
+		writeNoteEvent(w, event)
+	}
+	// id маркера двигает Last-Event-ID клиента, даже если событий не было
+	marker := "ready"
+	if !complete {
+		marker = "reset"
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: {}\n\n", sub.Start(), marker)
+	if err = rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			writeNoteEvent(w, event)
+		case <-heartbeat.C:
+			if err = checkStream(h.guard, r, userId); err != nil {
+				return
+			}
+			fmt.Fprint(w, ": ping\n\n")
+		}
+		if err = rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// checkStream repeats the checks of the auth middleware that can change while the stream
+// is open. Personal access tokens have no session, only the account is checked for them.
+func checkStream(guard StreamGuard, r *http.Request, userId uuid.UUID) error {
+	if sessionId, ok := r.Context().Value("sessionId").(uuid.UUID); ok {
+		if err := guard.CheckSession(userId, sessionId); err != nil {
+			return err
+		}
+	}
+	return guard.CheckAccount(userId)
+}
+
+func writeNoteEvent(w http.ResponseWriter, event models.NoteEvent) {
+	data, _ := json.Marshal(dto.NoteEventResponse{
+		NoteId: event.NoteId,
+		Note:   event.Note,
+		At:     event.At,
+	})
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+}
+
+func lastEventID(r *http.Request) (int64, error) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	if value == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 0 {
+		return 0, fmt.Errorf("Last-Event-ID %s is invalid", value)
+	}
+	return id, nil
+}
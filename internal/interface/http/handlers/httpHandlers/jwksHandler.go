@@ -0,0 +1,29 @@
+package httpHandlers
+
+import (
+	"2/internal/app/signing"
+	"net/http"
+)
+
+type JWKSHandler struct {
+	keys *signing.Keyring
+}
+
+func NewJWKSHandler(keys *signing.Keyring) *JWKSHandler {
+	return &JWKSHandler{
+		keys: keys,
+	}
+}
+
+// GetJWKS godoc
+// @Summary JSON Web Key Set
+// @Description Public keys access tokens are signed with, for services that verify tokens on their own.
+// @Description Tokens name their key in the kid header, fetch the set again when a kid is unknown
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} signing.JWKS
+// @Router /.well-known/jwks.json [get]
+func (h *JWKSHandler) GetJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, h.keys.JWKS())
+}
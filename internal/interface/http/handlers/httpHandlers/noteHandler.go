@@ -2,21 +2,32 @@ package httpHandlers
 
 import (
 	"2/internal/app/service"
+	"2/internal/domain/models"
 	"2/internal/errors"
 	"2/internal/interface/http/dto"
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"github.com/google/uuid"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
+var errIfMatchRequired = stderrors.New("If-Match with the ETag of the note is required")
+
 type NoteHandler struct {
 	noteService *service.NoteService
+	// requireIfMatch turns away PUT, DELETE and moves without If-Match instead of applying them blindly
+	requireIfMatch bool
 }
 
-func NewNoteHandler(service *service.NoteService) *NoteHandler {
+func NewNoteHandler(service *service.NoteService, requireIfMatch bool) *NoteHandler {
 	return &NoteHandler{
-		noteService: service,
+		noteService:    service,
+		requireIfMatch: requireIfMatch,
 	}
 }
 
@@ -33,6 +44,9 @@ func getUserIDFromContext(r *http.Request) (uuid.UUID, error) {
 		// Если не нашли, пробуем с ключом "user_id"
 		userIDVal = r.Context().Value("user_id")
 		if userIDVal == nil {
+			if usesAPIToken(r) {
+				return uuid.UUID{}, errAPITokenNotAllowed
+			}
 			return uuid.UUID{}, fmt.Errorf("user ID not found in context")
 		}
 	}
@@ -56,14 +70,18 @@ func getUserIDFromContext(r *http.Request) (uuid.UUID, error) {
 
 // GetNoteHandler godoc
 // @Summary Get note by ID
-// @Description Get single note by its ID
+// @Description Get single note by its ID, an own note or one shared with the user. The ETag header is the version of the
+// @Description note, send it back as If-Match when changing the note. If-None-Match with the current ETag answers 304
 // @Tags Notes
 // @Security JWTAuth
 // @Produce json
 // @Param id path string true "Note ID"
+// @Param If-None-Match header string false "ETag of the copy the client has"
 // @Success 200 {object} models.Note
+// @Success 304
 // @Failure 400 {object} errors.ErrorResponse
 // @Failure 401 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse
 // @Failure 404 {object} errors.ErrorResponse
 // @Router /notes/{id} [get]
 func (h *NoteHandler) GetNoteHandler(w http.ResponseWriter, r *http.Request) {
@@ -94,7 +112,7 @@ func (h *NoteHandler) GetNoteHandler(w http.ResponseWriter, r *http.Request) {
 	note, err := h.noteService.GetNote(userId, noteId)
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
+		w.WriteHeader(errorStatus(err))
 		resp := errors.ErrorResponse{
 			Error: err.Error(),
 		}
@@ -102,18 +120,36 @@ func (h *NoteHandler) GetNoteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	etag := noteETag(note.Version)
+	w.Header().Set("ETag", etag)
+	if match := r.Header.Get("If-None-Match"); match == "*" || slices.Contains(etagList(match), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(note)
 }
 
 // GetNotes godoc
-// @Summary Get all notes
-// @Description Get list of all user's notes
+// @Summary Get notes
+// @Description Get a page of user's notes, optionally filtered by tags and dates. Pass next_cursor of the response as cursor to get the next page
 // @Tags Notes
 // @Security JWTAuth
 // @Produce json
-// @Success 200 {array} models.Note
+// @Param tag query []string false "Tag to filter by, repeat for several tags" collectionFormat(multi)
+// @Param match query string false "Tag matching mode" Enums(any, all) default(any)
+// @Param limit query int false "Page size" default(50) maximum(200)
+// @Param cursor query string false "Cursor from the previous page"
+// @Param sort query string false "Sort field" Enums(updated_at, created_at, title) default(updated_at)
+// @Param order query string false "Sort direction" Enums(asc, desc) default(desc)
+// @Param created_after query string false "Only notes created after, RFC 3339"
+// @Param created_before query string false "Only notes created before, RFC 3339"
+// @Param updated_after query string false "Only notes updated after, RFC 3339"
+// @Param updated_before query string false "Only notes updated before, RFC 3339"
+// @Success 200 {object} dto.NotesPageResponse
+// @Failure 400 {object} errors.ErrorResponse
 // @Failure 401 {object} errors.ErrorResponse
 // @Failure 500 {object} errors.ErrorResponse
 // @Router /notes [get]
@@ -125,7 +161,56 @@ func (h *NoteHandler) GetNotes(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	notes, err := h.noteService.GetUserNotes(userId)
+	filter := models.NoteFilter{
+		Tags: r.URL.Query()["tag"],
+	}
+	switch r.URL.Query().Get("match") {
+	case "", "any":
+	case "all":
+		filter.MatchAllTags = true
+	default:
+		writeError(w, http.StatusBadRequest, "match must be any or all")
+		return
+	}
+
+	query := r.URL.Query()
+	for name, bound := range map[string]**time.Time{
+		"created_after":  &filter.CreatedAfter,
+		"created_before": &filter.CreatedBefore,
+		"updated_after":  &filter.UpdatedAfter,
+		"updated_before": &filter.UpdatedBefore,
+	} {
+		if value := query.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("%s must be an RFC 3339 time", name))
+				return
+			}
+			*bound = &t
+		}
+	}
+
+	page := models.NotePage{
+		Sort: models.NoteSort(query.Get("sort")),
+		Desc: true,
+	}
+	switch query.Get("order") {
+	case "", "desc":
+	case "asc":
+		page.Desc = false
+	default:
+		writeError(w, http.StatusBadRequest, "order must be asc or desc")
+		return
+	}
+	if value := query.Get("limit"); value != "" {
+		page.Limit, err = strconv.Atoi(value)
+		if err != nil || page.Limit <= 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+	}
+
+	notes, err := h.noteService.GetUserNotes(userId, filter, page, query.Get("cursor"))
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
@@ -133,6 +218,7 @@ func (h *NoteHandler) GetNotes(w http.ResponseWriter, r *http.Request) {
 			Error: err.Error(),
 		}
 		json.NewEncoder(w).Encode(resp)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -191,14 +277,19 @@ func (h *NoteHandler) CreateNote(w http.ResponseWriter, r *http.Request) {
 
 // DeleteNote godoc
 // @Summary Delete note
-// @Description Delete note by ID
+// @Description Move note to the trash, it can be restored until the trash is purged. Only the owner can delete a note.
+// @Description With If-Match the note is only deleted while it is at that version
 // @Tags Notes
 // @Security JWTAuth
 // @Param id path string true "Note ID"
+// @Param If-Match header string false "ETag of the note from GET /notes/{id}"
 // @Success 204
 // @Failure 400 {object} errors.ErrorResponse
 // @Failure 401 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse
 // @Failure 404 {object} errors.ErrorResponse
+// @Failure 412 {object} dto.VersionConflictResponse
+// @Failure 428 {object} errors.ErrorResponse
 // @Router /notes/{id} [delete]
 func (h *NoteHandler) DeleteNote(w http.ResponseWriter, r *http.Request) {
 	// Безопасное получение UUID
@@ -225,31 +316,37 @@ func (h *NoteHandler) DeleteNote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.noteService.DeleteNote(userId, noteId)
+	version, err := h.ifMatch(r)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		resp := errors.ErrorResponse{
-			Error: err.Error(),
-		}
-		json.NewEncoder(w).Encode(resp)
+		writeIfMatchError(w, err)
+		return
+	}
+
+	err = h.noteService.DeleteNote(userId, noteId, version)
+	if err != nil {
+		writeNoteError(w, err)
 		return
 	}
 }
 
 // UpdateNote godoc
 // @Summary Update note
-// @Description Update existing note
+// @Description Update existing note, an own note or one shared with the user as editor. With If-Match the note is only
+// @Description changed while it is at that version, otherwise 412 tells the current one. The new ETag comes back with the note
 // @Tags Notes
 // @Security JWTAuth
 // @Accept json
 // @Produce json
 // @Param id path string true "Note ID"
+// @Param If-Match header string false "ETag of the note from GET /notes/{id}"
 // @Param input body dto.UpdateNoteRequest true "Updated note data"
 // @Success 200 {object} models.Note
 // @Failure 400 {object} errors.ErrorResponse
 // @Failure 401 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse
 // @Failure 404 {object} errors.ErrorResponse
+// @Failure 412 {object} dto.VersionConflictResponse
+// @Failure 428 {object} errors.ErrorResponse
 // @Router /notes/{id} [put]
 func (h *NoteHandler) UpdateNote(w http.ResponseWriter, r *http.Request) {
 	// Безопасное получение UUID
@@ -288,15 +385,133 @@ func (h *NoteHandler) UpdateNote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.noteService.UpdateNote(userId, noteId, req)
+	version, err := h.ifMatch(r)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		resp := errors.ErrorResponse{
-			Error: err.Error(),
-		}
-		json.NewEncoder(w).Encode(resp)
+		writeIfMatchError(w, err)
+		return
+	}
+
+	note, err := h.noteService.UpdateNote(userId, noteId, req, version)
+	if err != nil {
+		writeNoteError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", noteETag(note.Version))
+	writeJSON(w, http.StatusOK, note)
+}
+
+// MoveNote godoc
+// @Summary Move note
+// @Description Move note into a notebook or to the top level. The move changes the version of the note: with If-Match
+// @Description the note is only moved while it is at that version, otherwise 412 tells the current one. The new ETag comes back with the note
+// @Tags Notes
+// @Security JWTAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Note ID"
+// @Param If-Match header string false "ETag of the note from GET /notes/{id}"
+// @Param input body dto.MoveNoteRequest true "Target notebook"
+// @Success 200 {object} models.Note
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 412 {object} dto.VersionConflictResponse
+// @Failure 428 {object} errors.ErrorResponse
+// @Router /notes/{id}/move [post]
+func (h *NoteHandler) MoveNote(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	noteId, err := pathUUID(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var req dto.MoveNoteRequest
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	version, err := h.ifMatch(r)
+	if err != nil {
+		writeIfMatchError(w, err)
+		return
+	}
+
+	note, err := h.noteService.MoveNote(userId, noteId, req.NotebookId, version)
+	if err != nil {
+		writeNoteError(w, err)
 		return
+	}
+
+	w.Header().Set("ETag", noteETag(note.Version))
+	writeJSON(w, http.StatusOK, note)
+}
+
+// noteETag is the strong ETag of a note version.
+func noteETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ifMatch returns the note version required by If-Match, 0 when any version will do.
+func (h *NoteHandler) ifMatch(r *http.Request) (int64, error) {
+	value := r.Header.Get("If-Match")
+	if value == "" {
+		if h.requireIfMatch {
+			return 0, errIfMatchRequired
+		}
+		return 0, nil
+	}
+	if value == "*" {
+		return 0, nil
+	}
 
+	// версия у заметки одна, поэтому и ETag ждём один; слабый W/ для If-Match не годится
+	tags := etagList(value)
+	if len(tags) == 1 && len(tags[0]) > 2 && strings.HasPrefix(tags[0], `"`) && strings.HasSuffix(tags[0], `"`) {
+		version, err := strconv.ParseInt(tags[0][1:len(tags[0])-1], 10, 64)
+		if err == nil && version > 0 {
+			return version, nil
+		}
+	}
+	return 0, fmt.Errorf("If-Match %s is not an ETag of this note", value)
+}
+
+func etagList(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func writeIfMatchError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if stderrors.Is(err, errIfMatchRequired) {
+		status = http.StatusPreconditionRequired
+	}
+	writeError(w, status, err.Error())
+}
+
+// writeNoteError reports a version mismatch with the current version, both in the body
+// and as the ETag, so the client can reload and retry.
+func writeNoteError(w http.ResponseWriter, err error) {
+	var mismatch *service.VersionMismatchError
+	if stderrors.As(err, &mismatch) {
+		w.Header().Set("ETag", noteETag(mismatch.Current))
+		writeJSON(w, http.StatusPreconditionFailed, dto.VersionConflictResponse{
+			Error:          err.Error(),
+			CurrentVersion: mismatch.Current,
+		})
+		return
 	}
+	writeError(w, errorStatus(err), err.Error())
 }
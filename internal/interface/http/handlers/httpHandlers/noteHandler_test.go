@@ -1 +1,325 @@
 package httpHandlers_test
+
+import (
+	"2/internal/app/events"
+	"2/internal/app/service"
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"2/internal/infrastructure/storage/memory"
+	"2/internal/interface/http/dto"
+	"2/internal/interface/http/handlers/httpHandlers"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// racingNotes lets another write slip in after the service has checked the version
+// and before the repository writes, the way a concurrent request would.
+type racingNotes struct {
+	repository.NotesRepository
+	race func()
+}
+
+func (r *racingNotes) Update(note models.Note) error {
+	r.fire()
+	return r.NotesRepository.Update(note)
+}
+
+func (r *racingNotes) Trash(id uuid.UUID, deletedAt time.Time, version int64) error {
+	r.fire()
+	return r.NotesRepository.Trash(id, deletedAt, version)
+}
+
+func (r *racingNotes) Move(id uuid.UUID, notebookId *uuid.UUID, version int64) error {
+	r.fire()
+	return r.NotesRepository.Move(id, notebookId, version)
+}
+
+func (r *racingNotes) fire() {
+	if race := r.race; race != nil {
+		r.race = nil
+		race()
+	}
+}
+
+type noteEnv struct {
+	mux    *http.ServeMux
+	notes  *racingNotes
+	svc    *service.NoteService
+	userId uuid.UUID
+}
+
+func newNoteEnv(t *testing.T, requireIfMatch bool) *noteEnv {
+	t.Helper()
+
+	notes := memory.NewNotesRepository()
+	users := memory.NewUserRepository(notes)
+	racing := &racingNotes{NotesRepository: notes}
+	svc := service.NewNoteService(racing, memory.NewNotebooksRepository(notes), memory.NewSharesRepository(notes, users), events.NewBus(16))
+	handler := httpHandlers.NewNoteHandler(svc, requireIfMatch)
+
+	env := &noteEnv{mux: http.NewServeMux(), notes: racing, svc: svc, userId: uuid.New()}
+	// вместо AuthMiddleware пользователь кладётся в контекст напрямую
+	auth := func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			next(w, r.WithContext(context.WithValue(r.Context(), "userId", env.userId)))
+		}
+	}
+	env.mux.HandleFunc("GET /notes/{id}", auth(handler.GetNoteHandler))
+	env.mux.HandleFunc("PUT /notes/{id}", auth(handler.UpdateNote))
+	env.mux.HandleFunc("DELETE /notes/{id}", auth(handler.DeleteNote))
+	env.mux.HandleFunc("POST /notes/{id}/move", auth(handler.MoveNote))
+	return env
+}
+
+func (e *noteEnv) createNote(t *testing.T) models.Note {
+	t.Helper()
+	note, err := e.svc.CreateNote(e.userId, dto.CreateNoteRequest{Title: "title", Content: "content"})
+	if err != nil {
+		t.Fatalf("create note: %v", err)
+	}
+	return note
+}
+
+func (e *noteEnv) do(method string, noteId uuid.UUID, headers map[string]string, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/notes/"+noteId.String(), strings.NewReader(body))
+	for name, value := range headers {
+		r.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	e.mux.ServeHTTP(w, r)
+	return w
+}
+
+func (e *noteEnv) update(noteId uuid.UUID, ifMatch string, content string) *httptest.ResponseRecorder {
+	headers := map[string]string{}
+	if ifMatch != "" {
+		headers["If-Match"] = ifMatch
+	}
+	return e.do(http.MethodPut, noteId, headers, `{"title":"title","content":"`+content+`"}`)
+}
+
+func (e *noteEnv) delete(noteId uuid.UUID, ifMatch string) *httptest.ResponseRecorder {
+	headers := map[string]string{}
+	if ifMatch != "" {
+		headers["If-Match"] = ifMatch
+	}
+	return e.do(http.MethodDelete, noteId, headers, "")
+}
+
+// move sends the note to the top level.
+func (e *noteEnv) move(noteId uuid.UUID, ifMatch string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/notes/"+noteId.String()+"/move", strings.NewReader(`{"notebook_id":null}`))
+	if ifMatch != "" {
+		r.Header.Set("If-Match", ifMatch)
+	}
+	w := httptest.NewRecorder()
+	e.mux.ServeHTTP(w, r)
+	return w
+}
+
+// expectConflict checks a 412 that tells the current version both in the body and as the ETag.
+func expectConflict(t *testing.T, w *httptest.ResponseRecorder, current int64) {
+	t.Helper()
+
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("status %d, want 412: %s", w.Code, w.Body)
+	}
+	var resp dto.VersionConflictResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode conflict: %v: %s", err, w.Body)
+	}
+	if resp.CurrentVersion != current || resp.Error == "" {
+		t.Errorf("conflict body %+v, want current_version %d and an error", resp, current)
+	}
+	if etag := w.Header().Get("ETag"); etag != `"`+strconv.FormatInt(current, 10)+`"` {
+		t.Errorf("ETag %s, want the current version %d", etag, current)
+	}
+}
+
+func TestGetNoteETag(t *testing.T) {
+	env := newNoteEnv(t, false)
+	note := env.createNote(t)
+
+	w := env.do(http.MethodGet, note.ID, nil, "")
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"1"` {
+		t.Fatalf("get: status %d, ETag %s, want 200 and \"1\"", w.Code, w.Header().Get("ETag"))
+	}
+
+	for _, tc := range []struct {
+		ifNoneMatch string
+		want        int
+	}{
+		{`"1"`, http.StatusNotModified},
+		{`"0", "1"`, http.StatusNotModified},
+		{`*`, http.StatusNotModified},
+		{`"2"`, http.StatusOK},
+	} {
+		w = env.do(http.MethodGet, note.ID, map[string]string{"If-None-Match": tc.ifNoneMatch}, "")
+		if w.Code != tc.want {
+			t.Errorf("If-None-Match %s: status %d, want %d", tc.ifNoneMatch, w.Code, tc.want)
+		}
+		if tc.want == http.StatusNotModified && w.Body.Len() != 0 {
+			t.Errorf("If-None-Match %s: 304 with a body: %s", tc.ifNoneMatch, w.Body)
+		}
+	}
+
+	// после изменения старый ETag больше не совпадает
+	if w = env.update(note.ID, `"1"`, "changed"); w.Code != http.StatusOK {
+		t.Fatalf("update: status %d: %s", w.Code, w.Body)
+	}
+	w = env.do(http.MethodGet, note.ID, map[string]string{"If-None-Match": `"1"`}, "")
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"2"` {
+		t.Errorf("get after update: status %d, ETag %s, want 200 and \"2\"", w.Code, w.Header().Get("ETag"))
+	}
+}
+
+func TestUpdateNoteIfMatch(t *testing.T) {
+	env := newNoteEnv(t, false)
+	note := env.createNote(t)
+
+	w := env.update(note.ID, `"1"`, "first")
+	if w.Code != http.StatusOK {
+		t.Fatalf("update with the current ETag: status %d: %s", w.Code, w.Body)
+	}
+	var updated models.Note
+	if err := json.Unmarshal(w.Body.Bytes(), &updated); err != nil {
+		t.Fatalf("decode note: %v", err)
+	}
+	if updated.Version != 2 || updated.Content != "first" || w.Header().Get("ETag") != `"2"` {
+		t.Errorf("updated note version %d, content %q, ETag %s, want 2, first, \"2\"", updated.Version, updated.Content, w.Header().Get("ETag"))
+	}
+
+	expectConflict(t, env.update(note.ID, `"1"`, "stale"), 2)
+
+	for _, header := range []string{`W/"2"`, `"2", "3"`, `"two"`, `"0"`, `2`} {
+		if w = env.update(note.ID, header, "bad"); w.Code != http.StatusBadRequest {
+			t.Errorf("If-Match %s: status %d, want 400", header, w.Code)
+		}
+	}
+
+	// * и отсутствие заголовка подходят к любой версии
+	if w = env.update(note.ID, "*", "any"); w.Code != http.StatusOK {
+		t.Errorf("If-Match *: status %d, want 200", w.Code)
+	}
+	if w = env.update(note.ID, "", "blind"); w.Code != http.StatusOK {
+		t.Errorf("no If-Match: status %d, want 200", w.Code)
+	}
+
+	current, err := env.svc.GetNote(env.userId, note.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if current.Content != "blind" || current.Version != 4 {
+		t.Errorf("note content %q, version %d, want blind, 4", current.Content, current.Version)
+	}
+}
+
+func TestDeleteNoteIfMatch(t *testing.T) {
+	env := newNoteEnv(t, false)
+	note := env.createNote(t)
+	env.update(note.ID, "", "changed")
+
+	expectConflict(t, env.delete(note.ID, `"1"`), 2)
+	if _, err := env.svc.GetNote(env.userId, note.ID); err != nil {
+		t.Fatalf("note is gone after a stale delete: %v", err)
+	}
+
+	if w := env.delete(note.ID, `"2"`); w.Code >= 300 {
+		t.Fatalf("delete with the current ETag: status %d: %s", w.Code, w.Body)
+	}
+	if w := env.do(http.MethodGet, note.ID, nil, ""); w.Code != http.StatusNotFound {
+		t.Errorf("get after delete: status %d, want 404", w.Code)
+	}
+}
+
+func TestMoveNoteIfMatch(t *testing.T) {
+	env := newNoteEnv(t, false)
+	note := env.createNote(t)
+
+	w := env.move(note.ID, `"1"`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("move with the current ETag: status %d: %s", w.Code, w.Body)
+	}
+	var moved models.Note
+	if err := json.Unmarshal(w.Body.Bytes(), &moved); err != nil {
+		t.Fatalf("decode note: %v", err)
+	}
+	if moved.Version != 2 || w.Header().Get("ETag") != `"2"` {
+		t.Errorf("moved note version %d, ETag %s, want 2 and \"2\"", moved.Version, w.Header().Get("ETag"))
+	}
+
+	// перенос меняет версию, и старый ETag к правке больше не подходит
+	expectConflict(t, env.move(note.ID, `"1"`), 2)
+	expectConflict(t, env.update(note.ID, `"1"`, "stale"), 2)
+
+	if w = env.move(note.ID, `two`); w.Code != http.StatusBadRequest {
+		t.Errorf("bad If-Match: status %d, want 400", w.Code)
+	}
+	if w = env.move(note.ID, ""); w.Code != http.StatusOK || w.Header().Get("ETag") != `"3"` {
+		t.Errorf("no If-Match: status %d, ETag %s, want 200 and \"3\"", w.Code, w.Header().Get("ETag"))
+	}
+}
+
+func TestRequireIfMatch(t *testing.T) {
+	env := newNoteEnv(t, true)
+	note := env.createNote(t)
+
+	if w := env.update(note.ID, "", "blind"); w.Code != http.StatusPreconditionRequired {
+		t.Errorf("update without If-Match: status %d, want 428", w.Code)
+	}
+	if w := env.delete(note.ID, ""); w.Code != http.StatusPreconditionRequired {
+		t.Errorf("delete without If-Match: status %d, want 428", w.Code)
+	}
+	if w := env.move(note.ID, ""); w.Code != http.StatusPreconditionRequired {
+		t.Errorf("move without If-Match: status %d, want 428", w.Code)
+	}
+	if w := env.update(note.ID, `"1"`, "checked"); w.Code != http.StatusOK {
+		t.Errorf("update with If-Match: status %d, want 200", w.Code)
+	}
+	if w := env.delete(note.ID, "*"); w.Code >= 300 {
+		t.Errorf("delete with If-Match *: status %d", w.Code)
+	}
+}
+
+// A write that lands between the version check and the write of another request wins,
+// the later one gets 412 instead of overwriting or trashing it.
+func TestConcurrentWriteConflicts(t *testing.T) {
+	env := newNoteEnv(t, false)
+
+	for _, tc := range []struct {
+		name string
+		send func(noteId uuid.UUID, ifMatch string) *httptest.ResponseRecorder
+	}{
+		{"update", func(noteId uuid.UUID, ifMatch string) *httptest.ResponseRecorder {
+			return env.update(noteId, ifMatch, "loser")
+		}},
+		{"delete", env.delete},
+		{"move", env.move},
+	} {
+		for _, ifMatch := range []string{`"1"`, ""} {
+			note := env.createNote(t)
+			env.notes.race = func() {
+				if _, err := env.svc.UpdateNote(env.userId, note.ID, dto.UpdateNoteRequest{Title: "title", Content: "winner"}, 0); err != nil {
+					t.Fatalf("racing update: %v", err)
+				}
+			}
+
+			expectConflict(t, tc.send(note.ID, ifMatch), 2)
+
+			current, err := env.svc.GetNote(env.userId, note.ID)
+			if err != nil {
+				t.Fatalf("%s with If-Match %q: note is gone: %v", tc.name, ifMatch, err)
+			}
+			if current.Content != "winner" {
+				t.Errorf("%s with If-Match %q: content %q, want the racing write", tc.name, ifMatch, current.Content)
+			}
+		}
+	}
+}
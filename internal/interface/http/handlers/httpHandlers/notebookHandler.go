@@ -0,0 +1,274 @@
+package httpHandlers
+
+import (
+	"2/internal/app/service"
+	"2/internal/interface/http/dto"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type NotebookHandler struct {
+	notebookService *service.NotebookService
+}
+
+func NewNotebookHandler(service *service.NotebookService) *NotebookHandler {
+	return &NotebookHandler{
+		notebookService: service,
+	}
+}
+
+// CreateNotebook godoc
+// @Summary Create notebook
+// @Description Create notebook, optionally nested into another one
+// @Tags Notebooks
+// @Security JWTAuth
+// @Accept json
+// @Produce json
+// @Param input body dto.CreateNotebookRequest true "Notebook data"
+// @Success 201 {object} models.Notebook
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /notebooks [post]
+func (h *NotebookHandler) CreateNotebook(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	var req dto.CreateNotebookRequest
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	notebook, err := h.notebookService.CreateNotebook(userId, req)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, notebook)
+}
+
+// GetNotebooks godoc
+// @Summary Get notebooks
+// @Description Get flat list of all user's notebooks, parent_id describes the hierarchy
+// @Tags Notebooks
+// @Security JWTAuth
+// @Produce json
+// @Success 200 {array} models.Notebook
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /notebooks [get]
+func (h *NotebookHandler) GetNotebooks(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	notebooks, err := h.notebookService.GetUserNotebooks(userId)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, notebooks)
+}
+
+// GetNotebook godoc
+// @Summary Get notebook by ID
+// @Tags Notebooks
+// @Security JWTAuth
+// @Produce json
+// @Param id path string true "Notebook ID"
+// @Success 200 {object} models.Notebook
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /notebooks/{id} [get]
+func (h *NotebookHandler) GetNotebook(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	notebookId, err := pathUUID(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	notebook, err := h.notebookService.GetNotebook(userId, notebookId)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, notebook)
+}
+
+// GetNotebookNotes godoc
+// @Summary Get notebook notes
+// @Description Get notes placed directly in the notebook
+// @Tags Notebooks
+// @Security JWTAuth
+// @Produce json
+// @Param id path string true "Notebook ID"
+// @Success 200 {array} models.Note
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /notebooks/{id}/notes [get]
+func (h *NotebookHandler) GetNotebookNotes(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	notebookId, err := pathUUID(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	notes, err := h.notebookService.GetNotebookNotes(userId, notebookId)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, notes)
+}
+
+// RenameNotebook godoc
+// @Summary Rename notebook
+// @Tags Notebooks
+// @Security JWTAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Notebook ID"
+// @Param input body dto.RenameNotebookRequest true "New name"
+// @Success 200 {object} models.Notebook
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /notebooks/{id} [patch]
+func (h *NotebookHandler) RenameNotebook(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	notebookId, err := pathUUID(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var req dto.RenameNotebookRequest
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	notebook, err := h.notebookService.RenameNotebook(userId, notebookId, req.Name)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, notebook)
+}
+
+// MoveNotebook godoc
+// @Summary Move notebook
+// @Description Move notebook under another notebook or to the top level
+// @Tags Notebooks
+// @Security JWTAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Notebook ID"
+// @Param input body dto.MoveNotebookRequest true "New parent"
+// @Success 200 {object} models.Notebook
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /notebooks/{id}/move [post]
+func (h *NotebookHandler) MoveNotebook(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	notebookId, err := pathUUID(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var req dto.MoveNotebookRequest
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	notebook, err := h.notebookService.MoveNotebook(userId, notebookId, req.ParentId)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, notebook)
+}
+
+// DeleteNotebook godoc
+// @Summary Delete notebook
+// @Description Delete notebook. mode=cascade also deletes sub-notebooks and moves their notes to the trash, mode=reparent moves them to the parent notebook
+// @Tags Notebooks
+// @Security JWTAuth
+// @Param id path string true "Notebook ID"
+// @Param mode query string false "Delete mode" Enums(reparent, cascade) default(reparent)
+// @Success 204
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /notebooks/{id} [delete]
+func (h *NotebookHandler) DeleteNotebook(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	notebookId, err := pathUUID(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var cascade bool
+	switch r.URL.Query().Get("mode") {
+	case "", "reparent":
+	case "cascade":
+		cascade = true
+	default:
+		writeError(w, http.StatusBadRequest, "mode must be reparent or cascade")
+		return
+	}
+
+	err = h.notebookService.DeleteNotebook(userId, notebookId, cascade)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,96 @@
+package httpHandlers
+
+import (
+	"2/internal/app/service"
+	"2/internal/interface/http/dto"
+	stderrors "errors"
+	"log/slog"
+	"net/http"
+)
+
+type OIDCHandler struct {
+	oidcService *service.OIDCService
+}
+
+func NewOIDCHandler(service *service.OIDCService) *OIDCHandler {
+	return &OIDCHandler{
+		oidcService: service,
+	}
+}
+
+// GetProviders godoc
+// @Summary List identity providers
+// @Description OpenID Connect providers users can sign in with instead of a password
+// @Tags Auth
+// @Produce json
+// @Success 200 {array} dto.OIDCProviderResponse
+// @Router /user/oidc [get]
+func (h *OIDCHandler) GetProviders(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.oidcService.Providers())
+}
+
+// Login godoc
+// @Summary Sign in with an identity provider
+// @Description Redirects to the login page of the provider (authorization code flow with PKCE).
+// @Description The provider sends the user back to /user/oidc/{provider}/callback
+// @Tags Auth
+// @Param provider path string true "Provider name"
+// @Success 302
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 502 {object} errors.ErrorResponse
+// @Router /user/oidc/{provider}/login [get]
+func (h *OIDCHandler) Login(w http.ResponseWriter, r *http.Request) {
+	location, err := h.oidcService.LoginURL(r.PathValue("provider"))
+	if stderrors.Is(err, service.ErrUnknownProvider) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to start OIDC login", "provider", r.PathValue("provider"), "error", err)
+		writeError(w, http.StatusBadGateway, "identity provider is unavailable")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, location, http.StatusFound)
+}
+
+// Callback godoc
+// @Summary Finish sign in with an identity provider
+// @Description The provider redirects here after the login. The external account is linked to the user
+// @Description with the same email when the provider confirmed it, otherwise a new user is created.
+// @Description With two-factor authentication enabled the login is finished at /user/login/2fa
+// @Tags Auth
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param code query string false "Authorization code"
+// @Param state query string true "State of the login"
+// @Param error query string false "Error returned by the provider"
+// @Success 200 {object} dto.AuthResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /user/oidc/{provider}/callback [get]
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req := dto.OIDCCallbackRequest{
+		Code:             query.Get("code"),
+		State:            query.Get("state"),
+		Error:            query.Get("error"),
+		ErrorDescription: query.Get("error_description"),
+	}
+	if req.State == "" {
+		writeError(w, http.StatusBadRequest, "state is required")
+		return
+	}
+
+	resp, err := h.oidcService.Callback(r.PathValue("provider"), req, clientInfo(r))
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, resp)
+}
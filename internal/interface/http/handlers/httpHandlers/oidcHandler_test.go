@@ -0,0 +1,448 @@
+package httpHandlers_test
+
+import (
+	"2/internal/app/oidc"
+	"2/internal/app/password"
+	"2/internal/app/service"
+	"2/internal/app/signing"
+	"2/internal/domain/models"
+	"2/internal/infrastructure/storage/memory"
+	"2/internal/interface/http/dto"
+	"2/internal/interface/http/handlers/httpHandlers"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testClientID     = "notes"
+	testClientSecret = "notes-secret"
+)
+
+// fakeAccount is the account the fake provider logs in without asking anything.
+type fakeAccount struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type issuedCode struct {
+	challenge   string
+	redirectURI string
+	nonce       string
+	account     fakeAccount
+}
+
+// fakeIdP is an in-process OpenID Connect provider: discovery, JWKS, an authorize
+// endpoint that approves at once and a token endpoint that checks PKCE.
+type fakeIdP struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu      sync.Mutex
+	account fakeAccount
+	codes   map[string]issuedCode
+	// wrongNonce makes the provider put a different nonce into the ID token
+	wrongNonce bool
+}
+
+func startFakeIdP(t *testing.T) *fakeIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	idp := &fakeIdP{t: t, key: key, codes: make(map[string]issuedCode)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("GET /jwks", idp.jwks)
+	mux.HandleFunc("GET /authorize", idp.authorize)
+	mux.HandleFunc("POST /token", idp.token)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (p *fakeIdP) issuer() string {
+	return p.server.URL
+}
+
+func (p *fakeIdP) setAccount(account fakeAccount) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.account = account
+}
+
+func (p *fakeIdP) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"issuer":                                p.issuer(),
+		"authorization_endpoint":                p.issuer() + "/authorize",
+		"token_endpoint":                        p.issuer() + "/token",
+		"jwks_uri":                              p.issuer() + "/jwks",
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (p *fakeIdP) jwks(w http.ResponseWriter, r *http.Request) {
+	encode := base64.RawURLEncoding.EncodeToString
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "idp-key",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   encode(p.key.N.Bytes()),
+			"e":   encode(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (p *fakeIdP) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != testClientID || query.Get("response_type") != "code" ||
+		query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		http.Error(w, "bad authorization request", http.StatusBadRequest)
+		return
+	}
+
+	p.mu.Lock()
+	code := uuid.NewString()
+	p.codes[code] = issuedCode{
+		challenge:   query.Get("code_challenge"),
+		redirectURI: query.Get("redirect_uri"),
+		nonce:       query.Get("nonce"),
+		account:     p.account,
+	}
+	p.mu.Unlock()
+
+	back, _ := url.Parse(query.Get("redirect_uri"))
+	params := back.Query()
+	params.Set("code", code)
+	params.Set("state", query.Get("state"))
+	back.RawQuery = params.Encode()
+	http.Redirect(w, r, back.String(), http.StatusFound)
+}
+
+func (p *fakeIdP) token(w http.ResponseWriter, r *http.Request) {
+	fail := func(code string) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": code})
+	}
+
+	id, secret, ok := r.BasicAuth()
+	if !ok || id != testClientID || secret != testClientSecret {
+		fail("invalid_client")
+		return
+	}
+	if r.PostFormValue("grant_type") != "authorization_code" {
+		fail("unsupported_grant_type")
+		return
+	}
+
+	p.mu.Lock()
+	issued, ok := p.codes[r.PostFormValue("code")]
+	delete(p.codes, r.PostFormValue("code"))
+	wrongNonce := p.wrongNonce
+	p.mu.Unlock()
+
+	if !ok || issued.redirectURI != r.PostFormValue("redirect_uri") ||
+		oidc.Challenge(r.PostFormValue("code_verifier")) != issued.challenge {
+		fail("invalid_grant")
+		return
+	}
+
+	nonce := issued.nonce
+	if wrongNonce {
+		nonce = "something-else"
+	}
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            p.issuer(),
+		"aud":            testClientID,
+		"sub":            issued.account.Subject,
+		"email":          issued.account.Email,
+		"email_verified": issued.account.EmailVerified,
+		"name":           issued.account.Name,
+		"nonce":          nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Minute).Unix(),
+	})
+	token.Header["kid"] = "idp-key"
+	idToken, err := token.SignedString(p.key)
+	if err != nil {
+		p.t.Errorf("sign id token: %v", err)
+		fail("server_error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "opaque",
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+type oidcEnv struct {
+	idp       *fakeIdP
+	api       *httptest.Server
+	passwords *service.Passwords
+	users     *memory.UserRepository
+	twoFactor *memory.TwoFactorRepository
+	tokens    *service.TokenSigner
+}
+
+func newOIDCEnv(t *testing.T) oidcEnv {
+	t.Helper()
+
+	idp := startFakeIdP(t)
+	mux := http.NewServeMux()
+	api := httptest.NewServer(mux)
+	t.Cleanup(api.Close)
+
+	identities := memory.NewIdentitiesRepository()
+	users := memory.NewUserRepository(identities)
+	twoFactor := memory.NewTwoFactorRepository()
+	keys, err := signing.NewKeyring(memory.NewSigningKeysRepository(), "test-secret", signing.Policy{
+		Algorithm: signing.EdDSA,
+		Rotation:  time.Hour,
+		Grace:     time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("keyring: %v", err)
+	}
+	tokens := service.NewTokenSigner(keys)
+
+	argon, err := password.NewArgon2id(password.Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+	if err != nil {
+		t.Fatalf("argon2id: %v", err)
+	}
+	policy, err := password.NewPolicy(8, 128, 0, "")
+	if err != nil {
+		t.Fatalf("password policy: %v", err)
+	}
+	passwords := service.NewPasswords(password.NewHasher(argon), policy)
+
+	guard := service.NewLoginGuard(memory.NewLoginGuardRepository(), models.LoginPolicy{
+		Account:         models.LoginLimit{FreeAttempts: 5, LockoutAfter: 10},
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		LockoutDuration: time.Minute,
+		ResetAfter:      time.Hour,
+	})
+	auth := service.NewAuthService(users, memory.NewSessionsRepository(), twoFactor, tokens, passwords, guard, models.TokenPolicy{
+		AccessTTL:  time.Minute,
+		RefreshTTL: time.Hour,
+	})
+	provider := oidc.NewProvider(oidc.Config{
+		Name:         "university",
+		Issuer:       idp.issuer(),
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  api.URL + "/user/oidc/university/callback",
+	}, nil)
+	handler := httpHandlers.NewOIDCHandler(service.NewOIDCService(identities, users, passwords, auth, provider))
+
+	mux.HandleFunc("GET /user/oidc", handler.GetProviders)
+	mux.HandleFunc("GET /user/oidc/{provider}/login", handler.Login)
+	mux.HandleFunc("GET /user/oidc/{provider}/callback", handler.Callback)
+
+	return oidcEnv{idp: idp, api: api, passwords: passwords, users: users, twoFactor: twoFactor, tokens: tokens}
+}
+
+// signIn walks the whole flow like a browser would: login, the provider, the callback.
+func (e oidcEnv) signIn(t *testing.T) (int, dto.AuthResponse) {
+	t.Helper()
+	resp, err := http.Get(e.api.URL + "/user/oidc/university/login")
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var auth dto.AuthResponse
+	if resp.StatusCode == http.StatusOK {
+		if err = json.NewDecoder(resp.Body).Decode(&auth); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+	}
+	return resp.StatusCode, auth
+}
+
+// startLogin follows the redirects only up to the callback and returns its address.
+func (e oidcEnv) startLogin(t *testing.T) string {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if req.URL.Host == e.idp.server.Listener.Addr().String() {
+			return nil
+		}
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(e.api.URL + "/user/oidc/university/login")
+	if err != nil {
+		t.Fatalf("start login: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("start login: status %d", resp.StatusCode)
+	}
+	return resp.Header.Get("Location")
+}
+
+func callback(t *testing.T, address string) int {
+	t.Helper()
+	resp, err := http.Get(address)
+	if err != nil {
+		t.Fatalf("callback: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func (e oidcEnv) userOf(t *testing.T, auth dto.AuthResponse) uuid.UUID {
+	t.Helper()
+	claims, err := e.tokens.Parse(auth.Token, "access")
+	if err != nil {
+		t.Fatalf("access token: %v", err)
+	}
+	userId, err := uuid.Parse(claims["user_id"].(string))
+	if err != nil {
+		t.Fatalf("user_id: %v", err)
+	}
+	return userId
+}
+
+func TestOIDCCreatesAndReusesUser(t *testing.T) {
+	env := newOIDCEnv(t)
+	env.idp.setAccount(fakeAccount{Subject: "s-1", Email: "kate@uni.test", EmailVerified: true, Name: "Kate"})
+
+	status, auth := env.signIn(t)
+	if status != http.StatusOK || auth.Token == "" || auth.RefreshToken == "" {
+		t.Fatalf("first sign in: status %d, %+v", status, auth)
+	}
+	userId := env.userOf(t, auth)
+
+	user, exists, err := env.users.GetUserByEmail("kate@uni.test")
+	if err != nil || !exists {
+		t.Fatalf("user was not created: %v", err)
+	}
+	if user.UserId != userId || user.Username != "Kate" || !user.EmailVerified {
+		t.Fatalf("unexpected user %+v", user)
+	}
+
+	// тот же sub находит того же пользователя, даже если адрес у провайдера сменился
+	env.idp.setAccount(fakeAccount{Subject: "s-1", Email: "kate.new@uni.test", EmailVerified: true})
+	status, auth = env.signIn(t)
+	if status != http.StatusOK {
+		t.Fatalf("second sign in: status %d", status)
+	}
+	if got := env.userOf(t, auth); got != userId {
+		t.Fatalf("second sign in as %s, want %s", got, userId)
+	}
+}
+
+func TestOIDCLinksByVerifiedEmail(t *testing.T) {
+	env := newOIDCEnv(t)
+
+	local := models.User{UserId: uuid.New(), Username: "ann", Email: "ann@uni.test", Created: time.Now()}
+	if err := env.passwords.Set(&local, "correct horse battery"); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.users.Create(local); err != nil {
+		t.Fatal(err)
+	}
+
+	// провайдер не подтвердил адрес, связывать нельзя
+	env.idp.setAccount(fakeAccount{Subject: "s-2", Email: "ann@uni.test", EmailVerified: false})
+	if status, _ := env.signIn(t); status != http.StatusForbidden {
+		t.Fatalf("unverified at the provider: status %d, want 403", status)
+	}
+
+	// адрес подтверждён провайдером, но не у нас: аккаунт мог зарегистрировать кто-то другой
+	env.idp.setAccount(fakeAccount{Subject: "s-2", Email: "ann@uni.test", EmailVerified: true})
+	if status, _ := env.signIn(t); status != http.StatusForbidden {
+		t.Fatalf("unverified local account: status %d, want 403", status)
+	}
+
+	if err := env.users.SetEmailVerified(local.UserId); err != nil {
+		t.Fatal(err)
+	}
+	status, auth := env.signIn(t)
+	if status != http.StatusOK {
+		t.Fatalf("sign in: status %d", status)
+	}
+	if got := env.userOf(t, auth); got != local.UserId {
+		t.Fatalf("signed in as %s, want the local user %s", got, local.UserId)
+	}
+}
+
+func TestOIDCRespectsTwoFactor(t *testing.T) {
+	env := newOIDCEnv(t)
+	env.idp.setAccount(fakeAccount{Subject: "s-3", Email: "bob@uni.test", EmailVerified: true})
+
+	_, auth := env.signIn(t)
+	userId := env.userOf(t, auth)
+	if err := env.twoFactor.SaveTOTP(models.TOTP{UserId: userId, Secret: "secret", Enabled: true, CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	status, auth := env.signIn(t)
+	if status != http.StatusOK || !auth.MFARequired || auth.MFAToken == "" || auth.Token != "" {
+		t.Fatalf("sign in with 2FA: status %d, %+v", status, auth)
+	}
+}
+
+func TestOIDCRejectsReplayAndTampering(t *testing.T) {
+	env := newOIDCEnv(t)
+	env.idp.setAccount(fakeAccount{Subject: "s-4", Email: "eve@uni.test", EmailVerified: true})
+
+	address := env.startLogin(t)
+	if status := callback(t, address); status != http.StatusOK {
+		t.Fatalf("callback: status %d", status)
+	}
+	if status := callback(t, address); status != http.StatusUnauthorized {
+		t.Fatalf("replayed callback: status %d, want 401", status)
+	}
+
+	// код одного входа со state другого: verifier не подходит к challenge
+	first, _ := url.Parse(env.startLogin(t))
+	second, _ := url.Parse(env.startLogin(t))
+	swapped := *second
+	query := second.Query()
+	query.Set("code", first.Query().Get("code"))
+	swapped.RawQuery = query.Encode()
+	if status := callback(t, swapped.String()); status != http.StatusUnauthorized {
+		t.Fatalf("swapped code: status %d, want 401", status)
+	}
+
+	env.idp.mu.Lock()
+	env.idp.wrongNonce = true
+	env.idp.mu.Unlock()
+	if status, _ := env.signIn(t); status != http.StatusUnauthorized {
+		t.Fatalf("wrong nonce: status %d, want 401", status)
+	}
+
+	resp, err := http.Get(env.api.URL + "/user/oidc/nowhere/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("unknown provider: status %d, want 404", resp.StatusCode)
+	}
+}
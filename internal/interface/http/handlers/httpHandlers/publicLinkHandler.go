@@ -0,0 +1,283 @@
+package httpHandlers
+
+import (
+	"2/internal/app/service"
+	"2/internal/interface/http/dto"
+	_ "embed"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+//go:embed public-note.html
+var publicNotePage string
+
+var publicNoteTemplate = template.Must(template.New("public-note").Parse(publicNotePage))
+
+// пароль ссылки короткий, больше формы никто честно не пришлёт
+const maxUnlockBody = 64 << 10
+
+type PublicLinkHandler struct {
+	linkService *service.PublicLinkService
+}
+
+func NewPublicLinkHandler(service *service.PublicLinkService) *PublicLinkHandler {
+	return &PublicLinkHandler{
+		linkService: service,
+	}
+}
+
+// CreateLink godoc
+// @Summary Create public link
+// @Description Create an unguessable link that opens the note to anyone, without an account. The link may be protected
+// @Description with a password and may expire. Only the owner creates links
+// @Tags Public links
+// @Security JWTAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Note ID"
+// @Param input body dto.CreatePublicLinkRequest false "Password and expiry, both optional"
+// @Success 201 {object} dto.PublicLinkResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /notes/{id}/links [post]
+func (h *PublicLinkHandler) CreateLink(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	noteId, err := pathUUID(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// тело необязательно: пустой запрос создаёт ссылку без пароля и срока
+	var req dto.CreatePublicLinkRequest
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	link, err := h.linkService.CreateLink(userId, noteId, req)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, link)
+}
+
+// GetLinks godoc
+// @Summary Get public links
+// @Description List public links of the note with how many times each was opened, only the owner can see them
+// @Tags Public links
+// @Security JWTAuth
+// @Produce json
+// @Param id path string true "Note ID"
+// @Success 200 {array} dto.PublicLinkResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /notes/{id}/links [get]
+func (h *PublicLinkHandler) GetLinks(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	noteId, err := pathUUID(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	links, err := h.linkService.GetLinks(userId, noteId)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, links)
+}
+
+// RevokeLink godoc
+// @Summary Revoke public link
+// @Description Delete a public link of the note, it stops working immediately
+// @Tags Public links
+// @Security JWTAuth
+// @Param id path string true "Note ID"
+// @Param linkId path string true "Link ID"
+// @Success 204
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /notes/{id}/links/{linkId} [delete]
+func (h *PublicLinkHandler) RevokeLink(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	noteId, err := pathUUID(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	linkId, err := pathUUID(r, "linkId")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err = h.linkService.RevokeLink(userId, noteId, linkId); err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ViewNote godoc
+// @Summary Open public link
+// @Description Read the note behind a public link, no authentication needed. Browsers get an HTML page, other clients JSON;
+// @Description format overrides the Accept header. A password-protected link answers 401 here, send the password with POST
+// @Tags Public links
+// @Produce json
+// @Produce html
+// @Param slug path string true "Link slug"
+// @Param format query string false "Response format" Enums(json, html)
+// @Success 200 {object} dto.PublicNoteResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /p/{slug} [get]
+func (h *PublicLinkHandler) ViewNote(w http.ResponseWriter, r *http.Request) {
+	h.view(w, r, "")
+}
+
+// UnlockNote godoc
+// @Summary Open password-protected public link
+// @Description Read the note behind a public link protected with a password. The password comes as JSON or as the password
+// @Description field of a form, the HTML page posts the form here. After repeated wrong passwords the link and the
+// @Description address have to wait, 429 with Retry-After until then
+// @Tags Public links
+// @Accept json
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Produce html
+// @Param slug path string true "Link slug"
+// @Param format query string false "Response format" Enums(json, html)
+// @Param input body dto.UnlockPublicLinkRequest true "Link password"
+// @Success 200 {object} dto.PublicNoteResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 429 {object} errors.ErrorResponse
+// @Router /p/{slug} [post]
+func (h *PublicLinkHandler) UnlockNote(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUnlockBody)
+
+	var req dto.UnlockPublicLinkRequest
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	} else {
+		if err := r.ParseForm(); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid form")
+			return
+		}
+		req.Password = r.PostForm.Get("password")
+	}
+
+	h.view(w, r, req.Password)
+}
+
+func (h *PublicLinkHandler) view(w http.ResponseWriter, r *http.Request, password string) {
+	// ссылку не кэшируют и не индексируют, а slug не уходит дальше в Referer
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+
+	slug := r.PathValue("slug")
+	note, err := h.linkService.View(slug, password, clientInfo(r))
+	setRetryAfter(w, err)
+
+	if !wantsHTML(r) {
+		if err != nil {
+			writeError(w, errorStatus(err), err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, note)
+		return
+	}
+
+	page := publicNoteView{Slug: slug}
+	status := http.StatusOK
+	switch {
+	case err == nil:
+		page.Note = &note
+	case stderrors.Is(err, service.ErrLinkPasswordRequired):
+		status = http.StatusUnauthorized
+		page.NeedPassword = true
+	case stderrors.Is(err, service.ErrWrongLinkPassword):
+		status = http.StatusUnauthorized
+		page.NeedPassword = true
+		page.Error = "Wrong password, try again."
+	case stderrors.Is(err, service.ErrTooManyAttempts):
+		status = http.StatusTooManyRequests
+		page.NeedPassword = true
+		page.Error = "Too many wrong passwords, try again later."
+	default:
+		status = errorStatus(err)
+		page.Error = "This link does not exist, has expired or was revoked."
+		if status != http.StatusNotFound {
+			slog.Error("Failed to open public link", "error", err)
+			status = http.StatusInternalServerError
+			page.Error = "Something went wrong, try again later."
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; form-action 'self'; frame-ancestors 'none'; base-uri 'none'")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	if err = publicNoteTemplate.Execute(w, page); err != nil {
+		slog.Error("Failed to render public note", "error", err)
+	}
+}
+
+type publicNoteView struct {
+	Slug         string
+	Note         *dto.PublicNoteResponse
+	NeedPassword bool
+	Error        string
+}
+
+// wantsHTML picks the format of a public link: ?format=html|json first, otherwise
+// HTML for browsers, which ask for text/html, and JSON for everyone else.
+func wantsHTML(r *http.Request) bool {
+	switch r.URL.Query().Get("format") {
+	case "html":
+		return true
+	case "json":
+		return false
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
@@ -0,0 +1,110 @@
+package httpHandlers
+
+import (
+	"2/internal/app/service"
+	"2/internal/domain/models"
+	"2/internal/domain/repository"
+	"2/internal/errors"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"github.com/google/uuid"
+	"net"
+	"net/http"
+)
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errors.ErrorResponse{
+		Error: message,
+	})
+}
+
+// errorStatus maps a service error to the HTTP status it should be reported with.
+func errorStatus(err error) int {
+	switch {
+	case stderrors.Is(err, repository.ErrNotFound),
+		stderrors.Is(err, service.ErrUnknownProvider),
+		stderrors.Is(err, service.ErrShareUserNotFound),
+		stderrors.Is(err, service.ErrPublicLinkNotFound):
+		return http.StatusNotFound
+	case stderrors.Is(err, service.ErrAccessDenied),
+		stderrors.Is(err, service.ErrEmailNotVerified),
+		stderrors.Is(err, service.ErrWrongPassword),
+		stderrors.Is(err, service.ErrOIDCEmailNotVerified),
+		stderrors.Is(err, service.ErrOIDCAccountUnverified),
+		stderrors.Is(err, service.ErrAccountDisabled):
+		return http.StatusForbidden
+	case stderrors.Is(err, service.ErrInvalidRefreshToken),
+		stderrors.Is(err, service.ErrRefreshTokenReused),
+		stderrors.Is(err, service.ErrSessionRevoked),
+		stderrors.Is(err, service.ErrInvalidMFAToken),
+		stderrors.Is(err, service.ErrInvalidCode),
+		stderrors.Is(err, service.ErrInvalidCredentials),
+		stderrors.Is(err, service.ErrInvalidOIDCState),
+		stderrors.Is(err, service.ErrOIDCLoginFailed),
+		stderrors.Is(err, service.ErrLinkPasswordRequired),
+		stderrors.Is(err, service.ErrWrongLinkPassword):
+		return http.StatusUnauthorized
+	case stderrors.Is(err, service.ErrTwoFactorEnabled),
+		stderrors.Is(err, service.ErrEmailTaken):
+		return http.StatusConflict
+	case stderrors.Is(err, service.ErrTooManyAttempts):
+		return http.StatusTooManyRequests
+	case stderrors.Is(err, repository.ErrVersionConflict):
+		return http.StatusPreconditionFailed
+	case stderrors.Is(err, service.ErrSyncTokenExpired):
+		return http.StatusGone
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// pathUUID parses a UUID path parameter such as {id}.
+func pathUUID(r *http.Request, name string) (uuid.UUID, error) {
+	value := r.PathValue(name)
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("%s %s is invalid", name, value)
+	}
+	return id, nil
+}
+
+// getSessionIDFromContext returns the session of the access token the request was made with.
+func getSessionIDFromContext(r *http.Request) (uuid.UUID, error) {
+	sessionId, ok := r.Context().Value("sessionId").(uuid.UUID)
+	if !ok {
+		if usesAPIToken(r) {
+			return uuid.UUID{}, errAPITokenNotAllowed
+		}
+		return uuid.UUID{}, fmt.Errorf("session ID not found in context")
+	}
+	return sessionId, nil
+}
+
+var errAPITokenNotAllowed = stderrors.New("personal access tokens cannot be used here, log in instead")
+
+// usesAPIToken tells whether the request was authenticated with a personal access token,
+// such requests reach only routes that declare a scope.
+func usesAPIToken(r *http.Request) bool {
+	_, ok := r.Context().Value("scopes").([]string)
+	return ok
+}
+
+// clientInfo describes the device of the request, the address is taken from the connection
+// itself because forwarded headers can be set by anyone.
+func clientInfo(r *http.Request) models.ClientInfo {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return models.ClientInfo{
+		UserAgent: r.UserAgent(),
+		IP:        ip,
+	}
+}
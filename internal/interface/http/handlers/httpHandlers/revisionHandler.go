@@ -0,0 +1,203 @@
+package httpHandlers
+
+import (
+	"2/internal/app/service"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+type RevisionHandler struct {
+	revisionService *service.RevisionService
+}
+
+func NewRevisionHandler(service *service.RevisionService) *RevisionHandler {
+	return &RevisionHandler{
+		revisionService: service,
+	}
+}
+
+func parseRevision(value string) (int, error) {
+	revision, err := strconv.Atoi(value)
+	if err != nil || revision <= 0 {
+		return 0, fmt.Errorf("revision %s is invalid", value)
+	}
+	return revision, nil
+}
+
+// GetRevisions godoc
+// @Summary Get note revisions
+// @Description Get revisions of a note, the newest first
+// @Tags Revisions
+// @Security JWTAuth
+// @Produce json
+// @Param id path string true "Note ID"
+// @Success 200 {array} models.NoteRevision
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /notes/{id}/revisions [get]
+func (h *RevisionHandler) GetRevisions(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	noteId, err := pathUUID(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	revisions, err := h.revisionService.GetRevisions(userId, noteId)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, revisions)
+}
+
+// GetRevision godoc
+// @Summary Get note revision
+// @Tags Revisions
+// @Security JWTAuth
+// @Produce json
+// @Param id path string true "Note ID"
+// @Param rev path int true "Revision number"
+// @Success 200 {object} models.NoteRevision
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /notes/{id}/revisions/{rev} [get]
+func (h *RevisionHandler) GetRevision(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	noteId, err := pathUUID(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	revision, err := parseRevision(r.PathValue("rev"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rev, err := h.revisionService.GetRevision(userId, noteId, revision)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rev)
+}
+
+// DiffRevisions godoc
+// @Summary Diff note revisions
+// @Description Compare content of two revisions as a unified line diff or as word-level changes
+// @Tags Revisions
+// @Security JWTAuth
+// @Produce json
+// @Param id path string true "Note ID"
+// @Param from query int true "Old revision number"
+// @Param to query int true "New revision number"
+// @Param mode query string false "Diff mode" Enums(unified, words) default(unified)
+// @Success 200 {object} dto.RevisionDiffResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /notes/{id}/revisions/diff [get]
+func (h *RevisionHandler) DiffRevisions(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	noteId, err := pathUUID(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	from, err := parseRevision(r.URL.Query().Get("from"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	to, err := parseRevision(r.URL.Query().Get("to"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var words bool
+	switch r.URL.Query().Get("mode") {
+	case "", "unified":
+	case "words":
+		words = true
+	default:
+		writeError(w, http.StatusBadRequest, "mode must be unified or words")
+		return
+	}
+
+	result, err := h.revisionService.DiffRevisions(userId, noteId, from, to, words)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// RestoreRevision godoc
+// @Summary Restore note revision
+// @Description Make title and content of the revision current, the restore itself becomes a new revision
+// @Tags Revisions
+// @Security JWTAuth
+// @Produce json
+// @Param id path string true "Note ID"
+// @Param rev path int true "Revision number"
+// @Success 200 {object} models.Note
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /notes/{id}/revisions/{rev}/restore [post]
+func (h *RevisionHandler) RestoreRevision(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	noteId, err := pathUUID(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	revision, err := parseRevision(r.PathValue("rev"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	note, err := h.revisionService.RestoreRevision(userId, noteId, revision)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, note)
+}
@@ -0,0 +1,56 @@
+package httpHandlers
+
+import (
+	"2/internal/app/service"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+type SearchHandler struct {
+	searchService *service.SearchService
+}
+
+func NewSearchHandler(service *service.SearchService) *SearchHandler {
+	return &SearchHandler{
+		searchService: service,
+	}
+}
+
+// SearchNotes godoc
+// @Summary Search notes
+// @Description Full-text search over titles and contents of user's notes, the most relevant first. title_highlight and snippet
+// @Description are HTML: the note text is escaped and matches are wrapped into <mark></mark>
+// @Tags Notes
+// @Security JWTAuth
+// @Produce json
+// @Param q query string true "Search query"
+// @Param limit query int false "Maximum number of results" default(20) maximum(100)
+// @Success 200 {array} models.SearchResult
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Router /notes/search [get]
+func (h *SearchHandler) SearchNotes(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	var limit int
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+	}
+
+	results, err := h.searchService.SearchNotes(userId, r.URL.Query().Get("q"), limit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, results)
+}
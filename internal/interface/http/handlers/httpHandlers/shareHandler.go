@@ -0,0 +1,162 @@
+package httpHandlers
+
+import (
+	"2/internal/app/service"
+	"2/internal/interface/http/dto"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type ShareHandler struct {
+	shareService *service.ShareService
+}
+
+func NewShareHandler(service *service.ShareService) *ShareHandler {
+	return &ShareHandler{
+		shareService: service,
+	}
+}
+
+// ShareNote godoc
+// @Summary Share note
+// @Description Share a note with another user by email. A viewer reads the note and its revisions, an editor also changes it.
+// @Description Only the owner deletes, moves and shares the note. Sharing with the same user again changes the permission
+// @Tags Sharing
+// @Security JWTAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Note ID"
+// @Param input body dto.ShareNoteRequest true "User email and permission"
+// @Success 200 {object} dto.NoteShareResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /notes/{id}/shares [post]
+func (h *ShareHandler) ShareNote(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	noteId, err := pathUUID(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var req dto.ShareNoteRequest
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	share, err := h.shareService.ShareNote(userId, noteId, req)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, share)
+}
+
+// GetShares godoc
+// @Summary Get note shares
+// @Description List users the note is shared with, only the owner can see them
+// @Tags Sharing
+// @Security JWTAuth
+// @Produce json
+// @Param id path string true "Note ID"
+// @Success 200 {array} dto.NoteShareResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /notes/{id}/shares [get]
+func (h *ShareHandler) GetShares(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	noteId, err := pathUUID(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	shares, err := h.shareService.GetShares(userId, noteId)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, shares)
+}
+
+// RevokeShare godoc
+// @Summary Revoke note share
+// @Description Take access to the note away from a user. The owner revokes anyone, others can only remove their own access
+// @Tags Sharing
+// @Security JWTAuth
+// @Param id path string true "Note ID"
+// @Param userId path string true "ID of the user the note is shared with"
+// @Success 204
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /notes/{id}/shares/{userId} [delete]
+func (h *ShareHandler) RevokeShare(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	noteId, err := pathUUID(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	sharedWith, err := pathUUID(r, "userId")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err = h.shareService.RevokeShare(userId, noteId, sharedWith); err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetSharedWithMe godoc
+// @Summary Get notes shared with me
+// @Description List notes other users shared with the current user, recently updated first
+// @Tags Sharing
+// @Security JWTAuth
+// @Produce json
+// @Success 200 {array} dto.SharedNoteResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Router /notes/shared-with-me [get]
+func (h *ShareHandler) GetSharedWithMe(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	notes, err := h.shareService.GetSharedWithMe(userId)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, notes)
+}
@@ -0,0 +1,101 @@
+package httpHandlers
+
+import (
+	"2/internal/app/service"
+	"2/internal/interface/http/dto"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+type SyncHandler struct {
+	syncService *service.SyncService
+}
+
+func NewSyncHandler(service *service.SyncService) *SyncHandler {
+	return &SyncHandler{
+		syncService: service,
+	}
+}
+
+// Pull godoc
+// @Summary Get changes since the last sync
+// @Description Notes of the current user created, changed, moved to the trash or deleted since the sync token, oldest change first.
+// @Description A note appears once with its latest state, a note in the trash or deleted for good comes as a tombstone with
+// @Description deleted set. Without since the feed starts from the beginning, so a new client gets every note. Keep next_token
+// @Description and pass it as since next time, while has_more is set request again at once. Tombstones of deleted notes are kept
+// @Description for SYNC_TOMBSTONE_RETENTION, an older token gets 410 and the client has to sync again without since
+// @Tags Sync
+// @Security JWTAuth
+// @Produce json
+// @Param since query string false "next_token of the previous response"
+// @Param limit query int false "Maximum number of changes" default(500) maximum(1000)
+// @Success 200 {object} dto.SyncPullResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 410 {object} errors.ErrorResponse
+// @Router /sync [get]
+func (h *SyncHandler) Pull(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	var limit int
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+	}
+
+	resp, err := h.syncService.Pull(userId, r.URL.Query().Get("since"), limit)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// Push godoc
+// @Summary Send offline changes
+// @Description Applies changes made on the client while it was offline, in order, each on its own. base_version 0 creates the
+// @Description note with the id chosen by the client, otherwise base_version is the version the change was made on. Every change
+// @Description gets a result: applied, conflict when the note has changed on the server since base_version (note is the server
+// @Description copy, the server does not merge texts: keep it, or merge on the client and send again with its version), deleted
+// @Description when the note is gone from the server, rejected with the error otherwise. A change that leaves the note as the
+// @Description server has it is applied, so a batch can be resent safely after a lost response
+// @Tags Sync
+// @Security JWTAuth
+// @Accept json
+// @Produce json
+// @Param changes body dto.SyncPushRequest true "Offline changes, at most 500"
+// @Success 200 {object} dto.SyncPushResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Router /sync [post]
+func (h *SyncHandler) Push(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	var req dto.SyncPushRequest
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	resp, err := h.syncService.Push(userId, req)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,116 @@
+package httpHandlers
+
+import (
+	"2/internal/app/service"
+	"2/internal/interface/http/dto"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type TagHandler struct {
+	tagService *service.TagService
+}
+
+func NewTagHandler(service *service.TagService) *TagHandler {
+	return &TagHandler{
+		tagService: service,
+	}
+}
+
+// GetTags godoc
+// @Summary Get tags
+// @Description Get user's tags with the number of notes carrying each of them
+// @Tags Tags
+// @Security JWTAuth
+// @Produce json
+// @Success 200 {array} models.TagCount
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /tags [get]
+func (h *TagHandler) GetTags(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	tags, err := h.tagService.GetUserTags(userId)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tags)
+}
+
+// RenameTag godoc
+// @Summary Rename tag
+// @Description Rename tag on all user's notes
+// @Tags Tags
+// @Security JWTAuth
+// @Accept json
+// @Produce json
+// @Param name path string true "Tag name"
+// @Param input body dto.RenameTagRequest true "New tag name"
+// @Success 200 {object} dto.StandartResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /tags/{name} [patch]
+func (h *TagHandler) RenameTag(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	var req dto.RenameTagRequest
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = h.tagService.RenameTag(userId, r.PathValue("name"), req.Name)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, dto.StandartResponse{Message: "succsess"})
+}
+
+// MergeTags godoc
+// @Summary Merge tags
+// @Description Move notes of the source tags to the target tag and remove the sources
+// @Tags Tags
+// @Security JWTAuth
+// @Accept json
+// @Produce json
+// @Param input body dto.MergeTagsRequest true "Tags to merge"
+// @Success 200 {object} dto.StandartResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /tags/merge [post]
+func (h *TagHandler) MergeTags(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	var req dto.MergeTagsRequest
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = h.tagService.MergeTags(userId, req.Sources, req.Target)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, dto.StandartResponse{Message: "succsess"})
+}
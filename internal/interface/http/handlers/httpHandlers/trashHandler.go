@@ -0,0 +1,101 @@
+package httpHandlers
+
+import (
+	"2/internal/app/service"
+	"2/internal/interface/http/dto"
+	"fmt"
+	"net/http"
+)
+
+type TrashHandler struct {
+	trashService *service.TrashService
+}
+
+func NewTrashHandler(service *service.TrashService) *TrashHandler {
+	return &TrashHandler{
+		trashService: service,
+	}
+}
+
+// GetTrash godoc
+// @Summary Get trash
+// @Description Get deleted notes of the current user, the most recently deleted first
+// @Tags Trash
+// @Security JWTAuth
+// @Produce json
+// @Success 200 {array} models.Note
+// @Failure 401 {object} errors.ErrorResponse
+// @Router /trash [get]
+func (h *TrashHandler) GetTrash(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	notes, err := h.trashService.GetTrash(userId)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, notes)
+}
+
+// RestoreNote godoc
+// @Summary Restore note from trash
+// @Tags Trash
+// @Security JWTAuth
+// @Produce json
+// @Param id path string true "Note ID"
+// @Success 200 {object} models.Note
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /trash/{id}/restore [post]
+func (h *TrashHandler) RestoreNote(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	noteId, err := pathUUID(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	note, err := h.trashService.RestoreNote(userId, noteId)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, note)
+}
+
+// EmptyTrash godoc
+// @Summary Empty trash
+// @Description Permanently delete every note in the trash
+// @Tags Trash
+// @Security JWTAuth
+// @Produce json
+// @Success 200 {object} dto.EmptyTrashResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Router /trash [delete]
+func (h *TrashHandler) EmptyTrash(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	deleted, err := h.trashService.EmptyTrash(userId)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, dto.EmptyTrashResponse{Deleted: deleted})
+}
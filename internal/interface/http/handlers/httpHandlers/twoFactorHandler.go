@@ -0,0 +1,111 @@
+package httpHandlers
+
+import (
+	"2/internal/app/service"
+	"2/internal/interface/http/dto"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type TwoFactorHandler struct {
+	twoFactorService *service.TwoFactorService
+}
+
+func NewTwoFactorHandler(service *service.TwoFactorService) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		twoFactorService: service,
+	}
+}
+
+// Enroll godoc
+// @Summary Start two-factor enrollment
+// @Description Create a TOTP secret for an authenticator app. It protects logins only after confirmation
+// @Tags Two-factor
+// @Security JWTAuth
+// @Produce json
+// @Success 200 {object} dto.TwoFactorEnrollResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse
+// @Router /user/2fa/enroll [post]
+func (h *TwoFactorHandler) Enroll(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	resp, err := h.twoFactorService.Enroll(userId)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// Confirm godoc
+// @Summary Confirm two-factor enrollment
+// @Description Enable two-factor authentication with the first code from the app. Returns recovery codes, they are shown only once
+// @Tags Two-factor
+// @Security JWTAuth
+// @Accept json
+// @Produce json
+// @Param input body dto.TwoFactorCodeRequest true "Authenticator code"
+// @Success 200 {object} dto.RecoveryCodesResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse
+// @Router /user/2fa/confirm [post]
+func (h *TwoFactorHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	var req dto.TwoFactorCodeRequest
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		writeError(w, http.StatusBadRequest, "code is required")
+		return
+	}
+
+	resp, err := h.twoFactorService.Confirm(userId, req.Code)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// Disable godoc
+// @Summary Disable two-factor authentication
+// @Tags Two-factor
+// @Security JWTAuth
+// @Accept json
+// @Param input body dto.DisableTwoFactorRequest true "Password and code"
+// @Success 204
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Router /user/2fa/disable [post]
+func (h *TwoFactorHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	var req dto.DisableTwoFactorRequest
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err = h.twoFactorService.Disable(userId, req); err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
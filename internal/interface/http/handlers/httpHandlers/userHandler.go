@@ -0,0 +1,154 @@
+package httpHandlers
+
+import (
+	"2/internal/app/service"
+	"2/internal/interface/http/dto"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type UserHandler struct {
+	userService *service.UserService
+}
+
+func NewUserHandler(service *service.UserService) *UserHandler {
+	return &UserHandler{
+		userService: service,
+	}
+}
+
+// GetMe godoc
+// @Summary Get current user
+// @Description Get the profile of the current user
+// @Tags User
+// @Security JWTAuth
+// @Produce json
+// @Success 200 {object} dto.UserResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Router /user/me [get]
+func (h *UserHandler) GetMe(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	user, err := h.userService.GetMe(userId)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+// UpdateMe godoc
+// @Summary Update current user
+// @Description Change username and email. A new email has to be verified again, a link is sent to it
+// @Tags User
+// @Security JWTAuth
+// @Accept json
+// @Produce json
+// @Param input body dto.UpdateUserRequest true "Profile fields to change"
+// @Success 200 {object} dto.UserResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse
+// @Router /user/me [patch]
+func (h *UserHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	var req dto.UpdateUserRequest
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.userService.UpdateMe(userId, req)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+// ChangePassword godoc
+// @Summary Change password
+// @Description Set a new password with the current one. Other sessions of the user are revoked. Wrong current passwords are throttled like failed logins, 429 comes with Retry-After. Accounts created by an identity provider have no known password, set one with POST /user/password/forgot
+// @Tags User
+// @Security JWTAuth
+// @Accept json
+// @Param input body dto.ChangePasswordRequest true "Current and new password"
+// @Success 204
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse
+// @Failure 429 {object} errors.ErrorResponse
+// @Router /user/password [post]
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+	sessionId, err := getSessionIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	var req dto.ChangePasswordRequest
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil || req.CurrentPassword == "" || req.NewPassword == "" {
+		writeError(w, http.StatusBadRequest, "current_password and new_password are required")
+		return
+	}
+
+	if err = h.userService.ChangePassword(userId, sessionId, req, clientInfo(r)); err != nil {
+		setRetryAfter(w, err)
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteMe godoc
+// @Summary Delete account
+// @Description Delete the current user with all notes, notebooks and sessions. Requires the password, wrong ones are throttled like failed logins. Accounts created by an identity provider have no known password, set one with POST /user/password/forgot first
+// @Tags User
+// @Security JWTAuth
+// @Accept json
+// @Param input body dto.DeleteAccountRequest true "Password"
+// @Success 204
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 403 {object} errors.ErrorResponse
+// @Failure 429 {object} errors.ErrorResponse
+// @Router /user/me [delete]
+func (h *UserHandler) DeleteMe(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, fmt.Sprintf("Auth error: %v", err))
+		return
+	}
+
+	var req dto.DeleteAccountRequest
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil || req.Password == "" {
+		writeError(w, http.StatusBadRequest, "password is required")
+		return
+	}
+
+	if err = h.userService.DeleteMe(userId, req, clientInfo(r)); err != nil {
+		setRetryAfter(w, err)
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"2/internal/domain/models"
 	"context"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
@@ -10,29 +11,56 @@ import (
 	"time"
 )
 
+// SessionChecker tells whether the session an access token was issued for is still active.
+type SessionChecker interface {
+	CheckSession(userId uuid.UUID, sessionId uuid.UUID) error
+}
+
+// APITokenChecker resolves a personal access token to its owner and scopes.
+type APITokenChecker interface {
+	CheckAPIToken(token string) (uuid.UUID, []string, error)
+}
+
+// AccountChecker turns away users whose account is disabled, whatever token they bring.
+type AccountChecker interface {
+	CheckAccount(userId uuid.UUID) error
+}
+
+// PublicRoutes tells which requests go to routes declared public, they need no token.
+type PublicRoutes interface {
+	IsPublic(r *http.Request) bool
+}
+
+// KeySet finds the key a JWT was signed with, by its kid header.
+type KeySet interface {
+	Keyfunc(token *jwt.Token) (interface{}, error)
+	Methods() []string
+}
+
 type AuthMiddleware struct {
-	secret string
+	keys      KeySet
+	sessions  SessionChecker
+	apiTokens APITokenChecker
+	accounts  AccountChecker
+	routes    PublicRoutes
 }
 
-func NewAuthMiddleware(secret string) *AuthMiddleware {
-	return &AuthMiddleware{secret: secret}
+func NewAuthMiddleware(keys KeySet, sessions SessionChecker, apiTokens APITokenChecker, accounts AccountChecker, routes PublicRoutes) *AuthMiddleware {
+	return &AuthMiddleware{keys: keys, sessions: sessions, apiTokens: apiTokens, accounts: accounts, routes: routes}
 }
 
 func (m *AuthMiddleware) AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-		fmt.Printf("Request path: %s\n", r.URL.Path)
-
-		if r.URL.Path == "/user/login" ||
-			r.URL.Path == "/user/register" ||
-			strings.HasPrefix(r.URL.Path, "/swagger/") {
-
+		if m.routes.IsPublic(r) {
 			next.ServeHTTP(w, r)
 			return
 		}
 
 		authHeader := r.Header.Get("Authorization")
-		fmt.Printf("Auth header: %s\n", authHeader)
+		if authHeader == "" {
+			authHeader = webSocketBearer(r)
+		}
 
 		if authHeader == "" {
 			w.WriteHeader(http.StatusUnauthorized)
@@ -47,17 +75,28 @@ func (m *AuthMiddleware) AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		// Отладочная информация о токене
 		tokenStr := authString[1]
-		fmt.Printf("Token: %s\n", tokenStr)
 
-		// Парсинг токена
-		token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		// персональный токен не кладёт userId в контекст сам, это делает RequireScope маршрута,
+		// поэтому маршруты без объявленного права такой токен не пускают
+		if strings.HasPrefix(tokenStr, models.APITokenPrefix) {
+			userID, scopes, err := m.apiTokens.CheckAPIToken(tokenStr)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			if err = m.accounts.CheckAccount(userID); err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
 			}
-			return []byte(m.secret), nil
-		})
+			ctx := context.WithValue(r.Context(), "apiTokenUserId", userID)
+			ctx = context.WithValue(ctx, "scopes", scopes)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		// Парсинг токена
+		token, err := jwt.Parse(tokenStr, m.keys.Keyfunc, jwt.WithValidMethods(m.keys.Methods()))
 
 		if err != nil {
 			fmt.Printf("Token parse error: %v\n", err)
@@ -85,10 +124,10 @@ func (m *AuthMiddleware) AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		// Отладочная информация о claims
-		fmt.Println("Token claims:")
-		for k, v := range claims {
-			fmt.Printf("  %s: (%T) %v\n", k, v, v)
+		// mfa_pending и прочие служебные токены доступа к API не дают
+		if typ, ok := claims["typ"]; ok && typ != "access" {
+			http.Error(w, fmt.Sprintf("Token of type %v is not an access token", typ), http.StatusUnauthorized)
+			return
 		}
 
 		// Проверка времени истечения
@@ -115,8 +154,6 @@ func (m *AuthMiddleware) AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		fmt.Printf("user_id from token: (%T) %v\n", userIDRaw, userIDRaw)
-
 		// Обработка user_id в зависимости от типа
 		var userID uuid.UUID
 		var userIDErr error
@@ -124,18 +161,14 @@ func (m *AuthMiddleware) AuthMiddleware(next http.Handler) http.Handler {
 		switch v := userIDRaw.(type) {
 		case string:
 			userID, userIDErr = uuid.Parse(v)
-			fmt.Printf("Parsing string user_id: %s\n", v)
 		case map[string]interface{}:
-			fmt.Printf("user_id is a map: %v\n", v)
 			if str, ok := v["String"].(string); ok {
 				userID, userIDErr = uuid.Parse(str)
-				fmt.Printf("Parsing string from map: %s\n", str)
 			} else {
 				userIDErr = fmt.Errorf("could not find String field in map")
 			}
 		default:
 			// Попытка преобразовать в строку
-			fmt.Printf("Converting unknown type to string: %v\n", v)
 			str := fmt.Sprintf("%v", v)
 			userID, userIDErr = uuid.Parse(str)
 		}
@@ -147,10 +180,51 @@ func (m *AuthMiddleware) AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		fmt.Printf("Successfully parsed user_id as UUID: %s\n", userID.String())
+		// токены без сессии выпущены до появления refresh-токенов и отозвать их нельзя
+		sidRaw, _ := claims["sid"].(string)
+		sessionID, err := uuid.Parse(sidRaw)
+		if err != nil {
+			http.Error(w, "Token has no session, log in again", http.StatusUnauthorized)
+			return
+		}
+
+		if err := m.sessions.CheckSession(userID, sessionID); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if err := m.accounts.CheckAccount(userID); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		// токены, выданные до появления ролей, считаются токенами обычного пользователя
+		role, _ := claims["role"].(string)
+		if role == "" {
+			role = models.RoleUser
+		}
 
 		// Сохраняем в контекст с ключом "userId" для согласованности с обработчиками
 		ctx := context.WithValue(r.Context(), "userId", userID)
+		ctx = context.WithValue(ctx, "sessionId", sessionID)
+		ctx = context.WithValue(ctx, "role", role)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// webSocketBearer reads the token of a WebSocket handshake. Browsers cannot set headers on
+// a WebSocket, so the token comes as the subprotocol list "bearer, <token>".
+func webSocketBearer(r *http.Request) string {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return ""
+	}
+	var protocols []string
+	for _, value := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(value, ",") {
+			protocols = append(protocols, strings.TrimSpace(protocol))
+		}
+	}
+	if len(protocols) != 2 || protocols[0] != "bearer" {
+		return ""
+	}
+	return "Bearer " + protocols[1]
+}
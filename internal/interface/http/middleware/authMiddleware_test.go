@@ -0,0 +1,191 @@
+package middleware_test
+
+import (
+	"2/internal/app/events"
+	"2/internal/app/password"
+	"2/internal/app/service"
+	"2/internal/app/signing"
+	"2/internal/domain/models"
+	"2/internal/infrastructure/storage/memory"
+	"2/internal/interface/http/dto"
+	"2/internal/interface/http/handlers/httpHandlers"
+	"2/internal/interface/http/middleware"
+	"encoding/json"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// authStack is the auth middleware in front of a few real routes, on memory repositories.
+type authStack struct {
+	handler   http.Handler
+	auth      *service.AuthService
+	apiTokens *service.APITokenService
+	users     *memory.UserRepository
+	userId    uuid.UUID
+}
+
+func newAuthStack(t *testing.T) *authStack {
+	t.Helper()
+
+	keys, err := signing.NewKeyring(memory.NewSigningKeysRepository(), "test-secret", signing.Policy{Algorithm: signing.EdDSA, Rotation: time.Hour, Grace: time.Hour})
+	if err != nil {
+		t.Fatalf("keyring: %v", err)
+	}
+	argon, err := password.NewArgon2id(password.Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+	if err != nil {
+		t.Fatalf("argon2id: %v", err)
+	}
+	policy, err := password.NewPolicy(8, 128, 0, "")
+	if err != nil {
+		t.Fatalf("password policy: %v", err)
+	}
+
+	notes := memory.NewNotesRepository()
+	users := memory.NewUserRepository(notes)
+	guard := service.NewLoginGuard(memory.NewLoginGuardRepository(), models.LoginPolicy{
+		Account:         models.LoginLimit{FreeAttempts: 5, LockoutAfter: 10},
+		IP:              models.LoginLimit{FreeAttempts: 20, LockoutAfter: 50},
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		LockoutDuration: time.Minute,
+		ResetAfter:      time.Hour,
+	})
+	stack := &authStack{
+		auth: service.NewAuthService(users, memory.NewSessionsRepository(), memory.NewTwoFactorRepository(), service.NewTokenSigner(keys),
+			service.NewPasswords(password.NewHasher(argon), policy), guard, models.TokenPolicy{AccessTTL: time.Minute, RefreshTTL: time.Hour}),
+		apiTokens: service.NewAPITokenService(memory.NewAPITokensRepository()),
+		users:     users,
+	}
+
+	noteHandler := httpHandlers.NewNoteHandler(service.NewNoteService(notes, memory.NewNotebooksRepository(notes), memory.NewSharesRepository(notes, users), events.NewBus(16)), false)
+	authHandler := httpHandlers.NewAuthHandler(stack.auth, nil)
+
+	routes := middleware.NewRoutes()
+	routes.HandleFunc("GET /notes", middleware.RequireScope(models.ScopeNotesRead, noteHandler.GetNotes))
+	routes.HandleFunc("POST /notes", middleware.RequireScope(models.ScopeNotesWrite, noteHandler.CreateNote))
+	routes.HandleFunc("GET /user/sessions", authHandler.GetSessions)
+	routes.HandleFunc("DELETE /user/sessions/{id}", authHandler.RevokeSession)
+	stack.handler = middleware.NewAuthMiddleware(keys, stack.auth, stack.apiTokens, stack.auth, routes).AuthMiddleware(routes)
+
+	if err = stack.auth.RegisterUser(dto.RegistrationRequest{Email: "ann@notes.test", Username: "ann", Password: "long password"}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	user, _, err := users.GetUserByEmail("ann@notes.test")
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	stack.userId = user.UserId
+	return stack
+}
+
+func (s *authStack) do(method string, path string, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(`{"title":"from a script","content":"text"}`))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.handler.ServeHTTP(w, r)
+	return w
+}
+
+// login returns the access token of a new session and the session id.
+func (s *authStack) login(t *testing.T) (string, uuid.UUID) {
+	t.Helper()
+
+	resp, err := s.auth.LoginUser(dto.LoginRequest{Email: "ann@notes.test", Password: "long password"}, models.ClientInfo{IP: "192.0.2.1"})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	w := s.do(http.MethodGet, "/user/sessions", resp.Token)
+	var sessions []dto.SessionResponse
+	if err = json.Unmarshal(w.Body.Bytes(), &sessions); err != nil {
+		t.Fatalf("decode sessions: %v: %s", err, w.Body)
+	}
+	for _, session := range sessions {
+		if session.Current {
+			return resp.Token, session.ID
+		}
+	}
+	t.Fatalf("no current session in %s", w.Body)
+	return "", uuid.Nil
+}
+
+func (s *authStack) apiToken(t *testing.T, expiresAt *time.Time, scopes ...string) dto.CreatedAPITokenResponse {
+	t.Helper()
+
+	token, err := s.apiTokens.CreateToken(s.userId, dto.CreateAPITokenRequest{Name: "script", Scopes: scopes, ExpiresAt: expiresAt})
+	if err != nil {
+		t.Fatalf("create api token: %v", err)
+	}
+	return token
+}
+
+func expectStatus(t *testing.T, what string, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+
+	if w.Code != status {
+		t.Errorf("%s: status %d, want %d: %s", what, w.Code, status, strings.TrimSpace(w.Body.String()))
+	}
+}
+
+func TestAPITokenScopes(t *testing.T) {
+	stack := newAuthStack(t)
+	readOnly := stack.apiToken(t, nil, models.ScopeNotesRead).Token
+	readWrite := stack.apiToken(t, nil, models.ScopeNotesRead, models.ScopeNotesWrite).Token
+
+	expectStatus(t, "read-only token reads notes", stack.do(http.MethodGet, "/notes", readOnly), http.StatusOK)
+	expectStatus(t, "read-only token creates a note", stack.do(http.MethodPost, "/notes", readOnly), http.StatusForbidden)
+	expectStatus(t, "read-write token creates a note", stack.do(http.MethodPost, "/notes", readWrite), http.StatusCreated)
+	// маршрут без объявленного права персональному токену закрыт
+	expectStatus(t, "token on a route without a scope", stack.do(http.MethodGet, "/user/sessions", readWrite), http.StatusUnauthorized)
+}
+
+func TestAPITokenRejected(t *testing.T) {
+	stack := newAuthStack(t)
+
+	soon := time.Now().Add(50 * time.Millisecond)
+	expiring := stack.apiToken(t, &soon, models.ScopeNotesRead).Token
+	expectStatus(t, "token before its expiry", stack.do(http.MethodGet, "/notes", expiring), http.StatusOK)
+	time.Sleep(time.Until(soon))
+	expectStatus(t, "expired token", stack.do(http.MethodGet, "/notes", expiring), http.StatusUnauthorized)
+
+	revoked := stack.apiToken(t, nil, models.ScopeNotesRead)
+	if err := stack.apiTokens.RevokeToken(stack.userId, revoked.ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	expectStatus(t, "revoked token", stack.do(http.MethodGet, "/notes", revoked.Token), http.StatusUnauthorized)
+	// токен, отличающийся от выданного одним символом
+	forged := revoked.Token[:len(revoked.Token)-1] + "x"
+	if forged == revoked.Token {
+		forged = revoked.Token[:len(revoked.Token)-1] + "y"
+	}
+	expectStatus(t, "unknown token", stack.do(http.MethodGet, "/notes", forged), http.StatusUnauthorized)
+
+	active := stack.apiToken(t, nil, models.ScopeNotesRead).Token
+	now := time.Now()
+	if err := stack.users.SetDisabled(stack.userId, &now); err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+	expectStatus(t, "token of a disabled account", stack.do(http.MethodGet, "/notes", active), http.StatusUnauthorized)
+}
+
+// An access token stops working as soon as its session is revoked, long before it expires.
+func TestRevokedSessionRejected(t *testing.T) {
+	stack := newAuthStack(t)
+	current, _ := stack.login(t)
+	lost, lostId := stack.login(t)
+
+	expectStatus(t, "revoke a device", stack.do(http.MethodDelete, "/user/sessions/"+lostId.String(), current), http.StatusNoContent)
+	expectStatus(t, "token of the revoked session", stack.do(http.MethodGet, "/notes", lost), http.StatusUnauthorized)
+	expectStatus(t, "revoke it again", stack.do(http.MethodDelete, "/user/sessions/"+lostId.String(), current), http.StatusNotFound)
+	expectStatus(t, "token of the current session", stack.do(http.MethodGet, "/notes", current), http.StatusOK)
+
+	if err := stack.auth.LogoutAll(stack.userId); err != nil {
+		t.Fatalf("logout all: %v", err)
+	}
+	expectStatus(t, "token after logout everywhere", stack.do(http.MethodGet, "/user/sessions", current), http.StatusUnauthorized)
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"2/internal/domain/models"
+	"fmt"
+	"net/http"
+)
+
+// RequireRole guards a group of routes: only users whose access token carries the role,
+// or a higher one, get through. Personal access tokens carry no role and never pass.
+func RequireRole(role string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current, ok := r.Context().Value("role").(string)
+		if !ok || models.RoleRank(current) < models.RoleRank(role) {
+			http.Error(w, fmt.Sprintf("This requires the %s role", role), http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
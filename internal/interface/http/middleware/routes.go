@@ -0,0 +1,35 @@
+package middleware
+
+import "net/http"
+
+// Routes is a ServeMux that remembers which routes are public. Every route registered
+// with Handle or HandleFunc needs a login, Public and PublicHandler open a route to anyone.
+type Routes struct {
+	*http.ServeMux
+	public map[string]bool
+}
+
+func NewRoutes() *Routes {
+	return &Routes{
+		ServeMux: http.NewServeMux(),
+		public:   make(map[string]bool),
+	}
+}
+
+// Public registers a handler that is served without authentication.
+func (rt *Routes) Public(pattern string, handler http.HandlerFunc) {
+	rt.PublicHandler(pattern, handler)
+}
+
+// PublicHandler is Public for an http.Handler.
+func (rt *Routes) PublicHandler(pattern string, handler http.Handler) {
+	rt.Handle(pattern, handler)
+	rt.public[pattern] = true
+}
+
+// IsPublic reports whether the request goes to a public route. Requests no route
+// matches are private, they get 401 before anyone learns the route does not exist.
+func (rt *Routes) IsPublic(r *http.Request) bool {
+	_, pattern := rt.Handler(r)
+	return rt.public[pattern]
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"net/http"
+	"slices"
+)
+
+// RequireScope declares the scope a personal access token needs for the route. Requests
+// with a login session pass unchanged, they are not limited by scopes.
+func RequireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scopes, ok := r.Context().Value("scopes").([]string)
+		if !ok {
+			next(w, r)
+			return
+		}
+
+		if !slices.Contains(scopes, scope) {
+			http.Error(w, fmt.Sprintf("Token does not have the %s scope", scope), http.StatusForbidden)
+			return
+		}
+
+		userID, _ := r.Context().Value("apiTokenUserId").(uuid.UUID)
+		ctx := context.WithValue(r.Context(), "userId", userID)
+		next(w, r.WithContext(ctx))
+	}
+}